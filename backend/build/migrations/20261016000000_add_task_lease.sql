@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `tasks`
+    ADD COLUMN `heartbeat_at` TIMESTAMP(6) NULL AFTER `picked_up_at`,
+    ADD COLUMN `lease_expires_at` TIMESTAMP(6) NULL AFTER `heartbeat_at`,
+    ADD INDEX `idx_status_lease_expires` (`status`, `lease_expires_at`);
+
+-- give tasks which are running during the migration a grace period before the reaper picks them up
+UPDATE `tasks`
+SET `lease_expires_at` = DATE_ADD(CURRENT_TIMESTAMP(6), INTERVAL 10 MINUTE)
+WHERE `status` = 'running';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `tasks`
+    DROP INDEX `idx_status_lease_expires`,
+    DROP COLUMN `lease_expires_at`,
+    DROP COLUMN `heartbeat_at`;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `tasks`
+    ADD COLUMN `parent_task_id` BIGINT NULL AFTER `id`,
+    ADD COLUMN `attempt` INT NOT NULL DEFAULT 1 AFTER `retried`,
+    ADD COLUMN `not_before` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) AFTER `started_at`,
+    ADD INDEX `idx_parent_task_id` (`parent_task_id`);
+
+UPDATE `tasks`
+SET `not_before` = `started_at`;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `tasks`
+    DROP INDEX `idx_parent_task_id`,
+    DROP COLUMN `not_before`,
+    DROP COLUMN `attempt`,
+    DROP COLUMN `parent_task_id`;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `tasks`
+    ADD COLUMN `priority` INT NOT NULL DEFAULT 0 AFTER `status`,
+    ADD INDEX `idx_status_priority_started_at` (`status`, `priority`, `started_at`);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `tasks`
+    DROP INDEX `idx_status_priority_started_at`,
+    DROP COLUMN `priority`;
+-- +goose StatementEnd
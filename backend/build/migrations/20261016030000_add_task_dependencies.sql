@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `task_dependencies` (
+    `task_id` BIGINT NOT NULL,
+    `depends_on_task_id` BIGINT NOT NULL,
+
+    PRIMARY KEY (`task_id`, `depends_on_task_id`),
+    INDEX `idx_depends_on_task_id` (`depends_on_task_id`),
+    CONSTRAINT `fk_task_dependencies_task` FOREIGN KEY (`task_id`) REFERENCES `tasks` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_task_dependencies_depends_on_task` FOREIGN KEY (`depends_on_task_id`) REFERENCES `tasks` (`id`) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `task_dependencies`;
+-- +goose StatementEnd
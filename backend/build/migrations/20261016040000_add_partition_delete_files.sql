@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE partitions
+    ADD COLUMN delete_file_count BIGINT NOT NULL DEFAULT 0 AFTER total_data_file_size_in_bytes,
+    ADD COLUMN total_delete_file_size_in_bytes BIGINT NOT NULL DEFAULT 0 AFTER delete_file_count,
+    ADD COLUMN needs_compaction BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE partitions
+    DROP COLUMN needs_compaction,
+    DROP COLUMN total_delete_file_size_in_bytes,
+    DROP COLUMN delete_file_count;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE `tables`
+    ADD COLUMN `sort_order` json NULL AFTER `partitions`;
+
+UPDATE `tables` SET `sort_order` = JSON_ARRAY();
+
+ALTER TABLE `tables`
+    MODIFY COLUMN `sort_order` json NOT NULL;
+
+-- +goose Down
+ALTER TABLE `tables`
+    DROP COLUMN `sort_order`;
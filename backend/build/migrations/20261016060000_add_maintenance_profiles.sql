@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `maintenance_profiles` (
+    `database` VARCHAR(255) NOT NULL,
+    `table` VARCHAR(255) NOT NULL DEFAULT '',
+    `profile` JSON NOT NULL,
+    `updated_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+
+    PRIMARY KEY (`database`, `table`)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `maintenance_profiles`;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE `tables`
+    ADD COLUMN `properties` json NULL AFTER `sort_order`;
+
+UPDATE `tables` SET `properties` = JSON_OBJECT();
+
+ALTER TABLE `tables`
+    MODIFY COLUMN `properties` json NOT NULL;
+
+-- +goose Down
+ALTER TABLE `tables`
+    DROP COLUMN `properties`;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `rollback_confirmations` (
+    `token` VARCHAR(64) NOT NULL,
+    `database` VARCHAR(255) NOT NULL,
+    `table` VARCHAR(255) NOT NULL,
+    `snapshot_id` BIGINT NOT NULL,
+    `current_snapshot_id` BIGINT NOT NULL,
+    `expires_at` TIMESTAMP(6) NOT NULL,
+    `created_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+    PRIMARY KEY (`token`),
+    KEY `idx_rollback_confirmations_expires_at` (`expires_at`)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `rollback_confirmations`;
+-- +goose StatementEnd
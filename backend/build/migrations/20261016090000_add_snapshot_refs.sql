@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE `snapshot_refs` (
+    `database` VARCHAR(255) NOT NULL,
+    `table` VARCHAR(255) NOT NULL,
+    `name` VARCHAR(255) NOT NULL,
+    `type` VARCHAR(16) NOT NULL,
+    `snapshot_id` BIGINT NOT NULL,
+    `min_snapshots_to_keep` INT NULL,
+    `max_snapshot_age_ms` BIGINT NULL,
+    `max_ref_age_ms` BIGINT NULL,
+
+    PRIMARY KEY (`database`, `table`, `name`)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE `snapshot_refs`;
+-- +goose StatementEnd
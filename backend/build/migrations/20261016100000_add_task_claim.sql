@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE `tasks`
+    ADD COLUMN `claim` INT NOT NULL DEFAULT 0 AFTER `attempt`;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE `tasks`
+    DROP COLUMN `claim`;
+-- +goose StatementEnd
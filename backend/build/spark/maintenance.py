@@ -10,6 +10,8 @@ from datetime import date, datetime, timezone
 PROCEDURE_REWRITE_DATA_FILES = "rewrite_data_files"
 PROCEDURE_EXPIRE_SNAPSHOTS = "expire_snapshots"
 PROCEDURE_REMOVE_ORPHAN_FILES = "remove_orphan_files"
+PROCEDURE_REWRITE_MANIFESTS = "rewrite_manifests"
+PROCEDURE_REWRITE_POSITION_DELETE_FILES = "rewrite_position_delete_files"
 
 
 def require_env(name: str) -> str:
@@ -32,6 +34,22 @@ def bool_string(name: str, default: str) -> str:
     return value
 
 
+def optional_positive_int(name: str) -> int | None:
+    value = os.getenv(name, "").strip()
+    if not value:
+        return None
+
+    try:
+        parsed = int(value)
+    except ValueError as err:
+        raise ValueError(f"{name} must be an integer, got: {value}") from err
+
+    if parsed < 0:
+        raise ValueError(f"{name} must not be negative, got: {value}")
+
+    return parsed or None
+
+
 def callback_enabled() -> bool:
     value = os.getenv("TASK_CALLBACK_ENABLED", "false").strip().lower()
     return value == "true"
@@ -90,6 +108,8 @@ def task_procedure() -> str:
         PROCEDURE_REWRITE_DATA_FILES,
         PROCEDURE_EXPIRE_SNAPSHOTS,
         PROCEDURE_REMOVE_ORPHAN_FILES,
+        PROCEDURE_REWRITE_MANIFESTS,
+        PROCEDURE_REWRITE_POSITION_DELETE_FILES,
     }:
         raise ValueError(f"unsupported TASK_PROCEDURE: {procedure}")
 
@@ -150,24 +170,46 @@ def older_than_timestamp() -> str:
     return parsed.strftime("%Y-%m-%d %H:%M:%S.%f")[:-3]
 
 
+def build_optimize_where_clause() -> str:
+    # partition scoped tasks pass a ready filter, an empty filter rewrites the whole table
+    scope = os.getenv("OPTIMIZE_SCOPE", "range").strip().lower() or "range"
+
+    if scope == "partition":
+        return os.getenv("ICEBERG_WHERE", "").strip()
+
+    if scope == "range":
+        return build_where_clause()
+
+    raise ValueError(f"unsupported OPTIMIZE_SCOPE: {scope}")
+
+
 def build_rewrite_data_files_query() -> str:
     catalog = os.getenv("ICEBERG_CATALOG", "lakehouse").strip() or "lakehouse"
     database = os.getenv("ICEBERG_DATABASE", "main").strip() or "main"
     table = require_env("ICEBERG_TABLE")
-    where = build_where_clause()
+    where = build_optimize_where_clause()
     target_file_size_bytes = os.getenv("TARGET_FILE_SIZE_BYTES", "536870912").strip()
     max_concurrent_file_group_rewrites = os.getenv("MAX_CONCURRENT_FILE_GROUP_REWRITES", "5").strip()
     min_input_files = os.getenv("MIN_INPUT_FILES", "2").strip()
     partial_progress_enabled = bool_string("PARTIAL_PROGRESS_ENABLED", "true")
     partial_progress_max_commits = os.getenv("PARTIAL_PROGRESS_MAX_COMMITS", "10").strip()
+    strategy = os.getenv("REWRITE_STRATEGY", "binpack").strip().lower() or "binpack"
+    sort_order = os.getenv("SORT_ORDER", "").strip()
+
+    if strategy not in ("binpack", "sort"):
+        raise ValueError(f"unsupported REWRITE_STRATEGY: {strategy}")
+
+    if strategy == "sort" and not sort_order:
+        raise ValueError("SORT_ORDER is required for REWRITE_STRATEGY sort")
 
     qualified_table = f"{database}.{table}"
+    where_argument = f"\n  where => {sql_literal(where)}," if where else ""
+    sort_order_argument = f"\n  sort_order => {sql_literal(sort_order)}," if strategy == "sort" else ""
 
     return f"""
 CALL {catalog}.system.rewrite_data_files(
-  table => {sql_literal(qualified_table)},
-  where => {sql_literal(where)},
-  strategy => 'binpack',
+  table => {sql_literal(qualified_table)},{where_argument}
+  strategy => {sql_literal(strategy)},{sort_order_argument}
   options => map(
     'target-file-size-bytes', {sql_literal(target_file_size_bytes)},
     'max-concurrent-file-group-rewrites', {sql_literal(max_concurrent_file_group_rewrites)},
@@ -183,16 +225,19 @@ def build_expire_snapshots_query() -> str:
     catalog = os.getenv("ICEBERG_CATALOG", "lakehouse").strip() or "lakehouse"
     database = os.getenv("ICEBERG_DATABASE", "main").strip() or "main"
     table = require_env("ICEBERG_TABLE")
-    require_env("RETENTION_DAYS")
+    retain_last = optional_positive_int("RETAIN_LAST")
     clean_expired_metadata = bool_string("CLEAN_EXPIRED_METADATA", "true")
 
     qualified_table = f"{database}.{table}"
     older_than = older_than_timestamp()
 
+    # without retain_last iceberg keeps history.expire.min-snapshots-to-keep snapshots
+    retain_last_argument = f"\n  retain_last => {retain_last}," if retain_last is not None else ""
+
     return f"""
 CALL {catalog}.system.expire_snapshots(
   table => {sql_literal(qualified_table)},
-  older_than => TIMESTAMP {sql_literal(older_than)},
+  older_than => TIMESTAMP {sql_literal(older_than)},{retain_last_argument}
   clean_expired_metadata => {clean_expired_metadata}
 )
 """.strip()
@@ -215,6 +260,50 @@ CALL {catalog}.system.remove_orphan_files(
 """.strip()
 
 
+def build_rewrite_manifests_query() -> str:
+    catalog = os.getenv("ICEBERG_CATALOG", "lakehouse").strip() or "lakehouse"
+    database = os.getenv("ICEBERG_DATABASE", "main").strip() or "main"
+    table = require_env("ICEBERG_TABLE")
+
+    qualified_table = f"{database}.{table}"
+
+    return f"""
+CALL {catalog}.system.rewrite_manifests(
+  table => {sql_literal(qualified_table)}
+)
+""".strip()
+
+
+def build_rewrite_position_delete_files_query() -> str:
+    catalog = os.getenv("ICEBERG_CATALOG", "lakehouse").strip() or "lakehouse"
+    database = os.getenv("ICEBERG_DATABASE", "main").strip() or "main"
+    table = require_env("ICEBERG_TABLE")
+    partial_progress_enabled = bool_string("PARTIAL_PROGRESS_ENABLED", "true")
+    partial_progress_max_commits = os.getenv("PARTIAL_PROGRESS_MAX_COMMITS", "10").strip()
+
+    qualified_table = f"{database}.{table}"
+
+    return f"""
+CALL {catalog}.system.rewrite_position_delete_files(
+  table => {sql_literal(qualified_table)},
+  options => map(
+    'partial-progress.enabled', {sql_literal(partial_progress_enabled)},
+    'partial-progress.max-commits', {sql_literal(partial_progress_max_commits)}
+  )
+)
+""".strip()
+
+
+def count_manifests(spark) -> int:
+    catalog = os.getenv("ICEBERG_CATALOG", "lakehouse").strip() or "lakehouse"
+    database = os.getenv("ICEBERG_DATABASE", "main").strip() or "main"
+    table = require_env("ICEBERG_TABLE")
+
+    query = f"SELECT count(*) AS manifest_count FROM {catalog}.{database}.{table}.manifests"
+
+    return int(spark.sql(query).collect()[0]["manifest_count"])
+
+
 def build_query(procedure: str) -> str:
     if procedure == PROCEDURE_REWRITE_DATA_FILES:
         return build_rewrite_data_files_query()
@@ -225,6 +314,12 @@ def build_query(procedure: str) -> str:
     if procedure == PROCEDURE_REMOVE_ORPHAN_FILES:
         return build_remove_orphan_files_query()
 
+    if procedure == PROCEDURE_REWRITE_MANIFESTS:
+        return build_rewrite_manifests_query()
+
+    if procedure == PROCEDURE_REWRITE_POSITION_DELETE_FILES:
+        return build_rewrite_position_delete_files_query()
+
     raise ValueError(f"unsupported TASK_PROCEDURE: {procedure}")
 
 
@@ -238,6 +333,12 @@ def app_name(procedure: str) -> str:
     if procedure == PROCEDURE_REMOVE_ORPHAN_FILES:
         return "remove-orphan-files"
 
+    if procedure == PROCEDURE_REWRITE_MANIFESTS:
+        return "rewrite-manifests"
+
+    if procedure == PROCEDURE_REWRITE_POSITION_DELETE_FILES:
+        return "rewrite-position-delete-files"
+
     raise ValueError(f"unsupported TASK_PROCEDURE: {procedure}")
 
 
@@ -254,11 +355,18 @@ def main() -> int:
         query = build_query(procedure)
         print(json.dumps({"query": query}, indent=2))
 
+        meta = {"procedure": procedure}
+        if procedure == PROCEDURE_REWRITE_MANIFESTS:
+            meta["manifest_count_before"] = count_manifests(spark)
+
         rows = [row.asDict(recursive=True) for row in spark.sql(query).collect()]
         print(json.dumps({"result": rows}, indent=2))
 
+        if procedure == PROCEDURE_REWRITE_MANIFESTS:
+            meta["manifest_count_after"] = count_manifests(spark)
+
         try:
-            post_procedure_result(query, rows, {"procedure": procedure})
+            post_procedure_result(query, rows, meta)
         except Exception as callback_err:
             report_callback_failure(callback_err)
 
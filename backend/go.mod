@@ -9,6 +9,7 @@ require (
 	github.com/apache/spark-connect-go v0.1.1-0.20250826122459-0e3d565b63e6
 	github.com/aws/aws-sdk-go-v2 v1.41.0
 	github.com/gin-contrib/cors v1.6.0
+	github.com/gin-gonic/gin v1.11.0
 	github.com/gosoline-project/httpserver v0.2.0
 	github.com/gosoline-project/sqlc v0.2.0
 	github.com/gosoline-project/sqlh v0.3.0
@@ -120,7 +121,6 @@ require (
 	github.com/gin-contrib/gzip v0.0.5 // indirect
 	github.com/gin-contrib/location v0.0.2 // indirect
 	github.com/gin-contrib/sse v1.1.0 // indirect
-	github.com/gin-gonic/gin v1.11.0 // indirect
 	github.com/go-errors/errors v1.5.1 // indirect
 	github.com/go-http-utils/headers v0.0.0-20181008091004-fed159eddc2a // indirect
 	github.com/go-jose/go-jose/v4 v4.1.1 // indirect
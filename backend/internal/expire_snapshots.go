@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// ExpireSnapshotsOptions controls which snapshots expire_snapshots removes. Snapshots older than
+// OlderThan, or RetentionDays if OlderThan is not set, are expired, but the RetainLast newest snapshots
+// are always kept. CleanExpiredMetadata also removes partition specs and schemas no snapshot uses
+// anymore and defaults to true.
+type ExpireSnapshotsOptions struct {
+	RetentionDays        int        `json:"retention_days"`
+	RetainLast           int        `json:"retain_last"`
+	OlderThan            *time.Time `json:"older_than"`
+	CleanExpiredMetadata *bool      `json:"clean_expired_metadata"`
+}
+
+type expireSnapshotsParams struct {
+	retentionDays        int
+	olderThan            time.Time
+	retainLast           int
+	cleanExpiredMetadata bool
+}
+
+// expireSnapshotsParamsFromTaskInput reads the parameters of an expire_snapshots task. Tasks without an
+// explicit older_than expire relative to now.
+func expireSnapshotsParamsFromTaskInput(input map[string]any, now time.Time) (expireSnapshotsParams, error) {
+	retentionDays, _ := input["retention_days"].(float64)
+	retainLast, _ := input["retain_last"].(float64)
+
+	params := expireSnapshotsParams{
+		retentionDays:        int(retentionDays),
+		retainLast:           int(retainLast),
+		cleanExpiredMetadata: true,
+	}
+
+	if clean, ok := input["clean_expired_metadata"].(bool); ok {
+		params.cleanExpiredMetadata = clean
+	}
+
+	if raw, ok := input["older_than"]; ok && raw != nil {
+		olderThan, err := cast.ToTimeE(raw)
+		if err != nil {
+			return expireSnapshotsParams{}, fmt.Errorf("invalid older_than %v: %w", raw, err)
+		}
+
+		params.olderThan = olderThan.UTC()
+
+		return params, nil
+	}
+
+	if params.retentionDays < 1 {
+		return expireSnapshotsParams{}, fmt.Errorf("retention days must be at least 1")
+	}
+
+	params.olderThan = now.UTC().AddDate(0, 0, -params.retentionDays)
+
+	return params, nil
+}
+
+// retainLastCutoff moves olderThan back to the commit time of the retainLast newest snapshot, so
+// expiring everything older than the result keeps at least retainLast snapshots. Iceberg always keeps
+// the current snapshot, so a retainLast below 2 never changes olderThan.
+func retainLastCutoff(olderThan time.Time, committedAt []time.Time, retainLast int) time.Time {
+	if retainLast < 2 || len(committedAt) == 0 {
+		return olderThan
+	}
+
+	sorted := append([]time.Time(nil), committedAt...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].After(sorted[j])
+	})
+
+	cutoff := sorted[min(retainLast, len(sorted))-1]
+	if cutoff.Before(olderThan) {
+		return cutoff
+	}
+
+	return olderThan
+}
+
+// trinoRetentionThreshold renders the age of olderThan as the retention_threshold of trino, which only
+// accepts a duration measured from its own clock when the procedure runs. The age is rounded up to whole
+// minutes with one extra minute for that delay, so trino never expires more than requested.
+func trinoRetentionThreshold(olderThan time.Time, now time.Time) string {
+	minutes := int64(math.Ceil(now.Sub(olderThan).Minutes())) + 1
+
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// countExpiredSnapshots returns how many of the snapshots known before expire_snapshots ran are gone
+// from the refreshed snapshot list.
+func countExpiredSnapshots(before []int64, after []Snapshot) int {
+	remaining := make(map[int64]struct{}, len(after))
+	for _, snapshot := range after {
+		remaining[snapshot.SnapshotId] = struct{}{}
+	}
+
+	expired := 0
+	for _, snapshotID := range before {
+		if _, ok := remaining[snapshotID]; !ok {
+			expired++
+		}
+	}
+
+	return expired
+}
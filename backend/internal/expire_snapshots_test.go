@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpireSnapshotsParamsFromTaskInput(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	params, err := expireSnapshotsParamsFromTaskInput(map[string]any{"retention_days": float64(7), "retain_last": float64(3)}, now)
+	require.NoError(t, err)
+	require.Equal(t, expireSnapshotsParams{
+		retentionDays:        7,
+		olderThan:            time.Date(2026, 10, 9, 12, 0, 0, 0, time.UTC),
+		retainLast:           3,
+		cleanExpiredMetadata: true,
+	}, params)
+
+	params, err = expireSnapshotsParamsFromTaskInput(map[string]any{"older_than": "2026-09-01T00:00:00Z", "clean_expired_metadata": false}, now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), params.olderThan)
+	require.False(t, params.cleanExpiredMetadata)
+
+	_, err = expireSnapshotsParamsFromTaskInput(map[string]any{}, now)
+	require.ErrorContains(t, err, "retention days must be at least 1")
+}
+
+func TestRetainLastCutoff(t *testing.T) {
+	olderThan := time.Date(2026, 10, 9, 0, 0, 0, 0, time.UTC)
+	committedAt := []time.Time{
+		time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	require.Equal(t, olderThan, retainLastCutoff(olderThan, committedAt, 1))
+	require.Equal(t, time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), retainLastCutoff(olderThan, committedAt, 2))
+	require.Equal(t, time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC), retainLastCutoff(olderThan, committedAt, 10))
+	require.Equal(t, olderThan, retainLastCutoff(olderThan, []time.Time{time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)}, 2))
+}
+
+func TestTrinoRetentionThreshold(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	require.Equal(t, "10081m", trinoRetentionThreshold(now.AddDate(0, 0, -7), now))
+	require.Equal(t, "62m", trinoRetentionThreshold(now.Add(-time.Hour-time.Second), now))
+}
+
+func TestCountExpiredSnapshots(t *testing.T) {
+	after := []Snapshot{{SnapshotId: 3}, {SnapshotId: 4}}
+
+	require.Equal(t, 2, countExpiredSnapshots([]int64{1, 2, 3}, after))
+	require.Equal(t, 0, countExpiredSnapshots(nil, after))
+}
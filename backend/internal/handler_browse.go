@@ -41,8 +41,11 @@ type ListPartitionItem struct {
 	FileCount                int64  `json:"file_count" db:"file_count"`
 	RecordCount              int64  `json:"record_count" db:"record_count"`
 	TotalDataFileSizeInBytes int64  `json:"total_data_file_size_in_bytes" db:"total_data_file_size_in_bytes"`
+	DeleteFileCount          int64  `json:"delete_file_count" db:"delete_file_count"`
 	NeedsOptimize            bool   `json:"needs_optimize" db:"needs_optimize"`
 	NeedsOptimizeCount       int64  `json:"needs_optimize_count" db:"needs_optimize_count"`
+	NeedsCompaction          bool   `json:"needs_compaction" db:"needs_compaction"`
+	NeedsCompactionCount     int64  `json:"needs_compaction_count" db:"needs_compaction_count"`
 }
 
 type DataFileItem struct {
@@ -163,6 +166,9 @@ func (h *HandlerBrowse) ListPartitions(ctx context.Context, input *ListPartition
 		Column(sqlc.Col("p.total_data_file_size_in_bytes").Sum().As("total_data_file_size_in_bytes")).
 		Column(sqlc.Coalesce(sqlc.Col("p.needs_optimize").Max(), false).As("needs_optimize")).
 		Column(sqlc.Col("p.needs_optimize").Sum().As("needs_optimize_count")).
+		Column(sqlc.Col("p.delete_file_count").Sum().As("delete_file_count")).
+		Column(sqlc.Coalesce(sqlc.Col("p.needs_compaction").Max(), false).As("needs_compaction")).
+		Column(sqlc.Col("p.needs_compaction").Sum().As("needs_compaction_count")).
 		Where(where).
 		GroupBy(sqlc.Lit(1)).
 		OrderBy(sqlc.Lit(1).Asc())
@@ -0,0 +1,31 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gosoline-project/httpserver"
+	"github.com/justtrackio/gosoline/pkg/cfg"
+	"github.com/justtrackio/gosoline/pkg/log"
+)
+
+func NewHandlerEngines(ctx context.Context, config cfg.Config, logger log.Logger) (*HandlerEngines, error) {
+	var err error
+	var executors *ServiceMaintenanceExecutor
+
+	if executors, err = ProvideServiceMaintenanceExecutor(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create maintenance executor service: %w", err)
+	}
+
+	return &HandlerEngines{
+		executors: executors,
+	}, nil
+}
+
+type HandlerEngines struct {
+	executors *ServiceMaintenanceExecutor
+}
+
+func (h *HandlerEngines) ListEngines(ctx context.Context) (httpserver.Response, error) {
+	return httpserver.NewJsonResponse(h.executors.ListEngines(ctx)), nil
+}
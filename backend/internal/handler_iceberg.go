@@ -2,7 +2,10 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/gosoline-project/httpserver"
 	"github.com/gosoline-project/sqlc"
@@ -69,17 +72,91 @@ type SnapshotMissingFilesInput struct {
 	SnapshotID int64  `uri:"snapshotId"`
 }
 
-type SnapshotRollbackInput struct {
+type SnapshotInput struct {
 	Database   string `uri:"database"`
 	Table      string `uri:"table"`
 	SnapshotID int64  `uri:"snapshotId"`
 }
 
+type SnapshotRollbackInput struct {
+	Database          string `uri:"database"`
+	Table             string `uri:"table"`
+	SnapshotID        int64  `uri:"snapshotId"`
+	DryRun            bool   `json:"dry_run"`
+	ConfirmationToken string `json:"confirmation_token"`
+}
+
+type SetCurrentSnapshotInput struct {
+	Database          string `uri:"database"`
+	Table             string `uri:"table"`
+	SnapshotID        int64  `uri:"snapshotId"`
+	DryRun            bool   `json:"dry_run"`
+	ConfirmationToken string `json:"confirmation_token"`
+}
+
+type SnapshotRollbackToTimestampInput struct {
+	Database          string    `uri:"database"`
+	Table             string    `uri:"table"`
+	Timestamp         time.Time `json:"timestamp"`
+	DryRun            bool      `json:"dry_run"`
+	ConfirmationToken string    `json:"confirmation_token"`
+}
+
+type SnapshotRefInput struct {
+	Database string `uri:"database"`
+	Table    string `uri:"table"`
+	Name     string `uri:"name"`
+}
+
+type CreateTagInput struct {
+	Database    string `uri:"database"`
+	Table       string `uri:"table"`
+	Name        string `json:"name"`
+	SnapshotID  int64  `json:"snapshot_id,string"`
+	MaxRefAgeMs *int64 `json:"max_ref_age_ms"`
+}
+
+type ReplaceTagInput struct {
+	Database    string `uri:"database"`
+	Table       string `uri:"table"`
+	Name        string `uri:"name"`
+	SnapshotID  int64  `json:"snapshot_id,string"`
+	MaxRefAgeMs *int64 `json:"max_ref_age_ms"`
+}
+
+type CreateBranchInput struct {
+	Database   string `uri:"database"`
+	Table      string `uri:"table"`
+	Name       string `json:"name"`
+	SnapshotID int64  `json:"snapshot_id,string"`
+	SnapshotRefRetention
+}
+
+type FastForwardBranchInput struct {
+	Database string `uri:"database"`
+	Table    string `uri:"table"`
+	Name     string `uri:"name"`
+	To       string `json:"to"`
+}
+
+type SnapshotDiffInput struct {
+	Database       string `uri:"database"`
+	Table          string `uri:"table"`
+	FromSnapshotID int64  `uri:"snapshotId"`
+	ToSnapshotID   int64  `uri:"to"`
+	Limit          int    `form:"limit"`
+	Offset         int    `form:"offset"`
+}
+
 type SnapshotMissingFilesResponse struct {
 	SnapshotID   int64    `json:"snapshot_id,string"`
 	MissingFiles []string `json:"missing_files"`
 }
 
+type SnapshotRefsResponse struct {
+	Refs []SnapshotRef `json:"refs"`
+}
+
 type SnapshotRollbackResponse struct {
 	SnapshotID int64  `json:"snapshot_id,string"`
 	Status     string `json:"status"`
@@ -111,6 +188,18 @@ func (h *HandlerIceberg) ListPartitions(ctx context.Context, input *TableSelectI
 	}), nil
 }
 
+// DiffSnapshots compares the files, schema and partition specs of two snapshots. Limit and offset only page
+// the returned files: every request reads all manifests of both snapshots, so each page costs as much as the
+// whole diff.
+func (h *HandlerIceberg) DiffSnapshots(ctx context.Context, input *SnapshotDiffInput) (httpserver.Response, error) {
+	diff, err := h.service.DiffSnapshots(ctx, input.Database, input.Table, input.FromSnapshotID, input.ToSnapshotID, input.Limit, input.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("could not diff snapshots %d and %d: %w", input.FromSnapshotID, input.ToSnapshotID, err)
+	}
+
+	return httpserver.NewJsonResponse(diff), nil
+}
+
 func (h *HandlerIceberg) ListSnapshotMissingFiles(ctx context.Context, input *SnapshotMissingFilesInput) (httpserver.Response, error) {
 	missingFiles, err := h.files.ListMissingFiles(ctx, input.Database, input.Table, input.SnapshotID)
 	if err != nil {
@@ -123,27 +212,180 @@ func (h *HandlerIceberg) ListSnapshotMissingFiles(ctx context.Context, input *Sn
 	}), nil
 }
 
+// RollbackToSnapshot rolls the table back to the snapshot. With dry_run only the pre-flight checks run and
+// the preview, including the confirmation token the actual rollback has to present, is returned.
 func (h *HandlerIceberg) RollbackToSnapshot(ctx context.Context, input *SnapshotRollbackInput) (httpserver.Response, error) {
-	if err := h.admin.RollbackToSnapshot(ctx, input.Database, input.Table, input.SnapshotID); err != nil {
+	if input.DryRun {
+		preview, err := h.admin.PreviewRollback(ctx, input.Database, input.Table, input.SnapshotID)
+
+		return rollbackPreviewResponse(preview, err)
+	}
+
+	if err := h.admin.RollbackToSnapshot(ctx, input.Database, input.Table, input.SnapshotID, input.ConfirmationToken); err != nil {
+		if errors.Is(err, errRollbackRejected) {
+			return httpserver.GetErrorHandler()(http.StatusBadRequest, err), nil
+		}
+
 		return nil, fmt.Errorf("could not rollback table %s.%s to snapshot %d: %w", input.Database, input.Table, input.SnapshotID, err)
 	}
 
-	if err := h.sqlClient.WithTx(ctx, func(cttx sqlc.Tx) error {
-		if err := h.refresh.RefreshTableFull(cttx, input.Database, input.Table); err != nil {
-			return fmt.Errorf("could not refresh table metadata after rollback: %w", err)
+	return h.refreshAfterSnapshotChange(ctx, input.Database, input.Table, input.SnapshotID, "rollback")
+}
+
+func (h *HandlerIceberg) RollbackToTimestamp(ctx context.Context, input *SnapshotRollbackToTimestampInput) (httpserver.Response, error) {
+	if input.Timestamp.IsZero() {
+		return nil, fmt.Errorf("timestamp is required")
+	}
+
+	if input.DryRun {
+		preview, err := h.admin.PreviewRollbackToTimestamp(ctx, input.Database, input.Table, input.Timestamp)
+
+		return rollbackPreviewResponse(preview, err)
+	}
+
+	snapshotID, err := h.admin.RollbackToTimestamp(ctx, input.Database, input.Table, input.Timestamp, input.ConfirmationToken)
+	if err != nil {
+		if errors.Is(err, errRollbackRejected) {
+			return httpserver.GetErrorHandler()(http.StatusBadRequest, err), nil
 		}
 
-		return nil
-	}); err != nil {
-		return nil, fmt.Errorf("could not refresh table %s.%s after rollback to snapshot %d: %w", input.Database, input.Table, input.SnapshotID, err)
+		return nil, fmt.Errorf("could not rollback table %s.%s to %s: %w", input.Database, input.Table, input.Timestamp.UTC().Format(time.RFC3339), err)
+	}
+
+	return h.refreshAfterSnapshotChange(ctx, input.Database, input.Table, snapshotID, "rollback")
+}
+
+// SetCurrentSnapshot points the table to the snapshot. Moving back to an ancestor of the current snapshot is
+// a rollback and needs the confirmation token of a preview, which dry_run returns like for RollbackToSnapshot.
+func (h *HandlerIceberg) SetCurrentSnapshot(ctx context.Context, input *SetCurrentSnapshotInput) (httpserver.Response, error) {
+	if input.DryRun {
+		preview, err := h.admin.PreviewRollback(ctx, input.Database, input.Table, input.SnapshotID)
+
+		return rollbackPreviewResponse(preview, err)
+	}
+
+	if err := h.admin.SetCurrentSnapshot(ctx, input.Database, input.Table, input.SnapshotID, input.ConfirmationToken); err != nil {
+		if errors.Is(err, errRollbackRejected) {
+			return httpserver.GetErrorHandler()(http.StatusBadRequest, err), nil
+		}
+
+		return nil, err
+	}
+
+	return h.refreshAfterSnapshotChange(ctx, input.Database, input.Table, input.SnapshotID, "setting the current snapshot")
+}
+
+// CherryPickSnapshot applies a staged snapshot to the table, the response contains the resulting current
+// snapshot, which is a new one unless the staged snapshot could be fast-forwarded to.
+func (h *HandlerIceberg) CherryPickSnapshot(ctx context.Context, input *SnapshotInput) (httpserver.Response, error) {
+	snapshotID, err := h.admin.CherryPickSnapshot(ctx, input.Database, input.Table, input.SnapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.refreshAfterSnapshotChange(ctx, input.Database, input.Table, snapshotID, "cherry-pick")
+}
+
+// rollbackPreviewResponse returns the preview, a failed pre-flight check is reported as a bad request.
+func rollbackPreviewResponse(preview *RollbackPreview, err error) (httpserver.Response, error) {
+	if errors.Is(err, errRollbackRejected) {
+		return httpserver.GetErrorHandler()(http.StatusBadRequest, err), nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not preview rollback: %w", err)
+	}
+
+	return httpserver.NewJsonResponse(preview), nil
+}
+
+// refreshAfterSnapshotChange refreshes the stored table, including its current snapshot id, partitions and
+// snapshots, after the current snapshot of the table was changed to snapshotID.
+func (h *HandlerIceberg) refreshAfterSnapshotChange(ctx context.Context, database string, table string, snapshotID int64, operation string) (httpserver.Response, error) {
+	if err := h.refreshTable(ctx, database, table, operation); err != nil {
+		return nil, fmt.Errorf("could not refresh table %s.%s after %s to snapshot %d: %w", database, table, operation, snapshotID, err)
 	}
 
 	return httpserver.NewJsonResponse(SnapshotRollbackResponse{
-		SnapshotID: input.SnapshotID,
+		SnapshotID: snapshotID,
 		Status:     statusOK,
 	}), nil
 }
 
+func (h *HandlerIceberg) ListSnapshotRefs(ctx context.Context, input *TableSelectInput) (httpserver.Response, error) {
+	refs, err := h.service.ListSnapshotRefs(ctx, input.Database, input.Table)
+	if err != nil {
+		return nil, fmt.Errorf("could not list snapshot refs: %w", err)
+	}
+
+	return httpserver.NewJsonResponse(SnapshotRefsResponse{
+		Refs: refs,
+	}), nil
+}
+
+func (h *HandlerIceberg) CreateTag(ctx context.Context, input *CreateTagInput) (httpserver.Response, error) {
+	err := h.admin.SetTag(ctx, input.Database, input.Table, input.Name, input.SnapshotID, input.MaxRefAgeMs, false)
+
+	return h.refsAfterRefChange(ctx, input.Database, input.Table, "creating tag "+input.Name, err)
+}
+
+func (h *HandlerIceberg) ReplaceTag(ctx context.Context, input *ReplaceTagInput) (httpserver.Response, error) {
+	err := h.admin.SetTag(ctx, input.Database, input.Table, input.Name, input.SnapshotID, input.MaxRefAgeMs, true)
+
+	return h.refsAfterRefChange(ctx, input.Database, input.Table, "replacing tag "+input.Name, err)
+}
+
+func (h *HandlerIceberg) DropTag(ctx context.Context, input *SnapshotRefInput) (httpserver.Response, error) {
+	err := h.admin.DropTag(ctx, input.Database, input.Table, input.Name)
+
+	return h.refsAfterRefChange(ctx, input.Database, input.Table, "dropping tag "+input.Name, err)
+}
+
+// CreateBranch creates a branch at the given snapshot, without a snapshot at the current one.
+func (h *HandlerIceberg) CreateBranch(ctx context.Context, input *CreateBranchInput) (httpserver.Response, error) {
+	_, err := h.admin.CreateBranch(ctx, input.Database, input.Table, input.Name, input.SnapshotID, input.SnapshotRefRetention)
+
+	return h.refsAfterRefChange(ctx, input.Database, input.Table, "creating branch "+input.Name, err)
+}
+
+func (h *HandlerIceberg) FastForwardBranch(ctx context.Context, input *FastForwardBranchInput) (httpserver.Response, error) {
+	if input.To == "" {
+		return nil, fmt.Errorf("to is required")
+	}
+
+	_, err := h.admin.FastForwardBranch(ctx, input.Database, input.Table, input.Name, input.To)
+
+	return h.refsAfterRefChange(ctx, input.Database, input.Table, "fast-forwarding branch "+input.Name, err)
+}
+
+// refsAfterRefChange reports a rejected ref change as a bad request. Otherwise the stored table is
+// refreshed, as moving main changes the current snapshot, and the refs of the table are returned.
+func (h *HandlerIceberg) refsAfterRefChange(ctx context.Context, database string, table string, operation string, err error) (httpserver.Response, error) {
+	if errors.Is(err, errSnapshotRefRejected) {
+		return httpserver.GetErrorHandler()(http.StatusBadRequest, err), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = h.refreshTable(ctx, database, table, operation); err != nil {
+		return nil, fmt.Errorf("could not refresh table %s.%s after %s: %w", database, table, operation, err)
+	}
+
+	return h.ListSnapshotRefs(ctx, &TableSelectInput{Database: database, Table: table})
+}
+
+func (h *HandlerIceberg) refreshTable(ctx context.Context, database string, table string, operation string) error {
+	return h.sqlClient.WithTx(ctx, func(cttx sqlc.Tx) error {
+		if err := h.refresh.RefreshTableFull(cttx, database, table); err != nil {
+			return fmt.Errorf("could not refresh table metadata after %s: %w", operation, err)
+		}
+
+		return nil
+	})
+}
+
 func (h *HandlerIceberg) ListTables(ctx context.Context, input *DatabaseInput) (httpserver.Response, error) {
 	var err error
 	var tables []CatalogTable
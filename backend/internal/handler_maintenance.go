@@ -10,20 +10,34 @@ import (
 )
 
 type BatchExpireSnapshotsInput struct {
-	Database      string   `uri:"database"`
-	Tables        []string `json:"tables"`
-	RetentionDays int      `json:"retention_days"`
+	ExpireSnapshotsOptions
+	Database string   `uri:"database"`
+	Tables   []string `json:"tables"`
+	Priority int      `json:"priority"`
+	DryRun   bool     `json:"dry_run"`
 }
 
 type BatchRemoveOrphanFilesInput struct {
 	Database      string   `uri:"database"`
 	Tables        []string `json:"tables"`
 	RetentionDays int      `json:"retention_days"`
+	Priority      int      `json:"priority"`
+	DryRun        bool     `json:"dry_run"`
+}
+
+// BatchTableTaskInput enqueues tasks of a kind which only needs the table, see HandlerMaintenance.TableTask.
+type BatchTableTaskInput struct {
+	Database string   `uri:"database"`
+	Tables   []string `json:"tables"`
+	Priority int      `json:"priority"`
+	DryRun   bool     `json:"dry_run"`
 }
 
 type BatchOptimizeTableInput struct {
-	Table   string `json:"table"`
-	ChunkBy string `json:"chunk_by"`
+	Table    string   `json:"table"`
+	ChunkBy  string   `json:"chunk_by"`
+	Strategy string   `json:"strategy"`
+	Columns  []string `json:"columns"`
 }
 
 type BatchOptimizeInput struct {
@@ -32,6 +46,8 @@ type BatchOptimizeInput struct {
 	TargetFileSizeMb int                       `json:"target_file_size_mb"`
 	From             DateTime                  `json:"from"`
 	To               DateTime                  `json:"to"`
+	Priority         int                       `json:"priority"`
+	DryRun           bool                      `json:"dry_run"`
 }
 
 func NewHandlerMaintenance(ctx context.Context, config cfg.Config, logger log.Logger) (*HandlerMaintenance, error) {
@@ -50,7 +66,16 @@ type HandlerMaintenance struct {
 }
 
 func (h *HandlerMaintenance) ExpireSnapshots(ctx context.Context, input *BatchExpireSnapshotsInput) (httpserver.Response, error) {
-	result, err := h.serviceTasks.EnqueueExpireSnapshotsBatch(ctx, input.Database, input.Tables, input.RetentionDays)
+	if input.DryRun {
+		result, err := h.serviceTasks.PlanExpireSnapshotsBatch(ctx, input.Database, input.Tables, input.ExpireSnapshotsOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		return httpserver.NewJsonResponse(result), nil
+	}
+
+	result, err := h.serviceTasks.EnqueueExpireSnapshotsBatch(ctx, input.Database, input.Tables, input.ExpireSnapshotsOptions, TaskEnqueueOptions{Priority: input.Priority})
 	if err != nil {
 		return nil, err
 	}
@@ -59,7 +84,16 @@ func (h *HandlerMaintenance) ExpireSnapshots(ctx context.Context, input *BatchEx
 }
 
 func (h *HandlerMaintenance) RemoveOrphanFiles(ctx context.Context, input *BatchRemoveOrphanFilesInput) (httpserver.Response, error) {
-	result, err := h.serviceTasks.EnqueueRemoveOrphanFilesBatch(ctx, input.Database, input.Tables, input.RetentionDays)
+	if input.DryRun {
+		result, err := h.serviceTasks.PlanRemoveOrphanFilesBatch(ctx, input.Database, input.Tables, input.RetentionDays)
+		if err != nil {
+			return nil, err
+		}
+
+		return httpserver.NewJsonResponse(result), nil
+	}
+
+	result, err := h.serviceTasks.EnqueueRemoveOrphanFilesBatch(ctx, input.Database, input.Tables, input.RetentionDays, TaskEnqueueOptions{Priority: input.Priority})
 	if err != nil {
 		return nil, err
 	}
@@ -67,13 +101,44 @@ func (h *HandlerMaintenance) RemoveOrphanFiles(ctx context.Context, input *Batch
 	return httpserver.NewJsonResponse(result), nil
 }
 
+// TableTask returns the handler enqueueing or, with dry_run, planning tasks of a kind which only needs the
+// table for all given tables.
+func (h *HandlerMaintenance) TableTask(kind TaskKind) func(ctx context.Context, input *BatchTableTaskInput) (httpserver.Response, error) {
+	return func(ctx context.Context, input *BatchTableTaskInput) (httpserver.Response, error) {
+		if input.DryRun {
+			result, err := h.serviceTasks.PlanTableTaskBatch(ctx, kind, input.Database, input.Tables)
+			if err != nil {
+				return nil, err
+			}
+
+			return httpserver.NewJsonResponse(result), nil
+		}
+
+		result, err := h.serviceTasks.EnqueueTableTaskBatch(ctx, kind, input.Database, input.Tables, TaskEnqueueOptions{Priority: input.Priority})
+		if err != nil {
+			return nil, err
+		}
+
+		return httpserver.NewJsonResponse(result), nil
+	}
+}
+
 func (h *HandlerMaintenance) Optimize(ctx context.Context, input *BatchOptimizeInput) (httpserver.Response, error) {
 	tables := make([]BatchOptimizeTable, 0, len(input.Tables))
 	for _, table := range input.Tables {
 		tables = append(tables, BatchOptimizeTable(table))
 	}
 
-	result, err := h.serviceTasks.EnqueueOptimizeBatch(ctx, input.Database, tables, input.TargetFileSizeMb, input.From.Time, input.To.Time)
+	if input.DryRun {
+		result, err := h.serviceTasks.PlanOptimizeBatch(ctx, input.Database, tables, input.TargetFileSizeMb, input.From.Time, input.To.Time)
+		if err != nil {
+			return nil, err
+		}
+
+		return httpserver.NewJsonResponse(result), nil
+	}
+
+	result, err := h.serviceTasks.EnqueueOptimizeBatch(ctx, input.Database, tables, input.TargetFileSizeMb, input.From.Time, input.To.Time, TaskEnqueueOptions{Priority: input.Priority})
 	if err != nil {
 		return nil, err
 	}
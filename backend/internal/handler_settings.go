@@ -3,6 +3,7 @@ package internal
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/gosoline-project/httpserver"
 	"github.com/justtrackio/gosoline/pkg/cfg"
@@ -17,14 +18,45 @@ type SetTaskConcurrencyRequest struct {
 	Value int `json:"value"`
 }
 
+type SetTaskConcurrencyLimitsRequest struct {
+	Engines     map[TaskEngine]int `json:"engines"`
+	Kinds       map[TaskKind]int   `json:"kinds"`
+	OnePerTable *bool              `json:"one_per_table"`
+}
+
+type MaintenanceProfileSelectInput struct {
+	Database string `uri:"database"`
+	Table    string `uri:"table"`
+}
+
+type SetMaintenanceProfileInput struct {
+	Database string `uri:"database"`
+	Table    string `uri:"table"`
+	MaintenanceProfile
+}
+
+// MaintenanceProfileResponse returns the stored profile of a database or table together with the
+// effective profile after merging the database profile into a table profile.
+type MaintenanceProfileResponse struct {
+	Database  string             `json:"database"`
+	Table     string             `json:"table"`
+	Profile   MaintenanceProfile `json:"profile"`
+	Effective MaintenanceProfile `json:"effective"`
+}
+
 func NewHandlerSettings(ctx context.Context, config cfg.Config, logger log.Logger) (*HandlerSettings, error) {
 	var err error
 	var serviceSettings *ServiceSettings
+	var profiles *ServiceMaintenanceProfiles
 
 	if serviceSettings, err = NewServiceSettings(ctx, config, logger); err != nil {
 		return nil, fmt.Errorf("could not create settings service: %w", err)
 	}
 
+	if profiles, err = NewServiceMaintenanceProfiles(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create maintenance profiles service: %w", err)
+	}
+
 	// Get the default from config as fallback
 	defaultWorkerCount, err := config.GetInt("tasks.worker_count")
 	if err != nil {
@@ -36,6 +68,7 @@ func NewHandlerSettings(ctx context.Context, config cfg.Config, logger log.Logge
 
 	return &HandlerSettings{
 		serviceSettings:    serviceSettings,
+		profiles:           profiles,
 		defaultWorkerCount: defaultWorkerCount,
 		logger:             logger.WithChannel("handler_settings"),
 	}, nil
@@ -43,6 +76,7 @@ func NewHandlerSettings(ctx context.Context, config cfg.Config, logger log.Logge
 
 type HandlerSettings struct {
 	serviceSettings    *ServiceSettings
+	profiles           *ServiceMaintenanceProfiles
 	defaultWorkerCount int
 	logger             log.Logger
 }
@@ -75,3 +109,106 @@ func (h *HandlerSettings) SetTaskConcurrency(ctx context.Context, input *SetTask
 		Value: input.Value,
 	}), nil
 }
+
+func (h *HandlerSettings) GetTaskConcurrencyLimits(ctx context.Context) (httpserver.Response, error) {
+	limits, err := h.serviceSettings.GetTaskConcurrencyLimits(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task concurrency limits: %w", err)
+	}
+
+	return httpserver.NewJsonResponse(limits), nil
+}
+
+func (h *HandlerSettings) SetTaskConcurrencyLimits(ctx context.Context, input *SetTaskConcurrencyLimitsRequest) (httpserver.Response, error) {
+	for engine, limit := range input.Engines {
+		if !slices.Contains(registeredTaskEngines(), engine) {
+			return nil, fmt.Errorf("unknown task engine %s", engine)
+		}
+
+		if limit < 0 {
+			return nil, fmt.Errorf("concurrency limit for engine %s must not be negative", engine)
+		}
+	}
+
+	for kind, limit := range input.Kinds {
+		if !slices.Contains(taskKinds, kind) {
+			return nil, fmt.Errorf("unknown task kind %s", kind)
+		}
+
+		if limit < 0 {
+			return nil, fmt.Errorf("concurrency limit for task kind %s must not be negative", kind)
+		}
+	}
+
+	limits := &TaskConcurrencyLimits{
+		Engines:     input.Engines,
+		Kinds:       input.Kinds,
+		OnePerTable: true,
+	}
+
+	if input.OnePerTable != nil {
+		limits.OnePerTable = *input.OnePerTable
+	}
+
+	if err := h.serviceSettings.SetTaskConcurrencyLimits(ctx, limits); err != nil {
+		return nil, fmt.Errorf("failed to set task concurrency limits: %w", err)
+	}
+
+	h.logger.Info(ctx, "updated task concurrency limits: engines %v, kinds %v, one per table %t", input.Engines, input.Kinds, limits.OnePerTable)
+
+	return h.GetTaskConcurrencyLimits(ctx)
+}
+
+func (h *HandlerSettings) ListMaintenanceProfiles(ctx context.Context, input *MaintenanceProfileSelectInput) (httpserver.Response, error) {
+	records, err := h.profiles.ListProfiles(ctx, input.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list maintenance profiles: %w", err)
+	}
+
+	return httpserver.NewJsonResponse(records), nil
+}
+
+func (h *HandlerSettings) GetMaintenanceProfile(ctx context.Context, input *MaintenanceProfileSelectInput) (httpserver.Response, error) {
+	var err error
+	var records []MaintenanceProfileRecord
+	var effective MaintenanceProfile
+
+	if records, err = h.profiles.ListProfiles(ctx, input.Database); err != nil {
+		return nil, fmt.Errorf("failed to get maintenance profile: %w", err)
+	}
+
+	if effective, err = h.profiles.ResolveProfile(ctx, input.Database, input.Table); err != nil {
+		return nil, fmt.Errorf("failed to resolve maintenance profile: %w", err)
+	}
+
+	response := &MaintenanceProfileResponse{
+		Database:  input.Database,
+		Table:     input.Table,
+		Effective: effective,
+	}
+
+	for _, record := range records {
+		if record.Table == input.Table {
+			response.Profile = record.Profile.Get()
+		}
+	}
+
+	return httpserver.NewJsonResponse(response), nil
+}
+
+func (h *HandlerSettings) SetMaintenanceProfile(ctx context.Context, input *SetMaintenanceProfileInput) (httpserver.Response, error) {
+	record, err := h.profiles.SetProfile(ctx, input.Database, input.Table, input.MaintenanceProfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set maintenance profile: %w", err)
+	}
+
+	return httpserver.NewJsonResponse(record), nil
+}
+
+func (h *HandlerSettings) DeleteMaintenanceProfile(ctx context.Context, input *MaintenanceProfileSelectInput) (httpserver.Response, error) {
+	if err := h.profiles.DeleteProfile(ctx, input.Database, input.Table); err != nil {
+		return nil, fmt.Errorf("failed to delete maintenance profile: %w", err)
+	}
+
+	return httpserver.NewJsonResponse(map[string]string{"status": statusOK}), nil
+}
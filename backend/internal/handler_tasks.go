@@ -3,23 +3,42 @@ package internal
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"slices"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/gosoline-project/httpserver"
 	"github.com/justtrackio/gosoline/pkg/cfg"
 	"github.com/justtrackio/gosoline/pkg/log"
 )
 
 type ExpireSnapshotsInput struct {
-	Database      string `uri:"database"`
-	Table         string `uri:"table"`
-	RetentionDays int    `json:"retention_days"`
+	ExpireSnapshotsOptions
+	Database  string  `uri:"database"`
+	Table     string  `uri:"table"`
+	Priority  int     `json:"priority"`
+	DependsOn []int64 `json:"depends_on"`
+	DryRun    bool    `json:"dry_run"`
 }
 
 type RemoveOrphanFilesInput struct {
-	Database      string `uri:"database"`
-	Table         string `uri:"table"`
-	RetentionDays int    `json:"retention_days"`
+	Database      string  `uri:"database"`
+	Table         string  `uri:"table"`
+	RetentionDays int     `json:"retention_days"`
+	Priority      int     `json:"priority"`
+	DependsOn     []int64 `json:"depends_on"`
+	DryRun        bool    `json:"dry_run"`
+}
+
+// TableTaskInput enqueues a task of a kind which only needs the table, see HandlerTasks.TableTask.
+type TableTaskInput struct {
+	Database  string  `uri:"database"`
+	Table     string  `uri:"table"`
+	Priority  int     `json:"priority"`
+	DependsOn []int64 `json:"depends_on"`
+	DryRun    bool    `json:"dry_run"`
 }
 
 type OptimizeInput struct {
@@ -29,6 +48,11 @@ type OptimizeInput struct {
 	From             DateTime `json:"from"`
 	To               DateTime `json:"to"`
 	ChunkBy          string   `json:"chunk_by"`
+	Strategy         string   `json:"strategy"`
+	Columns          []string `json:"columns"`
+	Priority         int      `json:"priority"`
+	DependsOn        []int64  `json:"depends_on"`
+	DryRun           bool     `json:"dry_run"`
 }
 
 type ListAllTasksInput struct {
@@ -51,21 +75,55 @@ type RetryTaskInput struct {
 	Id int64 `uri:"id"`
 }
 
+type TaskChainInput struct {
+	Id int64 `uri:"id"`
+}
+
+type SetTaskPriorityInput struct {
+	Id       int64 `uri:"id"`
+	Priority int   `json:"priority"`
+}
+
+type CancelTaskInput struct {
+	Id int64 `uri:"id"`
+}
+
 type TaskProcedureCallbackInput struct {
 	Id    int64            `uri:"id"`
+	Claim int              `form:"claim"`
 	Query string           `json:"query"`
 	Rows  []map[string]any `json:"rows"`
 	Meta  map[string]any   `json:"meta"`
 }
 
+type TaskEventsInput struct {
+	Database string `form:"database"`
+	Table    string `form:"table"`
+}
+
 type TaskQueuedResponse struct {
 	TaskId int64  `json:"task_id"`
 	Status string `json:"status"`
 }
 
 type OptimizeTaskQueuedResponse struct {
-	TaskIds []int64 `json:"task_ids"`
-	Status  string  `json:"status"`
+	TaskIds      []int64 `json:"task_ids"`
+	Deduplicated []int64 `json:"deduplicated"`
+	Status       string  `json:"status"`
+}
+
+// TaskPlanResponse is returned instead of a queued response if dry_run is set on an enqueue request.
+type TaskPlanResponse struct {
+	Tasks []PlannedTask `json:"tasks"`
+}
+
+type TaskPriorityResponse struct {
+	TaskId   int64 `json:"task_id"`
+	Priority int   `json:"priority"`
+}
+
+type CancelAllTasksResponse struct {
+	CancelledCount int64 `json:"cancelled_count"`
 }
 
 type TaskCountsResponse struct {
@@ -73,8 +131,17 @@ type TaskCountsResponse struct {
 	Queued  int64 `json:"queued"`
 }
 
+type FlushTasksInput struct {
+	Database      string   `uri:"database"`
+	Status        []string `form:"status"`
+	Kind          []string `form:"kind"`
+	OlderThanDays int      `form:"older_than_days"`
+}
+
 type FlushTasksResponse struct {
-	Deleted int64 `json:"deleted"`
+	Deleted  int64    `json:"deleted"`
+	Archived int64    `json:"archived"`
+	Archives []string `json:"archives"`
 }
 
 type RetryAllTasksResponse struct {
@@ -84,54 +151,161 @@ type RetryAllTasksResponse struct {
 func NewHandlerTasks(ctx context.Context, config cfg.Config, logger log.Logger) (*HandlerTasks, error) {
 	var err error
 	var serviceTasks *ServiceTasks
+	var events *TaskEventBus
 
 	if serviceTasks, err = NewServiceTasks(ctx, config, logger); err != nil {
 		return nil, fmt.Errorf("could not create tasks service: %w", err)
 	}
 
+	if events, err = ProvideTaskEventBus(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create task event bus: %w", err)
+	}
+
 	return &HandlerTasks{
 		serviceTasks: serviceTasks,
+		events:       events,
 	}, nil
 }
 
 type HandlerTasks struct {
 	serviceTasks *ServiceTasks
+	events       *TaskEventBus
 }
 
 func (h *HandlerTasks) ExpireSnapshots(ctx context.Context, input *ExpireSnapshotsInput) (httpserver.Response, error) {
-	taskId, err := h.serviceTasks.EnqueueExpireSnapshots(ctx, input.Database, input.Table, input.RetentionDays)
+	if input.DryRun {
+		plan, err := h.serviceTasks.PlanExpireSnapshots(ctx, input.Database, input.Table, input.ExpireSnapshotsOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		return httpserver.NewJsonResponse(&TaskPlanResponse{Tasks: []PlannedTask{plan}}), nil
+	}
+
+	enqueued, err := h.serviceTasks.EnqueueExpireSnapshots(ctx, input.Database, input.Table, input.ExpireSnapshotsOptions, TaskEnqueueOptions{Priority: input.Priority, DependsOn: input.DependsOn})
 	if err != nil {
 		return nil, err
 	}
 
-	return httpserver.NewJsonResponse(&TaskQueuedResponse{
-		TaskId: taskId,
-		Status: taskStatusQueued,
-	}), nil
+	return httpserver.NewJsonResponse(newTaskQueuedResponse(enqueued)), nil
 }
 
 func (h *HandlerTasks) RemoveOrphanFiles(ctx context.Context, input *RemoveOrphanFilesInput) (httpserver.Response, error) {
-	taskId, err := h.serviceTasks.EnqueueRemoveOrphanFiles(ctx, input.Database, input.Table, input.RetentionDays)
+	if input.DryRun {
+		plan, err := h.serviceTasks.PlanRemoveOrphanFiles(ctx, input.Database, input.Table, input.RetentionDays)
+		if err != nil {
+			return nil, err
+		}
+
+		return httpserver.NewJsonResponse(&TaskPlanResponse{Tasks: []PlannedTask{plan}}), nil
+	}
+
+	enqueued, err := h.serviceTasks.EnqueueRemoveOrphanFiles(ctx, input.Database, input.Table, input.RetentionDays, TaskEnqueueOptions{Priority: input.Priority, DependsOn: input.DependsOn})
 	if err != nil {
 		return nil, err
 	}
 
-	return httpserver.NewJsonResponse(&TaskQueuedResponse{
-		TaskId: taskId,
-		Status: taskStatusQueued,
-	}), nil
+	return httpserver.NewJsonResponse(newTaskQueuedResponse(enqueued)), nil
+}
+
+// TableTask returns the handler enqueueing or, with dry_run, planning a task of a kind which only needs
+// the table, e.g. rewrite_manifests.
+func (h *HandlerTasks) TableTask(kind TaskKind) func(ctx context.Context, input *TableTaskInput) (httpserver.Response, error) {
+	return func(ctx context.Context, input *TableTaskInput) (httpserver.Response, error) {
+		if input.DryRun {
+			plan, err := h.serviceTasks.PlanTableTask(ctx, kind, input.Database, input.Table)
+			if err != nil {
+				return nil, err
+			}
+
+			return httpserver.NewJsonResponse(&TaskPlanResponse{Tasks: []PlannedTask{plan}}), nil
+		}
+
+		enqueued, err := h.serviceTasks.EnqueueTableTask(ctx, kind, input.Database, input.Table, TaskEnqueueOptions{Priority: input.Priority, DependsOn: input.DependsOn})
+		if err != nil {
+			return nil, err
+		}
+
+		return httpserver.NewJsonResponse(newTaskQueuedResponse(enqueued)), nil
+	}
 }
 
 func (h *HandlerTasks) Optimize(ctx context.Context, input *OptimizeInput) (httpserver.Response, error) {
-	taskIds, err := h.serviceTasks.EnqueueOptimize(ctx, input.Database, input.Table, input.TargetFileSizeMb, input.From.Time, input.To.Time, input.ChunkBy)
+	if input.DryRun {
+		plans, err := h.serviceTasks.PlanOptimize(ctx, input.Database, input.Table, input.TargetFileSizeMb, input.From.Time, input.To.Time, input.ChunkBy, OptimizeStrategy{Strategy: input.Strategy, Columns: input.Columns})
+		if err != nil {
+			return nil, err
+		}
+
+		return httpserver.NewJsonResponse(&TaskPlanResponse{Tasks: plans}), nil
+	}
+
+	enqueuedTasks, err := h.serviceTasks.EnqueueOptimize(ctx, input.Database, input.Table, input.TargetFileSizeMb, input.From.Time, input.To.Time, input.ChunkBy, OptimizeStrategy{Strategy: input.Strategy, Columns: input.Columns}, TaskEnqueueOptions{Priority: input.Priority, DependsOn: input.DependsOn})
 	if err != nil {
 		return nil, err
 	}
 
-	return httpserver.NewJsonResponse(&OptimizeTaskQueuedResponse{
-		TaskIds: taskIds,
-		Status:  taskStatusQueued,
-	}), nil
+	response := &OptimizeTaskQueuedResponse{
+		TaskIds:      make([]int64, 0, len(enqueuedTasks)),
+		Deduplicated: make([]int64, 0),
+		Status:       taskStatusQueued,
+	}
+
+	for _, enqueued := range enqueuedTasks {
+		response.TaskIds = append(response.TaskIds, enqueued.TaskId)
+
+		if enqueued.Deduplicated {
+			response.Deduplicated = append(response.Deduplicated, enqueued.TaskId)
+		}
+	}
+
+	return httpserver.NewJsonResponse(response), nil
+}
+
+// Events streams task lifecycle events as server-sent events until the client disconnects. Each event
+// is named after its type and carries the json encoded TaskEvent as data.
+func (h *HandlerTasks) Events(ginCtx *gin.Context) {
+	input := &TaskEventsInput{}
+	if err := ginCtx.ShouldBindQuery(input); err != nil {
+		ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+		return
+	}
+
+	if input.Table != "" && input.Database == "" {
+		ginCtx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "a table filter requires a database filter"})
+
+		return
+	}
+
+	subscription := h.events.Subscribe(TaskEventFilter{Database: input.Database, Table: input.Table})
+	defer h.events.Unsubscribe(subscription)
+
+	keepalive := time.NewTicker(h.events.Settings().KeepaliveInterval)
+	defer keepalive.Stop()
+
+	ginCtx.Header("Cache-Control", "no-cache")
+	ginCtx.Header("Connection", "keep-alive")
+	ginCtx.Header("X-Accel-Buffering", "no")
+
+	ginCtx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ginCtx.Request.Context().Done():
+			return false
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return false
+			}
+
+			ginCtx.SSEvent(string(event.Type), event)
+
+			return true
+		case <-keepalive.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+
+			return err == nil
+		}
+	})
 }
 
 func (h *HandlerTasks) ListTasks(ctx context.Context, input *ListTasksInput) (httpserver.Response, error) {
@@ -166,6 +340,48 @@ func (h *HandlerTasks) RetryAllTasks(ctx context.Context, input *DatabaseInput)
 	}), nil
 }
 
+func (h *HandlerTasks) TaskChain(ctx context.Context, input *TaskChainInput) (httpserver.Response, error) {
+	chain, err := h.serviceTasks.GetTaskChain(ctx, input.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return httpserver.NewJsonResponse(chain), nil
+}
+
+func (h *HandlerTasks) SetTaskPriority(ctx context.Context, input *SetTaskPriorityInput) (httpserver.Response, error) {
+	if err := h.serviceTasks.SetTaskPriority(ctx, input.Id, input.Priority); err != nil {
+		return nil, err
+	}
+
+	return httpserver.NewJsonResponse(&TaskPriorityResponse{
+		TaskId:   input.Id,
+		Priority: input.Priority,
+	}), nil
+}
+
+func (h *HandlerTasks) CancelTask(ctx context.Context, input *CancelTaskInput) (httpserver.Response, error) {
+	if err := h.serviceTasks.CancelTask(ctx, input.Id); err != nil {
+		return nil, err
+	}
+
+	return httpserver.NewJsonResponse(&TaskQueuedResponse{
+		TaskId: input.Id,
+		Status: taskStatusCancelled,
+	}), nil
+}
+
+func (h *HandlerTasks) CancelAllTasks(ctx context.Context, input *DatabaseInput) (httpserver.Response, error) {
+	cancelledCount, err := h.serviceTasks.CancelAllTasks(ctx, input.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	return httpserver.NewJsonResponse(&CancelAllTasksResponse{
+		CancelledCount: cancelledCount,
+	}), nil
+}
+
 func (h *HandlerTasks) ProcedureResultCallback(ctx context.Context, input *TaskProcedureCallbackInput) (httpserver.Response, error) {
 	callback := &TaskProcedureCallback{
 		Query:      input.Query,
@@ -174,7 +390,7 @@ func (h *HandlerTasks) ProcedureResultCallback(ctx context.Context, input *TaskP
 		ReceivedAt: DateTime{Time: time.Now().UTC()},
 	}
 
-	if err := h.serviceTasks.UpdateProcedureResult(ctx, input.Id, callback); err != nil {
+	if err := h.serviceTasks.UpdateProcedureResult(ctx, TaskClaim{TaskId: input.Id, Claim: input.Claim}, callback); err != nil {
 		return nil, err
 	}
 
@@ -193,14 +409,44 @@ func (h *HandlerTasks) TaskCounts(ctx context.Context, input *DatabaseInput) (ht
 	}), nil
 }
 
-func (h *HandlerTasks) FlushTasks(ctx context.Context, input *DatabaseInput) (httpserver.Response, error) {
-	deleted, err := h.serviceTasks.FlushTasks(ctx, input.Database)
+// FlushTasks deletes the tasks matching the optional status, kind and age filters. Without any filter
+// all tasks of the database are deleted. Deleted tasks are archived first if archival is configured.
+func (h *HandlerTasks) FlushTasks(ctx context.Context, input *FlushTasksInput) (httpserver.Response, error) {
+	for _, status := range input.Status {
+		if !slices.Contains(taskStatuses, status) {
+			return nil, fmt.Errorf("unknown task status %s", status)
+		}
+	}
+
+	for _, kind := range input.Kind {
+		if !slices.Contains(taskKinds, TaskKind(kind)) && !slices.Contains(recordedTaskKinds, TaskKind(kind)) {
+			return nil, fmt.Errorf("unknown task kind %s", kind)
+		}
+	}
+
+	if input.OlderThanDays < 0 {
+		return nil, fmt.Errorf("older_than_days must not be negative")
+	}
+
+	filter := TaskPruneFilter{
+		Database: input.Database,
+		Statuses: input.Status,
+		Kinds:    input.Kind,
+	}
+
+	if input.OlderThanDays > 0 {
+		filter.FinishedBefore = time.Now().AddDate(0, 0, -input.OlderThanDays)
+	}
+
+	result, err := h.serviceTasks.FlushTasks(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
 	return httpserver.NewJsonResponse(&FlushTasksResponse{
-		Deleted: deleted,
+		Deleted:  result.Deleted,
+		Archived: result.Archived,
+		Archives: result.Archives,
 	}), nil
 }
 
@@ -225,15 +471,10 @@ func (h *HandlerTasks) AllTaskCounts(ctx context.Context) (httpserver.Response,
 	}), nil
 }
 
-func (h *HandlerTasks) FlushAllTasks(ctx context.Context) (httpserver.Response, error) {
-	deleted, err := h.serviceTasks.FlushTasks(ctx, "")
-	if err != nil {
-		return nil, err
-	}
+func (h *HandlerTasks) FlushAllTasks(ctx context.Context, input *FlushTasksInput) (httpserver.Response, error) {
+	input.Database = ""
 
-	return httpserver.NewJsonResponse(&FlushTasksResponse{
-		Deleted: deleted,
-	}), nil
+	return h.FlushTasks(ctx, input)
 }
 
 func (h *HandlerTasks) RetryAllTasksGlobal(ctx context.Context) (httpserver.Response, error) {
@@ -246,3 +487,15 @@ func (h *HandlerTasks) RetryAllTasksGlobal(ctx context.Context) (httpserver.Resp
 		RetriedCount: retriedCount,
 	}), nil
 }
+
+func newTaskQueuedResponse(enqueued EnqueuedTask) *TaskQueuedResponse {
+	status := taskStatusQueued
+	if enqueued.Deduplicated {
+		status = statusDeduplicated
+	}
+
+	return &TaskQueuedResponse{
+		TaskId: enqueued.TaskId,
+		Status: status,
+	}
+}
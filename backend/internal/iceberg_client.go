@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,14 +19,29 @@ import (
 	gosoGlue "github.com/justtrackio/gosoline/pkg/cloud/aws/glue"
 	"github.com/justtrackio/gosoline/pkg/db"
 	"github.com/justtrackio/gosoline/pkg/log"
+	"github.com/spf13/cast"
 )
 
+// snapshotSummarySourceSnapshotID references the staged snapshot a cherry-picked snapshot replayed, as the
+// cherrypick_snapshot procedure of Iceberg does.
+const snapshotSummarySourceSnapshotID = "source-snapshot-id"
+
 const (
+	transformHour  = "hour"
 	transformDay   = "day"
 	transformMonth = "month"
 	transformYear  = "year"
 )
 
+func isTimeTransform(transform string) bool {
+	switch transform {
+	case transformHour, transformDay, transformMonth, transformYear:
+		return true
+	default:
+		return false
+	}
+}
+
 type IcebergSettings struct {
 	Catalog            string        `cfg:"catalog" default:"lakehouse"`
 	DefaultDatabase    string        `cfg:"default_database" default:"main"`
@@ -157,10 +173,482 @@ func (c *IcebergClient) listSnapshotDataFilePaths(ctx context.Context, tbl *tabl
 	return result, nil
 }
 
+// SetCurrentSnapshot points the main branch of the loaded table to the given snapshot, which can be any
+// snapshot of the table, so unlike a rollback it can also move forward again. The commit fails if the main
+// branch moved since tbl was loaded.
+func (c *IcebergClient) SetCurrentSnapshot(ctx context.Context, tbl *table.Table, snapshotID int64) error {
+	if tbl.Metadata().SnapshotByID(snapshotID) == nil {
+		return fmt.Errorf("snapshot %d not found", snapshotID)
+	}
+
+	mainRef, _ := snapshotRefByName(tbl.Metadata(), table.MainBranch)
+
+	return c.commitSnapshotRef(ctx, tbl, table.MainBranch, table.BranchRef, snapshotID, snapshotRefRetention(mainRef))
+}
+
+// CherryPickSnapshot applies a staged snapshot, e.g. one written with write-audit-publish, to the main
+// branch and returns the resulting current snapshot. Like the cherrypick_snapshot procedure of Iceberg, a
+// snapshot staged on top of the current snapshot is fast-forwarded to. The data files any other snapshot
+// added and removed are replayed onto the current snapshot in a new snapshot whose summary references the
+// staged one. Changes of delete files can not be replayed, so such snapshots are rejected.
+func (c *IcebergClient) CherryPickSnapshot(ctx context.Context, database string, logicalName string, snapshotID int64) (int64, error) {
+	tbl, err := c.LoadTable(ctx, database, logicalName)
+	if err != nil {
+		return 0, fmt.Errorf("could not load table: %w", err)
+	}
+
+	snapshot := tbl.Metadata().SnapshotByID(snapshotID)
+	if snapshot == nil {
+		return 0, fmt.Errorf("snapshot %d not found", snapshotID)
+	}
+
+	current := tbl.CurrentSnapshot()
+	if current == nil {
+		return 0, fmt.Errorf("table has no current snapshot to cherry-pick onto")
+	}
+
+	sourceSnapshotID := strconv.FormatInt(snapshotID, 10)
+	for ancestor := current; ancestor != nil; {
+		if ancestor.SnapshotID == snapshotID {
+			return 0, fmt.Errorf("snapshot %d is already part of the current table state", snapshotID)
+		}
+
+		if ancestor.Summary != nil && ancestor.Summary.Properties[snapshotSummarySourceSnapshotID] == sourceSnapshotID {
+			return 0, fmt.Errorf("snapshot %d was already cherry-picked as snapshot %d", snapshotID, ancestor.SnapshotID)
+		}
+
+		if ancestor.ParentSnapshotID == nil {
+			break
+		}
+
+		ancestor = tbl.Metadata().SnapshotByID(*ancestor.ParentSnapshotID)
+	}
+
+	if snapshot.ParentSnapshotID != nil && *snapshot.ParentSnapshotID == current.SnapshotID {
+		mainRef, _ := snapshotRefByName(tbl.Metadata(), table.MainBranch)
+		if err = c.commitSnapshotRef(ctx, tbl, table.MainBranch, table.BranchRef, snapshotID, snapshotRefRetention(mainRef)); err != nil {
+			return 0, err
+		}
+
+		return snapshotID, nil
+	}
+
+	added, removed, err := c.snapshotDataFileChanges(ctx, tbl, snapshot)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		return 0, fmt.Errorf("snapshot %d changes no data files which could be cherry-picked", snapshotID)
+	}
+
+	ctx = utils.WithAwsConfig(ctx, &c.awsCfg)
+	tx := tbl.NewTransaction()
+	props := iceberg.Properties{snapshotSummarySourceSnapshotID: sourceSnapshotID}
+
+	if err = tx.ReplaceDataFiles(ctx, removed, added, props); err != nil {
+		return 0, fmt.Errorf("could not replay the data files of snapshot %d: %w", snapshotID, err)
+	}
+
+	if tbl, err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("could not commit the cherry-pick of snapshot %d: %w", snapshotID, err)
+	}
+
+	return tbl.CurrentSnapshot().SnapshotID, nil
+}
+
+// snapshotDataFileChanges returns the paths of the data files a snapshot added and removed, read from the
+// manifests the snapshot wrote itself.
+func (c *IcebergClient) snapshotDataFileChanges(ctx context.Context, tbl *table.Table, snapshot *table.Snapshot) ([]string, []string, error) {
+	ctx = utils.WithAwsConfig(ctx, &c.awsCfg)
+	fs, err := tbl.FS(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create file io: %w", err)
+	}
+
+	manifests, err := snapshot.Manifests(fs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read manifest list of snapshot %d: %w", snapshot.SnapshotID, err)
+	}
+
+	added := make([]string, 0)
+	removed := make([]string, 0)
+
+	for _, manifest := range manifests {
+		if manifest.SnapshotID() != snapshot.SnapshotID {
+			continue
+		}
+
+		entries, err := manifest.FetchEntries(fs, false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read manifest %s: %w", manifest.FilePath(), err)
+		}
+
+		for _, entry := range entries {
+			if entry.SnapshotID() != snapshot.SnapshotID || entry.Status() == iceberg.EntryStatusEXISTING {
+				continue
+			}
+
+			file := entry.DataFile()
+			if file.ContentType() != iceberg.EntryContentData {
+				return nil, nil, fmt.Errorf("snapshot %d changes the delete file %s, only changes of data files can be cherry-picked", snapshot.SnapshotID, file.FilePath())
+			}
+
+			if entry.Status() == iceberg.EntryStatusADDED {
+				added = append(added, file.FilePath())
+			} else {
+				removed = append(removed, file.FilePath())
+			}
+		}
+	}
+
+	return added, removed, nil
+}
+
+// ListSnapshotRefs returns the branches and tags of a table.
+func (c *IcebergClient) ListSnapshotRefs(ctx context.Context, database string, logicalName string) ([]SnapshotRef, error) {
+	tbl, err := c.LoadTable(ctx, database, logicalName)
+	if err != nil {
+		return nil, fmt.Errorf("could not load table: %w", err)
+	}
+
+	return snapshotRefsFromMetadata(tbl.Metadata()), nil
+}
+
+// SetSnapshotRef creates the branch or tag ref pointing to snapshotID. With replace an existing ref of the
+// same type is moved to snapshotID instead and its retention settings are replaced.
+func (c *IcebergClient) SetSnapshotRef(ctx context.Context, database string, logicalName string, ref string, refType table.RefType, snapshotID int64, retention SnapshotRefRetention, replace bool) error {
+	tbl, err := c.LoadTable(ctx, database, logicalName)
+	if err != nil {
+		return fmt.Errorf("could not load table: %w", err)
+	}
+
+	var existing *table.SnapshotRef
+	if current, ok := snapshotRefByName(tbl.Metadata(), ref); ok {
+		existing = &current
+	}
+
+	if err = checkSetSnapshotRef(existing, ref, refType, retention, replace); err != nil {
+		return err
+	}
+
+	if tbl.Metadata().SnapshotByID(snapshotID) == nil {
+		return fmt.Errorf("snapshot %d not found: %w", snapshotID, errSnapshotRefRejected)
+	}
+
+	return c.commitSnapshotRef(ctx, tbl, ref, refType, snapshotID, retention)
+}
+
+// RemoveSnapshotRef drops the branch or tag ref. The snapshots are kept until they are expired.
+func (c *IcebergClient) RemoveSnapshotRef(ctx context.Context, database string, logicalName string, ref string, refType table.RefType) error {
+	tbl, err := c.LoadTable(ctx, database, logicalName)
+	if err != nil {
+		return fmt.Errorf("could not load table: %w", err)
+	}
+
+	if ref == table.MainBranch {
+		return fmt.Errorf("the %s branch can not be dropped: %w", table.MainBranch, errSnapshotRefRejected)
+	}
+
+	current, ok := snapshotRefByName(tbl.Metadata(), ref)
+	if !ok || current.SnapshotRefType != refType {
+		return fmt.Errorf("%s %s does not exist: %w", refType, ref, errSnapshotRefRejected)
+	}
+
+	requirements := []table.Requirement{table.AssertRefSnapshotID(ref, &current.SnapshotID)}
+	updates := []table.Update{table.NewRemoveSnapshotRefUpdate(ref)}
+
+	ctx = utils.WithAwsConfig(ctx, &c.awsCfg)
+	if _, _, err = c.catalog.CommitTable(ctx, tbl.Identifier(), requirements, updates); err != nil {
+		return fmt.Errorf("could not drop %s %s: %w", refType, ref, err)
+	}
+
+	return nil
+}
+
+// FastForwardBranch moves branch to the snapshot ref to points to. This is only possible if the head of
+// branch is an ancestor of it, the retention settings of branch are kept. It returns the new head.
+func (c *IcebergClient) FastForwardBranch(ctx context.Context, database string, logicalName string, branch string, to string) (int64, error) {
+	tbl, err := c.LoadTable(ctx, database, logicalName)
+	if err != nil {
+		return 0, fmt.Errorf("could not load table: %w", err)
+	}
+
+	current, ok := snapshotRefByName(tbl.Metadata(), branch)
+	if !ok || current.SnapshotRefType != table.BranchRef {
+		return 0, fmt.Errorf("branch %s does not exist: %w", branch, errSnapshotRefRejected)
+	}
+
+	target, ok := snapshotRefByName(tbl.Metadata(), to)
+	if !ok {
+		return 0, fmt.Errorf("ref %s does not exist: %w", to, errSnapshotRefRejected)
+	}
+
+	if err = checkFastForward(lineageFromIcebergSnapshots(tbl.Metadata().Snapshots()), current.SnapshotID, target.SnapshotID); err != nil {
+		return 0, err
+	}
+
+	if current.SnapshotID == target.SnapshotID {
+		return current.SnapshotID, nil
+	}
+
+	if err = c.commitSnapshotRef(ctx, tbl, branch, table.BranchRef, target.SnapshotID, snapshotRefRetention(current)); err != nil {
+		return 0, err
+	}
+
+	return target.SnapshotID, nil
+}
+
+// commitSnapshotRef points ref to snapshotID. The commit fails if ref was moved since the table was loaded.
+func (c *IcebergClient) commitSnapshotRef(ctx context.Context, tbl *table.Table, ref string, refType table.RefType, snapshotID int64, retention SnapshotRefRetention) error {
+	var expectedSnapshotID *int64
+	if current := tbl.Metadata().SnapshotByName(ref); current != nil {
+		expectedSnapshotID = &current.SnapshotID
+	}
+
+	maxRefAgeMs, maxSnapshotAgeMs, minSnapshotsToKeep := int64(-1), int64(-1), -1
+	if retention.MaxRefAgeMs != nil {
+		maxRefAgeMs = *retention.MaxRefAgeMs
+	}
+
+	if retention.MaxSnapshotAgeMs != nil {
+		maxSnapshotAgeMs = *retention.MaxSnapshotAgeMs
+	}
+
+	if retention.MinSnapshotsToKeep != nil {
+		minSnapshotsToKeep = *retention.MinSnapshotsToKeep
+	}
+
+	requirements := []table.Requirement{table.AssertRefSnapshotID(ref, expectedSnapshotID)}
+	updates := []table.Update{table.NewSetSnapshotRefUpdate(ref, snapshotID, refType, maxRefAgeMs, maxSnapshotAgeMs, minSnapshotsToKeep)}
+
+	ctx = utils.WithAwsConfig(ctx, &c.awsCfg)
+	if _, _, err := c.catalog.CommitTable(ctx, tbl.Identifier(), requirements, updates); err != nil {
+		return fmt.Errorf("could not point %s to snapshot %d: %w", ref, snapshotID, err)
+	}
+
+	return nil
+}
+
+// ListSnapshotStates returns the live data and delete files of each of the given snapshots together with
+// the columns of the schema the snapshot was written with. The files are collected by reading every manifest
+// of the snapshots, so unlike a scan this includes delete files no data file references anymore.
+func (c *IcebergClient) ListSnapshotStates(ctx context.Context, database string, logicalName string, snapshotIDs ...int64) ([]IcebergSnapshotState, error) {
+	tbl, err := c.LoadTable(ctx, database, logicalName)
+	if err != nil {
+		return nil, fmt.Errorf("could not load table: %w", err)
+	}
+
+	states := make([]IcebergSnapshotState, 0, len(snapshotIDs))
+	for _, snapshotID := range snapshotIDs {
+		state, err := c.listSnapshotState(ctx, tbl, snapshotID)
+		if err != nil {
+			return nil, err
+		}
+
+		states = append(states, *state)
+	}
+
+	return states, nil
+}
+
+func (c *IcebergClient) listSnapshotState(ctx context.Context, tbl *table.Table, snapshotID int64) (*IcebergSnapshotState, error) {
+	metadata := tbl.Metadata()
+
+	snapshot := metadata.SnapshotByID(snapshotID)
+	if snapshot == nil {
+		return nil, fmt.Errorf("snapshot %d not found", snapshotID)
+	}
+
+	schema := metadata.CurrentSchema()
+	if snapshot.SchemaID != nil {
+		for _, candidate := range metadata.Schemas() {
+			if candidate.ID == *snapshot.SchemaID {
+				schema = candidate
+
+				break
+			}
+		}
+	}
+
+	state := &IcebergSnapshotState{
+		SnapshotID:     snapshotID,
+		SchemaID:       schema.ID,
+		Columns:        make(map[string]string),
+		PartitionSpecs: make(map[int32]string),
+	}
+
+	for _, field := range schema.Fields() {
+		state.Columns[field.Name] = c.formatType(field.Type)
+	}
+
+	ctx = utils.WithAwsConfig(ctx, &c.awsCfg)
+	fs, err := tbl.FS(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create file io: %w", err)
+	}
+
+	manifests, err := snapshot.Manifests(fs)
+	if err != nil {
+		return nil, fmt.Errorf("could not read manifest list of snapshot %d: %w", snapshotID, err)
+	}
+
+	seen := make(map[string]struct{})
+	for _, manifest := range manifests {
+		entries, err := manifest.FetchEntries(fs, true)
+		if err != nil {
+			return nil, fmt.Errorf("could not read manifest %s: %w", manifest.FilePath(), err)
+		}
+
+		for _, entry := range entries {
+			file := entry.DataFile()
+			if _, ok := seen[file.FilePath()]; ok {
+				continue
+			}
+
+			seen[file.FilePath()] = struct{}{}
+
+			spec := c.partitionSpecByID(metadata, int(file.SpecID()))
+			if _, ok := state.PartitionSpecs[file.SpecID()]; !ok {
+				state.PartitionSpecs[file.SpecID()] = c.describePartitionSpec(spec, schema)
+			}
+
+			state.Files = append(state.Files, IcebergSnapshotFile{
+				Path:        file.FilePath(),
+				Content:     dataFileContent(file),
+				Partition:   c.normalizePartitionForBrowse(file.Partition(), spec, schema),
+				SpecID:      file.SpecID(),
+				RecordCount: file.Count(),
+				SizeBytes:   file.FileSizeBytes(),
+			})
+		}
+	}
+
+	return state, nil
+}
+
+// ListTableObjects returns every object referenced by the current metadata of a table: the metadata file
+// itself and, for all snapshots, the manifest list, the manifests and the live data and delete files of
+// the manifests. Unlike a scan this includes delete files no data file references anymore. Manifests
+// shared between snapshots are only read once.
+func (c *IcebergClient) ListTableObjects(ctx context.Context, database string, logicalName string) (*IcebergTableObjects, error) {
+	tbl, err := c.LoadTable(ctx, database, logicalName)
+	if err != nil {
+		return nil, fmt.Errorf("could not load table: %w", err)
+	}
+
+	ctx = utils.WithAwsConfig(ctx, &c.awsCfg)
+	fs, err := tbl.FS(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create file io: %w", err)
+	}
+
+	objects := &IcebergTableObjects{Objects: make([]IcebergTableObject, 0)}
+	seen := make(map[string]struct{})
+	addObject := func(path string, content string, sizeBytes *int64) bool {
+		if _, ok := seen[path]; ok || path == "" {
+			return false
+		}
+
+		seen[path] = struct{}{}
+		objects.Objects = append(objects.Objects, IcebergTableObject{Path: path, Content: content, SizeBytes: sizeBytes})
+
+		return true
+	}
+
+	addObject(tbl.MetadataLocation(), tableObjectContentMetadata, nil)
+
+	for _, snapshot := range tbl.Metadata().Snapshots() {
+		objects.SnapshotCount++
+		addObject(snapshot.ManifestList, tableObjectContentManifestList, nil)
+
+		manifests, err := snapshot.Manifests(fs)
+		if err != nil {
+			return nil, fmt.Errorf("could not read manifest list of snapshot %d: %w", snapshot.SnapshotID, err)
+		}
+
+		for _, manifest := range manifests {
+			length := manifest.Length()
+			if !addObject(manifest.FilePath(), tableObjectContentManifest, &length) {
+				continue
+			}
+
+			entries, err := manifest.FetchEntries(fs, true)
+			if err != nil {
+				return nil, fmt.Errorf("could not read manifest %s: %w", manifest.FilePath(), err)
+			}
+
+			for _, entry := range entries {
+				file := entry.DataFile()
+				sizeBytes := file.FileSizeBytes()
+				addObject(file.FilePath(), dataFileContent(file), &sizeBytes)
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+func dataFileContent(file iceberg.DataFile) string {
+	switch file.ContentType() {
+	case iceberg.EntryContentPosDeletes:
+		return snapshotFileContentPositionDeletes
+	case iceberg.EntryContentEqDeletes:
+		return snapshotFileContentEqualityDeletes
+	default:
+		return snapshotFileContentData
+	}
+}
+
+func (c *IcebergClient) partitionSpecByID(metadata table.Metadata, specID int) *iceberg.PartitionSpec {
+	specs := metadata.PartitionSpecs()
+	for i := range specs {
+		if specs[i].ID() == specID {
+			return &specs[i]
+		}
+	}
+
+	return c.getDefaultPartitionSpec(metadata)
+}
+
+func (c *IcebergClient) describePartitionSpec(spec *iceberg.PartitionSpec, schema *iceberg.Schema) string {
+	if spec == nil {
+		return "unpartitioned"
+	}
+
+	fields := make([]string, 0)
+	for pf := range spec.Fields() {
+		sourceColumnName, ok := c.findSourceColumnName(schema, pf.SourceID)
+		if !ok {
+			sourceColumnName = fmt.Sprintf("field_%d", pf.SourceID)
+		}
+
+		fields = append(fields, fmt.Sprintf("%s(%s)", pf.Transform.String(), sourceColumnName))
+	}
+
+	if len(fields) == 0 {
+		return "unpartitioned"
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+// partitionDeleteFile identifies a delete file within the partition it is counted for.
+type partitionDeleteFile struct {
+	partition string
+	path      string
+}
+
 // ListPartitions returns partition stats with browse-compatible keys
 // that match the TableDescription.Partitions names (year, month, day for time transforms,
 // or column name for identity transforms).
 func (c *IcebergClient) ListPartitions(ctx context.Context, database string, logicalName string) ([]IcebergPartitionStats, error) {
+	return c.ListPartitionsMatching(ctx, database, logicalName, iceberg.AlwaysTrue{})
+}
+
+// ListPartitionsMatching is ListPartitions for the files selected by rowFilter. The filter prunes manifests and
+// files by their partition values and column bounds, so partitions only partially matching it are incomplete.
+func (c *IcebergClient) ListPartitionsMatching(ctx context.Context, database string, logicalName string, rowFilter iceberg.BooleanExpression) ([]IcebergPartitionStats, error) {
 	tbl, err := c.LoadTable(ctx, database, logicalName)
 	if err != nil {
 		return nil, fmt.Errorf("could not load table: %w", err)
@@ -176,8 +664,11 @@ func (c *IcebergClient) ListPartitions(ctx context.Context, database string, log
 	schema := metadata.CurrentSchema()
 
 	partitionMap := make(map[string]*IcebergPartitionStats)
+	// delete files apply to several data files and are thus part of several scan tasks. They are counted once
+	// per partition, so a global equality delete file of an unpartitioned spec is reported in every partition.
+	seenDeleteFiles := make(map[partitionDeleteFile]struct{})
 
-	scanner := tbl.Scan()
+	scanner := tbl.Scan(table.WithRowFilter(rowFilter))
 
 	ctx = utils.WithAwsConfig(ctx, &c.awsCfg)
 	tasks, err := scanner.PlanFiles(ctx)
@@ -199,6 +690,7 @@ func (c *IcebergClient) ListPartitions(ctx context.Context, database string, log
 				SpecID:         file.SpecID(),
 				RecordCount:    0,
 				Files:          make(IcebergPartitionStatsFiles, 0),
+				DeleteFiles:    make(IcebergPartitionStatsFiles, 0),
 				LastUpdatedAt:  currentSnapshot.TimestampMs,
 				LastSnapshotID: currentSnapshot.SnapshotID,
 			}
@@ -209,6 +701,18 @@ func (c *IcebergClient) ListPartitions(ctx context.Context, database string, log
 		stats.Files = append(stats.Files, IcebergPartitionFileStats{
 			SizeBytes: file.FileSizeBytes(),
 		})
+
+		for _, deleteFile := range task.DeleteFiles {
+			seenKey := partitionDeleteFile{partition: partitionKey, path: deleteFile.FilePath()}
+			if _, seen := seenDeleteFiles[seenKey]; seen {
+				continue
+			}
+
+			seenDeleteFiles[seenKey] = struct{}{}
+			stats.DeleteFiles = append(stats.DeleteFiles, IcebergPartitionFileStats{
+				SizeBytes: deleteFile.FileSizeBytes(),
+			})
+		}
 	}
 
 	result := make([]IcebergPartitionStats, 0, len(partitionMap))
@@ -292,6 +796,19 @@ func (c *IcebergClient) normalizePartitionForBrowse(rawPartition map[int]any, sp
 		switch transform {
 		case "identity":
 			result[sourceColumnName] = val
+		case transformHour:
+			hours, err := cast.ToInt64E(val)
+			if err != nil {
+				result[pf.Name] = val
+
+				continue
+			}
+
+			t := time.Unix(hours*3600, 0).UTC()
+			result["year"] = t.Format("2006")
+			result["month"] = t.Format("01")
+			result["day"] = t.Format("02")
+			result["hour"] = t.Format("15")
 		case transformDay:
 			t := val.(iceberg.Date).ToTime()
 			result["year"] = t.Format("2006")
@@ -326,6 +843,15 @@ func (c *IcebergClient) findSourceColumnName(schema *iceberg.Schema, sourceID in
 	return sourceField.Name, true
 }
 
+func (c *IcebergClient) findSourceColumnType(schema *iceberg.Schema, sourceID int) string {
+	sourceField, ok := schema.FindFieldByID(sourceID)
+	if !ok {
+		return ""
+	}
+
+	return sourceField.Type.String()
+}
+
 func (c *IcebergClient) ListTables(ctx context.Context, database string) ([]table.Identifier, error) {
 	var err error
 	var t table.Identifier
@@ -367,11 +893,23 @@ func (c *IcebergClient) DescribeTable(ctx context.Context, database string, logi
 		return nil, fmt.Errorf("could not extract partitions: %w", err)
 	}
 
+	sortOrder, err := c.extractSortOrder(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("could not extract sort order: %w", err)
+	}
+
+	properties := make(map[string]string, len(metadata.Properties()))
+	for key, value := range metadata.Properties() {
+		properties[key] = value
+	}
+
 	desc := &TableDescription{
 		Database:          database,
 		Name:              logicalName,
 		Columns:           columns,
 		Partitions:        partitions,
+		SortOrder:         sortOrder,
+		Properties:        db.NewJSON(properties, db.NonNullable{}),
 		CurrentSnapshotID: nil,
 		UpdatedAt:         time.Now(),
 	}
@@ -455,26 +993,65 @@ func (c *IcebergClient) extractPartitions(metadata table.Metadata) (db.JSON[[]Ta
 			return db.NewJSON(partitions, db.NonNullable{}), fmt.Errorf("could not find source field with id %d for partition field %s", pf.SourceID, pf.Name)
 		}
 
-		switch pf.Transform.String() {
-		case transformDay, transformMonth, transformYear:
-			partitions = append(partitions, c.expandTimeTransform(pf.Transform.String(), sourceColumnName, pf.Name)...)
-		case "identity":
+		transform := pf.Transform.String()
+
+		switch {
+		case isTimeTransform(transform):
+			partitions = append(partitions, c.expandTimeTransform(transform, sourceColumnName, pf.Name)...)
+		case transform == "identity":
 			partitions = append(partitions, TablePartition{
 				Name:         sourceColumnName,
 				RawFieldName: pf.Name,
 				IsHidden:     false,
 				Hidden:       TablePartitionHidden{},
+				SourceType:   c.findSourceColumnType(schema, pf.SourceID),
+			})
+		case transform == "void", strings.HasPrefix(transform, "bucket["), strings.HasPrefix(transform, "truncate["):
+			// browse keys of these transforms are the partition field names, see normalizePartitionForBrowse
+			partitions = append(partitions, TablePartition{
+				Name:         pf.Name,
+				RawFieldName: pf.Name,
+				IsHidden:     true,
+				Hidden:       TablePartitionHidden{Column: sourceColumnName, Type: transform},
 			})
 		default:
-			return db.NewJSON(partitions, db.NonNullable{}), fmt.Errorf("unknown partition transformer type: %s", pf.Transform.String())
+			return db.NewJSON(partitions, db.NonNullable{}), fmt.Errorf("unknown partition transformer type: %s", transform)
 		}
 	}
 
 	return db.NewJSON(partitions, db.NonNullable{}), nil
 }
 
+func (c *IcebergClient) extractSortOrder(metadata table.Metadata) (db.JSON[[]TableSortField, db.NonNullable], error) {
+	fields := make([]TableSortField, 0)
+	schema := metadata.CurrentSchema()
+
+	for sf := range metadata.SortOrder().Fields() {
+		sourceColumnName, found := c.findSourceColumnName(schema, sf.SourceID)
+		if !found {
+			return db.NewJSON(fields, db.NonNullable{}), fmt.Errorf("could not find source field with id %d for sort field", sf.SourceID)
+		}
+
+		fields = append(fields, TableSortField{
+			Column:    sourceColumnName,
+			Transform: sf.Transform.String(),
+			Direction: string(sf.Direction),
+			NullOrder: string(sf.NullOrder),
+		})
+	}
+
+	return db.NewJSON(fields, db.NonNullable{}), nil
+}
+
 func (c *IcebergClient) expandTimeTransform(transform, sourceCol, rawFieldName string) []TablePartition {
 	switch transform {
+	case transformHour:
+		return []TablePartition{
+			{Name: "year", RawFieldName: rawFieldName, IsHidden: true, Hidden: TablePartitionHidden{Column: sourceCol, Type: transformHour}},
+			{Name: "month", RawFieldName: rawFieldName, IsHidden: true, Hidden: TablePartitionHidden{Column: sourceCol, Type: transformHour}},
+			{Name: "day", RawFieldName: rawFieldName, IsHidden: true, Hidden: TablePartitionHidden{Column: sourceCol, Type: transformHour}},
+			{Name: "hour", RawFieldName: rawFieldName, IsHidden: true, Hidden: TablePartitionHidden{Column: sourceCol, Type: transformHour}},
+		}
 	case transformDay:
 		return []TablePartition{
 			{Name: "year", RawFieldName: rawFieldName, IsHidden: true, Hidden: TablePartitionHidden{Column: sourceCol, Type: transformDay}},
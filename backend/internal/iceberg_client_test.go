@@ -2,6 +2,7 @@ package internal
 
 import (
 	"iter"
+	"maps"
 	"testing"
 
 	iceberg "github.com/apache/iceberg-go"
@@ -88,6 +89,33 @@ func TestExtractPartitionsUsesNestedIdentityColumnName(t *testing.T) {
 	require.Equal(t, []TablePartition{{Name: "goal.conversionHappenedAt", RawFieldName: "goal_conversion_happened_at", IsHidden: false, Hidden: TablePartitionHidden{}}}, partitions.Get())
 }
 
+func TestExtractPartitionsSupportsHourAndBucketTransforms(t *testing.T) {
+	client := &IcebergClient{}
+	schema := iceberg.NewSchema(1,
+		iceberg.NestedField{ID: 1, Name: "created_at", Type: iceberg.PrimitiveTypes.Timestamp},
+		iceberg.NestedField{ID: 2, Name: "user_id", Type: iceberg.PrimitiveTypes.Int64},
+	)
+	spec := iceberg.NewPartitionSpec(
+		iceberg.PartitionField{SourceID: 1, FieldID: 1000, Name: "created_at_hour", Transform: iceberg.HourTransform{}},
+		iceberg.PartitionField{SourceID: 2, FieldID: 1001, Name: "user_id_bucket", Transform: iceberg.BucketTransform{NumBuckets: 16}},
+	)
+	metadata := &testTableMetadata{schema: schema, specs: []iceberg.PartitionSpec{spec}}
+
+	partitions, err := client.extractPartitions(metadata)
+	require.NoError(t, err)
+	require.Equal(t, []TablePartition{
+		{Name: "year", RawFieldName: "created_at_hour", IsHidden: true, Hidden: TablePartitionHidden{Column: "created_at", Type: transformHour}},
+		{Name: "month", RawFieldName: "created_at_hour", IsHidden: true, Hidden: TablePartitionHidden{Column: "created_at", Type: transformHour}},
+		{Name: "day", RawFieldName: "created_at_hour", IsHidden: true, Hidden: TablePartitionHidden{Column: "created_at", Type: transformHour}},
+		{Name: "hour", RawFieldName: "created_at_hour", IsHidden: true, Hidden: TablePartitionHidden{Column: "created_at", Type: transformHour}},
+		{Name: "user_id_bucket", RawFieldName: "user_id_bucket", IsHidden: true, Hidden: TablePartitionHidden{Column: "user_id", Type: "bucket[16]"}},
+	}, partitions.Get())
+
+	partition := client.normalizePartitionForBrowse(map[int]any{1000: int32(492013), 1001: int32(3)}, &spec, schema)
+
+	require.Equal(t, PartitionValues{"year": "2026", "month": "02", "day": "16", "hour": "13", "user_id_bucket": int32(3)}, PartitionValues(partition))
+}
+
 func TestPartitionJSONPathExprQuotesLiteralKeys(t *testing.T) {
 	require.Equal(t, `p.partition->>'$."goal.conversionHappenedAt"'`, partitionJSONPathExpr("goal.conversionHappenedAt", true))
 	require.Equal(t, `p.partition->'$."goal.conversionHappenedAt"'`, partitionJSONPathExpr("goal.conversionHappenedAt", false))
@@ -96,6 +124,7 @@ func TestPartitionJSONPathExprQuotesLiteralKeys(t *testing.T) {
 type testTableMetadata struct {
 	schema *iceberg.Schema
 	specs  []iceberg.PartitionSpec
+	refs   map[string]table.SnapshotRef
 }
 
 func (m *testTableMetadata) Version() int                            { return 0 }
@@ -123,7 +152,7 @@ func (m *testTableMetadata) SnapshotByName(string) *table.Snapshot { return nil
 func (m *testTableMetadata) CurrentSnapshot() *table.Snapshot      { return nil }
 func (m *testTableMetadata) Ref() table.SnapshotRef                { return table.SnapshotRef{} }
 func (m *testTableMetadata) Refs() iter.Seq2[string, table.SnapshotRef] {
-	return func(func(string, table.SnapshotRef) bool) {}
+	return maps.All(m.refs)
 }
 
 func (m *testTableMetadata) SnapshotLogs() iter.Seq[table.SnapshotLogEntry] {
@@ -10,39 +10,80 @@ import (
 type TaskKind string
 
 const (
-	TaskKindExpireSnapshots   TaskKind = "expire_snapshots"
-	TaskKindRemoveOrphanFiles TaskKind = "remove_orphan_files"
-	TaskKindOptimize          TaskKind = "optimize"
+	TaskKindExpireSnapshots            TaskKind = "expire_snapshots"
+	TaskKindRemoveOrphanFiles          TaskKind = "remove_orphan_files"
+	TaskKindOptimize                   TaskKind = "optimize"
+	TaskKindRewriteManifests           TaskKind = "rewrite_manifests"
+	TaskKindRewritePositionDeleteFiles TaskKind = "rewrite_position_delete_files"
+	TaskKindCheckFileIntegrity         TaskKind = "check_file_integrity"
+
+	// TaskKindRollback and TaskKindSetCurrentSnapshot tasks are only recorded for auditing after the
+	// current snapshot was changed through the api, they are never queued and therefore not part of taskKindDescriptors.
+	TaskKindRollback           TaskKind = "rollback"
+	TaskKindSetCurrentSnapshot TaskKind = "set_current_snapshot"
 )
 
+var recordedTaskKinds = []TaskKind{
+	TaskKindRollback,
+	TaskKindSetCurrentSnapshot,
+}
+
 type TaskEngine string
 
 const (
 	TaskEngineTrino TaskEngine = "trino"
 	TaskEngineSpark TaskEngine = "spark"
+
+	// TaskEngineCatalog is only used for recorded tasks which were committed directly to the iceberg catalog.
+	TaskEngineCatalog TaskEngine = "catalog"
 )
 
 // TaskClaimer abstracts task queue operations used by the task worker.
 type TaskClaimer interface {
 	ClaimTask(ctx context.Context) (*Task, error)
-	UpdateTaskResult(ctx context.Context, id int64, result map[string]any) error
-	UpdateTaskResultNested(ctx context.Context, id int64, key string, result map[string]any) error
-	CompleteTask(ctx context.Context, id int64, result map[string]any, err error) error
+	UpdateTaskResult(ctx context.Context, claim TaskClaim, result map[string]any) error
+	UpdateTaskResultNested(ctx context.Context, claim TaskClaim, key string, result map[string]any) error
+	CompleteTask(ctx context.Context, claim TaskClaim, result map[string]any, err error) error
+	HeartbeatTasks(ctx context.Context, claims ...TaskClaim) error
+	ListRunningTasks(ctx context.Context, engine TaskEngine) ([]Task, error)
+	ReapExpiredTasks(ctx context.Context, stop func(ctx context.Context, task *Task) error) (int, error)
+	RunTableRefreshes(ctx context.Context) error
 }
 
 type MaintenanceExecutor interface {
 	Engine() TaskEngine
 	Run(ctx context.Context) error
 	ProcessTask(ctx context.Context, task *Task) error
+	// CancelTask stops the engine work of a running task which has already been marked as cancelled.
+	CancelTask(ctx context.Context, task *Task) error
+}
+
+// MaintenanceExecutorHealthChecker is implemented by executors able to check whether their engine is reachable.
+type MaintenanceExecutorHealthChecker interface {
+	HealthCheck(ctx context.Context) error
 }
 
 type SparkApplicationCreator interface {
 	CreateSparkApplication(ctx context.Context, manifest *SparkApplicationManifest) (*SparkApplicationManifest, error)
 	DeleteSparkApplication(ctx context.Context, namespace string, name string) error
 	WatchSparkApplications(ctx context.Context) (cache.SharedIndexInformer, error)
+	CheckSparkApplicationAccess(ctx context.Context) error
+}
+
+// TaskArchiver persists pruned tasks before they are deleted and returns the location they were written to.
+type TaskArchiver interface {
+	Archive(ctx context.Context, tasks []Task) (string, error)
 }
 
 // SnapshotRefresher abstracts the snapshot refresh operation.
 type SnapshotRefresher interface {
 	RefreshSnapshots(cttx sqlc.Tx, database string, table string) ([]Snapshot, error)
 }
+
+// TableRefresher abstracts the refresh operations of the stored state of a single table.
+type TableRefresher interface {
+	SnapshotRefresher
+	RefreshTable(cttx sqlc.Tx, database string, table string) (*TableDescription, error)
+	RefreshPartitions(cttx sqlc.Tx, database string, table string) ([]Partition, error)
+	RefreshPartitionsInScope(cttx sqlc.Tx, database string, table string, scope partitionRefreshScope) ([]Partition, error)
+}
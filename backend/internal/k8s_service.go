@@ -156,6 +156,16 @@ func (s *K8sService) DeleteSparkApplication(ctx context.Context, namespace strin
 	return fmt.Errorf("could not delete spark application %s/%s: %w", namespace, name, err)
 }
 
+// CheckSparkApplicationAccess verifies that spark applications can be listed in the namespace of the service.
+func (s *K8sService) CheckSparkApplicationAccess(ctx context.Context) error {
+	gvr := schema.GroupVersionResource{Group: "spark.apache.org", Version: "v1", Resource: "sparkapplications"}
+	if _, err := s.dynamicClient.Resource(gvr).Namespace(s.namespace).List(ctx, metav1.ListOptions{Limit: 1}); err != nil {
+		return fmt.Errorf("could not list spark applications in namespace %s: %w", s.namespace, err)
+	}
+
+	return nil
+}
+
 func (s *K8sService) WatchSparkApplications(ctx context.Context) (cache.SharedIndexInformer, error) {
 	gvr := schema.GroupVersionResource{Group: "spark.apache.org", Version: "v1", Resource: "sparkapplications"}
 	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(s.dynamicClient, time.Minute, s.namespace, nil)
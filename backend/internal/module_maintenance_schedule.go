@@ -61,7 +61,7 @@ func (m *ModuleMaintenanceSchedule) runMaintenanceCycle(ctx context.Context) {
 
 	m.logger.Info(
 		ctx,
-		"finished scheduled maintenance cycle for %d tables (optimize: %d tasks, %d failures; expire_snapshots: %d tasks, %d failures; remove_orphan_files: %d tasks, %d failures)",
+		"finished scheduled maintenance cycle for %d tables (optimize: %d tasks, %d failures; expire_snapshots: %d tasks, %d failures; remove_orphan_files: %d tasks, %d failures; %d deduplicated)",
 		result.TableCount,
 		result.OptimizeTaskCount,
 		result.OptimizeFailureCount,
@@ -69,5 +69,6 @@ func (m *ModuleMaintenanceSchedule) runMaintenanceCycle(ctx context.Context) {
 		result.ExpireSnapshotsFailureCount,
 		result.RemoveOrphanFilesTaskCount,
 		result.RemoveOrphanFilesFailureCount,
+		result.DeduplicatedTaskCount,
 	)
 }
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/justtrackio/gosoline/pkg/cfg"
+	"github.com/justtrackio/gosoline/pkg/kernel"
+	"github.com/justtrackio/gosoline/pkg/log"
+)
+
+func NewModuleTaskHistory(ctx context.Context, config cfg.Config, logger log.Logger) (kernel.Module, error) {
+	var err error
+	var service *ServiceTaskHistory
+	var settings *TaskHistorySettings
+
+	if service, err = NewServiceTaskHistory(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create task history service: %w", err)
+	}
+
+	if settings, err = ReadTaskHistorySettings(config); err != nil {
+		return nil, fmt.Errorf("could not read task history settings: %w", err)
+	}
+
+	return &ModuleTaskHistory{
+		logger:   logger.WithChannel("task_history"),
+		service:  service,
+		settings: settings,
+	}, nil
+}
+
+type ModuleTaskHistory struct {
+	kernel.ServiceStage
+	kernel.BackgroundModule
+
+	logger   log.Logger
+	service  *ServiceTaskHistory
+	settings *TaskHistorySettings
+}
+
+func (m *ModuleTaskHistory) Run(ctx context.Context) error {
+	if !m.settings.Enabled {
+		return nil
+	}
+
+	return runCronLoop(ctx, m.logger, "task history", m.settings.Cron, m.enforceRetention)
+}
+
+func (m *ModuleTaskHistory) enforceRetention(ctx context.Context) {
+	m.logger.Info(ctx, "starting task history retention")
+
+	var err error
+	var result *TaskPruneResult
+
+	if result, err = m.service.EnforceRetention(ctx, time.Now().UTC()); err != nil {
+		m.logger.Error(ctx, "failed task history retention: %s", err)
+
+		return
+	}
+
+	m.logger.Info(ctx, "finished task history retention: deleted %d tasks, archived %d tasks to %d archives", result.Deleted, result.Archived, len(result.Archives))
+}
@@ -29,12 +29,13 @@ func NewModuleTasks(ctx context.Context, config cfg.Config, logger log.Logger) (
 	var err error
 	var serviceTaskQueue *ServiceTaskQueue
 	var serviceMaintenanceExecutor *ServiceMaintenanceExecutor
+	var leaseSettings *TaskLeaseSettings
 
 	if serviceTaskQueue, err = NewServiceTaskQueue(ctx, config, logger); err != nil {
 		return nil, fmt.Errorf("could not create task queue service: %w", err)
 	}
 
-	if serviceMaintenanceExecutor, err = NewServiceMaintenanceExecutor(ctx, config, logger); err != nil {
+	if serviceMaintenanceExecutor, err = ProvideServiceMaintenanceExecutor(ctx, config, logger); err != nil {
 		return nil, fmt.Errorf("could not create maintenance executor service: %w", err)
 	}
 
@@ -43,11 +44,16 @@ func NewModuleTasks(ctx context.Context, config cfg.Config, logger log.Logger) (
 		return nil, fmt.Errorf("could not unmarshal tasks settings: %w", err)
 	}
 
+	if leaseSettings, err = ReadTaskLeaseSettings(config); err != nil {
+		return nil, fmt.Errorf("could not read task lease settings: %w", err)
+	}
+
 	module := &ModuleTasks{
 		logger:                     logger.WithChannel("task_worker"),
 		serviceTaskQueue:           serviceTaskQueue,
 		serviceMaintenanceExecutor: serviceMaintenanceExecutor,
 		settings:                   settings,
+		leaseSettings:              leaseSettings,
 	}
 
 	return module, nil
@@ -63,7 +69,8 @@ type ModuleTasks struct {
 		All() []MaintenanceExecutor
 		ForEngine(TaskEngine) (MaintenanceExecutor, error)
 	}
-	settings *TaskSettings
+	settings      *TaskSettings
+	leaseSettings *TaskLeaseSettings
 }
 
 func (m *ModuleTasks) Run(ctx context.Context) error {
@@ -96,9 +103,48 @@ func (m *ModuleTasks) Run(ctx context.Context) error {
 		}
 	})
 
+	cfn.GoWithContext(ctx, m.runReaper)
+	cfn.GoWithContext(ctx, m.serviceTaskQueue.RunTableRefreshes)
+
 	return cfn.Wait()
 }
 
+func (m *ModuleTasks) runReaper(ctx context.Context) error {
+	ticker := time.NewTicker(m.leaseSettings.ReaperInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			reaped, err := m.serviceTaskQueue.ReapExpiredTasks(ctx, m.stopTask)
+			if err != nil {
+				m.logger.Error(ctx, "failed to reap tasks with expired leases: %s", err)
+
+				continue
+			}
+
+			if reaped > 0 {
+				m.logger.Info(ctx, "reaped %d tasks with expired leases", reaped)
+			}
+		}
+	}
+}
+
+// stopTask stops the engine work of a task whose lease expired. Tasks of engines which are not registered
+// anymore have no engine work this process could stop.
+func (m *ModuleTasks) stopTask(ctx context.Context, task *Task) error {
+	executor, err := m.serviceMaintenanceExecutor.ForEngine(TaskEngine(task.Engine))
+	if err != nil {
+		m.logger.Warn(ctx, "can not stop task %d with expired lease: %s", task.Id, err)
+
+		return nil
+	}
+
+	return executor.CancelTask(ctx, task)
+}
+
 func (m *ModuleTasks) tryProcessTasks(ctx context.Context, cfn coffin.Coffin) {
 	for {
 		task, err := m.serviceTaskQueue.ClaimTask(ctx)
@@ -127,16 +173,19 @@ func (m *ModuleTasks) tryProcessTasks(ctx context.Context, cfn coffin.Coffin) {
 func (m *ModuleTasks) processTask(ctx context.Context, task *Task) error {
 	executor, err := m.serviceMaintenanceExecutor.ForEngine(TaskEngine(task.Engine))
 	if err != nil {
-		if completeErr := m.serviceTaskQueue.CompleteTask(ctx, task.Id, nil, err); completeErr != nil {
+		if completeErr := m.serviceTaskQueue.CompleteTask(ctx, task.CurrentClaim(), nil, err); completeErr != nil {
 			return fmt.Errorf("could not complete task %d after engine lookup failure: %w", task.Id, completeErr)
 		}
 
 		return nil
 	}
 
+	stopHeartbeat := m.startHeartbeat(ctx, task.CurrentClaim())
+	defer stopHeartbeat()
+
 	if err = executor.ProcessTask(ctx, task); err != nil {
 		wrappedErr := fmt.Errorf("engine %s failed to process task %d: %w", executor.Engine(), task.Id, err)
-		if completeErr := m.serviceTaskQueue.CompleteTask(ctx, task.Id, nil, wrappedErr); completeErr != nil {
+		if completeErr := m.serviceTaskQueue.CompleteTask(ctx, task.CurrentClaim(), nil, wrappedErr); completeErr != nil {
 			return fmt.Errorf("could not complete task %d after processing failure: %w", task.Id, completeErr)
 		}
 
@@ -145,3 +194,33 @@ func (m *ModuleTasks) processTask(ctx context.Context, task *Task) error {
 
 	return nil
 }
+
+// startHeartbeat keeps extending the lease of a task claim while the worker processes it. The returned
+// function stops the heartbeat.
+func (m *ModuleTasks) startHeartbeat(ctx context.Context, claim TaskClaim) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(m.leaseSettings.HeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.serviceTaskQueue.HeartbeatTasks(ctx, claim); err != nil {
+					m.logger.Warn(ctx, "failed to heartbeat task %d: %s", claim.TaskId, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,295 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// optimizePartitionLayout describes how optimize selects the partitions of a table. Tables partitioned
+// by hour or day are optimized in chunks of their date range, all other tables per partition scope.
+type optimizePartitionLayout struct {
+	fields        []TablePartition
+	timeColumn    string
+	timeTransform string
+}
+
+type optimizeScopePlan struct {
+	scope      PartitionValues
+	period     *optimizeRangeChunk
+	partitions []PlannedPartition
+}
+
+func newOptimizePartitionLayout(fields []TablePartition) optimizePartitionLayout {
+	layout := optimizePartitionLayout{fields: fields}
+
+	for _, field := range fields {
+		if field.IsHidden && isTimeTransform(field.Hidden.Type) {
+			layout.timeColumn = field.Hidden.Column
+			layout.timeTransform = field.Hidden.Type
+
+			break
+		}
+	}
+
+	return layout
+}
+
+// chunksByDate reports whether the partitions are grouped into date range chunks, which requires at
+// least one partition per day.
+func (l optimizePartitionLayout) chunksByDate() bool {
+	return l.timeTransform == transformHour || l.timeTransform == transformDay
+}
+
+// scope reduces partition values to the values optimize can filter on. Bucket and truncate values can
+// not be expressed as a filter on their source column, so partitions only differing in them share a
+// scope. An empty scope covers the whole table.
+func (l optimizePartitionLayout) scope(values PartitionValues) PartitionValues {
+	scope := PartitionValues{}
+
+	for _, field := range l.fields {
+		if field.IsHidden && !isTimeTransform(field.Hidden.Type) {
+			continue
+		}
+
+		if value, ok := values[field.Name]; ok {
+			scope[field.Name] = value
+		}
+	}
+
+	return scope
+}
+
+// period returns the date range covered by the scope of a month or year partitioned table.
+func (l optimizePartitionLayout) period(scope PartitionValues) (*optimizeRangeChunk, error) {
+	var err error
+	var year, month int
+
+	if l.timeTransform != transformMonth && l.timeTransform != transformYear {
+		return nil, nil
+	}
+
+	if year, err = partitionValueInt(scope, "year"); err != nil {
+		return nil, err
+	}
+
+	if l.timeTransform == transformYear {
+		from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+		return &optimizeRangeChunk{from: from, to: from.AddDate(1, 0, -1)}, nil
+	}
+
+	if month, err = partitionValueInt(scope, "month"); err != nil {
+		return nil, err
+	}
+
+	from := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+
+	return &optimizeRangeChunk{from: from, to: from.AddDate(0, 1, -1)}, nil
+}
+
+// periodStart truncates the date to the start of the month or year for month or year partitioned tables.
+func (l optimizePartitionLayout) periodStart(date time.Time) time.Time {
+	switch l.timeTransform {
+	case transformMonth:
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case transformYear:
+		return time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return date
+	}
+}
+
+// predicate renders the scope as a filter on the source columns of the partitions. The filter only uses
+// comparisons spark and trino both understand, an empty filter covers the whole table.
+func (l optimizePartitionLayout) predicate(scope PartitionValues, dialect sqlDialect) (string, error) {
+	conditions := make([]string, 0, len(l.fields))
+
+	period, err := l.period(scope)
+	if err != nil {
+		return "", err
+	}
+
+	if period != nil {
+		conditions = append(conditions, l.rangePredicate(period.from, period.to, dialect))
+	}
+
+	for _, field := range l.fields {
+		if field.IsHidden {
+			continue
+		}
+
+		value, ok := scope[field.Name]
+		if !ok {
+			return "", fmt.Errorf("missing value for partition %s", field.Name)
+		}
+
+		column := quoteIdentPath(field.Name, dialect.quoteIdent)
+
+		keyword, literal, err := temporalPartitionLiteral(field.SourceType, value)
+		if err != nil {
+			return "", fmt.Errorf("unsupported value %v for partition %s: %w", value, field.Name, err)
+		}
+
+		if keyword != "" {
+			conditions = append(conditions, fmt.Sprintf("%s = %s %s", column, keyword, dialect.quoteLiteral(literal)))
+
+			continue
+		}
+
+		switch v := value.(type) {
+		case nil:
+			conditions = append(conditions, fmt.Sprintf("%s IS NULL", column))
+		case string:
+			conditions = append(conditions, fmt.Sprintf("%s = %s", column, dialect.quoteLiteral(v)))
+		case bool:
+			conditions = append(conditions, fmt.Sprintf("%s = %t", column, v))
+		default:
+			number, err := cast.ToStringE(v)
+			if err != nil {
+				return "", fmt.Errorf("unsupported value %v for partition %s: %w", value, field.Name, err)
+			}
+
+			conditions = append(conditions, fmt.Sprintf("%s = %s", column, number))
+		}
+	}
+
+	return strings.Join(conditions, " AND "), nil
+}
+
+// rangePredicate filters the time column on the days from to to, both inclusive. Comparing the plain
+// column keeps the filter enforceable on the partitions of the time transform.
+func (l optimizePartitionLayout) rangePredicate(from time.Time, to time.Time, dialect sqlDialect) string {
+	column := quoteIdentPath(l.timeColumn, dialect.quoteIdent)
+
+	return fmt.Sprintf("%s >= DATE %s AND %s < DATE %s",
+		column,
+		dialect.quoteLiteral(from.Format(time.DateOnly)),
+		column,
+		dialect.quoteLiteral(to.AddDate(0, 0, 1).Format(time.DateOnly)),
+	)
+}
+
+// temporalPartitionLiteral formats the value of an identity partition on a date or timestamp column as
+// the text of a DATE or TIMESTAMP literal, which is also how trino casts these values to varchar. Iceberg
+// stores such values as days or micro- respectively nanoseconds since the epoch. The keyword is empty for
+// null values and all other column types.
+func temporalPartitionLiteral(sourceType string, value any) (keyword string, literal string, err error) {
+	if value == nil {
+		return "", "", nil
+	}
+
+	var layout string
+	var toTime func(int64) time.Time
+
+	switch sourceType {
+	case "date":
+		keyword, layout = "DATE", time.DateOnly
+		toTime = func(days int64) time.Time { return time.Unix(days*86400, 0) }
+	case "timestamp", "timestamptz":
+		keyword, layout = "TIMESTAMP", "2006-01-02 15:04:05.000000"
+		toTime = time.UnixMicro
+	case "timestamp_ns", "timestamptz_ns":
+		keyword, layout = "TIMESTAMP", "2006-01-02 15:04:05.000000000"
+		toTime = func(nanos int64) time.Time { return time.Unix(0, nanos) }
+	default:
+		return "", "", nil
+	}
+
+	if text, ok := value.(string); ok {
+		return keyword, text, nil
+	}
+
+	epoch, err := cast.ToInt64E(value)
+	if err != nil {
+		return "", "", err
+	}
+
+	literal = toTime(epoch).UTC().Format(layout)
+	if strings.HasPrefix(sourceType, "timestamptz") {
+		literal += " UTC"
+	}
+
+	return keyword, literal, nil
+}
+
+// planOptimizeScopes groups the partitions by their scope, one plan per scope ordered by the scope values.
+func planOptimizeScopes(layout optimizePartitionLayout, partitions []optimizePartitionRow) ([]optimizeScopePlan, error) {
+	scopePlans := make([]optimizeScopePlan, 0, len(partitions))
+	scopeIndexes := make(map[string]int, len(partitions))
+
+	for _, p := range partitions {
+		scope := layout.scope(p.Partition.Get())
+		scopeKey := scope.String()
+
+		index, ok := scopeIndexes[scopeKey]
+		if !ok {
+			period, err := layout.period(scope)
+			if err != nil {
+				return nil, fmt.Errorf("could not determine period of partition %s: %w", scopeKey, err)
+			}
+
+			index = len(scopePlans)
+			scopeIndexes[scopeKey] = index
+			scopePlans = append(scopePlans, optimizeScopePlan{scope: scope, period: period})
+		}
+
+		scopePlans[index].partitions = append(scopePlans[index].partitions, PlannedPartition{
+			Partition:                p.Partition.Get(),
+			RecordCount:              p.RecordCount,
+			FileCount:                p.FileCount,
+			TotalDataFileSizeInBytes: p.TotalDataFileSizeInBytes,
+		})
+	}
+
+	sort.SliceStable(scopePlans, func(i, j int) bool {
+		return scopePlans[i].scope.String() < scopePlans[j].scope.String()
+	})
+
+	return scopePlans, nil
+}
+
+// optimizeScopeFromTaskInput returns the partition scope of an optimize task, false for tasks optimizing
+// a date range.
+func optimizeScopeFromTaskInput(input map[string]any) (PartitionValues, bool) {
+	switch scope := input["partition"].(type) {
+	case PartitionValues:
+		return scope, true
+	case map[string]any:
+		return scope, true
+	default:
+		return nil, false
+	}
+}
+
+func describeOptimizeTaskInput(input map[string]any) string {
+	if scope, ok := optimizeScopeFromTaskInput(input); ok {
+		if len(scope) == 0 {
+			return "the whole table"
+		}
+
+		return fmt.Sprintf("partition %s", scope.String())
+	}
+
+	from, to := cast.ToTime(input["from"]), cast.ToTime(input["to"])
+
+	return fmt.Sprintf("range %s to %s", from.Format(time.DateOnly), to.Format(time.DateOnly))
+}
+
+func partitionValueInt(values PartitionValues, key string) (int, error) {
+	value, ok := values[key]
+	if !ok {
+		return 0, fmt.Errorf("missing partition value %s", key)
+	}
+
+	result, err := strconv.Atoi(cast.ToString(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid partition value %s=%v: %w", key, value, err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/justtrackio/gosoline/pkg/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptimizePartitionLayoutPredicate(t *testing.T) {
+	layout := newOptimizePartitionLayout([]TablePartition{
+		{Name: "year", RawFieldName: "created_at_month", IsHidden: true, Hidden: TablePartitionHidden{Column: "created_at", Type: transformMonth}},
+		{Name: "month", RawFieldName: "created_at_month", IsHidden: true, Hidden: TablePartitionHidden{Column: "created_at", Type: transformMonth}},
+		{Name: "goal.country", RawFieldName: "goal_country", IsHidden: false},
+		{Name: "user_id_bucket", RawFieldName: "user_id_bucket", IsHidden: true, Hidden: TablePartitionHidden{Column: "user_id", Type: "bucket[16]"}},
+	})
+
+	require.False(t, layout.chunksByDate())
+
+	scope := layout.scope(PartitionValues{"year": "2026", "month": "02", "goal.country": "d\"e", "user_id_bucket": float64(3)})
+	require.Equal(t, PartitionValues{"year": "2026", "month": "02", "goal.country": "d\"e"}, scope)
+
+	predicate, err := layout.predicate(scope, sparkDialect)
+	require.NoError(t, err)
+	require.Equal(t, "`created_at` >= DATE \"2026-02-01\" AND `created_at` < DATE \"2026-03-01\" AND `goal`.`country` = \"d\\\"e\"", predicate)
+}
+
+func TestOptimizePartitionLayoutPredicateOfTemporalIdentityPartitions(t *testing.T) {
+	layout := newOptimizePartitionLayout([]TablePartition{
+		{Name: "event_date", RawFieldName: "event_date", SourceType: "date"},
+		{Name: "loaded_at", RawFieldName: "loaded_at", SourceType: "timestamptz"},
+		{Name: "country", RawFieldName: "country", SourceType: "string"},
+	})
+
+	// partition values are stored as json, so the epoch based values come back as floats
+	scope := layout.scope(PartitionValues{"event_date": float64(20376), "loaded_at": float64(1760522400000000), "country": "de"})
+
+	predicate, err := layout.predicate(scope, trinoDialect)
+	require.NoError(t, err)
+	require.Equal(t, `"event_date" = DATE '2025-10-15' AND "loaded_at" = TIMESTAMP '2025-10-15 10:00:00.000000 UTC' AND "country" = 'de'`, predicate)
+
+	predicate, err = layout.predicate(PartitionValues{"event_date": nil, "loaded_at": "2025-10-15 10:00:00.000000 UTC", "country": "de"}, sparkDialect)
+	require.NoError(t, err)
+	require.Equal(t, "`event_date` IS NULL AND `loaded_at` = TIMESTAMP \"2025-10-15 10:00:00.000000 UTC\" AND `country` = \"de\"", predicate)
+
+	require.Equal(t, `CAST(partition."event_date" AS VARCHAR) = '2025-10-15'`, trinoFilesPartitionFilter(layout, time.Time{}, time.Time{}, PartitionValues{"event_date": float64(20376)}))
+
+	_, err = layout.predicate(PartitionValues{"event_date": []any{1}, "loaded_at": nil, "country": "de"}, trinoDialect)
+	require.Error(t, err)
+}
+
+func TestOptimizePartitionLayoutPredicateCoversWholeTableWithoutFilterablePartitions(t *testing.T) {
+	layout := newOptimizePartitionLayout([]TablePartition{
+		{Name: "user_id_bucket", RawFieldName: "user_id_bucket", IsHidden: true, Hidden: TablePartitionHidden{Column: "user_id", Type: "bucket[16]"}},
+	})
+
+	predicate, err := layout.predicate(layout.scope(PartitionValues{"user_id_bucket": float64(3)}), sparkDialect)
+	require.NoError(t, err)
+	require.Equal(t, "", predicate)
+
+	predicate, err = newOptimizePartitionLayout(nil).predicate(PartitionValues{}, sparkDialect)
+	require.NoError(t, err)
+	require.Equal(t, "", predicate)
+}
+
+func TestPlanOptimizeScopesGroupsPartitionsSharingAScope(t *testing.T) {
+	layout := newOptimizePartitionLayout([]TablePartition{
+		{Name: "country", RawFieldName: "country", IsHidden: false},
+		{Name: "user_id_bucket", RawFieldName: "user_id_bucket", IsHidden: true, Hidden: TablePartitionHidden{Column: "user_id", Type: "bucket[16]"}},
+	})
+
+	row := func(country string, bucket float64, fileCount int64) optimizePartitionRow {
+		return optimizePartitionRow{
+			Partition: db.NewJSON(PartitionValues{"country": country, "user_id_bucket": bucket}, db.NonNullable{}),
+			FileCount: fileCount,
+		}
+	}
+
+	scopePlans, err := planOptimizeScopes(layout, []optimizePartitionRow{row("fr", 1, 10), row("de", 1, 20), row("fr", 2, 30)})
+	require.NoError(t, err)
+	require.Len(t, scopePlans, 2)
+
+	require.Equal(t, PartitionValues{"country": "de"}, scopePlans[0].scope)
+	require.Len(t, scopePlans[0].partitions, 1)
+	require.Nil(t, scopePlans[0].period)
+
+	require.Equal(t, PartitionValues{"country": "fr"}, scopePlans[1].scope)
+	require.Len(t, scopePlans[1].partitions, 2)
+}
+
+func TestOptimizePartitionLayoutPeriodOfYearPartition(t *testing.T) {
+	layout := newOptimizePartitionLayout([]TablePartition{
+		{Name: "year", RawFieldName: "created_at_year", IsHidden: true, Hidden: TablePartitionHidden{Column: "created_at", Type: transformYear}},
+	})
+
+	period, err := layout.period(PartitionValues{"year": "2025"})
+	require.NoError(t, err)
+	require.Equal(t, &optimizeRangeChunk{
+		from: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		to:   time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC),
+	}, period)
+	require.Equal(t, time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC), layout.periodStart(time.Date(2025, time.June, 3, 0, 0, 0, 0, time.UTC)))
+}
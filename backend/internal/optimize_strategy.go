@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	optimizeStrategyBinpack = "binpack"
+	optimizeStrategySort    = "sort"
+	optimizeStrategyZorder  = "zorder"
+)
+
+var (
+	optimizeSortColumnPattern   = regexp.MustCompile(`(?i)^[A-Za-z_][A-Za-z0-9_.]*(\s+(ASC|DESC))?(\s+NULLS\s+(FIRST|LAST))?$`)
+	optimizeZorderColumnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+)
+
+// OptimizeStrategy selects how optimize rewrites data files. Binpack only combines files, sort orders
+// the rewritten rows by Columns, each given as "column [ASC|DESC] [NULLS FIRST|LAST]", and zorder
+// clusters them by the plain Columns.
+type OptimizeStrategy struct {
+	Strategy string   `json:"strategy"`
+	Columns  []string `json:"columns"`
+}
+
+// normalizeOptimizeStrategy validates the requested strategy. Without a strategy, tables with an iceberg
+// sort order are sorted by it and all other tables are bin-packed. A sort strategy without columns
+// falls back to the sort order of the table as well.
+func normalizeOptimizeStrategy(strategy OptimizeStrategy, sortOrder []TableSortField) (OptimizeStrategy, error) {
+	columns := make([]string, 0, len(strategy.Columns))
+	for _, column := range strategy.Columns {
+		if column = strings.Join(strings.Fields(column), " "); column != "" {
+			columns = append(columns, column)
+		}
+	}
+
+	tableSortColumns := sortColumnsFromSortOrder(sortOrder)
+
+	switch strings.TrimSpace(strings.ToLower(strategy.Strategy)) {
+	case "":
+		if len(tableSortColumns) == 0 {
+			return OptimizeStrategy{Strategy: optimizeStrategyBinpack, Columns: []string{}}, nil
+		}
+
+		return OptimizeStrategy{Strategy: optimizeStrategySort, Columns: tableSortColumns}, nil
+	case optimizeStrategyBinpack:
+		if len(columns) > 0 {
+			return OptimizeStrategy{}, fmt.Errorf("optimize strategy %s does not accept columns", optimizeStrategyBinpack)
+		}
+
+		return OptimizeStrategy{Strategy: optimizeStrategyBinpack, Columns: []string{}}, nil
+	case optimizeStrategySort:
+		if len(columns) == 0 {
+			columns = tableSortColumns
+		}
+
+		if len(columns) == 0 {
+			return OptimizeStrategy{}, fmt.Errorf("optimize strategy %s requires columns as the table has no sort order", optimizeStrategySort)
+		}
+
+		for _, column := range columns {
+			if !optimizeSortColumnPattern.MatchString(column) {
+				return OptimizeStrategy{}, fmt.Errorf("invalid sort column %q, expected \"column [ASC|DESC] [NULLS FIRST|LAST]\"", column)
+			}
+		}
+
+		return OptimizeStrategy{Strategy: optimizeStrategySort, Columns: columns}, nil
+	case optimizeStrategyZorder:
+		if len(columns) == 0 {
+			return OptimizeStrategy{}, fmt.Errorf("optimize strategy %s requires columns", optimizeStrategyZorder)
+		}
+
+		for _, column := range columns {
+			if !optimizeZorderColumnPattern.MatchString(column) {
+				return OptimizeStrategy{}, fmt.Errorf("invalid zorder column %q", column)
+			}
+		}
+
+		return OptimizeStrategy{Strategy: optimizeStrategyZorder, Columns: columns}, nil
+	default:
+		return OptimizeStrategy{}, fmt.Errorf("unsupported optimize strategy %q, expected %s, %s or %s", strategy.Strategy, optimizeStrategyBinpack, optimizeStrategySort, optimizeStrategyZorder)
+	}
+}
+
+// sortColumnsFromSortOrder renders the identity fields of an iceberg sort order as sort columns. Fields
+// with other transforms can not be expressed as a plain column and are skipped.
+func sortColumnsFromSortOrder(sortOrder []TableSortField) []string {
+	columns := make([]string, 0, len(sortOrder))
+
+	for _, field := range sortOrder {
+		if field.Transform != "identity" {
+			continue
+		}
+
+		direction := "ASC"
+		if strings.EqualFold(field.Direction, "desc") {
+			direction = "DESC"
+		}
+
+		nullOrder := "NULLS FIRST"
+		if strings.EqualFold(field.NullOrder, "nulls-last") {
+			nullOrder = "NULLS LAST"
+		}
+
+		columns = append(columns, fmt.Sprintf("%s %s %s", field.Column, direction, nullOrder))
+	}
+
+	return columns
+}
+
+// sparkSortOrder returns the sort_order argument of the spark rewrite_data_files procedure.
+func (s OptimizeStrategy) sparkSortOrder() string {
+	switch s.Strategy {
+	case optimizeStrategySort:
+		return strings.Join(s.Columns, ",")
+	case optimizeStrategyZorder:
+		return fmt.Sprintf("zorder(%s)", strings.Join(s.Columns, ","))
+	default:
+		return ""
+	}
+}
+
+// sparkStrategy returns the strategy argument of the spark rewrite_data_files procedure, which
+// implements zorder as a special sort order.
+func (s OptimizeStrategy) sparkStrategy() string {
+	if s.Strategy == optimizeStrategyZorder {
+		return optimizeStrategySort
+	}
+
+	return s.Strategy
+}
+
+// validateTrinoOptimizeStrategy checks that trino can apply the strategy. Trino optimize has no strategy
+// argument, it always writes files ordered by the sort order of the table.
+func validateTrinoOptimizeStrategy(strategy OptimizeStrategy, sortOrder []TableSortField) error {
+	switch strategy.Strategy {
+	case optimizeStrategyBinpack:
+		return nil
+	case optimizeStrategySort:
+		if !slices.EqualFunc(strategy.Columns, sortColumnsFromSortOrder(sortOrder), strings.EqualFold) {
+			return fmt.Errorf("engine %s can only sort by the sort order of the table", TaskEngineTrino)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("optimize strategy %s is not supported by engine %s", strategy.Strategy, TaskEngineTrino)
+	}
+}
+
+func optimizeStrategyFromTaskInput(input map[string]any) OptimizeStrategy {
+	strategy, _ := input["strategy"].(string)
+	if strategy == "" {
+		strategy = optimizeStrategyBinpack
+	}
+
+	result := OptimizeStrategy{Strategy: strategy, Columns: []string{}}
+
+	columns, _ := input["columns"].([]any)
+	for _, column := range columns {
+		if value, ok := column.(string); ok {
+			result.Columns = append(result.Columns, value)
+		}
+	}
+
+	return result
+}
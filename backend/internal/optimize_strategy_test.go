@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeOptimizeStrategy(t *testing.T) {
+	sortOrder := []TableSortField{
+		{Column: "event_time", Transform: "identity", Direction: "desc", NullOrder: "nulls-last"},
+		{Column: "user_id", Transform: "bucket[16]", Direction: "asc", NullOrder: "nulls-first"},
+		{Column: "country", Transform: "identity", Direction: "asc", NullOrder: "nulls-first"},
+	}
+
+	tests := []struct {
+		name      string
+		strategy  OptimizeStrategy
+		sortOrder []TableSortField
+		expected  OptimizeStrategy
+		expectErr bool
+	}{
+		{
+			name:     "defaults to binpack without table sort order",
+			expected: OptimizeStrategy{Strategy: optimizeStrategyBinpack, Columns: []string{}},
+		},
+		{
+			name:      "defaults to the table sort order",
+			sortOrder: sortOrder,
+			expected:  OptimizeStrategy{Strategy: optimizeStrategySort, Columns: []string{"event_time DESC NULLS LAST", "country ASC NULLS FIRST"}},
+		},
+		{
+			name:      "explicit binpack ignores the table sort order",
+			strategy:  OptimizeStrategy{Strategy: "BINPACK"},
+			sortOrder: sortOrder,
+			expected:  OptimizeStrategy{Strategy: optimizeStrategyBinpack, Columns: []string{}},
+		},
+		{
+			name:     "sort with explicit columns",
+			strategy: OptimizeStrategy{Strategy: optimizeStrategySort, Columns: []string{" id  desc ", "name"}},
+			expected: OptimizeStrategy{Strategy: optimizeStrategySort, Columns: []string{"id desc", "name"}},
+		},
+		{
+			name:      "sort without columns uses the table sort order",
+			strategy:  OptimizeStrategy{Strategy: optimizeStrategySort},
+			sortOrder: sortOrder,
+			expected:  OptimizeStrategy{Strategy: optimizeStrategySort, Columns: []string{"event_time DESC NULLS LAST", "country ASC NULLS FIRST"}},
+		},
+		{
+			name:      "sort without columns and table sort order",
+			strategy:  OptimizeStrategy{Strategy: optimizeStrategySort},
+			expectErr: true,
+		},
+		{
+			name:      "sort rejects invalid columns",
+			strategy:  OptimizeStrategy{Strategy: optimizeStrategySort, Columns: []string{"id; drop table x"}},
+			expectErr: true,
+		},
+		{
+			name:     "zorder with columns",
+			strategy: OptimizeStrategy{Strategy: optimizeStrategyZorder, Columns: []string{"lat", "lon"}},
+			expected: OptimizeStrategy{Strategy: optimizeStrategyZorder, Columns: []string{"lat", "lon"}},
+		},
+		{
+			name:      "zorder requires columns",
+			strategy:  OptimizeStrategy{Strategy: optimizeStrategyZorder},
+			sortOrder: sortOrder,
+			expectErr: true,
+		},
+		{
+			name:      "zorder rejects sort directions",
+			strategy:  OptimizeStrategy{Strategy: optimizeStrategyZorder, Columns: []string{"lat desc"}},
+			expectErr: true,
+		},
+		{
+			name:      "unknown strategy",
+			strategy:  OptimizeStrategy{Strategy: "hilbert"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := normalizeOptimizeStrategy(tt.strategy, tt.sortOrder)
+			if tt.expectErr {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestOptimizeStrategySparkArguments(t *testing.T) {
+	sortStrategy := OptimizeStrategy{Strategy: optimizeStrategySort, Columns: []string{"id DESC", "name"}}
+	require.Equal(t, "sort", sortStrategy.sparkStrategy())
+	require.Equal(t, "id DESC,name", sortStrategy.sparkSortOrder())
+
+	zorderStrategy := OptimizeStrategy{Strategy: optimizeStrategyZorder, Columns: []string{"lat", "lon"}}
+	require.Equal(t, "sort", zorderStrategy.sparkStrategy())
+	require.Equal(t, "zorder(lat,lon)", zorderStrategy.sparkSortOrder())
+
+	binpackStrategy := optimizeStrategyFromTaskInput(map[string]any{})
+	require.Equal(t, "binpack", binpackStrategy.sparkStrategy())
+	require.Equal(t, "", binpackStrategy.sparkSortOrder())
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"time"
+
+	iceberg "github.com/apache/iceberg-go"
+	"github.com/spf13/cast"
+)
+
+// partitionRefreshScope selects the stored partitions a task changed, so only these have to be refreshed
+// after the task. A scope either covers the days from to to of a table partitioned by day or hour, or all
+// partitions matching the partition values of an optimize task.
+type partitionRefreshScope struct {
+	layout    optimizePartitionLayout
+	from      time.Time
+	to        time.Time
+	partition PartitionValues
+}
+
+// partitionRefreshScopeFromTask returns the scope of the partitions a task changed. It returns false if the
+// task may have changed any partition of the table, which then has to be refreshed as a whole.
+func partitionRefreshScopeFromTask(task *Task, fields []TablePartition) (partitionRefreshScope, bool) {
+	if TaskKind(task.Kind) != TaskKindOptimize {
+		return partitionRefreshScope{}, false
+	}
+
+	input := task.Input.Get()
+	layout := newOptimizePartitionLayout(fields)
+
+	if partition, ok := optimizeScopeFromTaskInput(input); ok {
+		if len(partition) == 0 {
+			return partitionRefreshScope{}, false
+		}
+
+		return partitionRefreshScope{layout: layout, partition: partition}, true
+	}
+
+	from, to := cast.ToTime(input["from"]), cast.ToTime(input["to"])
+	if !layout.chunksByDate() || from.IsZero() || to.IsZero() {
+		return partitionRefreshScope{}, false
+	}
+
+	return partitionRefreshScope{layout: layout, from: from.UTC(), to: to.UTC()}, true
+}
+
+// contains reports whether the stored partition values belong to the scope.
+func (s partitionRefreshScope) contains(values PartitionValues) bool {
+	if s.partition != nil {
+		for key, expected := range s.partition {
+			actual, ok := values[key]
+			if !ok || !partitionValueEqual(actual, expected) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	date, err := values.GetDate()
+	if err != nil || date == nil {
+		return false
+	}
+
+	day := date.Format(time.DateOnly)
+
+	return day >= s.from.Format(time.DateOnly) && day <= s.to.Format(time.DateOnly)
+}
+
+// rowFilter restricts the planned files to the scope, so only the manifests which can contain its partitions
+// are read. Values which can not be expressed as iceberg literals are left out of the filter, it then selects
+// more files than necessary and contains drops the additional partitions.
+func (s partitionRefreshScope) rowFilter() iceberg.BooleanExpression {
+	conditions := make([]iceberg.BooleanExpression, 0, len(s.layout.fields)+2)
+
+	from, to := s.from, s.to
+	if period, err := s.layout.period(s.partition); err == nil && period != nil {
+		from, to = period.from, period.to
+	}
+
+	if s.layout.timeColumn != "" && !from.IsZero() {
+		column := iceberg.Reference(s.layout.timeColumn)
+		conditions = append(conditions,
+			iceberg.GreaterThanEqual(column, iceberg.Timestamp(from.UnixMicro())),
+			iceberg.LessThan(column, iceberg.Timestamp(to.AddDate(0, 0, 1).UnixMicro())),
+		)
+	}
+
+	for _, field := range s.layout.fields {
+		value, ok := s.partition[field.Name]
+		if field.IsHidden || !ok {
+			continue
+		}
+
+		switch v := value.(type) {
+		case nil:
+			conditions = append(conditions, iceberg.IsNull(iceberg.Reference(field.Name)))
+		case string:
+			if field.SourceType == "string" {
+				conditions = append(conditions, iceberg.EqualTo(iceberg.Reference(field.Name), v))
+			}
+		}
+	}
+
+	switch len(conditions) {
+	case 0:
+		return iceberg.AlwaysTrue{}
+	case 1:
+		return conditions[0]
+	default:
+		return iceberg.NewAnd(conditions[0], conditions[1], conditions[2:]...)
+	}
+}
+
+// partitionValueEqual compares partition values after a round trip through json, which turns numbers into
+// floats.
+func partitionValueEqual(a any, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return cast.ToString(a) == cast.ToString(b)
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/apache/iceberg-go/table"
+)
+
+// rollbackConfirmationTTL is how long the confirmation token of a rollback preview can be presented.
+const rollbackConfirmationTTL = 5 * time.Minute
+
+var errRollbackRejected = errors.New("rollback rejected")
+
+// RollbackPreview describes what a rollback would change. The rollback itself has to present the
+// confirmation token before it expires.
+type RollbackPreview struct {
+	Database               string    `json:"database"`
+	Table                  string    `json:"table"`
+	SnapshotID             int64     `json:"snapshot_id,string"`
+	CurrentSnapshotID      int64     `json:"current_snapshot_id,string"`
+	CommittedAt            time.Time `json:"committed_at"`
+	DiscardedSnapshotCount int       `json:"discarded_snapshot_count"`
+	RecordCountDelta       int64     `json:"record_count_delta"`
+	DataFileCountDelta     int64     `json:"data_file_count_delta"`
+	DataFileSizeBytesDelta int64     `json:"data_file_size_bytes_delta"`
+	ConfirmationToken      string    `json:"confirmation_token"`
+	ExpiresAt              time.Time `json:"expires_at"`
+}
+
+type RollbackConfirmation struct {
+	Token             string    `db:"token"`
+	Database          string    `db:"database"`
+	Table             string    `db:"table"`
+	SnapshotId        int64     `db:"snapshot_id"`
+	CurrentSnapshotId int64     `db:"current_snapshot_id"`
+	ExpiresAt         time.Time `db:"expires_at"`
+	CreatedAt         time.Time `db:"created_at"`
+}
+
+// checkRollbackTarget verifies that snapshotID can be rolled back to: it has to be known to the table, i.e.
+// not expired, and be a proper ancestor of the current snapshot. It returns the snapshots which would be
+// discarded by the rollback, newest first.
+func checkRollbackTarget(snapshots []Snapshot, currentSnapshotID int64, snapshotID int64) ([]Snapshot, error) {
+	known := false
+	for _, snapshot := range snapshots {
+		if snapshot.SnapshotId == snapshotID {
+			known = true
+
+			break
+		}
+	}
+
+	if !known {
+		return nil, fmt.Errorf("snapshot %d does not exist anymore, it might have been expired: %w", snapshotID, errRollbackRejected)
+	}
+
+	if snapshotID == currentSnapshotID {
+		return nil, fmt.Errorf("snapshot %d is already the current snapshot: %w", snapshotID, errRollbackRejected)
+	}
+
+	ancestors := snapshotAncestors(snapshots, currentSnapshotID)
+	for i, ancestor := range ancestors {
+		if ancestor.SnapshotId == snapshotID {
+			return ancestors[:i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("snapshot %d is not an ancestor of the current snapshot %d: %w", snapshotID, currentSnapshotID, errRollbackRejected)
+}
+
+// lineageFromIcebergSnapshots converts the snapshots of the table metadata for snapshotAncestors.
+func lineageFromIcebergSnapshots(snapshots []table.Snapshot) []Snapshot {
+	lineage := make([]Snapshot, len(snapshots))
+	for i, snapshot := range snapshots {
+		lineage[i] = Snapshot{
+			SnapshotId:  snapshot.SnapshotID,
+			ParentId:    snapshot.ParentSnapshotID,
+			CommittedAt: time.UnixMilli(snapshot.TimestampMs),
+		}
+	}
+
+	return lineage
+}
+
+// applyRollbackImpact fills the record and data file deltas of a rollback from the table totals iceberg
+// keeps in the summaries of the target and the current snapshot.
+func applyRollbackImpact(preview *RollbackPreview, target *table.Summary, current *table.Summary) {
+	preview.RecordCountDelta = snapshotSummaryInt(target, "total-records") - snapshotSummaryInt(current, "total-records")
+	preview.DataFileCountDelta = snapshotSummaryInt(target, "total-data-files") - snapshotSummaryInt(current, "total-data-files")
+	preview.DataFileSizeBytesDelta = snapshotSummaryInt(target, "total-files-size") - snapshotSummaryInt(current, "total-files-size")
+}
+
+func snapshotSummaryInt(summary *table.Summary, key string) int64 {
+	if summary == nil {
+		return 0
+	}
+
+	value, err := strconv.ParseInt(summary.Properties[key], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return value
+}
+
+func newRollbackConfirmationToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("could not generate confirmation token: %w", err)
+	}
+
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/apache/iceberg-go/table"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRollbackTarget(t *testing.T) {
+	parent := func(id int64) *int64 {
+		return &id
+	}
+
+	// 4 is a staged snapshot on top of 2 which is not part of the current lineage 1 <- 2 <- 3
+	snapshots := lineageFromIcebergSnapshots([]table.Snapshot{
+		{SnapshotID: 1, TimestampMs: 1000},
+		{SnapshotID: 2, ParentSnapshotID: parent(1), TimestampMs: 2000},
+		{SnapshotID: 4, ParentSnapshotID: parent(2), TimestampMs: 3000},
+		{SnapshotID: 3, ParentSnapshotID: parent(2), TimestampMs: 4000},
+	})
+
+	discarded, err := checkRollbackTarget(snapshots, 3, 1)
+	require.NoError(t, err)
+	require.Len(t, discarded, 2)
+	require.Equal(t, int64(3), discarded[0].SnapshotId)
+	require.Equal(t, int64(2), discarded[1].SnapshotId)
+
+	_, err = checkRollbackTarget(snapshots, 3, 3)
+	require.ErrorIs(t, err, errRollbackRejected)
+	require.ErrorContains(t, err, "already the current snapshot")
+
+	_, err = checkRollbackTarget(snapshots, 3, 4)
+	require.ErrorIs(t, err, errRollbackRejected)
+	require.ErrorContains(t, err, "not an ancestor")
+
+	_, err = checkRollbackTarget(snapshots, 3, 5)
+	require.ErrorIs(t, err, errRollbackRejected)
+	require.ErrorContains(t, err, "might have been expired")
+}
+
+func TestApplyRollbackImpact(t *testing.T) {
+	target := &table.Summary{Properties: map[string]string{"total-records": "100", "total-data-files": "4", "total-files-size": "4096"}}
+	current := &table.Summary{Properties: map[string]string{"total-records": "150", "total-data-files": "5", "total-files-size": "invalid"}}
+
+	preview := &RollbackPreview{}
+	applyRollbackImpact(preview, target, current)
+	require.Equal(t, int64(-50), preview.RecordCountDelta)
+	require.Equal(t, int64(-1), preview.DataFileCountDelta)
+	require.Equal(t, int64(4096), preview.DataFileSizeBytesDelta)
+
+	preview = &RollbackPreview{}
+	applyRollbackImpact(preview, nil, current)
+	require.Equal(t, int64(-150), preview.RecordCountDelta)
+}
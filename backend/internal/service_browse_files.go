@@ -124,7 +124,8 @@ func (s *ServiceBrowseFiles) resolveBrowseFileSelections(fields []TablePartition
 }
 
 func (s *ServiceBrowseFiles) buildBrowseFileSelection(filters map[string]string, field TablePartition) (browseFileSelection, error) {
-	if !field.IsHidden {
+	// bucket and truncate values are filtered as they are, only time transforms span several browse keys
+	if !field.IsHidden || !isTimeTransform(field.Hidden.Type) {
 		value, ok := filters[field.Name]
 		if !ok {
 			return browseFileSelection{}, newBrowseInputError("missing partition filter %q", field.Name)
@@ -15,6 +15,33 @@ import (
 	"github.com/justtrackio/gosoline/pkg/log"
 )
 
+// maxFileIntegrityIssues caps the missing and mismatching objects listed in a report, the counts are
+// always complete.
+const maxFileIntegrityIssues = 1000
+
+const fileIntegrityStatusIssuesFound = "issues_found"
+
+// FileIntegrityReport is the result of checking the objects referenced by a table against object storage.
+type FileIntegrityReport struct {
+	Database           string               `json:"database"`
+	Table              string               `json:"table"`
+	SnapshotCount      int                  `json:"snapshot_count"`
+	ObjectCounts       map[string]int       `json:"object_counts"`
+	CheckedObjectCount int                  `json:"checked_object_count"`
+	MissingObjectCount int                  `json:"missing_object_count"`
+	SizeMismatchCount  int                  `json:"size_mismatch_count"`
+	MissingObjects     []FileIntegrityIssue `json:"missing_objects"`
+	SizeMismatches     []FileIntegrityIssue `json:"size_mismatches"`
+	Status             string               `json:"status"`
+}
+
+type FileIntegrityIssue struct {
+	Path              string `json:"path"`
+	Content           string `json:"content"`
+	ExpectedSizeBytes *int64 `json:"expected_size_bytes,omitempty"`
+	ActualSizeBytes   *int64 `json:"actual_size_bytes,omitempty"`
+}
+
 func NewServiceFileIntegrity(ctx context.Context, config cfg.Config, logger log.Logger) (*ServiceFileIntegrity, error) {
 	var err error
 	var icebergClient *IcebergClient
@@ -44,8 +71,7 @@ type ServiceFileIntegrity struct {
 func (s *ServiceFileIntegrity) ListMissingFiles(ctx context.Context, database string, tableName string, snapshotID int64) ([]string, error) {
 	var err error
 	var filePaths []string
-	var group *s3ListGroup
-	var existingKeys funk.Set[string]
+	var existing map[s3ObjectKey]int64
 
 	if filePaths, err = s.icebergClient.ListSnapshotDataFilePaths(ctx, database, tableName, snapshotID); err != nil {
 		return nil, fmt.Errorf("could not list data files for snapshot %d in table %s: %w", snapshotID, tableName, err)
@@ -55,89 +81,102 @@ func (s *ServiceFileIntegrity) ListMissingFiles(ctx context.Context, database st
 		return []string{}, nil
 	}
 
-	if group, err = s.buildS3ListGroup(filePaths); err != nil {
-		return nil, fmt.Errorf("could not group data files for s3 lookup: %w", err)
+	objects := make([]IcebergTableObject, len(filePaths))
+	for i, filePath := range filePaths {
+		objects[i] = IcebergTableObject{Path: filePath, Content: snapshotFileContentData}
 	}
 
-	if existingKeys, err = s.listKeysByPrefix(ctx, group.bucket, group.prefix); err != nil {
+	if existing, err = s.listExistingObjects(ctx, objects); err != nil {
 		return nil, err
 	}
 
-	missing := make([]string, 0)
-	for key, uri := range group.expectedByKey {
-		if existingKeys.Contains(key) {
-			continue
-		}
-
-		missing = append(missing, uri)
+	var report *FileIntegrityReport
+	if report, err = checkFileIntegrity(objects, existing, len(objects)); err != nil {
+		return nil, err
 	}
 
-	sort.Strings(missing)
+	missing := make([]string, len(report.MissingObjects))
+	for i, issue := range report.MissingObjects {
+		missing[i] = issue.Path
+	}
 
 	s.logger.Info(ctx, "checked %d data files for snapshot %d in table %s and found %d missing", len(filePaths), snapshotID, tableName, len(missing))
 
 	return missing, nil
 }
 
-type s3ObjectLocation struct {
-	bucket string
-	key    string
-	uri    string
-}
+// CheckTable verifies that every object referenced by the metadata of a table exists and, where the
+// metadata records it, has the expected size. See IcebergClient.ListTableObjects for the checked objects.
+func (s *ServiceFileIntegrity) CheckTable(ctx context.Context, database string, tableName string) (*FileIntegrityReport, error) {
+	var err error
+	var objects *IcebergTableObjects
+	var existing map[s3ObjectKey]int64
 
-type s3ListGroup struct {
-	bucket        string
-	prefix        string
-	expectedByKey map[string]string
-}
+	if objects, err = s.icebergClient.ListTableObjects(ctx, database, tableName); err != nil {
+		return nil, fmt.Errorf("could not list objects of table %s.%s: %w", database, tableName, err)
+	}
 
-func (s *ServiceFileIntegrity) buildS3ListGroup(filePaths []string) (*s3ListGroup, error) {
-	firstLocation, err := parseS3ObjectLocation(filePaths[0])
-	if err != nil {
+	if existing, err = s.listExistingObjects(ctx, objects.Objects); err != nil {
 		return nil, err
 	}
 
-	prefix, err := dataPrefixFromKey(firstLocation.key)
-	if err != nil {
+	var report *FileIntegrityReport
+	if report, err = checkFileIntegrity(objects.Objects, existing, maxFileIntegrityIssues); err != nil {
 		return nil, err
 	}
 
-	expectedByKey := make(map[string]string, len(filePaths))
-	for _, filePath := range filePaths {
-		location, err := parseS3ObjectLocation(filePath)
+	report.Database = database
+	report.Table = tableName
+	report.SnapshotCount = objects.SnapshotCount
+
+	s.logger.Info(ctx, "checked %d objects of %d snapshots in table %s.%s and found %d missing and %d with a wrong size", report.CheckedObjectCount, report.SnapshotCount, database, tableName, report.MissingObjectCount, report.SizeMismatchCount)
+
+	return report, nil
+}
+
+// processCheckFileIntegrityTask runs a check_file_integrity task. The check only reads the table metadata
+// and object storage, so every engine runs it the same way in this process.
+func processCheckFileIntegrityTask(ctx context.Context, files *ServiceFileIntegrity, taskQueue TaskClaimer, task *Task) error {
+	res, err := files.CheckTable(ctx, task.Database, task.Table)
+	if err != nil {
+		return taskQueue.CompleteTask(ctx, task.CurrentClaim(), nil, err)
+	}
+
+	return taskQueue.CompleteTask(ctx, task.CurrentClaim(), fileIntegrityReportResultMap(res), nil)
+}
+
+// listExistingObjects lists the sizes of all objects below the prefixes the given objects are stored in.
+func (s *ServiceFileIntegrity) listExistingObjects(ctx context.Context, objects []IcebergTableObject) (map[s3ObjectKey]int64, error) {
+	locations := make([]s3ObjectLocation, len(objects))
+	for i, object := range objects {
+		location, err := parseS3ObjectLocation(object.Path)
 		if err != nil {
 			return nil, err
 		}
 
-		if location.bucket != firstLocation.bucket {
-			return nil, fmt.Errorf("found data files in multiple buckets: %q and %q", firstLocation.bucket, location.bucket)
-		}
+		locations[i] = location
+	}
 
-		if !strings.HasPrefix(location.key, prefix) {
-			return nil, fmt.Errorf("data file %q does not use expected data prefix %q", filePath, prefix)
+	existing := make(map[s3ObjectKey]int64)
+	for _, prefix := range s3ListPrefixes(locations) {
+		if err := s.listObjectSizesByPrefix(ctx, prefix, existing); err != nil {
+			return nil, err
 		}
-
-		expectedByKey[location.key] = location.uri
 	}
 
-	return &s3ListGroup{
-		bucket:        firstLocation.bucket,
-		prefix:        prefix,
-		expectedByKey: expectedByKey,
-	}, nil
+	return existing, nil
 }
 
-func (s *ServiceFileIntegrity) listKeysByPrefix(ctx context.Context, bucket, prefix string) (funk.Set[string], error) {
+func (s *ServiceFileIntegrity) listObjectSizesByPrefix(ctx context.Context, prefix s3ListPrefix, sizes map[s3ObjectKey]int64) error {
 	paginator := awsS3.NewListObjectsV2Paginator(s.s3Client, &awsS3.ListObjectsV2Input{
-		Bucket: aws.String(bucket),
-		Prefix: aws.String(prefix),
+		Bucket: aws.String(prefix.bucket),
+		Prefix: aws.String(prefix.prefix),
 	})
 
-	keys := funk.Set[string]{}
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("could not list s3 objects for s3://%s/%s: %w", bucket, prefix, err)
+			return fmt.Errorf("could not list s3 objects for s3://%s/%s: %w", prefix.bucket, prefix.prefix, err)
 		}
 
 		for _, object := range page.Contents {
@@ -145,11 +184,144 @@ func (s *ServiceFileIntegrity) listKeysByPrefix(ctx context.Context, bucket, pre
 				continue
 			}
 
-			keys.Add(*object.Key)
+			sizes[s3ObjectKey{bucket: prefix.bucket, key: *object.Key}] = aws.ToInt64(object.Size)
+		}
+	}
+
+	return nil
+}
+
+type s3ObjectLocation struct {
+	bucket string
+	key    string
+	uri    string
+}
+
+type s3ObjectKey struct {
+	bucket string
+	key    string
+}
+
+type s3ListPrefix struct {
+	bucket string
+	prefix string
+}
+
+// checkFileIntegrity compares the referenced objects with the existing objects and their sizes. Objects
+// without a known size only have to exist.
+func checkFileIntegrity(objects []IcebergTableObject, existing map[s3ObjectKey]int64, maxIssues int) (*FileIntegrityReport, error) {
+	report := &FileIntegrityReport{
+		ObjectCounts:   make(map[string]int),
+		MissingObjects: make([]FileIntegrityIssue, 0),
+		SizeMismatches: make([]FileIntegrityIssue, 0),
+		Status:         statusOK,
+	}
+
+	for _, object := range objects {
+		location, err := parseS3ObjectLocation(object.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		report.CheckedObjectCount++
+		report.ObjectCounts[object.Content]++
+
+		actualSize, ok := existing[s3ObjectKey{bucket: location.bucket, key: location.key}]
+		switch {
+		case !ok:
+			report.MissingObjectCount++
+			report.MissingObjects = append(report.MissingObjects, FileIntegrityIssue{
+				Path:              object.Path,
+				Content:           object.Content,
+				ExpectedSizeBytes: object.SizeBytes,
+			})
+		case object.SizeBytes != nil && *object.SizeBytes != actualSize:
+			report.SizeMismatchCount++
+			report.SizeMismatches = append(report.SizeMismatches, FileIntegrityIssue{
+				Path:              object.Path,
+				Content:           object.Content,
+				ExpectedSizeBytes: object.SizeBytes,
+				ActualSizeBytes:   &actualSize,
+			})
+		}
+	}
+
+	if report.MissingObjectCount > 0 || report.SizeMismatchCount > 0 {
+		report.Status = fileIntegrityStatusIssuesFound
+	}
+
+	report.MissingObjects = sortAndCapFileIntegrityIssues(report.MissingObjects, maxIssues)
+	report.SizeMismatches = sortAndCapFileIntegrityIssues(report.SizeMismatches, maxIssues)
+
+	return report, nil
+}
+
+func sortAndCapFileIntegrityIssues(issues []FileIntegrityIssue, maxIssues int) []FileIntegrityIssue {
+	sort.Slice(issues, func(i, j int) bool {
+		return issues[i].Path < issues[j].Path
+	})
+
+	if len(issues) > maxIssues {
+		return issues[:maxIssues]
+	}
+
+	return issues
+}
+
+// s3ListPrefixes returns the prefixes to list to find all given objects. Objects below a data or metadata
+// directory are listed by that directory, any other object by its parent directory. This keeps the number
+// of list requests low while supporting tables spread over several buckets and locations. Prefixes
+// contained in another prefix of the same bucket are dropped.
+func s3ListPrefixes(locations []s3ObjectLocation) []s3ListPrefix {
+	prefixesByBucket := make(map[string]map[string]struct{})
+	for _, location := range locations {
+		if _, ok := prefixesByBucket[location.bucket]; !ok {
+			prefixesByBucket[location.bucket] = make(map[string]struct{})
+		}
+
+		prefixesByBucket[location.bucket][s3ListPrefixFromKey(location.key)] = struct{}{}
+	}
+
+	buckets := funk.Keys(prefixesByBucket)
+	sort.Strings(buckets)
+
+	result := make([]s3ListPrefix, 0)
+	for _, bucket := range buckets {
+		prefixes := funk.Keys(prefixesByBucket[bucket])
+		sort.Strings(prefixes)
+
+		kept := ""
+		for i, prefix := range prefixes {
+			if i > 0 && strings.HasPrefix(prefix, kept) {
+				continue
+			}
+
+			kept = prefix
+			result = append(result, s3ListPrefix{bucket: bucket, prefix: prefix})
 		}
 	}
 
-	return keys, nil
+	return result
+}
+
+func s3ListPrefixFromKey(key string) string {
+	end := -1
+	for _, separator := range []string{"/data/", "/metadata/"} {
+		idx := strings.Index(key, separator)
+		if idx == -1 {
+			continue
+		}
+
+		if end == -1 || idx+len(separator) < end {
+			end = idx + len(separator)
+		}
+	}
+
+	if end != -1 {
+		return key[:end]
+	}
+
+	return key[:strings.LastIndex(key, "/")+1]
 }
 
 func parseS3ObjectLocation(filePath string) (s3ObjectLocation, error) {
@@ -180,13 +352,3 @@ func parseS3ObjectLocation(filePath string) (s3ObjectLocation, error) {
 		uri:    filePath,
 	}, nil
 }
-
-func dataPrefixFromKey(key string) (string, error) {
-	separator := "/data/"
-	idx := strings.Index(key, separator)
-	if idx == -1 {
-		return "", fmt.Errorf("s3 object key %q does not contain %q", key, separator)
-	}
-
-	return key[:idx+len(separator)], nil
-}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3ListPrefixes(t *testing.T) {
+	var locations []s3ObjectLocation
+	for _, path := range []string{
+		"s3://warehouse/db/events/metadata/00003-abc.metadata.json",
+		"s3://warehouse/db/events/metadata/snap-1.avro",
+		"s3://warehouse/db/events/data/day=2026-10-15/a.parquet",
+		"s3://warehouse/db/events/data/day=2026-10-16/b.parquet",
+		"s3://warehouse/db/events/data/metadata/c.parquet",
+		"s3://archive/events/day=2026-10-01/c.parquet",
+		"s3://archive/events/d.parquet",
+	} {
+		location, err := parseS3ObjectLocation(path)
+		require.NoError(t, err)
+
+		locations = append(locations, location)
+	}
+
+	require.Equal(t, []s3ListPrefix{
+		{bucket: "archive", prefix: "events/"},
+		{bucket: "warehouse", prefix: "db/events/data/"},
+		{bucket: "warehouse", prefix: "db/events/metadata/"},
+	}, s3ListPrefixes(locations))
+}
+
+func TestCheckFileIntegrity(t *testing.T) {
+	size := func(size int64) *int64 {
+		return &size
+	}
+
+	objects := []IcebergTableObject{
+		{Path: "s3://warehouse/db/events/metadata/00003-abc.metadata.json", Content: tableObjectContentMetadata},
+		{Path: "s3://warehouse/db/events/metadata/snap-1.avro", Content: tableObjectContentManifestList},
+		{Path: "s3://warehouse/db/events/metadata/m1.avro", Content: tableObjectContentManifest, SizeBytes: size(512)},
+		{Path: "s3://warehouse/db/events/data/b.parquet", Content: snapshotFileContentData, SizeBytes: size(2048)},
+		{Path: "s3://warehouse/db/events/data/a.parquet", Content: snapshotFileContentData, SizeBytes: size(1024)},
+		{Path: "s3://archive/events/deletes.parquet", Content: snapshotFileContentPositionDeletes, SizeBytes: size(64)},
+	}
+
+	existing := map[s3ObjectKey]int64{
+		{bucket: "warehouse", key: "db/events/metadata/00003-abc.metadata.json"}: 100,
+		{bucket: "warehouse", key: "db/events/metadata/snap-1.avro"}:             200,
+		{bucket: "warehouse", key: "db/events/metadata/m1.avro"}:                 512,
+		{bucket: "warehouse", key: "db/events/data/a.parquet"}:                   1000,
+	}
+
+	report, err := checkFileIntegrity(objects, existing, 1)
+	require.NoError(t, err)
+	require.Equal(t, fileIntegrityStatusIssuesFound, report.Status)
+	require.Equal(t, 6, report.CheckedObjectCount)
+	require.Equal(t, 2, report.ObjectCounts[snapshotFileContentData])
+	require.Equal(t, 2, report.MissingObjectCount)
+	require.Equal(t, []FileIntegrityIssue{
+		{Path: "s3://archive/events/deletes.parquet", Content: snapshotFileContentPositionDeletes, ExpectedSizeBytes: size(64)},
+	}, report.MissingObjects)
+	require.Equal(t, 1, report.SizeMismatchCount)
+	require.Equal(t, []FileIntegrityIssue{
+		{Path: "s3://warehouse/db/events/data/a.parquet", Content: snapshotFileContentData, ExpectedSizeBytes: size(1024), ActualSizeBytes: size(1000)},
+	}, report.SizeMismatches)
+
+	existing[s3ObjectKey{bucket: "warehouse", key: "db/events/data/a.parquet"}] = 1024
+	existing[s3ObjectKey{bucket: "warehouse", key: "db/events/data/b.parquet"}] = 2048
+	existing[s3ObjectKey{bucket: "archive", key: "events/deletes.parquet"}] = 64
+
+	report, err = checkFileIntegrity(objects, existing, 1)
+	require.NoError(t, err)
+	require.Equal(t, statusOK, report.Status)
+	require.Empty(t, report.MissingObjects)
+	require.Empty(t, report.SizeMismatches)
+
+	_, err = checkFileIntegrity([]IcebergTableObject{{Path: "hdfs://warehouse/a.parquet"}}, existing, 1)
+	require.ErrorContains(t, err, "unsupported object storage scheme")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	iceberg "github.com/apache/iceberg-go"
 	"github.com/apache/iceberg-go/table"
 	"github.com/justtrackio/gosoline/pkg/cfg"
 	"github.com/justtrackio/gosoline/pkg/log"
@@ -15,6 +16,7 @@ func NewServiceIceberg(ctx context.Context, config cfg.Config, logger log.Logger
 	var client *IcebergClient
 	var settings *IcebergSettings
 	var serviceSettings *ServiceSettings
+	var profiles *ServiceMaintenanceProfiles
 
 	if client, err = ProvideIcebergClient(ctx, config, logger); err != nil {
 		return nil, fmt.Errorf("could not create iceberg client: %w", err)
@@ -24,6 +26,10 @@ func NewServiceIceberg(ctx context.Context, config cfg.Config, logger log.Logger
 		return nil, fmt.Errorf("could not create settings service: %w", err)
 	}
 
+	if profiles, err = NewServiceMaintenanceProfiles(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create maintenance profiles service: %w", err)
+	}
+
 	if settings, err = ReadIcebergSettings(config); err != nil {
 		return nil, fmt.Errorf("could not unmarshal iceberg settings: %w", err)
 	}
@@ -33,6 +39,7 @@ func NewServiceIceberg(ctx context.Context, config cfg.Config, logger log.Logger
 		client:          client,
 		settings:        settings,
 		serviceSettings: serviceSettings,
+		profiles:        profiles,
 	}, nil
 }
 
@@ -41,6 +48,7 @@ type ServiceIceberg struct {
 	client          *IcebergClient
 	settings        *IcebergSettings
 	serviceSettings *ServiceSettings
+	profiles        *ServiceMaintenanceProfiles
 }
 
 func (s *ServiceIceberg) ListSnapshots(ctx context.Context, database string, logicalName string) ([]IcebergSnapshot, error) {
@@ -78,6 +86,35 @@ func (s *ServiceIceberg) ListSnapshots(ctx context.Context, database string, log
 	return result, nil
 }
 
+// ListSnapshotRefs returns the branches and tags of a table, see snapshotRefsFromMetadata.
+func (s *ServiceIceberg) ListSnapshotRefs(ctx context.Context, database string, logicalName string) ([]SnapshotRef, error) {
+	refs, err := s.client.ListSnapshotRefs(ctx, database, logicalName)
+	if err != nil {
+		return nil, fmt.Errorf("could not list snapshot refs from iceberg: %w", err)
+	}
+
+	for i := range refs {
+		refs[i].Database = database
+		refs[i].Table = logicalName
+	}
+
+	return refs, nil
+}
+
+// DiffSnapshots compares the snapshots from and to of a table, see diffSnapshotStates.
+func (s *ServiceIceberg) DiffSnapshots(ctx context.Context, database string, logicalName string, from int64, to int64, limit int, offset int) (*SnapshotDiff, error) {
+	states, err := s.client.ListSnapshotStates(ctx, database, logicalName, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("could not list snapshot states from iceberg: %w", err)
+	}
+
+	diff := diffSnapshotStates(states[0], states[1], limit, offset)
+
+	s.logger.Info(ctx, "diffed snapshots %d and %d of table %s.%s: %d changed files", from, to, database, logicalName, diff.TotalFiles)
+
+	return diff, nil
+}
+
 func (s *ServiceIceberg) ListTables(ctx context.Context, database string) ([]CatalogTable, error) {
 	var err error
 	var tables []table.Identifier
@@ -115,14 +152,23 @@ func (s *ServiceIceberg) DescribeTable(ctx context.Context, database string, log
 }
 
 func (s *ServiceIceberg) ListPartitions(ctx context.Context, database string, logicalName string) ([]IcebergPartition, error) {
+	return s.ListPartitionsMatching(ctx, database, logicalName, iceberg.AlwaysTrue{})
+}
+
+// ListPartitionsMatching lists the partitions of the files selected by rowFilter, see
+// IcebergClient.ListPartitionsMatching.
+func (s *ServiceIceberg) ListPartitionsMatching(ctx context.Context, database string, logicalName string, rowFilter iceberg.BooleanExpression) ([]IcebergPartition, error) {
 	var err error
 	var partitionStats []IcebergPartitionStats
 	var needsOptimization bool
 	var smallFileThresholdBytes int64
 	var smallFileMinCount int
 	var smallFileMinSharePct int
+	var deleteFileMinCount int
+	var deleteFileMinRatioPct int
+	var profile MaintenanceProfile
 
-	if partitionStats, err = s.client.ListPartitions(ctx, database, logicalName); err != nil {
+	if partitionStats, err = s.client.ListPartitionsMatching(ctx, database, logicalName, rowFilter); err != nil {
 		return nil, fmt.Errorf("could not list partitions from iceberg: %w", err)
 	}
 
@@ -130,10 +176,18 @@ func (s *ServiceIceberg) ListPartitions(ctx context.Context, database string, lo
 		return nil, fmt.Errorf("could not load iceberg small file threshold bytes: %w", err)
 	}
 
+	if profile, err = s.profiles.ResolveProfile(ctx, database, logicalName); err != nil {
+		return nil, fmt.Errorf("could not resolve maintenance profile: %w", err)
+	}
+
+	smallFileThresholdBytes = valueOrDefault(profile.SmallFileThresholdBytes, smallFileThresholdBytes)
+
 	if smallFileMinCount, err = s.serviceSettings.GetIntSetting(ctx, settingKeySmallFileMinCount, defaultSmallFileMinCount); err != nil {
 		return nil, fmt.Errorf("could not load iceberg small file minimum count: %w", err)
 	}
 
+	smallFileMinCount = valueOrDefault(profile.SmallFileMinCount, smallFileMinCount)
+
 	if smallFileMinCount < 1 {
 		return nil, fmt.Errorf("iceberg small file minimum count must be at least 1")
 	}
@@ -142,10 +196,24 @@ func (s *ServiceIceberg) ListPartitions(ctx context.Context, database string, lo
 		return nil, fmt.Errorf("could not load iceberg small file minimum share percent: %w", err)
 	}
 
+	smallFileMinSharePct = valueOrDefault(profile.SmallFileMinSharePct, smallFileMinSharePct)
+
 	if smallFileMinSharePct < 0 || smallFileMinSharePct > 100 {
 		return nil, fmt.Errorf("iceberg small file minimum share percent must be between 0 and 100")
 	}
 
+	if deleteFileMinCount, err = s.serviceSettings.GetIntSetting(ctx, settingKeyDeleteFileMinCount, defaultDeleteFileMinCount); err != nil {
+		return nil, fmt.Errorf("could not load iceberg delete file minimum count: %w", err)
+	}
+
+	if deleteFileMinRatioPct, err = s.serviceSettings.GetIntSetting(ctx, settingKeyDeleteFileMinRatioPct, defaultDeleteFileMinRatioPct); err != nil {
+		return nil, fmt.Errorf("could not load iceberg delete file minimum ratio percent: %w", err)
+	}
+
+	if deleteFileMinCount < 0 || deleteFileMinRatioPct < 0 {
+		return nil, fmt.Errorf("iceberg delete file thresholds must not be negative")
+	}
+
 	result := make([]IcebergPartition, len(partitionStats))
 	for i, stats := range partitionStats {
 		if needsOptimization, err = s.partitionNeedsOptimize(stats, smallFileThresholdBytes, int64(smallFileMinCount), int64(smallFileMinSharePct)); err != nil {
@@ -153,14 +221,17 @@ func (s *ServiceIceberg) ListPartitions(ctx context.Context, database string, lo
 		}
 
 		result[i] = IcebergPartition{
-			Partition:         stats.Partition,
-			SpecID:            stats.SpecID,
-			RecordCount:       stats.RecordCount,
-			FileCount:         stats.Files.Len(),
-			DataFileSizeBytes: stats.Files.Bytes(),
-			NeedsOptimize:     needsOptimization,
-			LastUpdatedAt:     time.UnixMilli(stats.LastUpdatedAt),
-			LastSnapshotID:    stats.LastSnapshotID,
+			Partition:           stats.Partition,
+			SpecID:              stats.SpecID,
+			RecordCount:         stats.RecordCount,
+			FileCount:           stats.Files.Len(),
+			DataFileSizeBytes:   stats.Files.Bytes(),
+			DeleteFileCount:     stats.DeleteFiles.Len(),
+			DeleteFileSizeBytes: stats.DeleteFiles.Bytes(),
+			NeedsOptimize:       needsOptimization,
+			NeedsCompaction:     partitionNeedsCompaction(stats, int64(deleteFileMinCount), int64(deleteFileMinRatioPct)),
+			LastUpdatedAt:       time.UnixMilli(stats.LastUpdatedAt),
+			LastSnapshotID:      stats.LastSnapshotID,
 		}
 	}
 
@@ -223,6 +294,22 @@ func (s *ServiceIceberg) partitionNeedsOptimize(stats IcebergPartitionStats, sma
 	return needsOptimize, nil
 }
 
+// partitionNeedsCompaction flags partitions whose delete files slow down reads: either the number of
+// delete files reaches deleteFileMinCount, or the delete files make up at least deleteFileMinRatioPct
+// percent of the data files. A threshold of 0 disables the respective check.
+func partitionNeedsCompaction(stats IcebergPartitionStats, deleteFileMinCount int64, deleteFileMinRatioPct int64) bool {
+	deleteFileCount := stats.DeleteFiles.Len()
+	if deleteFileCount == 0 {
+		return false
+	}
+
+	if deleteFileMinCount > 0 && deleteFileCount >= deleteFileMinCount {
+		return true
+	}
+
+	return deleteFileMinRatioPct > 0 && deleteFileCount*100 >= stats.Files.Len()*deleteFileMinRatioPct
+}
+
 func latestOptimizablePartitionDate(now time.Time, delay time.Duration) time.Time {
 	now = now.Add(-delay)
 
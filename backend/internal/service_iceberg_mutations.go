@@ -2,8 +2,13 @@ package internal
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/apache/iceberg-go/table"
+	"github.com/gosoline-project/sqlc"
 	"github.com/justtrackio/gosoline/pkg/cfg"
 	"github.com/justtrackio/gosoline/pkg/log"
 )
@@ -11,38 +16,390 @@ import (
 func NewServiceIcebergAdmin(ctx context.Context, config cfg.Config, logger log.Logger) (*ServiceIcebergAdmin, error) {
 	var err error
 	var trino *TrinoClient
+	var client *IcebergClient
+	var metadata *ServiceMetadata
+	var files *ServiceFileIntegrity
+	var taskQueue *ServiceTaskQueue
+	var sqlClient sqlc.Client
 	var settings *IcebergSettings
 
 	if trino, err = ProvideTrinoClient(ctx, config, logger); err != nil {
 		return nil, fmt.Errorf("could not create trino client: %w", err)
 	}
 
+	if client, err = ProvideIcebergClient(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create iceberg client: %w", err)
+	}
+
+	if metadata, err = NewServiceMetadata(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create metadata service: %w", err)
+	}
+
+	if files, err = NewServiceFileIntegrity(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create file integrity service: %w", err)
+	}
+
+	if taskQueue, err = NewServiceTaskQueue(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create task queue service: %w", err)
+	}
+
+	if sqlClient, err = sqlc.ProvideClient(ctx, config, logger, "default"); err != nil {
+		return nil, fmt.Errorf("could not create sql client: %w", err)
+	}
+
 	if settings, err = ReadIcebergSettings(config); err != nil {
 		return nil, fmt.Errorf("could not read iceberg settings: %w", err)
 	}
 
 	return &ServiceIcebergAdmin{
-		logger:   logger.WithChannel("iceberg_admin"),
-		trino:    trino,
-		settings: settings,
+		logger:    logger.WithChannel("iceberg_admin"),
+		trino:     trino,
+		client:    client,
+		metadata:  metadata,
+		files:     files,
+		taskQueue: taskQueue,
+		sqlClient: sqlClient,
+		settings:  settings,
 	}, nil
 }
 
 type ServiceIcebergAdmin struct {
-	logger   log.Logger
-	trino    *TrinoClient
-	settings *IcebergSettings
+	logger    log.Logger
+	trino     *TrinoClient
+	client    *IcebergClient
+	metadata  *ServiceMetadata
+	files     *ServiceFileIntegrity
+	taskQueue *ServiceTaskQueue
+	sqlClient sqlc.Client
+	settings  *IcebergSettings
+}
+
+// PreviewRollback runs the pre-flight checks of a rollback to snapshotID: the snapshot must not be expired,
+// has to be an ancestor of the current snapshot and all of its data files have to exist. The returned preview
+// contains the impact of the rollback and the confirmation token RollbackToSnapshot expects.
+func (s *ServiceIcebergAdmin) PreviewRollback(ctx context.Context, database string, logicalName string, snapshotID int64) (*RollbackPreview, error) {
+	var err error
+	var tbl *table.Table
+	var discarded []Snapshot
+	var missingFiles []string
+
+	if tbl, err = s.client.LoadTable(ctx, database, logicalName); err != nil {
+		return nil, fmt.Errorf("could not load table %s.%s: %w", database, logicalName, err)
+	}
+
+	current := tbl.CurrentSnapshot()
+	if current == nil {
+		return nil, fmt.Errorf("table %s.%s has no current snapshot: %w", database, logicalName, errRollbackRejected)
+	}
+
+	if discarded, err = checkRollbackTarget(lineageFromIcebergSnapshots(tbl.Metadata().Snapshots()), current.SnapshotID, snapshotID); err != nil {
+		return nil, err
+	}
+
+	if missingFiles, err = s.files.ListMissingFiles(ctx, database, logicalName, snapshotID); err != nil {
+		return nil, fmt.Errorf("could not check data files of snapshot %d: %w", snapshotID, err)
+	}
+
+	if len(missingFiles) > 0 {
+		return nil, fmt.Errorf("%d data files of snapshot %d are missing, e.g. %s: %w", len(missingFiles), snapshotID, missingFiles[0], errRollbackRejected)
+	}
+
+	target := tbl.Metadata().SnapshotByID(snapshotID)
+	preview := &RollbackPreview{
+		Database:               database,
+		Table:                  logicalName,
+		SnapshotID:             snapshotID,
+		CurrentSnapshotID:      current.SnapshotID,
+		CommittedAt:            time.UnixMilli(target.TimestampMs),
+		DiscardedSnapshotCount: len(discarded),
+	}
+	applyRollbackImpact(preview, target.Summary, current.Summary)
+
+	if err = s.createRollbackConfirmation(ctx, preview); err != nil {
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+// PreviewRollbackToTimestamp previews a rollback to the snapshot RollbackToTimestamp would restore.
+func (s *ServiceIcebergAdmin) PreviewRollbackToTimestamp(ctx context.Context, database string, logicalName string, at time.Time) (*RollbackPreview, error) {
+	snapshotID, err := s.resolveRollbackTimestamp(ctx, database, logicalName, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.PreviewRollback(ctx, database, logicalName, snapshotID)
 }
 
-func (s *ServiceIcebergAdmin) RollbackToSnapshot(ctx context.Context, database string, logicalName string, snapshotID int64) error {
+// RollbackToSnapshot rolls the table back to snapshotID. The confirmation token of a preview of the same
+// rollback has to be presented and is consumed, the rollback is rejected if the table changed since the
+// preview. Every executed rollback is recorded as a task.
+func (s *ServiceIcebergAdmin) RollbackToSnapshot(ctx context.Context, database string, logicalName string, snapshotID int64, confirmationToken string) error {
+	var err error
+	var confirmation *RollbackConfirmation
+	var tbl *table.Table
+
+	if confirmation, err = s.consumeRollbackConfirmation(ctx, database, logicalName, snapshotID, confirmationToken); err != nil {
+		return err
+	}
+
+	if tbl, err = s.client.LoadTable(ctx, database, logicalName); err != nil {
+		return fmt.Errorf("could not load table %s.%s: %w", database, logicalName, err)
+	}
+
+	if current := tbl.CurrentSnapshot(); current == nil || current.SnapshotID != confirmation.CurrentSnapshotId {
+		return fmt.Errorf("the current snapshot of table %s.%s changed since the rollback was previewed: %w", database, logicalName, errRollbackRejected)
+	}
+
+	startedAt := time.Now()
 	qualifiedTable := qualifiedTableName(s.settings.Catalog, database, logicalName)
 	query := fmt.Sprintf("ALTER TABLE %s EXECUTE rollback_to_snapshot(%d)", qualifiedTable, snapshotID)
 
-	if err := s.trino.Exec(ctx, query); err != nil {
-		return fmt.Errorf("could not rollback table %s.%s to snapshot %d: %w", database, logicalName, snapshotID, err)
+	if err = s.trino.Exec(ctx, query); err != nil {
+		err = fmt.Errorf("could not rollback table %s.%s to snapshot %d: %w", database, logicalName, snapshotID, err)
+	}
+
+	s.recordSnapshotChange(ctx, TaskKindRollback, TaskEngineTrino, database, logicalName, snapshotID, &confirmation.CurrentSnapshotId, startedAt, err)
+
+	if err != nil {
+		return err
 	}
 
 	s.logger.Info(ctx, "rolled back table %s.%s to snapshot %d", database, logicalName, snapshotID)
 
 	return nil
 }
+
+// RollbackToTimestamp rolls the table back to the newest ancestor of its current snapshot committed at or
+// before at. The snapshot is resolved from the stored snapshots and returned.
+func (s *ServiceIcebergAdmin) RollbackToTimestamp(ctx context.Context, database string, logicalName string, at time.Time, confirmationToken string) (int64, error) {
+	snapshotID, err := s.resolveRollbackTimestamp(ctx, database, logicalName, at)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = s.RollbackToSnapshot(ctx, database, logicalName, snapshotID, confirmationToken); err != nil {
+		return 0, err
+	}
+
+	return snapshotID, nil
+}
+
+func (s *ServiceIcebergAdmin) resolveRollbackTimestamp(ctx context.Context, database string, logicalName string, at time.Time) (int64, error) {
+	var err error
+	var desc *TableDescription
+	var snapshots []Snapshot
+
+	if desc, err = s.metadata.GetTable(ctx, database, logicalName); err != nil {
+		return 0, fmt.Errorf("could not load table %s.%s: %w", database, logicalName, err)
+	}
+
+	if desc.CurrentSnapshotID == nil {
+		return 0, fmt.Errorf("table %s.%s has no current snapshot", database, logicalName)
+	}
+
+	if snapshots, err = s.metadata.ListSnapshots(ctx, database, logicalName); err != nil {
+		return 0, err
+	}
+
+	snapshot, ok := latestAncestorCommittedAt(snapshots, *desc.CurrentSnapshotID, at)
+	if !ok {
+		return 0, fmt.Errorf("table %s.%s has no snapshot committed at or before %s: %w", database, logicalName, at.UTC().Format(time.RFC3339), errRollbackRejected)
+	}
+
+	return snapshot.SnapshotId, nil
+}
+
+func (s *ServiceIcebergAdmin) createRollbackConfirmation(ctx context.Context, preview *RollbackPreview) error {
+	var err error
+
+	now := time.Now()
+	if _, err = s.sqlClient.Q().Delete("rollback_confirmations").Where(sqlc.Col("expires_at").Lte(now)).Exec(ctx); err != nil {
+		return fmt.Errorf("could not delete expired rollback confirmations: %w", err)
+	}
+
+	if preview.ConfirmationToken, err = newRollbackConfirmationToken(); err != nil {
+		return err
+	}
+
+	preview.ExpiresAt = now.Add(rollbackConfirmationTTL)
+	confirmation := &RollbackConfirmation{
+		Token:             preview.ConfirmationToken,
+		Database:          preview.Database,
+		Table:             preview.Table,
+		SnapshotId:        preview.SnapshotID,
+		CurrentSnapshotId: preview.CurrentSnapshotID,
+		ExpiresAt:         preview.ExpiresAt,
+		CreatedAt:         now,
+	}
+
+	if _, err = s.sqlClient.Q().Into("rollback_confirmations").Records(confirmation).Exec(ctx); err != nil {
+		return fmt.Errorf("could not store rollback confirmation: %w", err)
+	}
+
+	return nil
+}
+
+// consumeRollbackConfirmation loads the confirmation for the rollback and deletes it, so every token can
+// only be used once.
+func (s *ServiceIcebergAdmin) consumeRollbackConfirmation(ctx context.Context, database string, logicalName string, snapshotID int64, token string) (*RollbackConfirmation, error) {
+	if token == "" {
+		return nil, fmt.Errorf("a confirmation token is required, preview the rollback with dry_run first: %w", errRollbackRejected)
+	}
+
+	confirmation := &RollbackConfirmation{}
+	sel := s.sqlClient.Q().From("rollback_confirmations").
+		Where(sqlc.Eq{"token": token, "database": database, "table": logicalName, "snapshot_id": snapshotID}).
+		Where(sqlc.Col("expires_at").Gte(time.Now()))
+
+	if err := sel.Get(ctx, confirmation); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("the confirmation token is invalid or expired: %w", errRollbackRejected)
+		}
+
+		return nil, fmt.Errorf("could not load rollback confirmation: %w", err)
+	}
+
+	res, err := s.sqlClient.Q().Delete("rollback_confirmations").Where(sqlc.Eq{"token": token}).Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not consume rollback confirmation: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("could not get rows affected when consuming rollback confirmation: %w", err)
+	}
+
+	if affected == 0 {
+		return nil, fmt.Errorf("the confirmation token was already used: %w", errRollbackRejected)
+	}
+
+	return confirmation, nil
+}
+
+// recordSnapshotChange stores an executed change of the current snapshot as a task. A failure to do so is
+// only logged as the change itself already happened.
+func (s *ServiceIcebergAdmin) recordSnapshotChange(ctx context.Context, kind TaskKind, engine TaskEngine, database string, logicalName string, snapshotID int64, previousSnapshotID *int64, startedAt time.Time, changeErr error) {
+	input := map[string]any{
+		"snapshot_id":          snapshotID,
+		"previous_snapshot_id": previousSnapshotID,
+	}
+
+	var result map[string]any
+	if changeErr == nil {
+		result = map[string]any{"current_snapshot_id": snapshotID}
+	}
+
+	if _, err := s.taskQueue.RecordTask(ctx, database, logicalName, string(kind), string(engine), input, result, startedAt, changeErr); err != nil {
+		s.logger.Error(ctx, "could not record %s of table %s.%s to snapshot %d: %s", kind, database, logicalName, snapshotID, err)
+	}
+}
+
+// SetCurrentSnapshot points the main branch of the table to snapshotID. Moving back to an ancestor of the
+// current snapshot discards snapshots just like a rollback and therefore is executed as one, including its
+// pre-flight checks and confirmation token. Any other move is executed directly. Both are recorded as tasks.
+func (s *ServiceIcebergAdmin) SetCurrentSnapshot(ctx context.Context, database string, logicalName string, snapshotID int64, confirmationToken string) error {
+	var err error
+	var tbl *table.Table
+	var previousSnapshotID *int64
+
+	if tbl, err = s.client.LoadTable(ctx, database, logicalName); err != nil {
+		return fmt.Errorf("could not load table %s.%s: %w", database, logicalName, err)
+	}
+
+	if current := tbl.CurrentSnapshot(); current != nil {
+		if isProperAncestor(lineageFromIcebergSnapshots(tbl.Metadata().Snapshots()), current.SnapshotID, snapshotID) {
+			return s.RollbackToSnapshot(ctx, database, logicalName, snapshotID, confirmationToken)
+		}
+
+		previousSnapshotID = &current.SnapshotID
+	}
+
+	startedAt := time.Now()
+	if err = s.client.SetCurrentSnapshot(ctx, tbl, snapshotID); err != nil {
+		err = fmt.Errorf("could not set current snapshot of table %s.%s to %d: %w", database, logicalName, snapshotID, err)
+	}
+
+	s.recordSnapshotChange(ctx, TaskKindSetCurrentSnapshot, TaskEngineCatalog, database, logicalName, snapshotID, previousSnapshotID, startedAt, err)
+
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info(ctx, "set current snapshot of table %s.%s to %d", database, logicalName, snapshotID)
+
+	return nil
+}
+
+// CherryPickSnapshot applies the staged snapshot to the main branch and returns the resulting current
+// snapshot, see IcebergClient.CherryPickSnapshot.
+func (s *ServiceIcebergAdmin) CherryPickSnapshot(ctx context.Context, database string, logicalName string, snapshotID int64) (int64, error) {
+	currentSnapshotID, err := s.client.CherryPickSnapshot(ctx, database, logicalName, snapshotID)
+	if err != nil {
+		return 0, fmt.Errorf("could not cherry-pick snapshot %d into table %s.%s: %w", snapshotID, database, logicalName, err)
+	}
+
+	s.logger.Info(ctx, "cherry-picked snapshot %d into table %s.%s as snapshot %d", snapshotID, database, logicalName, currentSnapshotID)
+
+	return currentSnapshotID, nil
+}
+
+// SetTag creates the tag or, with replace, moves the existing tag to snapshotID.
+func (s *ServiceIcebergAdmin) SetTag(ctx context.Context, database string, logicalName string, tag string, snapshotID int64, maxRefAgeMs *int64, replace bool) error {
+	retention := SnapshotRefRetention{MaxRefAgeMs: maxRefAgeMs}
+	if err := s.client.SetSnapshotRef(ctx, database, logicalName, tag, table.TagRef, snapshotID, retention, replace); err != nil {
+		return fmt.Errorf("could not tag snapshot %d of table %s.%s as %s: %w", snapshotID, database, logicalName, tag, err)
+	}
+
+	s.logger.Info(ctx, "tagged snapshot %d of table %s.%s as %s", snapshotID, database, logicalName, tag)
+
+	return nil
+}
+
+func (s *ServiceIcebergAdmin) DropTag(ctx context.Context, database string, logicalName string, tag string) error {
+	if err := s.client.RemoveSnapshotRef(ctx, database, logicalName, tag, table.TagRef); err != nil {
+		return fmt.Errorf("could not drop tag %s of table %s.%s: %w", tag, database, logicalName, err)
+	}
+
+	s.logger.Info(ctx, "dropped tag %s of table %s.%s", tag, database, logicalName)
+
+	return nil
+}
+
+// CreateBranch creates the branch at snapshotID, or at the current snapshot if snapshotID is 0. The
+// snapshot the branch was created at is returned.
+func (s *ServiceIcebergAdmin) CreateBranch(ctx context.Context, database string, logicalName string, branch string, snapshotID int64, retention SnapshotRefRetention) (int64, error) {
+	if snapshotID == 0 {
+		tbl, err := s.client.LoadTable(ctx, database, logicalName)
+		if err != nil {
+			return 0, fmt.Errorf("could not load table %s.%s: %w", database, logicalName, err)
+		}
+
+		current := tbl.CurrentSnapshot()
+		if current == nil {
+			return 0, fmt.Errorf("table %s.%s has no current snapshot to branch from: %w", database, logicalName, errSnapshotRefRejected)
+		}
+
+		snapshotID = current.SnapshotID
+	}
+
+	if err := s.client.SetSnapshotRef(ctx, database, logicalName, branch, table.BranchRef, snapshotID, retention, false); err != nil {
+		return 0, fmt.Errorf("could not create branch %s of table %s.%s: %w", branch, database, logicalName, err)
+	}
+
+	s.logger.Info(ctx, "created branch %s of table %s.%s at snapshot %d", branch, database, logicalName, snapshotID)
+
+	return snapshotID, nil
+}
+
+func (s *ServiceIcebergAdmin) FastForwardBranch(ctx context.Context, database string, logicalName string, branch string, to string) (int64, error) {
+	snapshotID, err := s.client.FastForwardBranch(ctx, database, logicalName, branch, to)
+	if err != nil {
+		return 0, fmt.Errorf("could not fast-forward branch %s of table %s.%s to %s: %w", branch, database, logicalName, to, err)
+	}
+
+	s.logger.Info(ctx, "fast-forwarded branch %s of table %s.%s to snapshot %d", branch, database, logicalName, snapshotID)
+
+	return snapshotID, nil
+}
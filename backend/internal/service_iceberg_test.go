@@ -98,6 +98,69 @@ func TestPartitionNeedsOptimizeWithoutDateSkipsDelayCheck(t *testing.T) {
 	require.True(t, needsOptimize)
 }
 
+func TestPartitionNeedsCompactionUsesDeleteFileCountOrRatio(t *testing.T) {
+	testCases := []struct {
+		name          string
+		dataFiles     int
+		deleteFiles   int
+		minCount      int64
+		minRatioPct   int64
+		expectedValue bool
+	}{
+		{
+			name:          "without delete files",
+			dataFiles:     10,
+			deleteFiles:   0,
+			minCount:      1,
+			minRatioPct:   1,
+			expectedValue: false,
+		},
+		{
+			name:          "few delete files among many data files",
+			dataFiles:     100,
+			deleteFiles:   5,
+			minCount:      10,
+			minRatioPct:   10,
+			expectedValue: false,
+		},
+		{
+			name:          "delete file count reached",
+			dataFiles:     1000,
+			deleteFiles:   10,
+			minCount:      10,
+			minRatioPct:   10,
+			expectedValue: true,
+		},
+		{
+			name:          "delete to data ratio reached",
+			dataFiles:     20,
+			deleteFiles:   2,
+			minCount:      10,
+			minRatioPct:   10,
+			expectedValue: true,
+		},
+		{
+			name:          "disabled thresholds",
+			dataFiles:     20,
+			deleteFiles:   20,
+			minCount:      0,
+			minRatioPct:   0,
+			expectedValue: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			stats := IcebergPartitionStats{
+				Files:       make(IcebergPartitionStatsFiles, testCase.dataFiles),
+				DeleteFiles: make(IcebergPartitionStatsFiles, testCase.deleteFiles),
+			}
+
+			require.Equal(t, testCase.expectedValue, partitionNeedsCompaction(stats, testCase.minCount, testCase.minRatioPct))
+		})
+	}
+}
+
 func partitionValuesForDate(date time.Time) PartitionValues {
 	return PartitionValues{
 		"year":  date.Format("2006"),
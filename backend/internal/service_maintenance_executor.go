@@ -3,36 +3,81 @@ package internal
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/justtrackio/gosoline/pkg/appctx"
 	"github.com/justtrackio/gosoline/pkg/cfg"
 	"github.com/justtrackio/gosoline/pkg/log"
 )
 
+const (
+	engineHealthHealthy   = "healthy"
+	engineHealthUnhealthy = "unhealthy"
+	engineHealthUnknown   = "unknown"
+)
+
+const engineHealthCheckTimeout = 10 * time.Second
+
+// EngineStatus describes a registered maintenance engine, the task kinds it supports, the task kinds
+// tasks.engines routes to it and whether it is currently reachable.
+type EngineStatus struct {
+	Engine          TaskEngine `json:"engine"`
+	SupportedKinds  []TaskKind `json:"supported_kinds"`
+	ConfiguredKinds []TaskKind `json:"configured_kinds"`
+	Health          string     `json:"health"`
+	Error           string     `json:"error,omitempty"`
+}
+
+type serviceMaintenanceExecutorCtxKey struct{}
+
+func ProvideServiceMaintenanceExecutor(ctx context.Context, config cfg.Config, logger log.Logger) (*ServiceMaintenanceExecutor, error) {
+	return appctx.Provide(ctx, serviceMaintenanceExecutorCtxKey{}, func() (*ServiceMaintenanceExecutor, error) {
+		return NewServiceMaintenanceExecutor(ctx, config, logger)
+	})
+}
+
+// NewServiceMaintenanceExecutor creates the executors of all registered engines. The engine configuration
+// in tasks.engines is validated against the registered engines first, so a misconfiguration fails the
+// startup instead of the first task.
 func NewServiceMaintenanceExecutor(ctx context.Context, config cfg.Config, logger log.Logger) (*ServiceMaintenanceExecutor, error) {
 	var err error
-	var trino *TrinoMaintenanceExecutor
-	var spark *SparkMaintenanceExecutor
+	var engineResolver *TaskEngineResolver
 
-	if trino, err = NewTrinoMaintenanceExecutor(ctx, config, logger); err != nil {
-		return nil, fmt.Errorf("could not create trino maintenance executor: %w", err)
+	if engineResolver, err = NewTaskEngineResolver(config); err != nil {
+		return nil, fmt.Errorf("could not create task engine resolver: %w", err)
 	}
 
-	if spark, err = NewSparkMaintenanceExecutor(ctx, config, logger); err != nil {
-		return nil, fmt.Errorf("could not create spark maintenance executor: %w", err)
+	registrations := registeredMaintenanceExecutors()
+	service := &ServiceMaintenanceExecutor{
+		engineResolver: engineResolver,
+		registrations:  registrations,
+		all:            make([]MaintenanceExecutor, 0, len(registrations)),
+		executors:      make(map[TaskEngine]MaintenanceExecutor, len(registrations)),
 	}
 
-	return &ServiceMaintenanceExecutor{
-		all: []MaintenanceExecutor{trino, spark},
-		executors: map[TaskEngine]MaintenanceExecutor{
-			TaskEngineTrino: trino,
-			TaskEngineSpark: spark,
-		},
-	}, nil
+	for _, registration := range registrations {
+		var executor MaintenanceExecutor
+
+		if executor, err = registration.Factory(ctx, config, logger); err != nil {
+			return nil, fmt.Errorf("could not create %s maintenance executor: %w", registration.Engine, err)
+		}
+
+		if executor.Engine() != registration.Engine {
+			return nil, fmt.Errorf("maintenance executor registered for engine %s reports engine %s", registration.Engine, executor.Engine())
+		}
+
+		service.all = append(service.all, executor)
+		service.executors[registration.Engine] = executor
+	}
+
+	return service, nil
 }
 
 type ServiceMaintenanceExecutor struct {
-	all       []MaintenanceExecutor
-	executors map[TaskEngine]MaintenanceExecutor
+	engineResolver *TaskEngineResolver
+	registrations  []MaintenanceExecutorRegistration
+	all            []MaintenanceExecutor
+	executors      map[TaskEngine]MaintenanceExecutor
 }
 
 func (s *ServiceMaintenanceExecutor) All() []MaintenanceExecutor {
@@ -47,3 +92,38 @@ func (s *ServiceMaintenanceExecutor) ForEngine(engine TaskEngine) (MaintenanceEx
 
 	return executor, nil
 }
+
+// ListEngines returns the status of all registered engines. Executors which do not implement
+// MaintenanceExecutorHealthChecker are reported with an unknown health.
+func (s *ServiceMaintenanceExecutor) ListEngines(ctx context.Context) []EngineStatus {
+	statuses := make([]EngineStatus, len(s.registrations))
+
+	for i, registration := range s.registrations {
+		statuses[i] = EngineStatus{
+			Engine:          registration.Engine,
+			SupportedKinds:  registration.Kinds,
+			ConfiguredKinds: s.engineResolver.KindsForEngine(registration.Engine),
+			Health:          engineHealthUnknown,
+		}
+
+		checker, ok := s.executors[registration.Engine].(MaintenanceExecutorHealthChecker)
+		if !ok {
+			continue
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, engineHealthCheckTimeout)
+		err := checker.HealthCheck(checkCtx)
+		cancel()
+
+		if err != nil {
+			statuses[i].Health = engineHealthUnhealthy
+			statuses[i].Error = err.Error()
+
+			continue
+		}
+
+		statuses[i].Health = engineHealthHealthy
+	}
+
+	return statuses
+}
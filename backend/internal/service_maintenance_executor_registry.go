@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/justtrackio/gosoline/pkg/cfg"
+	"github.com/justtrackio/gosoline/pkg/log"
+)
+
+// MaintenanceExecutorFactory creates the executor of a maintenance engine.
+type MaintenanceExecutorFactory func(ctx context.Context, config cfg.Config, logger log.Logger) (MaintenanceExecutor, error)
+
+// MaintenanceExecutorRegistration declares a maintenance engine, the task kinds it is able to run and
+// the factory of its executor.
+type MaintenanceExecutorRegistration struct {
+	Engine  TaskEngine
+	Kinds   []TaskKind
+	Factory MaintenanceExecutorFactory
+}
+
+type maintenanceExecutorRegistry struct {
+	mutex         sync.RWMutex
+	registrations []MaintenanceExecutorRegistration
+}
+
+var defaultMaintenanceExecutorRegistry = &maintenanceExecutorRegistry{
+	registrations: []MaintenanceExecutorRegistration{
+		{
+			Engine:  TaskEngineTrino,
+			Kinds:   []TaskKind{TaskKindExpireSnapshots, TaskKindRemoveOrphanFiles, TaskKindOptimize, TaskKindRewriteManifests, TaskKindCheckFileIntegrity},
+			Factory: newMaintenanceExecutorFactory(NewTrinoMaintenanceExecutor),
+		},
+		{
+			Engine:  TaskEngineSpark,
+			Kinds:   []TaskKind{TaskKindExpireSnapshots, TaskKindRemoveOrphanFiles, TaskKindOptimize, TaskKindRewriteManifests, TaskKindRewritePositionDeleteFiles, TaskKindCheckFileIntegrity},
+			Factory: newMaintenanceExecutorFactory(NewSparkMaintenanceExecutor),
+		},
+	},
+}
+
+// RegisterMaintenanceExecutor adds an engine to the executors the task worker runs. It has to be called
+// before the application is started, e.g. in main, and fails for duplicate engines or unknown task kinds.
+func RegisterMaintenanceExecutor(registration MaintenanceExecutorRegistration) error {
+	return defaultMaintenanceExecutorRegistry.register(registration)
+}
+
+func registeredMaintenanceExecutors() []MaintenanceExecutorRegistration {
+	return defaultMaintenanceExecutorRegistry.all()
+}
+
+func registeredTaskEngines() []TaskEngine {
+	return defaultMaintenanceExecutorRegistry.engines()
+}
+
+func lookupMaintenanceExecutor(engine TaskEngine) (MaintenanceExecutorRegistration, bool) {
+	return defaultMaintenanceExecutorRegistry.lookup(engine)
+}
+
+func (r *maintenanceExecutorRegistry) register(registration MaintenanceExecutorRegistration) error {
+	if registration.Engine == "" {
+		return fmt.Errorf("maintenance executor engine is required")
+	}
+
+	if registration.Factory == nil {
+		return fmt.Errorf("maintenance executor factory of engine %s is required", registration.Engine)
+	}
+
+	if len(registration.Kinds) == 0 {
+		return fmt.Errorf("maintenance executor of engine %s has to support at least one task kind", registration.Engine)
+	}
+
+	for _, kind := range registration.Kinds {
+		if !slices.Contains(taskKinds, kind) {
+			return fmt.Errorf("maintenance executor of engine %s declares unknown task kind %s", registration.Engine, kind)
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.registrations {
+		if existing.Engine == registration.Engine {
+			return fmt.Errorf("maintenance executor of engine %s is already registered", registration.Engine)
+		}
+	}
+
+	registration.Kinds = slices.Clone(registration.Kinds)
+	r.registrations = append(r.registrations, registration)
+
+	return nil
+}
+
+func (r *maintenanceExecutorRegistry) all() []MaintenanceExecutorRegistration {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return slices.Clone(r.registrations)
+}
+
+func (r *maintenanceExecutorRegistry) engines() []TaskEngine {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	engines := make([]TaskEngine, len(r.registrations))
+	for i, registration := range r.registrations {
+		engines[i] = registration.Engine
+	}
+
+	return engines
+}
+
+func (r *maintenanceExecutorRegistry) lookup(engine TaskEngine) (MaintenanceExecutorRegistration, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, registration := range r.registrations {
+		if registration.Engine == engine {
+			return registration, true
+		}
+	}
+
+	return MaintenanceExecutorRegistration{}, false
+}
+
+// newMaintenanceExecutorFactory adapts the constructor of a concrete executor to a MaintenanceExecutorFactory.
+func newMaintenanceExecutorFactory[T MaintenanceExecutor](factory func(ctx context.Context, config cfg.Config, logger log.Logger) (T, error)) MaintenanceExecutorFactory {
+	return func(ctx context.Context, config cfg.Config, logger log.Logger) (MaintenanceExecutor, error) {
+		executor, err := factory(ctx, config, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		return executor, nil
+	}
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/justtrackio/gosoline/pkg/cfg"
+	"github.com/justtrackio/gosoline/pkg/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceExecutorRegistryRegister(t *testing.T) {
+	factory := func(context.Context, cfg.Config, log.Logger) (MaintenanceExecutor, error) {
+		return nil, nil
+	}
+
+	registry := &maintenanceExecutorRegistry{}
+
+	require.NoError(t, registry.register(MaintenanceExecutorRegistration{Engine: "local", Kinds: []TaskKind{TaskKindExpireSnapshots}, Factory: factory}))
+	require.ErrorContains(t, registry.register(MaintenanceExecutorRegistration{Engine: "local", Kinds: []TaskKind{TaskKindOptimize}, Factory: factory}), "already registered")
+	require.ErrorContains(t, registry.register(MaintenanceExecutorRegistration{Engine: "emr", Kinds: []TaskKind{"vacuum"}, Factory: factory}), "unknown task kind")
+	require.ErrorContains(t, registry.register(MaintenanceExecutorRegistration{Engine: "emr", Factory: factory}), "at least one task kind")
+	require.ErrorContains(t, registry.register(MaintenanceExecutorRegistration{Engine: "emr", Kinds: []TaskKind{TaskKindOptimize}}), "factory")
+
+	require.Equal(t, []TaskEngine{"local"}, registry.engines())
+
+	registration, ok := registry.lookup("local")
+	require.True(t, ok)
+	require.Equal(t, []TaskKind{TaskKindExpireSnapshots}, registration.Kinds)
+
+	_, ok = registry.lookup("emr")
+	require.False(t, ok)
+}
+
+func TestValidateTaskEngineUsesDeclaredKinds(t *testing.T) {
+	require.NoError(t, validateTaskEngine(TaskKindOptimize, TaskEngineTrino))
+	require.NoError(t, validateTaskEngine(TaskKindRewritePositionDeleteFiles, TaskEngineSpark))
+	require.ErrorContains(t, validateTaskEngine(TaskKindRewritePositionDeleteFiles, TaskEngineTrino), "not supported by engine trino")
+	require.NoError(t, validateTaskEngine(TaskKindCheckFileIntegrity, TaskEngineTrino))
+	require.NoError(t, validateTaskEngine(TaskKindCheckFileIntegrity, TaskEngineSpark))
+	require.ErrorContains(t, validateTaskEngine(TaskKindOptimize, "flink"), "invalid engine")
+}
+
+func TestTaskEngineResolverKindsForEngine(t *testing.T) {
+	resolver := &TaskEngineResolver{engines: map[TaskKind]TaskEngine{
+		TaskKindExpireSnapshots:            TaskEngineTrino,
+		TaskKindRemoveOrphanFiles:          TaskEngineTrino,
+		TaskKindOptimize:                   TaskEngineSpark,
+		TaskKindRewriteManifests:           TaskEngineTrino,
+		TaskKindRewritePositionDeleteFiles: TaskEngineSpark,
+	}}
+
+	require.Equal(t, []TaskKind{TaskKindOptimize, TaskKindRewritePositionDeleteFiles}, resolver.KindsForEngine(TaskEngineSpark))
+	require.Equal(t, []TaskKind{}, resolver.KindsForEngine("local"))
+}
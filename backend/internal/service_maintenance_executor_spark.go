@@ -20,6 +20,8 @@ type OptimizeResult struct {
 	Table            string `json:"table"`
 	TargetFileSizeMb int    `json:"target_file_size_mb"`
 	Where            string `json:"where"`
+	Strategy         string `json:"strategy"`
+	SortOrder        string `json:"sort_order"`
 	ApplicationName  string `json:"application_name"`
 	Status           string `json:"status"`
 }
@@ -33,17 +35,21 @@ type RemoveOrphanFilesParameters struct {
 }
 
 const (
-	sparkApplicationTaskIDAnnotation    = "lakehouse-admin.justtrack.io/task-id"
-	sparkApplicationTaskKindAnnotation  = "lakehouse-admin.justtrack.io/task-kind"
-	sparkApplicationTaskTableAnnotation = "lakehouse-admin.justtrack.io/task-table"
+	sparkApplicationTaskIDAnnotation       = "lakehouse-admin.justtrack.io/task-id"
+	sparkApplicationTaskClaimAnnotation    = "lakehouse-admin.justtrack.io/task-claim"
+	sparkApplicationTaskKindAnnotation     = "lakehouse-admin.justtrack.io/task-kind"
+	sparkApplicationTaskDatabaseAnnotation = "lakehouse-admin.justtrack.io/task-database"
+	sparkApplicationTaskTableAnnotation    = "lakehouse-admin.justtrack.io/task-table"
 )
 
 const sparkMaintenancePyFile = "maintenance.py"
 
 const (
-	sparkProcedureRewriteDataFiles  = "rewrite_data_files"
-	sparkProcedureExpireSnapshots   = "expire_snapshots"
-	sparkProcedureRemoveOrphanFiles = "remove_orphan_files"
+	sparkProcedureRewriteDataFiles           = "rewrite_data_files"
+	sparkProcedureExpireSnapshots            = "expire_snapshots"
+	sparkProcedureRemoveOrphanFiles          = "remove_orphan_files"
+	sparkProcedureRewriteManifests           = "rewrite_manifests"
+	sparkProcedureRewritePositionDeleteFiles = "rewrite_position_delete_files"
 )
 
 const sparkApplicationNameMaxLength = 63
@@ -53,8 +59,11 @@ type SparkMaintenanceExecutor struct {
 	metadata        *ServiceMetadata
 	k8s             SparkApplicationCreator
 	taskQueue       TaskClaimer
+	files           *ServiceFileIntegrity
 	icebergSettings *IcebergSettings
+	leaseSettings   *TaskLeaseSettings
 	settings        *SparkSettings
+	events          *TaskEventBus
 }
 
 func sparkTaskProcedure(taskKind TaskKind) (string, error) {
@@ -65,18 +74,36 @@ func sparkTaskProcedure(taskKind TaskKind) (string, error) {
 		return sparkProcedureExpireSnapshots, nil
 	case TaskKindRemoveOrphanFiles:
 		return sparkProcedureRemoveOrphanFiles, nil
+	case TaskKindRewriteManifests:
+		return sparkProcedureRewriteManifests, nil
+	case TaskKindRewritePositionDeleteFiles:
+		return sparkProcedureRewritePositionDeleteFiles, nil
 	default:
 		return "", fmt.Errorf("unknown task kind: %s", taskKind)
 	}
 }
 
+// sparkApplicationNamePrefix returns the application name prefix used for a task kind, which
+// is the dashed name of its procedure.
+func sparkApplicationNamePrefix(taskKind TaskKind) (string, error) {
+	procedure, err := sparkTaskProcedure(taskKind)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ReplaceAll(procedure, "_", "-"), nil
+}
+
 func NewSparkMaintenanceExecutor(ctx context.Context, config cfg.Config, logger log.Logger) (*SparkMaintenanceExecutor, error) {
 	var err error
 	var metadata *ServiceMetadata
 	var k8s *K8sService
 	var taskQueue TaskClaimer
+	var files *ServiceFileIntegrity
 	var icebergSettings *IcebergSettings
+	var leaseSettings *TaskLeaseSettings
 	var settings *SparkSettings
+	var events *TaskEventBus
 
 	if metadata, err = NewServiceMetadata(ctx, config, logger); err != nil {
 		return nil, fmt.Errorf("could not create metadata service: %w", err)
@@ -90,21 +117,36 @@ func NewSparkMaintenanceExecutor(ctx context.Context, config cfg.Config, logger
 		return nil, fmt.Errorf("could not create task queue service: %w", err)
 	}
 
+	if files, err = NewServiceFileIntegrity(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create file integrity service: %w", err)
+	}
+
 	if icebergSettings, err = ReadIcebergSettings(config); err != nil {
 		return nil, fmt.Errorf("could not read iceberg settings: %w", err)
 	}
 
+	if leaseSettings, err = ReadTaskLeaseSettings(config); err != nil {
+		return nil, fmt.Errorf("could not read task lease settings: %w", err)
+	}
+
 	if settings, err = ReadSparkSettings(config); err != nil {
 		return nil, fmt.Errorf("could not read spark settings: %w", err)
 	}
 
+	if events, err = ProvideTaskEventBus(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create task event bus: %w", err)
+	}
+
 	return &SparkMaintenanceExecutor{
 		logger:          logger.WithChannel("maintenance_executor_spark"),
 		metadata:        metadata,
 		k8s:             k8s,
 		taskQueue:       taskQueue,
+		files:           files,
 		icebergSettings: icebergSettings,
+		leaseSettings:   leaseSettings,
 		settings:        settings,
+		events:          events,
 	}, nil
 }
 
@@ -116,6 +158,8 @@ func (s *SparkMaintenanceExecutor) Run(ctx context.Context) error {
 	var err error
 	var informer cache.SharedIndexInformer
 
+	startedAt := time.Now()
+
 	if informer, err = s.k8s.WatchSparkApplications(ctx); err != nil {
 		return fmt.Errorf("could not watch spark applications: %w", err)
 	}
@@ -141,11 +185,96 @@ func (s *SparkMaintenanceExecutor) Run(ctx context.Context) error {
 		return fmt.Errorf("could not register spark application event handler: %w", err)
 	}
 
-	<-ctx.Done()
+	if err = s.reconcileRunningTasks(ctx, informer, startedAt); err != nil {
+		s.logger.Error(ctx, "could not reconcile running spark tasks: %s", err)
+	}
+
+	ticker := time.NewTicker(s.leaseSettings.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err = s.heartbeatRunningTasks(ctx, informer); err != nil {
+				s.logger.Error(ctx, "could not heartbeat running spark tasks: %s", err)
+			}
+		}
+	}
+}
+
+// reconcileRunningTasks fails running spark tasks which were picked up before this process started
+// and whose SparkApplication no longer exists, as no informer event will ever complete them.
+func (s *SparkMaintenanceExecutor) reconcileRunningTasks(ctx context.Context, informer cache.SharedIndexInformer, startedAt time.Time) error {
+	tasks, err := s.taskQueue.ListRunningTasks(ctx, TaskEngineSpark)
+	if err != nil {
+		return err
+	}
+
+	applications := s.sparkApplicationsByTaskClaim(ctx, informer)
+
+	for _, task := range tasks {
+		if _, ok := applications[task.CurrentClaim()]; ok {
+			continue
+		}
+
+		if task.PickedUpAt != nil && task.PickedUpAt.After(startedAt) {
+			continue
+		}
+
+		s.logger.Warn(ctx, "failing running task %d because its spark application no longer exists", task.Id)
+
+		taskErr := fmt.Errorf("spark application of task %d no longer exists", task.Id)
+		if err = s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), map[string]any{"status": statusError}, taskErr); err != nil {
+			return fmt.Errorf("could not fail task %d without spark application: %w", task.Id, err)
+		}
+	}
 
 	return nil
 }
 
+// heartbeatRunningTasks extends the lease of all running spark tasks which still have a SparkApplication.
+func (s *SparkMaintenanceExecutor) heartbeatRunningTasks(ctx context.Context, informer cache.SharedIndexInformer) error {
+	tasks, err := s.taskQueue.ListRunningTasks(ctx, TaskEngineSpark)
+	if err != nil {
+		return err
+	}
+
+	applications := s.sparkApplicationsByTaskClaim(ctx, informer)
+	alive := make([]TaskClaim, 0, len(tasks))
+
+	for _, task := range tasks {
+		if _, ok := applications[task.CurrentClaim()]; ok {
+			alive = append(alive, task.CurrentClaim())
+		}
+	}
+
+	return s.taskQueue.HeartbeatTasks(ctx, alive...)
+}
+
+func (s *SparkMaintenanceExecutor) sparkApplicationsByTaskClaim(ctx context.Context, informer cache.SharedIndexInformer) map[TaskClaim]*SparkApplicationManifest {
+	applications := make(map[TaskClaim]*SparkApplicationManifest)
+
+	for _, obj := range informer.GetStore().List() {
+		manifest, err := decodeSparkApplicationEvent(obj)
+		if err != nil {
+			s.logger.Warn(ctx, "%s", err)
+
+			continue
+		}
+
+		claim, err := sparkApplicationTaskClaim(manifest)
+		if err != nil {
+			continue
+		}
+
+		applications[claim] = manifest
+	}
+
+	return applications
+}
+
 func (s *SparkMaintenanceExecutor) ProcessTask(ctx context.Context, task *Task) error {
 	input := task.Input.Get()
 
@@ -156,23 +285,64 @@ func (s *SparkMaintenanceExecutor) ProcessTask(ctx context.Context, task *Task)
 		return s.processExpireSnapshots(ctx, task, input)
 	case TaskKindRemoveOrphanFiles:
 		return s.processRemoveOrphanFiles(ctx, task, input)
+	case TaskKindRewriteManifests:
+		return s.processRewriteManifests(ctx, task)
+	case TaskKindRewritePositionDeleteFiles:
+		return s.processRewritePositionDeleteFiles(ctx, task)
+	case TaskKindCheckFileIntegrity:
+		return processCheckFileIntegrityTask(ctx, s.files, s.taskQueue, task)
 	default:
-		return s.taskQueue.CompleteTask(ctx, task.Id, nil, fmt.Errorf("unknown task kind: %s", task.Kind))
+		return s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), nil, fmt.Errorf("unknown task kind: %s", task.Kind))
+	}
+}
+
+// HealthCheck verifies that the spark applications of the service are accessible in kubernetes.
+func (s *SparkMaintenanceExecutor) HealthCheck(ctx context.Context) error {
+	return s.k8s.CheckSparkApplicationAccess(ctx)
+}
+
+func (s *SparkMaintenanceExecutor) CancelTask(ctx context.Context, task *Task) error {
+	if TaskKind(task.Kind) == TaskKindCheckFileIntegrity {
+		// the check runs in this process without a spark application, its result is dropped as the task
+		// is not running anymore when it completes
+		return nil
 	}
+
+	applicationName, _ := task.Result.Get()["application_name"].(string)
+	if applicationName == "" {
+		prefix, err := sparkApplicationNamePrefix(TaskKind(task.Kind))
+		if err != nil {
+			return fmt.Errorf("could not determine spark application name of task %d: %w", task.Id, err)
+		}
+
+		applicationName = buildSparkApplicationName(prefix, task.Table, task.CurrentClaim())
+	}
+
+	if err := s.k8s.DeleteSparkApplication(ctx, "", applicationName); err != nil {
+		return fmt.Errorf("could not delete spark application %s of task %d: %w", applicationName, task.Id, err)
+	}
+
+	s.logger.Info(ctx, "deleted spark application %s of cancelled task %d", applicationName, task.Id)
+
+	return s.taskQueue.UpdateTaskResultNested(ctx, task.CurrentClaim(), "cancellation", map[string]any{
+		"deleted_application_name": applicationName,
+	})
 }
 
 func (s *SparkMaintenanceExecutor) processOptimize(ctx context.Context, task *Task, input map[string]any) error {
 	targetFileSizeMb, _ := input["target_file_size_mb"].(float64)
 	from := cast.ToTime(input["from"])
 	to := cast.ToTime(input["to"])
+	scope, _ := optimizeScopeFromTaskInput(input)
+	strategy := optimizeStrategyFromTaskInput(input)
 
-	res, err := s.executeOptimize(ctx, task.Id, task.Database, task.Table, int(targetFileSizeMb), from, to)
+	res, err := s.executeOptimize(ctx, task.CurrentClaim(), task.Database, task.Table, int(targetFileSizeMb), from, to, scope, strategy)
 	if err != nil {
 		return fmt.Errorf("could not execute optimize task: %w", err)
 	}
 
 	result := optimizeResultMap(res)
-	if err = s.taskQueue.UpdateTaskResult(ctx, task.Id, result); err != nil {
+	if err = s.taskQueue.UpdateTaskResult(ctx, task.CurrentClaim(), result); err != nil {
 		return fmt.Errorf("could not update task %d tracking result: %w", task.Id, err)
 	}
 
@@ -182,14 +352,17 @@ func (s *SparkMaintenanceExecutor) processOptimize(ctx context.Context, task *Ta
 }
 
 func (s *SparkMaintenanceExecutor) processExpireSnapshots(ctx context.Context, task *Task, input map[string]any) error {
-	retentionDays, _ := input["retention_days"].(float64)
+	params, err := expireSnapshotsParamsFromTaskInput(input, time.Now())
+	if err != nil {
+		return fmt.Errorf("could not read expire snapshots task input: %w", err)
+	}
 
-	result, err := s.executeExpireSnapshots(ctx, task.Id, task.Database, task.Table, int(retentionDays))
+	result, err := s.executeExpireSnapshots(ctx, task.CurrentClaim(), task.Database, task.Table, params)
 	if err != nil {
 		return fmt.Errorf("could not execute expire snapshots task: %w", err)
 	}
 
-	if err = s.taskQueue.UpdateTaskResult(ctx, task.Id, result); err != nil {
+	if err = s.taskQueue.UpdateTaskResult(ctx, task.CurrentClaim(), result); err != nil {
 		return fmt.Errorf("could not update task %d tracking result: %w", task.Id, err)
 	}
 
@@ -201,12 +374,12 @@ func (s *SparkMaintenanceExecutor) processExpireSnapshots(ctx context.Context, t
 func (s *SparkMaintenanceExecutor) processRemoveOrphanFiles(ctx context.Context, task *Task, input map[string]any) error {
 	retentionDays, _ := input["retention_days"].(float64)
 
-	result, err := s.executeRemoveOrphanFiles(ctx, task.Id, task.Database, task.Table, int(retentionDays))
+	result, err := s.executeRemoveOrphanFiles(ctx, task.CurrentClaim(), task.Database, task.Table, int(retentionDays))
 	if err != nil {
 		return fmt.Errorf("could not execute remove orphan files task: %w", err)
 	}
 
-	if err = s.taskQueue.UpdateTaskResult(ctx, task.Id, result); err != nil {
+	if err = s.taskQueue.UpdateTaskResult(ctx, task.CurrentClaim(), result); err != nil {
 		return fmt.Errorf("could not update task %d tracking result: %w", task.Id, err)
 	}
 
@@ -215,64 +388,105 @@ func (s *SparkMaintenanceExecutor) processRemoveOrphanFiles(ctx context.Context,
 	return nil
 }
 
-func (s *SparkMaintenanceExecutor) executeOptimize(ctx context.Context, taskID int64, database string, table string, targetFileSizeMb int, from time.Time, to time.Time) (*OptimizeResult, error) {
+func (s *SparkMaintenanceExecutor) processRewriteManifests(ctx context.Context, task *Task) error {
+	result, err := s.executeRewriteManifests(ctx, task.CurrentClaim(), task.Database, task.Table)
+	if err != nil {
+		return fmt.Errorf("could not execute rewrite manifests task: %w", err)
+	}
+
+	if err = s.taskQueue.UpdateTaskResult(ctx, task.CurrentClaim(), result); err != nil {
+		return fmt.Errorf("could not update task %d tracking result: %w", task.Id, err)
+	}
+
+	s.logger.Info(ctx, "task %d submitted and waiting for asynchronous completion", task.Id)
+
+	return nil
+}
+
+func (s *SparkMaintenanceExecutor) processRewritePositionDeleteFiles(ctx context.Context, task *Task) error {
+	result, err := s.executeRewritePositionDeleteFiles(ctx, task.CurrentClaim(), task.Database, task.Table)
+	if err != nil {
+		return fmt.Errorf("could not execute rewrite position delete files task: %w", err)
+	}
+
+	if err = s.taskQueue.UpdateTaskResult(ctx, task.CurrentClaim(), result); err != nil {
+		return fmt.Errorf("could not update task %d tracking result: %w", task.Id, err)
+	}
+
+	s.logger.Info(ctx, "task %d submitted and waiting for asynchronous completion", task.Id)
+
+	return nil
+}
+
+// executeOptimize rewrites the data files of a date range, or of a partition scope if scope is not nil.
+func (s *SparkMaintenanceExecutor) executeOptimize(ctx context.Context, claim TaskClaim, database string, table string, targetFileSizeMb int, from time.Time, to time.Time, scope PartitionValues, strategy OptimizeStrategy) (*OptimizeResult, error) {
 	if targetFileSizeMb < 1 {
 		return nil, fmt.Errorf("target file size must be at least 1 MB")
 	}
 
 	var err error
 	var desc *TableDescription
-	var partitionColumn string
+	var whereClause string
 	var manifest *SparkApplicationManifest
 
-	if from.After(to) {
-		return nil, fmt.Errorf("from date must be before or equal to the to date")
-	}
-
 	if desc, err = s.metadata.GetTable(ctx, database, table); err != nil {
 		return nil, fmt.Errorf("could not get table metadata: %w", err)
 	}
 
-	for _, p := range desc.Partitions.Get() {
-		if p.IsHidden && p.Hidden.Type == "day" {
-			partitionColumn = p.Hidden.Column
-		}
+	layout := newOptimizePartitionLayout(desc.Partitions.Get())
+
+	envValues := map[string]string{
+		"TARGET_FILE_SIZE_BYTES":             fmt.Sprintf("%d", int64(targetFileSizeMb)*1024*1024),
+		"MIN_INPUT_FILES":                    fmt.Sprintf("%d", 2),
+		"PARTIAL_PROGRESS_ENABLED":           fmt.Sprintf("%t", s.settings.Optimize.PartialProgressEnabled),
+		"PARTIAL_PROGRESS_MAX_COMMITS":       fmt.Sprintf("%d", s.settings.Optimize.PartialProgressMaxCommits),
+		"MAX_CONCURRENT_FILE_GROUP_REWRITES": fmt.Sprintf("%d", s.settings.Optimize.MaxConcurrentFileGroupRewrite),
+		"REWRITE_STRATEGY":                   strategy.sparkStrategy(),
+		"SORT_ORDER":                         strategy.sparkSortOrder(),
 	}
 
-	if partitionColumn == "" {
-		return nil, fmt.Errorf("no suitable day-partition column found for optimization")
+	if scope != nil {
+		if whereClause, err = layout.predicate(scope, sparkDialect); err != nil {
+			return nil, fmt.Errorf("could not build filter for partition %s: %w", scope.String(), err)
+		}
+
+		envValues["OPTIMIZE_SCOPE"] = "partition"
+		envValues["ICEBERG_WHERE"] = whereClause
+	} else {
+		if from.After(to) {
+			return nil, fmt.Errorf("from date must be before or equal to the to date")
+		}
+
+		if !layout.chunksByDate() {
+			return nil, fmt.Errorf("no suitable hour or day partition column found for optimization")
+		}
+
+		whereClause = fmt.Sprintf("date(%s) >= date '%s' AND date(%s) <= date '%s'", layout.timeColumn, from.Format(time.DateOnly), layout.timeColumn, to.Format(time.DateOnly))
+
+		envValues["OPTIMIZE_SCOPE"] = "range"
+		envValues["ICEBERG_WHERE_COLUMN"] = layout.timeColumn
+		envValues["ICEBERG_WHERE_FROM"] = from.Format(time.DateOnly)
+		envValues["ICEBERG_WHERE_UNTIL"] = to.Add(time.Hour * 24).Format(time.DateOnly)
 	}
 
-	whereClause := fmt.Sprintf("date(%s) >= date '%s' AND date(%s) <= date '%s'", partitionColumn, from.Format(time.DateOnly), partitionColumn, to.Format(time.DateOnly))
-	applicationName := buildSparkApplicationName("rewrite-data-files", table, taskID)
+	applicationName := buildSparkApplicationName("rewrite-data-files", table, claim)
 
-	s.logger.Info(ctx, "creating spark application for table %s range %s to %s", table, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	s.logger.Info(ctx, "creating spark application for table %s where %q", table, whereClause)
 
 	if manifest, err = LoadSparkApplicationTemplate(); err != nil {
 		return nil, fmt.Errorf("could not load spark application template: %w", err)
 	}
 
-	if err = s.prepareSparkApplication(manifest, TaskKindOptimize, taskID, database, table, applicationName); err != nil {
+	if err = s.prepareSparkApplication(manifest, TaskKindOptimize, claim, database, table, applicationName); err != nil {
 		return nil, fmt.Errorf("could not prepare spark application manifest: %w", err)
 	}
 
-	envValues := map[string]string{
-		"ICEBERG_WHERE_COLUMN":               partitionColumn,
-		"ICEBERG_WHERE_FROM":                 from.Format(time.DateOnly),
-		"ICEBERG_WHERE_UNTIL":                to.Add(time.Hour * 24).Format(time.DateOnly),
-		"TARGET_FILE_SIZE_BYTES":             fmt.Sprintf("%d", int64(targetFileSizeMb)*1024*1024),
-		"MIN_INPUT_FILES":                    fmt.Sprintf("%d", 2),
-		"PARTIAL_PROGRESS_ENABLED":           fmt.Sprintf("%t", s.settings.Optimize.PartialProgressEnabled),
-		"PARTIAL_PROGRESS_MAX_COMMITS":       fmt.Sprintf("%d", s.settings.Optimize.PartialProgressMaxCommits),
-		"MAX_CONCURRENT_FILE_GROUP_REWRITES": fmt.Sprintf("%d", s.settings.Optimize.MaxConcurrentFileGroupRewrite),
-	}
-
 	if err = manifest.SetEnvValues(envValues); err != nil {
 		return nil, fmt.Errorf("could not set env values: %w", err)
 	}
 
 	if _, err = s.k8s.CreateSparkApplication(ctx, manifest); err != nil {
-		return nil, fmt.Errorf("could not create spark application for table %s (range %s): %w", table, whereClause, err)
+		return nil, fmt.Errorf("could not create spark application for table %s (where %q): %w", table, whereClause, err)
 	}
 
 	return &OptimizeResult{
@@ -280,18 +494,15 @@ func (s *SparkMaintenanceExecutor) executeOptimize(ctx context.Context, taskID i
 		Table:            table,
 		TargetFileSizeMb: targetFileSizeMb,
 		Where:            whereClause,
+		Strategy:         strategy.Strategy,
+		SortOrder:        strategy.sparkSortOrder(),
 		ApplicationName:  applicationName,
 		Status:           statusSubmitted,
 	}, nil
 }
 
-func (s *SparkMaintenanceExecutor) executeExpireSnapshots(ctx context.Context, taskID int64, database string, table string, retentionDays int) (map[string]any, error) {
-	if retentionDays < 1 {
-		return nil, fmt.Errorf("retention days must be at least 1")
-	}
-
-	olderThan := time.Now().UTC().AddDate(0, 0, -retentionDays)
-	applicationName := buildSparkApplicationName("expire-snapshots", table, taskID)
+func (s *SparkMaintenanceExecutor) executeExpireSnapshots(ctx context.Context, claim TaskClaim, database string, table string, params expireSnapshotsParams) (map[string]any, error) {
+	applicationName := buildSparkApplicationName("expire-snapshots", table, claim)
 	s.logger.Info(ctx, "creating spark application to expire snapshots for table %s", table)
 
 	manifest, err := LoadSparkApplicationTemplate()
@@ -299,14 +510,15 @@ func (s *SparkMaintenanceExecutor) executeExpireSnapshots(ctx context.Context, t
 		return nil, fmt.Errorf("could not load spark application template: %w", err)
 	}
 
-	if err = s.prepareSparkApplication(manifest, TaskKindExpireSnapshots, taskID, database, table, applicationName); err != nil {
+	if err = s.prepareSparkApplication(manifest, TaskKindExpireSnapshots, claim, database, table, applicationName); err != nil {
 		return nil, fmt.Errorf("could not prepare spark application manifest: %w", err)
 	}
 
 	envValues := map[string]string{
-		"RETENTION_DAYS":         fmt.Sprintf("%d", retentionDays),
-		"OLDER_THAN":             olderThan.UTC().Format(time.RFC3339),
-		"CLEAN_EXPIRED_METADATA": fmt.Sprintf("%t", true),
+		"RETENTION_DAYS":         fmt.Sprintf("%d", params.retentionDays),
+		"OLDER_THAN":             params.olderThan.Format(time.RFC3339),
+		"RETAIN_LAST":            fmt.Sprintf("%d", params.retainLast),
+		"CLEAN_EXPIRED_METADATA": fmt.Sprintf("%t", params.cleanExpiredMetadata),
 	}
 
 	if err = manifest.SetEnvValues(envValues); err != nil {
@@ -320,22 +532,23 @@ func (s *SparkMaintenanceExecutor) executeExpireSnapshots(ctx context.Context, t
 	return map[string]any{
 		"database":               database,
 		"table":                  table,
-		"retention_days":         retentionDays,
-		"older_than":             olderThan,
-		"clean_expired_metadata": true,
+		"retention_days":         params.retentionDays,
+		"older_than":             params.olderThan,
+		"retain_last":            params.retainLast,
+		"clean_expired_metadata": params.cleanExpiredMetadata,
 		"tracking_id":            applicationName,
 		"application_name":       applicationName,
 		"status":                 statusSubmitted,
 	}, nil
 }
 
-func (s *SparkMaintenanceExecutor) executeRemoveOrphanFiles(ctx context.Context, taskID int64, database string, table string, retentionDays int) (map[string]any, error) {
+func (s *SparkMaintenanceExecutor) executeRemoveOrphanFiles(ctx context.Context, claim TaskClaim, database string, table string, retentionDays int) (map[string]any, error) {
 	if retentionDays < 1 {
 		return nil, fmt.Errorf("retention days must be at least 1")
 	}
 
 	olderThan := time.Now().UTC().AddDate(0, 0, -retentionDays)
-	applicationName := buildSparkApplicationName("remove-orphan-files", table, taskID)
+	applicationName := buildSparkApplicationName("remove-orphan-files", table, claim)
 	s.logger.Info(ctx, "creating spark application to remove orphan files for table %s", table)
 
 	manifest, err := LoadSparkApplicationTemplate()
@@ -343,7 +556,7 @@ func (s *SparkMaintenanceExecutor) executeRemoveOrphanFiles(ctx context.Context,
 		return nil, fmt.Errorf("could not load spark application template: %w", err)
 	}
 
-	if err = s.prepareSparkApplication(manifest, TaskKindRemoveOrphanFiles, taskID, database, table, applicationName); err != nil {
+	if err = s.prepareSparkApplication(manifest, TaskKindRemoveOrphanFiles, claim, database, table, applicationName); err != nil {
 		return nil, fmt.Errorf("could not prepare spark application manifest: %w", err)
 	}
 
@@ -371,15 +584,80 @@ func (s *SparkMaintenanceExecutor) executeRemoveOrphanFiles(ctx context.Context,
 	}, nil
 }
 
-func (s *SparkMaintenanceExecutor) prepareSparkApplication(manifest *SparkApplicationManifest, taskKind TaskKind, taskID int64, database string, table string, applicationName string) error {
+// executeRewriteManifests submits the rewrite_manifests procedure. The manifest counts before and after
+// the rewrite are reported by the procedure callback, see ServiceTasks.UpdateProcedureResult.
+func (s *SparkMaintenanceExecutor) executeRewriteManifests(ctx context.Context, claim TaskClaim, database string, table string) (map[string]any, error) {
+	applicationName := buildSparkApplicationName("rewrite-manifests", table, claim)
+	s.logger.Info(ctx, "creating spark application to rewrite manifests for table %s", table)
+
+	manifest, err := LoadSparkApplicationTemplate()
+	if err != nil {
+		return nil, fmt.Errorf("could not load spark application template: %w", err)
+	}
+
+	if err = s.prepareSparkApplication(manifest, TaskKindRewriteManifests, claim, database, table, applicationName); err != nil {
+		return nil, fmt.Errorf("could not prepare spark application manifest: %w", err)
+	}
+
+	if _, err = s.k8s.CreateSparkApplication(ctx, manifest); err != nil {
+		return nil, fmt.Errorf("could not create spark application to rewrite manifests for table %s: %w", table, err)
+	}
+
+	return map[string]any{
+		"database":         database,
+		"table":            table,
+		"tracking_id":      applicationName,
+		"application_name": applicationName,
+		"status":           statusSubmitted,
+	}, nil
+}
+
+func (s *SparkMaintenanceExecutor) executeRewritePositionDeleteFiles(ctx context.Context, claim TaskClaim, database string, table string) (map[string]any, error) {
+	applicationName := buildSparkApplicationName("rewrite-position-delete-files", table, claim)
+	s.logger.Info(ctx, "creating spark application to rewrite position delete files for table %s", table)
+
+	manifest, err := LoadSparkApplicationTemplate()
+	if err != nil {
+		return nil, fmt.Errorf("could not load spark application template: %w", err)
+	}
+
+	if err = s.prepareSparkApplication(manifest, TaskKindRewritePositionDeleteFiles, claim, database, table, applicationName); err != nil {
+		return nil, fmt.Errorf("could not prepare spark application manifest: %w", err)
+	}
+
+	envValues := map[string]string{
+		"PARTIAL_PROGRESS_ENABLED":     fmt.Sprintf("%t", s.settings.Optimize.PartialProgressEnabled),
+		"PARTIAL_PROGRESS_MAX_COMMITS": fmt.Sprintf("%d", s.settings.Optimize.PartialProgressMaxCommits),
+	}
+
+	if err = manifest.SetEnvValues(envValues); err != nil {
+		return nil, fmt.Errorf("could not set env values: %w", err)
+	}
+
+	if _, err = s.k8s.CreateSparkApplication(ctx, manifest); err != nil {
+		return nil, fmt.Errorf("could not create spark application to rewrite position delete files for table %s: %w", table, err)
+	}
+
+	return map[string]any{
+		"database":         database,
+		"table":            table,
+		"tracking_id":      applicationName,
+		"application_name": applicationName,
+		"status":           statusSubmitted,
+	}, nil
+}
+
+func (s *SparkMaintenanceExecutor) prepareSparkApplication(manifest *SparkApplicationManifest, taskKind TaskKind, claim TaskClaim, database string, table string, applicationName string) error {
 	procedure, err := sparkTaskProcedure(taskKind)
 	if err != nil {
 		return fmt.Errorf("could not determine spark task procedure: %w", err)
 	}
 
 	manifest.Metadata.Name = applicationName
-	manifest.SetAnnotation(sparkApplicationTaskIDAnnotation, strconv.FormatInt(taskID, 10))
+	manifest.SetAnnotation(sparkApplicationTaskIDAnnotation, strconv.FormatInt(claim.TaskId, 10))
+	manifest.SetAnnotation(sparkApplicationTaskClaimAnnotation, strconv.Itoa(claim.Claim))
 	manifest.SetAnnotation(sparkApplicationTaskKindAnnotation, string(taskKind))
+	manifest.SetAnnotation(sparkApplicationTaskDatabaseAnnotation, database)
 	manifest.SetAnnotation(sparkApplicationTaskTableAnnotation, table)
 	manifest.MergeDriverPodAnnotations(s.settings.PodSpec.Annotations)
 	manifest.MergeDriverNodeSelector(s.settings.PodSpec.NodeSelector)
@@ -394,13 +672,13 @@ func (s *SparkMaintenanceExecutor) prepareSparkApplication(manifest *SparkApplic
 		"ICEBERG_DATABASE":      database,
 		"ICEBERG_TABLE":         table,
 		"TASK_CALLBACK_ENABLED": fmt.Sprintf("%t", s.settings.Callback.Enabled),
-		"TASK_CALLBACK_URL":     BuildTaskProcedureCallbackURL(s.settings.Callback.BackendHost, taskID),
+		"TASK_CALLBACK_URL":     BuildTaskProcedureCallbackURL(s.settings.Callback.BackendHost, claim),
 		"TASK_PROCEDURE":        procedure,
-		"TASK_ID":               strconv.FormatInt(taskID, 10),
+		"TASK_ID":               strconv.FormatInt(claim.TaskId, 10),
 	})
 }
 
-func (s *SparkMaintenanceExecutor) HandleTaskUpdate(ctx context.Context, taskID int64, applicationName string, state string, message string, extraResult map[string]any) error {
+func (s *SparkMaintenanceExecutor) HandleTaskUpdate(ctx context.Context, claim TaskClaim, applicationName string, state string, message string, extraResult map[string]any) error {
 	var taskErr error
 
 	result := map[string]any{
@@ -422,15 +700,37 @@ func (s *SparkMaintenanceExecutor) HandleTaskUpdate(ctx context.Context, taskID
 		taskErr = fmt.Errorf("%s", message)
 	}
 
-	if err := s.taskQueue.CompleteTask(ctx, taskID, result, taskErr); err != nil {
-		return fmt.Errorf("could not complete task %d from spark application %s: %w", taskID, applicationName, err)
+	if err := s.taskQueue.CompleteTask(ctx, claim, result, taskErr); err != nil {
+		return fmt.Errorf("could not complete task %d from spark application %s: %w", claim.TaskId, applicationName, err)
 	}
 
-	s.logger.Info(ctx, "completed task %d from spark application %s with state %s", taskID, applicationName, state)
+	s.logger.Info(ctx, "completed task %d from spark application %s with state %s", claim.TaskId, applicationName, state)
 
 	return nil
 }
 
+// publishSparkStateEvent streams the state of a spark application to the subscribers of its task.
+// Applications without a task id annotation are not created by us and thus ignored.
+func (s *SparkMaintenanceExecutor) publishSparkStateEvent(ctx context.Context, manifest *SparkApplicationManifest, state string, result map[string]any) {
+	annotations := manifest.Metadata.Annotations
+
+	taskID, err := strconv.ParseInt(annotations[sparkApplicationTaskIDAnnotation], 10, 64)
+	if err != nil {
+		return
+	}
+
+	s.events.Publish(ctx, TaskEvent{
+		Type:     TaskEventSparkState,
+		TaskId:   taskID,
+		Database: annotations[sparkApplicationTaskDatabaseAnnotation],
+		Table:    annotations[sparkApplicationTaskTableAnnotation],
+		Kind:     annotations[sparkApplicationTaskKindAnnotation],
+		Status:   state,
+		Result:   result,
+		Time:     time.Now(),
+	})
+}
+
 func (s *SparkMaintenanceExecutor) handleSparkApplicationEvent(ctx context.Context, obj any) error {
 	manifest, err := decodeSparkApplicationEvent(obj)
 	if err != nil {
@@ -463,7 +763,7 @@ func (s *SparkMaintenanceExecutor) handleDecodedSparkApplicationEvent(ctx contex
 	var ok bool
 	var err error
 	var taskIDAnnotation string
-	var taskID int64
+	var claim TaskClaim
 
 	appName := manifest.Metadata.Name
 	resolvedStatus := manifest.Status.Resolve()
@@ -476,6 +776,8 @@ func (s *SparkMaintenanceExecutor) handleDecodedSparkApplicationEvent(ctx contex
 		extraResult["spark_state_transitions"] = transitions
 	}
 
+	s.publishSparkStateEvent(ctx, manifest, resolvedStatus.CurrentState, extraResult)
+
 	if !resolvedStatus.IsTerminal() {
 		return nil
 	}
@@ -484,11 +786,13 @@ func (s *SparkMaintenanceExecutor) handleDecodedSparkApplicationEvent(ctx contex
 		return fmt.Errorf("ignoring terminal spark application event for %s without %s annotation", appName, sparkApplicationTaskIDAnnotation)
 	}
 
-	if taskID, err = strconv.ParseInt(taskIDAnnotation, 10, 64); err != nil {
-		return fmt.Errorf("ignoring terminal spark application event for %s with invalid %s annotation %q: %w", appName, sparkApplicationTaskIDAnnotation, taskIDAnnotation, err)
+	if claim, err = sparkApplicationTaskClaim(manifest); err != nil {
+		return fmt.Errorf("ignoring terminal spark application event for %s: %w", appName, err)
 	}
 
-	if err = s.HandleTaskUpdate(ctx, taskID, appName, state, resolvedStatus.Message, extraResult); err == nil {
+	taskID := claim.TaskId
+
+	if err = s.HandleTaskUpdate(ctx, claim, appName, state, resolvedStatus.Message, extraResult); err == nil {
 		if !resolvedStatus.IsSuccess() {
 			return nil
 		}
@@ -513,9 +817,36 @@ func (s *SparkMaintenanceExecutor) handleDecodedSparkApplicationEvent(ctx contex
 	return fmt.Errorf("could not resolve spark tracking update for %s: %w", appName, err)
 }
 
-func buildSparkApplicationName(prefix string, table string, taskID int64) string {
+// sparkApplicationTaskClaim reads the task claim a SparkApplication was created for. Applications created
+// before claims were tracked have no claim annotation and belong to claim 0.
+func sparkApplicationTaskClaim(manifest *SparkApplicationManifest) (TaskClaim, error) {
+	var err error
+	var claim TaskClaim
+
+	annotations := manifest.Metadata.Annotations
+
+	if claim.TaskId, err = strconv.ParseInt(annotations[sparkApplicationTaskIDAnnotation], 10, 64); err != nil {
+		return TaskClaim{}, fmt.Errorf("invalid %s annotation %q: %w", sparkApplicationTaskIDAnnotation, annotations[sparkApplicationTaskIDAnnotation], err)
+	}
+
+	if value, ok := annotations[sparkApplicationTaskClaimAnnotation]; ok {
+		if claim.Claim, err = strconv.Atoi(value); err != nil {
+			return TaskClaim{}, fmt.Errorf("invalid %s annotation %q: %w", sparkApplicationTaskClaimAnnotation, value, err)
+		}
+	}
+
+	return claim, nil
+}
+
+// buildSparkApplicationName derives the application name of a task claim. The claim is part of the name,
+// so a re-queued task does not collide with the application of its previous claim. Claim 0 keeps the
+// name used before claims were tracked.
+func buildSparkApplicationName(prefix string, table string, claim TaskClaim) string {
 	tablePart := sanitizeK8sName(table)
-	suffix := strconv.FormatInt(taskID, 10)
+	suffix := strconv.FormatInt(claim.TaskId, 10)
+	if claim.Claim > 0 {
+		suffix += "-" + strconv.Itoa(claim.Claim)
+	}
 	maxTableLength := sparkApplicationNameMaxLength - len(prefix) - len(suffix) - 2
 
 	if maxTableLength <= 0 {
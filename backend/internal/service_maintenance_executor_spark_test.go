@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildSparkApplicationName(t *testing.T) {
+	require.Equal(t, "expire-snapshots-events-42", buildSparkApplicationName("expire-snapshots", "events", TaskClaim{TaskId: 42}))
+	require.Equal(t, "expire-snapshots-events-42-3", buildSparkApplicationName("expire-snapshots", "events", TaskClaim{TaskId: 42, Claim: 3}))
+	require.NotEqual(t,
+		buildSparkApplicationName("rewrite-data-files", "events", TaskClaim{TaskId: 42, Claim: 1}),
+		buildSparkApplicationName("rewrite-data-files", "events", TaskClaim{TaskId: 42, Claim: 2}),
+	)
+}
+
+func TestSparkApplicationTaskClaim(t *testing.T) {
+	manifest := &SparkApplicationManifest{}
+	manifest.SetAnnotation(sparkApplicationTaskIDAnnotation, "42")
+
+	claim, err := sparkApplicationTaskClaim(manifest)
+	require.NoError(t, err)
+	require.Equal(t, TaskClaim{TaskId: 42}, claim)
+
+	manifest.SetAnnotation(sparkApplicationTaskClaimAnnotation, "3")
+
+	claim, err = sparkApplicationTaskClaim(manifest)
+	require.NoError(t, err)
+	require.Equal(t, TaskClaim{TaskId: 42, Claim: 3}, claim)
+
+	manifest.SetAnnotation(sparkApplicationTaskClaimAnnotation, "invalid")
+
+	_, err = sparkApplicationTaskClaim(manifest)
+	require.Error(t, err)
+}
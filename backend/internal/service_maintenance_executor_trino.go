@@ -3,18 +3,24 @@ package internal
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/gosoline-project/sqlc"
 	"github.com/justtrackio/gosoline/pkg/cfg"
 	"github.com/justtrackio/gosoline/pkg/log"
+	"github.com/spf13/cast"
 )
 
 type ExpireSnapshotsResult struct {
-	Database             string `json:"database"`
-	Table                string `json:"table"`
-	RetentionDays        int    `json:"retention_days"`
-	CleanExpiredMetadata bool   `json:"clean_expired_metadata"`
-	Status               string `json:"status"`
+	Database             string    `json:"database"`
+	Table                string    `json:"table"`
+	RetentionDays        int       `json:"retention_days"`
+	OlderThan            time.Time `json:"older_than"`
+	RetainLast           int       `json:"retain_last"`
+	RetentionThreshold   string    `json:"retention_threshold"`
+	CleanExpiredMetadata bool      `json:"clean_expired_metadata"`
+	Status               string    `json:"status"`
 }
 
 type RemoveOrphanFilesResult struct {
@@ -25,13 +31,35 @@ type RemoveOrphanFilesResult struct {
 	Status        string         `json:"status"`
 }
 
+type RewriteManifestsResult struct {
+	Database            string `json:"database"`
+	Table               string `json:"table"`
+	ManifestCountBefore int64  `json:"manifest_count_before"`
+	ManifestCountAfter  int64  `json:"manifest_count_after"`
+	Status              string `json:"status"`
+}
+
+type TrinoOptimizeResult struct {
+	Database               string `json:"database"`
+	Table                  string `json:"table"`
+	TargetFileSizeMb       int    `json:"target_file_size_mb"`
+	Where                  string `json:"where"`
+	Strategy               string `json:"strategy"`
+	DataFileCountBefore    int64  `json:"data_file_count_before"`
+	DataFileCountAfter     int64  `json:"data_file_count_after"`
+	RewrittenDataFileCount int64  `json:"rewritten_data_file_count"`
+	AddedDataFileCount     int64  `json:"added_data_file_count"`
+	Status                 string `json:"status"`
+}
+
+const trinoTaskQueryTag = "lakehouse-admin-task"
+
 type TrinoMaintenanceExecutor struct {
 	logger    log.Logger
 	trino     *TrinoClient
 	metadata  *ServiceMetadata
 	taskQueue TaskClaimer
-	refresher SnapshotRefresher
-	sqlClient sqlc.Client
+	files     *ServiceFileIntegrity
 	settings  *IcebergSettings
 }
 
@@ -40,8 +68,7 @@ func NewTrinoMaintenanceExecutor(ctx context.Context, config cfg.Config, logger
 	var trino *TrinoClient
 	var metadata *ServiceMetadata
 	var taskQueue TaskClaimer
-	var refresher SnapshotRefresher
-	var sqlClient sqlc.Client
+	var files *ServiceFileIntegrity
 	var settings *IcebergSettings
 
 	if trino, err = ProvideTrinoClient(ctx, config, logger); err != nil {
@@ -56,12 +83,8 @@ func NewTrinoMaintenanceExecutor(ctx context.Context, config cfg.Config, logger
 		return nil, fmt.Errorf("could not create task queue service: %w", err)
 	}
 
-	if refresher, err = NewServiceRefresh(ctx, config, logger); err != nil {
-		return nil, fmt.Errorf("could not create refresh service: %w", err)
-	}
-
-	if sqlClient, err = sqlc.ProvideClient(ctx, config, logger, "default"); err != nil {
-		return nil, fmt.Errorf("could not create sqlg client: %w", err)
+	if files, err = NewServiceFileIntegrity(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create file integrity service: %w", err)
 	}
 
 	if settings, err = ReadIcebergSettings(config); err != nil {
@@ -73,8 +96,7 @@ func NewTrinoMaintenanceExecutor(ctx context.Context, config cfg.Config, logger
 		trino:     trino,
 		metadata:  metadata,
 		taskQueue: taskQueue,
-		refresher: refresher,
-		sqlClient: sqlClient,
+		files:     files,
 		settings:  settings,
 	}, nil
 }
@@ -97,67 +119,149 @@ func (s *TrinoMaintenanceExecutor) ProcessTask(ctx context.Context, task *Task)
 		return s.processExpireSnapshots(ctx, task, input)
 	case TaskKindRemoveOrphanFiles:
 		return s.processRemoveOrphanFiles(ctx, task, input)
+	case TaskKindRewriteManifests:
+		return s.processRewriteManifests(ctx, task)
 	case TaskKindOptimize:
-		return s.taskQueue.CompleteTask(ctx, task.Id, nil, fmt.Errorf("task kind %s is not supported by engine %s", TaskKind(task.Kind), s.Engine()))
+		return s.processOptimize(ctx, task, input)
+	case TaskKindRewritePositionDeleteFiles:
+		return s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), nil, fmt.Errorf("task kind %s is not supported by engine %s", TaskKind(task.Kind), s.Engine()))
+	case TaskKindCheckFileIntegrity:
+		return processCheckFileIntegrityTask(ctx, s.files, s.taskQueue, task)
 	default:
-		return s.taskQueue.CompleteTask(ctx, task.Id, nil, fmt.Errorf("unknown task kind: %s", task.Kind))
+		return s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), nil, fmt.Errorf("unknown task kind: %s", task.Kind))
 	}
 }
 
-func (s *TrinoMaintenanceExecutor) processExpireSnapshots(ctx context.Context, task *Task, input map[string]any) error {
-	retentionDays, _ := input["retention_days"].(float64)
+// HealthCheck runs a trivial query to verify that trino is reachable.
+func (s *TrinoMaintenanceExecutor) HealthCheck(ctx context.Context) error {
+	if _, err := s.trino.QueryRows(ctx, "SELECT 1"); err != nil {
+		return fmt.Errorf("could not query trino: %w", err)
+	}
 
-	res, err := s.executeExpireSnapshots(ctx, task.Database, task.Table, int(retentionDays))
+	return nil
+}
+
+func (s *TrinoMaintenanceExecutor) CancelTask(ctx context.Context, task *Task) error {
+	killed, err := s.trino.KillTaggedQueries(ctx, trinoTaskQueryTag, strconv.FormatInt(task.Id, 10), fmt.Sprintf("task %d was cancelled", task.Id))
 	if err != nil {
-		return s.taskQueue.CompleteTask(ctx, task.Id, nil, err)
+		return fmt.Errorf("could not kill trino queries of task %d: %w", task.Id, err)
 	}
 
-	err = s.sqlClient.WithTx(ctx, func(cttx sqlc.Tx) error {
-		_, err := s.refresher.RefreshSnapshots(cttx, task.Database, task.Table)
+	s.logger.Info(ctx, "killed %d trino queries of cancelled task %d", killed, task.Id)
 
-		return err
+	return s.taskQueue.UpdateTaskResultNested(ctx, task.CurrentClaim(), "cancellation", map[string]any{
+		"killed_queries": killed,
 	})
+}
+
+func (s *TrinoMaintenanceExecutor) processExpireSnapshots(ctx context.Context, task *Task, input map[string]any) error {
+	params, err := expireSnapshotsParamsFromTaskInput(input, time.Now())
 	if err != nil {
-		s.logger.Warn(ctx, "failed to refresh snapshots after expiring for table %s: %s", task.Table, err)
+		return s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), nil, err)
 	}
 
-	return s.taskQueue.CompleteTask(ctx, task.Id, expireSnapshotsResultMap(res), nil)
+	res, err := s.executeExpireSnapshots(ctx, task.Id, task.Database, task.Table, params)
+	if err != nil {
+		return s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), nil, err)
+	}
+
+	return s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), expireSnapshotsResultMap(res), nil)
 }
 
 func (s *TrinoMaintenanceExecutor) processRemoveOrphanFiles(ctx context.Context, task *Task, input map[string]any) error {
 	retentionDays, _ := input["retention_days"].(float64)
 
-	res, err := s.executeRemoveOrphanFiles(ctx, task.Database, task.Table, int(retentionDays))
+	res, err := s.executeRemoveOrphanFiles(ctx, task.Id, task.Database, task.Table, int(retentionDays))
 	if err != nil {
-		return s.taskQueue.CompleteTask(ctx, task.Id, nil, err)
+		return s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), nil, err)
 	}
 
-	return s.taskQueue.CompleteTask(ctx, task.Id, removeOrphanFilesResultMap(res), nil)
+	return s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), removeOrphanFilesResultMap(res), nil)
 }
 
-func (s *TrinoMaintenanceExecutor) executeExpireSnapshots(ctx context.Context, database string, table string, retentionDays int) (*ExpireSnapshotsResult, error) {
-	if retentionDays < 1 {
-		return nil, fmt.Errorf("retention days must be at least 1")
+func (s *TrinoMaintenanceExecutor) processRewriteManifests(ctx context.Context, task *Task) error {
+	res, err := s.executeRewriteManifests(ctx, task.Id, task.Database, task.Table)
+	if err != nil {
+		return s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), nil, err)
 	}
 
-	retentionThreshold := fmt.Sprintf("%dd", retentionDays)
+	return s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), rewriteManifestsResultMap(res), nil)
+}
+
+func (s *TrinoMaintenanceExecutor) processOptimize(ctx context.Context, task *Task, input map[string]any) error {
+	targetFileSizeMb, _ := input["target_file_size_mb"].(float64)
+	from := cast.ToTime(input["from"])
+	to := cast.ToTime(input["to"])
+	scope, _ := optimizeScopeFromTaskInput(input)
+	strategy := optimizeStrategyFromTaskInput(input)
+
+	res, err := s.executeOptimize(ctx, task.Id, task.Database, task.Table, int(targetFileSizeMb), from, to, scope, strategy)
+	if err != nil {
+		return s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), nil, err)
+	}
+
+	return s.taskQueue.CompleteTask(ctx, task.CurrentClaim(), trinoOptimizeResultMap(res), nil)
+}
+
+// executeExpireSnapshots expires the snapshots older than params.olderThan. Trino has no retain_last
+// argument, so the threshold is moved back far enough to keep the retain_last newest snapshots.
+func (s *TrinoMaintenanceExecutor) executeExpireSnapshots(ctx context.Context, taskID int64, database string, table string, params expireSnapshotsParams) (*ExpireSnapshotsResult, error) {
+	olderThan := params.olderThan
+
+	if params.retainLast > 1 {
+		committedAt, err := s.listSnapshotCommitTimes(ctx, taskID, database, table)
+		if err != nil {
+			return nil, err
+		}
+
+		olderThan = retainLastCutoff(olderThan, committedAt, params.retainLast)
+	}
+
+	retentionThreshold := trinoRetentionThreshold(olderThan, time.Now())
 	qualifiedTable := qualifiedTableName(s.settings.Catalog, database, table)
-	query := fmt.Sprintf("ALTER TABLE %s EXECUTE expire_snapshots(retention_threshold => %s, clean_expired_metadata => true)", qualifiedTable, quoteLiteral(retentionThreshold))
+	query := fmt.Sprintf("ALTER TABLE %s EXECUTE expire_snapshots(retention_threshold => %s, clean_expired_metadata => %t)", qualifiedTable, quoteLiteral(retentionThreshold), params.cleanExpiredMetadata)
 
-	if err := s.trino.Exec(ctx, query); err != nil {
+	if err := s.trino.Exec(ctx, TagQuery(trinoTaskQueryTag, strconv.FormatInt(taskID, 10), query)); err != nil {
 		return nil, fmt.Errorf("could not expire snapshots for table %s: %w", table, err)
 	}
 
 	return &ExpireSnapshotsResult{
 		Database:             database,
 		Table:                table,
-		RetentionDays:        retentionDays,
-		CleanExpiredMetadata: true,
+		RetentionDays:        params.retentionDays,
+		OlderThan:            olderThan,
+		RetainLast:           params.retainLast,
+		RetentionThreshold:   retentionThreshold,
+		CleanExpiredMetadata: params.cleanExpiredMetadata,
 		Status:               statusOK,
 	}, nil
 }
 
-func (s *TrinoMaintenanceExecutor) executeRemoveOrphanFiles(ctx context.Context, database string, table string, retentionDays int) (*RemoveOrphanFilesResult, error) {
+func (s *TrinoMaintenanceExecutor) listSnapshotCommitTimes(ctx context.Context, taskID int64, database string, table string) ([]time.Time, error) {
+	var err error
+	var rows []map[string]any
+
+	qualifiedTable := qualifiedTableName(s.settings.Catalog, database, table+"$snapshots")
+	query := fmt.Sprintf("SELECT committed_at FROM %s", qualifiedTable)
+
+	if rows, err = s.trino.QueryRows(ctx, TagQuery(trinoTaskQueryTag, strconv.FormatInt(taskID, 10), query)); err != nil {
+		return nil, fmt.Errorf("could not list snapshots of table %s: %w", table, err)
+	}
+
+	committedAt := make([]time.Time, 0, len(rows))
+	for _, row := range rows {
+		value, err := cast.ToTimeE(row["committed_at"])
+		if err != nil {
+			return nil, fmt.Errorf("could not cast committed_at: %w", err)
+		}
+
+		committedAt = append(committedAt, value)
+	}
+
+	return committedAt, nil
+}
+
+func (s *TrinoMaintenanceExecutor) executeRemoveOrphanFiles(ctx context.Context, taskID int64, database string, table string, retentionDays int) (*RemoveOrphanFilesResult, error) {
 	if retentionDays < 1 {
 		return nil, fmt.Errorf("retention days must be at least 1")
 	}
@@ -169,7 +273,7 @@ func (s *TrinoMaintenanceExecutor) executeRemoveOrphanFiles(ctx context.Context,
 	qualifiedTable := qualifiedTableName(s.settings.Catalog, database, table)
 	query := fmt.Sprintf("ALTER TABLE %s EXECUTE remove_orphan_files(retention_threshold => %s)", qualifiedTable, quoteLiteral(retentionThreshold))
 
-	if rows, err = s.trino.QueryRows(ctx, query); err != nil {
+	if rows, err = s.trino.QueryRows(ctx, TagQuery(trinoTaskQueryTag, strconv.FormatInt(taskID, 10), query)); err != nil {
 		return nil, fmt.Errorf("could not remove orphan files for table %s: %w", table, err)
 	}
 
@@ -191,3 +295,221 @@ func (s *TrinoMaintenanceExecutor) executeRemoveOrphanFiles(ctx context.Context,
 		Status:        statusOK,
 	}, nil
 }
+
+func (s *TrinoMaintenanceExecutor) executeRewriteManifests(ctx context.Context, taskID int64, database string, table string) (*RewriteManifestsResult, error) {
+	var err error
+	var before, after int64
+
+	if before, err = s.countManifests(ctx, taskID, database, table); err != nil {
+		return nil, err
+	}
+
+	qualifiedTable := qualifiedTableName(s.settings.Catalog, database, table)
+	query := fmt.Sprintf("ALTER TABLE %s EXECUTE optimize_manifests", qualifiedTable)
+
+	if err = s.trino.Exec(ctx, TagQuery(trinoTaskQueryTag, strconv.FormatInt(taskID, 10), query)); err != nil {
+		return nil, fmt.Errorf("could not rewrite manifests for table %s: %w", table, err)
+	}
+
+	if after, err = s.countManifests(ctx, taskID, database, table); err != nil {
+		return nil, err
+	}
+
+	return &RewriteManifestsResult{
+		Database:            database,
+		Table:               table,
+		ManifestCountBefore: before,
+		ManifestCountAfter:  after,
+		Status:              statusOK,
+	}, nil
+}
+
+// executeOptimize rewrites the small data files of a date range, or of a partition scope if scope is not
+// nil. The rewritten and added files are counted by comparing the data files of the affected partitions
+// before and after the rewrite.
+func (s *TrinoMaintenanceExecutor) executeOptimize(ctx context.Context, taskID int64, database string, table string, targetFileSizeMb int, from time.Time, to time.Time, scope PartitionValues, strategy OptimizeStrategy) (*TrinoOptimizeResult, error) {
+	if targetFileSizeMb < 1 {
+		return nil, fmt.Errorf("target file size must be at least 1 MB")
+	}
+
+	var err error
+	var desc *TableDescription
+	var where string
+	var before, after []string
+
+	if desc, err = s.metadata.GetTable(ctx, database, table); err != nil {
+		return nil, fmt.Errorf("could not get table metadata: %w", err)
+	}
+
+	if err = validateTrinoOptimizeStrategy(strategy, desc.SortOrder.Get()); err != nil {
+		return nil, err
+	}
+
+	layout := newOptimizePartitionLayout(desc.Partitions.Get())
+
+	if scope != nil {
+		if where, err = layout.predicate(scope, trinoDialect); err != nil {
+			return nil, fmt.Errorf("could not build filter for partition %s: %w", scope.String(), err)
+		}
+	} else {
+		if from.After(to) {
+			return nil, fmt.Errorf("from date must be before or equal to the to date")
+		}
+
+		if !layout.chunksByDate() {
+			return nil, fmt.Errorf("no suitable hour or day partition column found for optimization")
+		}
+
+		where = layout.rangePredicate(from, to, trinoDialect)
+	}
+
+	filesFilter := trinoFilesPartitionFilter(layout, from, to, scope)
+
+	if before, err = s.listDataFiles(ctx, taskID, database, table, filesFilter); err != nil {
+		return nil, err
+	}
+
+	qualifiedTable := qualifiedTableName(s.settings.Catalog, database, table)
+	query := fmt.Sprintf("ALTER TABLE %s EXECUTE optimize(file_size_threshold => %s)", qualifiedTable, quoteLiteral(fmt.Sprintf("%dMB", targetFileSizeMb)))
+	if where != "" {
+		query += " WHERE " + where
+	}
+
+	s.logger.Info(ctx, "optimizing table %s where %q", table, where)
+
+	if err = s.trino.Exec(ctx, TagQuery(trinoTaskQueryTag, strconv.FormatInt(taskID, 10), query)); err != nil {
+		return nil, fmt.Errorf("could not optimize table %s: %w", table, err)
+	}
+
+	if after, err = s.listDataFiles(ctx, taskID, database, table, filesFilter); err != nil {
+		return nil, err
+	}
+
+	rewritten, added := diffDataFiles(before, after)
+
+	return &TrinoOptimizeResult{
+		Database:               database,
+		Table:                  table,
+		TargetFileSizeMb:       targetFileSizeMb,
+		Where:                  where,
+		Strategy:               strategy.Strategy,
+		DataFileCountBefore:    int64(len(before)),
+		DataFileCountAfter:     int64(len(after)),
+		RewrittenDataFileCount: rewritten,
+		AddedDataFileCount:     added,
+		Status:                 statusOK,
+	}, nil
+}
+
+// listDataFiles returns the paths of the data files of the table matching the $files filter.
+func (s *TrinoMaintenanceExecutor) listDataFiles(ctx context.Context, taskID int64, database string, table string, filter string) ([]string, error) {
+	var err error
+	var rows []map[string]any
+
+	qualifiedTable := qualifiedTableName(s.settings.Catalog, database, table+"$files")
+	query := fmt.Sprintf("SELECT file_path FROM %s WHERE content = 0", qualifiedTable)
+	if filter != "" {
+		query += " AND " + filter
+	}
+
+	if rows, err = s.trino.QueryRows(ctx, TagQuery(trinoTaskQueryTag, strconv.FormatInt(taskID, 10), query)); err != nil {
+		return nil, fmt.Errorf("could not list data files of table %s: %w", table, err)
+	}
+
+	paths := make([]string, 0, len(rows))
+	for _, row := range rows {
+		path, err := cast.ToStringE(row["file_path"])
+		if err != nil {
+			return nil, fmt.Errorf("could not cast file_path: %w", err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// trinoFilesPartitionFilter narrows the $files of a table down to the partitions an optimize task
+// covers. Partitions which can not be matched in $files, like hours or months, are not filtered and
+// the filter then covers more files than the task rewrites.
+func trinoFilesPartitionFilter(layout optimizePartitionLayout, from time.Time, to time.Time, scope PartitionValues) string {
+	conditions := make([]string, 0, len(layout.fields))
+
+	for _, field := range layout.fields {
+		column := "partition." + quoteIdent(field.RawFieldName)
+
+		switch {
+		case scope == nil && field.IsHidden && field.Hidden.Type == transformDay && field.Name == "day":
+			conditions = append(conditions, fmt.Sprintf("CAST(%s AS VARCHAR) BETWEEN %s AND %s", column, quoteLiteral(from.Format(time.DateOnly)), quoteLiteral(to.Format(time.DateOnly))))
+		case scope != nil && !field.IsHidden:
+			value, ok := scope[field.Name]
+			if !ok {
+				continue
+			}
+
+			if value == nil {
+				conditions = append(conditions, fmt.Sprintf("%s IS NULL", column))
+
+				continue
+			}
+
+			literal := cast.ToString(value)
+			if keyword, temporal, err := temporalPartitionLiteral(field.SourceType, value); err == nil && keyword != "" {
+				literal = temporal
+			}
+
+			conditions = append(conditions, fmt.Sprintf("CAST(%s AS VARCHAR) = %s", column, quoteLiteral(literal)))
+		}
+	}
+
+	return strings.Join(conditions, " AND ")
+}
+
+// diffDataFiles counts the files removed from and added to a set of data files.
+func diffDataFiles(before []string, after []string) (removed int64, added int64) {
+	beforeSet := make(map[string]struct{}, len(before))
+	for _, path := range before {
+		beforeSet[path] = struct{}{}
+	}
+
+	afterSet := make(map[string]struct{}, len(after))
+	for _, path := range after {
+		afterSet[path] = struct{}{}
+
+		if _, ok := beforeSet[path]; !ok {
+			added++
+		}
+	}
+
+	for path := range beforeSet {
+		if _, ok := afterSet[path]; !ok {
+			removed++
+		}
+	}
+
+	return removed, added
+}
+
+// countManifests returns the number of manifests referenced by the current snapshot of the table.
+func (s *TrinoMaintenanceExecutor) countManifests(ctx context.Context, taskID int64, database string, table string) (int64, error) {
+	var err error
+	var rows []map[string]any
+	var count int64
+
+	qualifiedTable := qualifiedTableName(s.settings.Catalog, database, table+"$manifests")
+	query := fmt.Sprintf("SELECT count(*) AS manifest_count FROM %s", qualifiedTable)
+
+	if rows, err = s.trino.QueryRows(ctx, TagQuery(trinoTaskQueryTag, strconv.FormatInt(taskID, 10), query)); err != nil {
+		return 0, fmt.Errorf("could not count manifests of table %s: %w", table, err)
+	}
+
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	if count, err = cast.ToInt64E(rows[0]["manifest_count"]); err != nil {
+		return 0, fmt.Errorf("could not parse manifest count of table %s: %w", table, err)
+	}
+
+	return count, nil
+}
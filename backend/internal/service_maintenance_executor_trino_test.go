@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrinoFilesPartitionFilter(t *testing.T) {
+	from := time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.February, 7, 0, 0, 0, 0, time.UTC)
+
+	dayLayout := newOptimizePartitionLayout([]TablePartition{
+		{Name: "year", RawFieldName: "event_time_day", IsHidden: true, Hidden: TablePartitionHidden{Column: "event_time", Type: transformDay}},
+		{Name: "month", RawFieldName: "event_time_day", IsHidden: true, Hidden: TablePartitionHidden{Column: "event_time", Type: transformDay}},
+		{Name: "day", RawFieldName: "event_time_day", IsHidden: true, Hidden: TablePartitionHidden{Column: "event_time", Type: transformDay}},
+		{Name: "country", RawFieldName: "country", IsHidden: false},
+	})
+	require.Equal(t, `CAST(partition."event_time_day" AS VARCHAR) BETWEEN '2026-02-01' AND '2026-02-07'`, trinoFilesPartitionFilter(dayLayout, from, to, nil))
+	require.Equal(t, `"event_time" >= DATE '2026-02-01' AND "event_time" < DATE '2026-02-08'`, dayLayout.rangePredicate(from, to, trinoDialect))
+
+	identityLayout := newOptimizePartitionLayout([]TablePartition{
+		{Name: "country", RawFieldName: "country", IsHidden: false},
+		{Name: "platform", RawFieldName: "platform", IsHidden: false},
+		{Name: "user_id_bucket", RawFieldName: "user_id_bucket", IsHidden: true, Hidden: TablePartitionHidden{Column: "user_id", Type: "bucket[16]"}},
+	})
+	require.Equal(t, `CAST(partition."country" AS VARCHAR) = 'd''e' AND partition."platform" IS NULL`, trinoFilesPartitionFilter(identityLayout, time.Time{}, time.Time{}, PartitionValues{"country": "d'e", "platform": nil}))
+	require.Equal(t, "", trinoFilesPartitionFilter(identityLayout, time.Time{}, time.Time{}, PartitionValues{}))
+}
+
+func TestDiffDataFiles(t *testing.T) {
+	removed, added := diffDataFiles([]string{"a", "b", "c"}, []string{"c", "d"})
+	require.Equal(t, int64(2), removed)
+	require.Equal(t, int64(1), added)
+
+	removed, added = diffDataFiles(nil, nil)
+	require.Equal(t, int64(0), removed)
+	require.Equal(t, int64(0), added)
+}
+
+func TestValidateTrinoOptimizeStrategy(t *testing.T) {
+	sortOrder := []TableSortField{{Column: "event_time", Transform: "identity", Direction: "desc", NullOrder: "nulls-last"}}
+
+	require.NoError(t, validateTrinoOptimizeStrategy(OptimizeStrategy{Strategy: optimizeStrategyBinpack}, sortOrder))
+	require.NoError(t, validateTrinoOptimizeStrategy(OptimizeStrategy{Strategy: optimizeStrategySort, Columns: []string{"event_time desc nulls last"}}, sortOrder))
+	require.Error(t, validateTrinoOptimizeStrategy(OptimizeStrategy{Strategy: optimizeStrategySort, Columns: []string{"user_id"}}, sortOrder))
+	require.Error(t, validateTrinoOptimizeStrategy(OptimizeStrategy{Strategy: optimizeStrategyZorder, Columns: []string{"lat", "lon"}}, sortOrder))
+}
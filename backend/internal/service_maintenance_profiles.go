@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gosoline-project/sqlc"
+	"github.com/justtrackio/gosoline/pkg/cfg"
+	"github.com/justtrackio/gosoline/pkg/db"
+	"github.com/justtrackio/gosoline/pkg/log"
+)
+
+// MaintenanceProfile overrides the maintenance parameters of a database or a single table. Unset fields
+// of a table profile fall back to the profile of its database and then to the global configuration.
+type MaintenanceProfile struct {
+	Engines                        map[TaskKind]TaskEngine `json:"engines,omitempty"`
+	TargetFileSizeMb               *int                    `json:"target_file_size_mb,omitempty"`
+	ChunkBy                        *string                 `json:"chunk_by,omitempty"`
+	OptimizeLookbackDays           *int                    `json:"optimize_lookback_days,omitempty"`
+	ExpireSnapshotsRetentionDays   *int                    `json:"expire_snapshots_retention_days,omitempty"`
+	RemoveOrphanFilesRetentionDays *int                    `json:"remove_orphan_files_retention_days,omitempty"`
+	SmallFileThresholdBytes        *int64                  `json:"small_file_threshold_bytes,omitempty"`
+	SmallFileMinCount              *int                    `json:"small_file_min_count,omitempty"`
+	SmallFileMinSharePct           *int                    `json:"small_file_min_share_percent,omitempty"`
+}
+
+// MaintenanceProfileRecord is a stored profile. Database profiles are stored with an empty table.
+type MaintenanceProfileRecord struct {
+	Database  string                                      `json:"database" db:"database"`
+	Table     string                                      `json:"table" db:"table"`
+	Profile   db.JSON[MaintenanceProfile, db.NonNullable] `json:"profile" db:"profile"`
+	UpdatedAt time.Time                                   `json:"updated_at" db:"updated_at"`
+}
+
+func (p MaintenanceProfile) validate() error {
+	for kind, engine := range p.Engines {
+		if err := validateTaskEngine(kind, engine); err != nil {
+			return err
+		}
+	}
+
+	if p.TargetFileSizeMb != nil && *p.TargetFileSizeMb < 1 {
+		return fmt.Errorf("target_file_size_mb must be at least 1")
+	}
+
+	if p.ChunkBy != nil {
+		if _, err := normalizeOptimizeChunkBy(*p.ChunkBy); err != nil {
+			return err
+		}
+	}
+
+	if p.OptimizeLookbackDays != nil && *p.OptimizeLookbackDays < 1 {
+		return fmt.Errorf("optimize_lookback_days must be at least 1")
+	}
+
+	if p.ExpireSnapshotsRetentionDays != nil && *p.ExpireSnapshotsRetentionDays < minRetentionDays {
+		return fmt.Errorf("expire_snapshots_retention_days must be at least %d", minRetentionDays)
+	}
+
+	if p.RemoveOrphanFilesRetentionDays != nil && *p.RemoveOrphanFilesRetentionDays < minRetentionDays {
+		return fmt.Errorf("remove_orphan_files_retention_days must be at least %d", minRetentionDays)
+	}
+
+	if p.SmallFileThresholdBytes != nil && *p.SmallFileThresholdBytes < 1 {
+		return fmt.Errorf("small_file_threshold_bytes must be at least 1")
+	}
+
+	if p.SmallFileMinCount != nil && *p.SmallFileMinCount < 1 {
+		return fmt.Errorf("small_file_min_count must be at least 1")
+	}
+
+	if p.SmallFileMinSharePct != nil && (*p.SmallFileMinSharePct < 0 || *p.SmallFileMinSharePct > 100) {
+		return fmt.Errorf("small_file_min_share_percent must be between 0 and 100")
+	}
+
+	return nil
+}
+
+// merge returns the profile with all fields set in override replacing its own. Engines are merged per task kind.
+func (p MaintenanceProfile) merge(override MaintenanceProfile) MaintenanceProfile {
+	engines := make(map[TaskKind]TaskEngine, len(p.Engines)+len(override.Engines))
+	for kind, engine := range p.Engines {
+		engines[kind] = engine
+	}
+	for kind, engine := range override.Engines {
+		engines[kind] = engine
+	}
+
+	p.Engines = engines
+	p.TargetFileSizeMb = overrideValue(p.TargetFileSizeMb, override.TargetFileSizeMb)
+	p.ChunkBy = overrideValue(p.ChunkBy, override.ChunkBy)
+	p.OptimizeLookbackDays = overrideValue(p.OptimizeLookbackDays, override.OptimizeLookbackDays)
+	p.ExpireSnapshotsRetentionDays = overrideValue(p.ExpireSnapshotsRetentionDays, override.ExpireSnapshotsRetentionDays)
+	p.RemoveOrphanFilesRetentionDays = overrideValue(p.RemoveOrphanFilesRetentionDays, override.RemoveOrphanFilesRetentionDays)
+	p.SmallFileThresholdBytes = overrideValue(p.SmallFileThresholdBytes, override.SmallFileThresholdBytes)
+	p.SmallFileMinCount = overrideValue(p.SmallFileMinCount, override.SmallFileMinCount)
+	p.SmallFileMinSharePct = overrideValue(p.SmallFileMinSharePct, override.SmallFileMinSharePct)
+
+	return p
+}
+
+// resolveEngine returns the engine of the task kind, preferring the engine of the profile over the
+// globally configured one.
+func (p MaintenanceProfile) resolveEngine(kind TaskKind, resolver *TaskEngineResolver) (TaskEngine, error) {
+	if engine, ok := p.Engines[kind]; ok {
+		return engine, nil
+	}
+
+	return resolver.Resolve(kind)
+}
+
+func overrideValue[T any](value *T, override *T) *T {
+	if override != nil {
+		return override
+	}
+
+	return value
+}
+
+// valueOrDefault returns the value if it is set, fallback otherwise.
+func valueOrDefault[T any](value *T, fallback T) T {
+	if value != nil {
+		return *value
+	}
+
+	return fallback
+}
+
+type ServiceMaintenanceProfiles struct {
+	logger    log.Logger
+	sqlClient sqlc.Client
+}
+
+func NewServiceMaintenanceProfiles(ctx context.Context, config cfg.Config, logger log.Logger) (*ServiceMaintenanceProfiles, error) {
+	var err error
+	var sqlClient sqlc.Client
+
+	if sqlClient, err = sqlc.ProvideClient(ctx, config, logger, "default"); err != nil {
+		return nil, fmt.Errorf("could not create sqlc client: %w", err)
+	}
+
+	return &ServiceMaintenanceProfiles{
+		logger:    logger.WithChannel("maintenance_profiles"),
+		sqlClient: sqlClient,
+	}, nil
+}
+
+// ListProfiles returns the profile of the database and the profiles of its tables.
+func (s *ServiceMaintenanceProfiles) ListProfiles(ctx context.Context, database string) ([]MaintenanceProfileRecord, error) {
+	records := make([]MaintenanceProfileRecord, 0)
+
+	sel := s.sqlClient.Q().From("maintenance_profiles").Where(sqlc.Eq{"database": database}).OrderBy(sqlc.Col("table").Asc())
+	if err := sel.Select(ctx, &records); err != nil {
+		return nil, fmt.Errorf("could not list maintenance profiles of database %s: %w", database, err)
+	}
+
+	return records, nil
+}
+
+// SetProfile stores the profile of a database, or of a table if table is not empty.
+func (s *ServiceMaintenanceProfiles) SetProfile(ctx context.Context, database string, table string, profile MaintenanceProfile) (*MaintenanceProfileRecord, error) {
+	var err error
+	var encoded []byte
+
+	if database == "" {
+		return nil, fmt.Errorf("database is required")
+	}
+
+	if err = profile.validate(); err != nil {
+		return nil, fmt.Errorf("invalid maintenance profile: %w", err)
+	}
+
+	if encoded, err = json.Marshal(profile); err != nil {
+		return nil, fmt.Errorf("could not encode maintenance profile: %w", err)
+	}
+
+	rawSQL := "INSERT INTO maintenance_profiles (`database`, `table`, `profile`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `profile` = VALUES(`profile`), updated_at = CURRENT_TIMESTAMP(6)"
+	if _, err = s.sqlClient.Exec(ctx, rawSQL, database, table, string(encoded)); err != nil {
+		return nil, fmt.Errorf("could not store maintenance profile of %s: %w", describeProfileScope(database, table), err)
+	}
+
+	s.logger.Info(ctx, "stored maintenance profile of %s", describeProfileScope(database, table))
+
+	return &MaintenanceProfileRecord{
+		Database:  database,
+		Table:     table,
+		Profile:   db.NewJSON(profile, db.NonNullable{}),
+		UpdatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// DeleteProfile removes the profile of a database, or of a table if table is not empty. Table profiles
+// are not removed together with their database profile.
+func (s *ServiceMaintenanceProfiles) DeleteProfile(ctx context.Context, database string, table string) error {
+	if _, err := s.sqlClient.Q().Delete("maintenance_profiles").Where(sqlc.Eq{"database": database, "table": table}).Exec(ctx); err != nil {
+		return fmt.Errorf("could not delete maintenance profile of %s: %w", describeProfileScope(database, table), err)
+	}
+
+	s.logger.Info(ctx, "deleted maintenance profile of %s", describeProfileScope(database, table))
+
+	return nil
+}
+
+// ResolveProfile returns the effective profile of a table: its own profile merged over the profile of
+// its database. Fields set in neither are left unset for the caller to apply its defaults.
+func (s *ServiceMaintenanceProfiles) ResolveProfile(ctx context.Context, database string, table string) (MaintenanceProfile, error) {
+	records := make([]MaintenanceProfileRecord, 0, 2)
+
+	sel := s.sqlClient.Q().From("maintenance_profiles").
+		Where(sqlc.Eq{"database": database}).
+		Where(sqlc.Col("table").In("", table))
+
+	if err := sel.Select(ctx, &records); err != nil {
+		return MaintenanceProfile{}, fmt.Errorf("could not load maintenance profiles of %s: %w", describeProfileScope(database, table), err)
+	}
+
+	return resolveMaintenanceProfile(records, table), nil
+}
+
+func resolveMaintenanceProfile(records []MaintenanceProfileRecord, table string) MaintenanceProfile {
+	var databaseProfile, tableProfile MaintenanceProfile
+
+	for _, record := range records {
+		switch record.Table {
+		case "":
+			databaseProfile = record.Profile.Get()
+		case table:
+			tableProfile = record.Profile.Get()
+		}
+	}
+
+	return databaseProfile.merge(tableProfile)
+}
+
+func describeProfileScope(database string, table string) string {
+	if table == "" {
+		return fmt.Sprintf("database %s", database)
+	}
+
+	return fmt.Sprintf("table %s.%s", database, table)
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/justtrackio/gosoline/pkg/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveMaintenanceProfileMergesTableOverDatabase(t *testing.T) {
+	databaseSize, tableSize := 512, 1024
+	databaseChunkBy := optimizeChunkWeek
+	retentionDays := 14
+
+	records := []MaintenanceProfileRecord{
+		{
+			Database: "main",
+			Table:    "",
+			Profile: db.NewJSON(MaintenanceProfile{
+				Engines:                      map[TaskKind]TaskEngine{TaskKindOptimize: TaskEngineTrino, TaskKindExpireSnapshots: TaskEngineTrino},
+				TargetFileSizeMb:             &databaseSize,
+				ChunkBy:                      &databaseChunkBy,
+				ExpireSnapshotsRetentionDays: &retentionDays,
+			}, db.NonNullable{}),
+		},
+		{
+			Database: "main",
+			Table:    "clickstream",
+			Profile: db.NewJSON(MaintenanceProfile{
+				Engines:          map[TaskKind]TaskEngine{TaskKindOptimize: TaskEngineSpark},
+				TargetFileSizeMb: &tableSize,
+			}, db.NonNullable{}),
+		},
+	}
+
+	profile := resolveMaintenanceProfile(records, "clickstream")
+	require.Equal(t, map[TaskKind]TaskEngine{TaskKindOptimize: TaskEngineSpark, TaskKindExpireSnapshots: TaskEngineTrino}, profile.Engines)
+	require.Equal(t, 1024, valueOrDefault(profile.TargetFileSizeMb, 0))
+	require.Equal(t, optimizeChunkWeek, valueOrDefault(profile.ChunkBy, ""))
+	require.Equal(t, 14, valueOrDefault(profile.ExpireSnapshotsRetentionDays, 0))
+	require.Equal(t, 7, valueOrDefault(profile.RemoveOrphanFilesRetentionDays, 7))
+
+	profile = resolveMaintenanceProfile(records, "dimensions")
+	require.Equal(t, 512, valueOrDefault(profile.TargetFileSizeMb, 0))
+	require.Equal(t, TaskEngineTrino, profile.Engines[TaskKindOptimize])
+}
+
+func TestMaintenanceProfileValidate(t *testing.T) {
+	zero, tooShort, share := 0, 3, 101
+	chunkBy := "hourly"
+
+	require.NoError(t, MaintenanceProfile{}.validate())
+	require.NoError(t, MaintenanceProfile{Engines: map[TaskKind]TaskEngine{TaskKindOptimize: TaskEngineTrino}}.validate())
+	require.Error(t, MaintenanceProfile{Engines: map[TaskKind]TaskEngine{TaskKindRewritePositionDeleteFiles: TaskEngineTrino}}.validate())
+	require.Error(t, MaintenanceProfile{TargetFileSizeMb: &zero}.validate())
+	require.Error(t, MaintenanceProfile{ChunkBy: &chunkBy}.validate())
+	require.Error(t, MaintenanceProfile{ExpireSnapshotsRetentionDays: &tooShort}.validate())
+	require.Error(t, MaintenanceProfile{SmallFileMinSharePct: &share}.validate())
+}
@@ -17,12 +17,14 @@ type MaintenanceScheduleCycleResult struct {
 	ExpireSnapshotsFailureCount   int
 	RemoveOrphanFilesTaskCount    int
 	RemoveOrphanFilesFailureCount int
+	DeduplicatedTaskCount         int
 }
 
 type ServiceMaintenanceSchedule struct {
 	logger   log.Logger
 	metadata *ServiceMetadata
 	tasks    *ServiceTasks
+	profiles *ServiceMaintenanceProfiles
 	settings *MaintenanceScheduleSettings
 }
 
@@ -30,6 +32,7 @@ func NewServiceMaintenanceSchedule(ctx context.Context, config cfg.Config, logge
 	var err error
 	var metadata *ServiceMetadata
 	var tasks *ServiceTasks
+	var profiles *ServiceMaintenanceProfiles
 	var settings *MaintenanceScheduleSettings
 
 	if metadata, err = NewServiceMetadata(ctx, config, logger); err != nil {
@@ -40,6 +43,10 @@ func NewServiceMaintenanceSchedule(ctx context.Context, config cfg.Config, logge
 		return nil, fmt.Errorf("could not create tasks service: %w", err)
 	}
 
+	if profiles, err = NewServiceMaintenanceProfiles(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create maintenance profiles service: %w", err)
+	}
+
 	if settings, err = ReadMaintenanceScheduleSettings(config); err != nil {
 		return nil, fmt.Errorf("could not read maintenance schedule settings: %w", err)
 	}
@@ -48,6 +55,7 @@ func NewServiceMaintenanceSchedule(ctx context.Context, config cfg.Config, logge
 		logger:   logger.WithChannel("maintenance_schedule"),
 		metadata: metadata,
 		tasks:    tasks,
+		profiles: profiles,
 		settings: settings,
 	}, nil
 }
@@ -55,7 +63,6 @@ func NewServiceMaintenanceSchedule(ctx context.Context, config cfg.Config, logge
 func (s *ServiceMaintenanceSchedule) RunCycle(ctx context.Context, now time.Time) (*MaintenanceScheduleCycleResult, error) {
 	var err error
 	var tables []TableDescription
-	var taskIDs []int64
 
 	if tables, err = s.metadata.ListAllTables(ctx); err != nil {
 		return nil, fmt.Errorf("could not list tables for maintenance scheduling: %w", err)
@@ -66,41 +73,87 @@ func (s *ServiceMaintenanceSchedule) RunCycle(ctx context.Context, now time.Time
 		return result, nil
 	}
 
-	from, to := scheduledOptimizeRange(now.UTC(), s.settings.Optimize.LookbackDays)
 	for _, table := range tables {
-		if taskIDs, err = s.tasks.EnqueueOptimize(ctx, table.Database, table.Name, s.settings.Optimize.TargetFileSizeMb, from, to, s.settings.Optimize.ChunkBy); err != nil {
-			result.OptimizeFailureCount++
-			s.logger.Warn(ctx, "failed to enqueue scheduled optimize for table %s.%s: %s", table.Database, table.Name, err)
-
-			continue
+		profile, err := s.profiles.ResolveProfile(ctx, table.Database, table.Name)
+		if err != nil {
+			s.logger.Warn(ctx, "failed to resolve maintenance profile of table %s.%s, using the schedule defaults: %s", table.Database, table.Name, err)
 		}
 
-		result.OptimizeTaskCount += len(taskIDs)
+		s.enqueueTableChain(ctx, table, s.tableSettings(profile), now.UTC(), result)
 	}
 
-	for _, table := range tables {
-		if _, err = s.tasks.EnqueueExpireSnapshots(ctx, table.Database, table.Name, s.settings.ExpireSnapshots.RetentionDays); err != nil {
-			result.ExpireSnapshotsFailureCount++
-			s.logger.Warn(ctx, "failed to enqueue scheduled expire_snapshots for table %s.%s: %s", table.Database, table.Name, err)
+	return result, nil
+}
 
-			continue
-		}
+// enqueueTableChain enqueues the maintenance tasks of a table as a chain: expire_snapshots only runs after
+// all optimize tasks succeeded, and remove_orphan_files only after expire_snapshots succeeded. If a step
+// can not be enqueued, the next step is enqueued without depending on it.
+func (s *ServiceMaintenanceSchedule) enqueueTableChain(ctx context.Context, table TableDescription, settings MaintenanceScheduleSettings, now time.Time, result *MaintenanceScheduleCycleResult) {
+	var err error
+	var enqueued EnqueuedTask
+	var enqueuedTasks []EnqueuedTask
+	var dependsOn []int64
+
+	// scheduled tasks get a lower priority by default so manually enqueued work is not stuck behind them.
+	// The schedule settings are only defaults, the profile and the table properties of the table win.
+	options := TaskEnqueueOptions{Priority: settings.Priority, PreferProfile: true}
+
+	from, to := scheduledOptimizeRange(now, settings.Optimize.LookbackDays)
+	if enqueuedTasks, err = s.tasks.EnqueueOptimize(ctx, table.Database, table.Name, settings.Optimize.TargetFileSizeMb, from, to, settings.Optimize.ChunkBy, OptimizeStrategy{}, options); err != nil {
+		result.OptimizeFailureCount++
+		s.logger.Warn(ctx, "failed to enqueue scheduled optimize for table %s.%s: %s", table.Database, table.Name, err)
+	}
 
-		result.ExpireSnapshotsTaskCount++
+	for _, enqueued = range enqueuedTasks {
+		result.count(enqueued, &result.OptimizeTaskCount)
+		dependsOn = append(dependsOn, enqueued.TaskId)
 	}
 
-	for _, table := range tables {
-		if _, err = s.tasks.EnqueueRemoveOrphanFiles(ctx, table.Database, table.Name, s.settings.RemoveOrphanFiles.RetentionDays); err != nil {
-			result.RemoveOrphanFilesFailureCount++
-			s.logger.Warn(ctx, "failed to enqueue scheduled remove_orphan_files for table %s.%s: %s", table.Database, table.Name, err)
+	expireOptions := ExpireSnapshotsOptions{
+		RetentionDays:        settings.ExpireSnapshots.RetentionDays,
+		RetainLast:           settings.ExpireSnapshots.RetainLast,
+		CleanExpiredMetadata: &settings.ExpireSnapshots.CleanExpiredMetadata,
+	}
 
-			continue
-		}
+	options.DependsOn = dependsOn
+	if enqueued, err = s.tasks.EnqueueExpireSnapshots(ctx, table.Database, table.Name, expireOptions, options); err != nil {
+		result.ExpireSnapshotsFailureCount++
+		s.logger.Warn(ctx, "failed to enqueue scheduled expire_snapshots for table %s.%s: %s", table.Database, table.Name, err)
 
-		result.RemoveOrphanFilesTaskCount++
+		options.DependsOn = nil
+	} else {
+		result.count(enqueued, &result.ExpireSnapshotsTaskCount)
+		options.DependsOn = []int64{enqueued.TaskId}
 	}
 
-	return result, nil
+	if enqueued, err = s.tasks.EnqueueRemoveOrphanFiles(ctx, table.Database, table.Name, settings.RemoveOrphanFiles.RetentionDays, options); err != nil {
+		result.RemoveOrphanFilesFailureCount++
+		s.logger.Warn(ctx, "failed to enqueue scheduled remove_orphan_files for table %s.%s: %s", table.Database, table.Name, err)
+
+		return
+	}
+
+	result.count(enqueued, &result.RemoveOrphanFilesTaskCount)
+}
+
+// tableSettings applies the optimize lookback of the maintenance profile of a table to the schedule
+// settings. All other task parameters of the profile are resolved by the tasks service, see
+// TaskEnqueueOptions.PreferProfile.
+func (s *ServiceMaintenanceSchedule) tableSettings(profile MaintenanceProfile) MaintenanceScheduleSettings {
+	settings := *s.settings
+	settings.Optimize.LookbackDays = valueOrDefault(profile.OptimizeLookbackDays, settings.Optimize.LookbackDays)
+
+	return settings
+}
+
+func (r *MaintenanceScheduleCycleResult) count(enqueued EnqueuedTask, taskCount *int) {
+	if enqueued.Deduplicated {
+		r.DeduplicatedTaskCount++
+
+		return
+	}
+
+	*taskCount++
 }
 
 func scheduledOptimizeRange(now time.Time, lookbackDays int) (from time.Time, to time.Time) {
@@ -7,11 +7,12 @@ import (
 )
 
 type MaintenanceScheduleSettings struct {
-	Enabled           bool                                 `cfg:"enabled"`
-	Cron              string                               `cfg:"cron"`
-	Optimize          MaintenanceScheduleOptimizeSettings  `cfg:"optimize"`
-	ExpireSnapshots   MaintenanceScheduleRetentionSettings `cfg:"expire_snapshots"`
-	RemoveOrphanFiles MaintenanceScheduleRetentionSettings `cfg:"remove_orphan_files"`
+	Enabled           bool                                       `cfg:"enabled"`
+	Cron              string                                     `cfg:"cron"`
+	Priority          int                                        `cfg:"priority" default:"-10"`
+	Optimize          MaintenanceScheduleOptimizeSettings        `cfg:"optimize"`
+	ExpireSnapshots   MaintenanceScheduleExpireSnapshotsSettings `cfg:"expire_snapshots"`
+	RemoveOrphanFiles MaintenanceScheduleRetentionSettings       `cfg:"remove_orphan_files"`
 }
 
 type MaintenanceScheduleOptimizeSettings struct {
@@ -20,6 +21,12 @@ type MaintenanceScheduleOptimizeSettings struct {
 	ChunkBy          string `cfg:"chunk_by" default:"daily"`
 }
 
+type MaintenanceScheduleExpireSnapshotsSettings struct {
+	RetentionDays        int  `cfg:"retention_days" default:"7"`
+	RetainLast           int  `cfg:"retain_last" default:"0"`
+	CleanExpiredMetadata bool `cfg:"clean_expired_metadata" default:"true"`
+}
+
 type MaintenanceScheduleRetentionSettings struct {
 	RetentionDays int `cfg:"retention_days" default:"7"`
 }
@@ -50,6 +57,10 @@ func ReadMaintenanceScheduleSettings(config cfg.Config) (*MaintenanceScheduleSet
 		return nil, fmt.Errorf("tasks.schedule.expire_snapshots.retention_days must be at least 1")
 	}
 
+	if settings.ExpireSnapshots.RetainLast < 0 {
+		return nil, fmt.Errorf("tasks.schedule.expire_snapshots.retain_last must not be negative")
+	}
+
 	if settings.RemoveOrphanFiles.RetentionDays < 1 {
 		return nil, fmt.Errorf("tasks.schedule.remove_orphan_files.retention_days must be at least 1")
 	}
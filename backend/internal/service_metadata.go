@@ -47,7 +47,9 @@ func (s *ServiceMetadata) GetTableSummary(ctx context.Context, desc TableDescrip
 		Column(sqlc.Coalesce(sqlc.Col("p.file_count").Sum(), 0).As("file_count")).
 		Column(sqlc.Coalesce(sqlc.Col("p.record_count").Sum(), 0).As("record_count")).
 		Column(sqlc.Coalesce(sqlc.Col("p.total_data_file_size_in_bytes").Sum(), 0).As("total_data_file_size_in_bytes")).
+		Column(sqlc.Coalesce(sqlc.Col("p.delete_file_count").Sum(), 0).As("delete_file_count")).
 		Column(sqlc.Coalesce(sqlc.Col("p.needs_optimize").Max(), false).As("needs_optimize")).
+		Column(sqlc.Coalesce(sqlc.Col("p.needs_compaction").Max(), false).As("needs_compaction")).
 		Where(sqlc.Eq{"p.database": desc.Database, "p.table": desc.Name})
 
 	if err := sel.Get(ctx, summary); err != nil {
@@ -98,6 +100,34 @@ func (s *ServiceMetadata) ListAllTables(ctx context.Context) ([]TableDescription
 	return tables, nil
 }
 
+// ListSnapshots returns the stored snapshots of a table, oldest first.
+func (s *ServiceMetadata) ListSnapshots(ctx context.Context, database string, name string) ([]Snapshot, error) {
+	database = s.resolveDatabase(database)
+
+	snapshots := make([]Snapshot, 0)
+
+	sel := s.sqlClient.Q().From("snapshots").Where(sqlc.Eq{"database": database, "table": name}).OrderBy(sqlc.Col("committed_at").Asc())
+	if err := sel.Select(ctx, &snapshots); err != nil {
+		return nil, fmt.Errorf("could not list snapshots from db: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// ListSnapshotRefs returns the stored branches and tags of a table.
+func (s *ServiceMetadata) ListSnapshotRefs(ctx context.Context, database string, name string) ([]SnapshotRef, error) {
+	database = s.resolveDatabase(database)
+
+	refs := make([]SnapshotRef, 0)
+
+	sel := s.sqlClient.Q().From("snapshot_refs").Where(sqlc.Eq{"database": database, "table": name}).OrderBy(sqlc.Col("name").Asc())
+	if err := sel.Select(ctx, &refs); err != nil {
+		return nil, fmt.Errorf("could not list snapshot refs from db: %w", err)
+	}
+
+	return refs, nil
+}
+
 func (s *ServiceMetadata) resolveDatabase(database string) string {
 	if database != "" {
 		return database
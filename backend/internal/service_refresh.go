@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	iceberg "github.com/apache/iceberg-go"
 	"github.com/gosoline-project/sqlc"
 	"github.com/justtrackio/gosoline/pkg/cfg"
 	"github.com/justtrackio/gosoline/pkg/db"
@@ -17,7 +18,9 @@ type icebergRefresher interface {
 	ListTables(ctx context.Context, database string) ([]CatalogTable, error)
 	DescribeTable(ctx context.Context, database string, logicalName string) (*TableDescription, error)
 	ListPartitions(ctx context.Context, database string, logicalName string) ([]IcebergPartition, error)
+	ListPartitionsMatching(ctx context.Context, database string, logicalName string, rowFilter iceberg.BooleanExpression) ([]IcebergPartition, error)
 	ListSnapshots(ctx context.Context, database string, logicalName string) ([]IcebergSnapshot, error)
+	ListSnapshotRefs(ctx context.Context, database string, logicalName string) ([]SnapshotRef, error)
 }
 
 func NewServiceRefresh(ctx context.Context, config cfg.Config, logger log.Logger) (*ServiceRefresh, error) {
@@ -130,34 +133,93 @@ func (s *ServiceRefresh) RefreshPartitions(cttx sqlc.Tx, database string, table
 		return nil, fmt.Errorf("could not list partitions: %w", err)
 	}
 
-	partitions := make([]Partition, len(result))
-	for i, p := range result {
-		partitions[i] = Partition{
-			Database:                 database,
-			Table:                    table,
-			Partition:                db.NewJSON(p.Partition, db.NonNullable{}),
-			SpecId:                   int(p.SpecID),
-			RecordCount:              p.RecordCount,
-			FileCount:                p.FileCount,
-			TotalDataFileSizeInBytes: p.DataFileSizeBytes,
-			LastUpdatedAt:            p.LastUpdatedAt,
-			LastUpdatedSnapshotId:    p.LastSnapshotID,
-			NeedsOptimize:            p.NeedsOptimize,
+	partitions := newPartitionsFromIceberg(database, table, result)
+	if err = s.savePartitions(cttx, partitions); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info(cttx, "refreshed %d partitions for table %s.%s", len(partitions), database, table)
+
+	return partitions, nil
+}
+
+// RefreshPartitionsInScope replaces the stored partitions within scope and keeps all others. Only the files
+// matching the row filter of the scope are planned, so a task which changed a few partitions does not have
+// to read the manifests of the whole table. All stored partitions of the table are returned.
+func (s *ServiceRefresh) RefreshPartitionsInScope(cttx sqlc.Tx, database string, table string, scope partitionRefreshScope) ([]Partition, error) {
+	var err error
+	var stored []Partition
+	var result []IcebergPartition
+
+	sel := cttx.Q().From("partitions").Where(sqlc.Eq{"database": database, "table": table})
+	if err = sel.Select(cttx, &stored); err != nil {
+		return nil, fmt.Errorf("could not select existing partitions: %w", err)
+	}
+
+	if result, err = s.iceberg.ListPartitionsMatching(cttx, database, table, scope.rowFilter()); err != nil {
+		return nil, fmt.Errorf("could not list partitions: %w", err)
+	}
+
+	partitions := make([]Partition, 0, len(stored)+len(result))
+	for _, partition := range stored {
+		if !scope.contains(partition.Partition.Get()) {
+			partitions = append(partitions, partition)
 		}
 	}
 
-	chunks := funk.Chunk(partitions, 100)
-	for _, chunk := range chunks {
+	kept := len(partitions)
+	for _, partition := range newPartitionsFromIceberg(database, table, result) {
+		if scope.contains(partition.Partition.Get()) {
+			partitions = append(partitions, partition)
+		}
+	}
+
+	if _, err = cttx.Q().Delete("partitions").Where(sqlc.Eq{"database": database, "table": table}).Exec(cttx); err != nil {
+		return nil, fmt.Errorf("could not delete existing partitions: %w", err)
+	}
+
+	if err = s.savePartitions(cttx, partitions); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info(cttx, "refreshed %d partitions in scope for table %s.%s", len(partitions)-kept, database, table)
+
+	return partitions, nil
+}
+
+func (s *ServiceRefresh) savePartitions(cttx sqlc.Tx, partitions []Partition) error {
+	for _, chunk := range funk.Chunk(partitions, 100) {
 		insert := cttx.Q().Into("partitions").Records(chunk)
 
-		if _, err = insert.Exec(cttx); err != nil {
-			return nil, fmt.Errorf("could not save partitions: %w", err)
+		if _, err := insert.Exec(cttx); err != nil {
+			return fmt.Errorf("could not save partitions: %w", err)
 		}
 	}
 
-	s.logger.Info(cttx, "refreshed %d partitions for table %s.%s", len(partitions), database, table)
+	return nil
+}
 
-	return partitions, nil
+func newPartitionsFromIceberg(database string, table string, result []IcebergPartition) []Partition {
+	partitions := make([]Partition, len(result))
+	for i, p := range result {
+		partitions[i] = Partition{
+			Database:                   database,
+			Table:                      table,
+			Partition:                  db.NewJSON(p.Partition, db.NonNullable{}),
+			SpecId:                     int(p.SpecID),
+			RecordCount:                p.RecordCount,
+			FileCount:                  p.FileCount,
+			TotalDataFileSizeInBytes:   p.DataFileSizeBytes,
+			DeleteFileCount:            p.DeleteFileCount,
+			TotalDeleteFileSizeInBytes: p.DeleteFileSizeBytes,
+			LastUpdatedAt:              p.LastUpdatedAt,
+			LastUpdatedSnapshotId:      p.LastSnapshotID,
+			NeedsOptimize:              p.NeedsOptimize,
+			NeedsCompaction:            p.NeedsCompaction,
+		}
+	}
+
+	return partitions
 }
 
 func (s *ServiceRefresh) RefreshSnapshots(cttx sqlc.Tx, database string, table string) ([]Snapshot, error) {
@@ -193,11 +255,39 @@ func (s *ServiceRefresh) RefreshSnapshots(cttx sqlc.Tx, database string, table s
 		}
 	}
 
+	if err = s.refreshSnapshotRefs(cttx, database, table); err != nil {
+		return nil, err
+	}
+
 	s.logger.Info(cttx, "refreshed %d snapshots for table %s.%s", len(snapshots), database, table)
 
 	return snapshots, nil
 }
 
+// refreshSnapshotRefs replaces the stored branches and tags of a table.
+func (s *ServiceRefresh) refreshSnapshotRefs(cttx sqlc.Tx, database string, table string) error {
+	var err error
+	var refs []SnapshotRef
+
+	if _, err = cttx.Q().Delete("snapshot_refs").Where(sqlc.Eq{"database": database, "table": table}).Exec(cttx); err != nil {
+		return fmt.Errorf("could not delete existing snapshot refs: %w", err)
+	}
+
+	if refs, err = s.iceberg.ListSnapshotRefs(cttx, database, table); err != nil {
+		return fmt.Errorf("could not list snapshot refs: %w", err)
+	}
+
+	if len(refs) == 0 {
+		return nil
+	}
+
+	if _, err = cttx.Q().Into("snapshot_refs").Records(refs).Exec(cttx); err != nil {
+		return fmt.Errorf("could not save snapshot refs: %w", err)
+	}
+
+	return nil
+}
+
 func (s *ServiceRefresh) RefreshFull(cttx sqlc.Tx) ([]CatalogTable, error) {
 	var err error
 	var databases []CatalogDatabase
@@ -296,10 +386,11 @@ func (s *ServiceRefresh) listStoredTables(cttx sqlc.Tx, database string) ([]Cata
 
 func (s *ServiceRefresh) deleteStaleTable(cttx sqlc.Tx, database string, name string) error {
 	cleanupSteps := map[string]string{
-		"partitions": "table",
-		"snapshots":  "table",
-		"tasks":      "table",
-		"tables":     "name",
+		"partitions":    "table",
+		"snapshots":     "table",
+		"snapshot_refs": "table",
+		"tasks":         "table",
+		"tables":        "name",
 	}
 
 	for table, column := range cleanupSteps {
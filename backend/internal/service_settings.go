@@ -14,14 +14,30 @@ import (
 
 const (
 	settingKeyTaskConcurrency         = "task_concurrency"
+	settingKeyTaskConcurrencyEngine   = "task_concurrency_engine_"
+	settingKeyTaskConcurrencyKind     = "task_concurrency_kind_"
+	settingKeyTaskConcurrencyPerTable = "task_concurrency_one_per_table"
 	settingKeySmallFileThresholdBytes = "small_file_threshold_bytes"
 	defaultSmallFileThresholdBytes    = int64(256 * 1024 * 1024)
 	settingKeySmallFileMinCount       = "small_file_min_count"
 	defaultSmallFileMinCount          = 2
 	settingKeySmallFileMinSharePct    = "small_file_min_share_percent"
 	defaultSmallFileMinSharePct       = 25
+	settingKeyDeleteFileMinCount      = "delete_file_min_count"
+	defaultDeleteFileMinCount         = 10
+	settingKeyDeleteFileMinRatioPct   = "delete_file_min_ratio_percent"
+	defaultDeleteFileMinRatioPct      = 10
 )
 
+// TaskConcurrencyLimits restricts how many tasks may run at the same time per engine and per task kind,
+// on top of the global task concurrency. A limit of 0 means unlimited. OnePerTable, which is enabled
+// unless it was switched off, allows at most one running task per table.
+type TaskConcurrencyLimits struct {
+	Engines     map[TaskEngine]int `json:"engines"`
+	Kinds       map[TaskKind]int   `json:"kinds"`
+	OnePerTable bool               `json:"one_per_table"`
+}
+
 type ServiceSettings struct {
 	logger    log.Logger
 	sqlClient sqlc.Client
@@ -76,6 +92,108 @@ func (s *ServiceSettings) SetSetting(ctx context.Context, key string, value stri
 	return nil
 }
 
+// GetSettings retrieves the values of all given keys which have been set.
+func (s *ServiceSettings) GetSettings(ctx context.Context, keys ...string) (map[string]string, error) {
+	var settings []Setting
+
+	keysAny := make([]any, len(keys))
+	for i, key := range keys {
+		keysAny[i] = key
+	}
+
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	query := s.sqlClient.Q().From("settings").Where(sqlc.Col("key").In(keysAny...))
+	if err := query.Select(ctx, &settings); err != nil {
+		return nil, fmt.Errorf("could not get settings: %w", err)
+	}
+
+	for _, setting := range settings {
+		values[setting.Key] = setting.Value
+	}
+
+	return values, nil
+}
+
+// GetTaskConcurrencyLimits loads the per-engine, per-kind and per-table concurrency limits.
+func (s *ServiceSettings) GetTaskConcurrencyLimits(ctx context.Context) (*TaskConcurrencyLimits, error) {
+	engines := registeredTaskEngines()
+
+	keys := []string{settingKeyTaskConcurrencyPerTable}
+	for _, engine := range engines {
+		keys = append(keys, settingKeyTaskConcurrencyEngine+string(engine))
+	}
+	for _, kind := range taskKinds {
+		keys = append(keys, settingKeyTaskConcurrencyKind+string(kind))
+	}
+
+	values, err := s.GetSettings(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := &TaskConcurrencyLimits{
+		Engines:     make(map[TaskEngine]int, len(engines)),
+		Kinds:       make(map[TaskKind]int, len(taskKinds)),
+		OnePerTable: true,
+	}
+
+	for _, engine := range engines {
+		if limits.Engines[engine], err = parseIntSettingValue(values, settingKeyTaskConcurrencyEngine+string(engine)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, kind := range taskKinds {
+		if limits.Kinds[kind], err = parseIntSettingValue(values, settingKeyTaskConcurrencyKind+string(kind)); err != nil {
+			return nil, err
+		}
+	}
+
+	if value, ok := values[settingKeyTaskConcurrencyPerTable]; ok {
+		if limits.OnePerTable, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("could not parse setting %s as bool: %w", settingKeyTaskConcurrencyPerTable, err)
+		}
+	}
+
+	return limits, nil
+}
+
+// SetTaskConcurrencyLimits stores the limits for all known engines and task kinds. Engines and kinds
+// missing from the given limits are reset to unlimited.
+func (s *ServiceSettings) SetTaskConcurrencyLimits(ctx context.Context, limits *TaskConcurrencyLimits) error {
+	for _, engine := range registeredTaskEngines() {
+		if err := s.SetSetting(ctx, settingKeyTaskConcurrencyEngine+string(engine), strconv.Itoa(limits.Engines[engine])); err != nil {
+			return err
+		}
+	}
+
+	for _, kind := range taskKinds {
+		if err := s.SetSetting(ctx, settingKeyTaskConcurrencyKind+string(kind), strconv.Itoa(limits.Kinds[kind])); err != nil {
+			return err
+		}
+	}
+
+	return s.SetSetting(ctx, settingKeyTaskConcurrencyPerTable, strconv.FormatBool(limits.OnePerTable))
+}
+
+func parseIntSettingValue(values map[string]string, key string) (int, error) {
+	value, ok := values[key]
+	if !ok {
+		return 0, nil
+	}
+
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse setting %s as int: %w", key, err)
+	}
+
+	return intValue, nil
+}
+
 // GetIntSetting retrieves an integer setting with a default fallback.
 func (s *ServiceSettings) GetIntSetting(ctx context.Context, key string, defaultValue int) (int, error) {
 	value, err := s.GetSetting(ctx, key)
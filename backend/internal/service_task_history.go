@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gosoline-project/sqlc"
+	"github.com/justtrackio/gosoline/pkg/cfg"
+	"github.com/justtrackio/gosoline/pkg/log"
+)
+
+// TaskPruneFilter selects the tasks to prune. Empty fields match all tasks, a non-zero FinishedBefore
+// only matches tasks which finished before that time and thus never queued or running tasks.
+type TaskPruneFilter struct {
+	Database       string
+	Statuses       []string
+	Kinds          []string
+	FinishedBefore time.Time
+}
+
+type TaskPruneResult struct {
+	Deleted  int64    `json:"deleted"`
+	Archived int64    `json:"archived"`
+	Archives []string `json:"archives"`
+}
+
+type ServiceTaskHistory struct {
+	logger    log.Logger
+	sqlClient sqlc.Client
+	archiver  TaskArchiver
+	settings  *TaskHistorySettings
+}
+
+func NewServiceTaskHistory(ctx context.Context, config cfg.Config, logger log.Logger) (*ServiceTaskHistory, error) {
+	var err error
+	var sqlClient sqlc.Client
+	var archiver TaskArchiver
+	var settings *TaskHistorySettings
+
+	if sqlClient, err = sqlc.ProvideClient(ctx, config, logger, "default"); err != nil {
+		return nil, fmt.Errorf("could not create sql client: %w", err)
+	}
+
+	if settings, err = ReadTaskHistorySettings(config); err != nil {
+		return nil, fmt.Errorf("could not read task history settings: %w", err)
+	}
+
+	if archiver, err = NewTaskArchiver(ctx, config, logger, settings.Archive); err != nil {
+		return nil, fmt.Errorf("could not create task archiver: %w", err)
+	}
+
+	return &ServiceTaskHistory{
+		logger:    logger.WithChannel("task_history"),
+		sqlClient: sqlClient,
+		archiver:  archiver,
+		settings:  settings,
+	}, nil
+}
+
+// EnforceRetention prunes all finished tasks which are older than the retention configured for their status.
+func (s *ServiceTaskHistory) EnforceRetention(ctx context.Context, now time.Time) (*TaskPruneResult, error) {
+	total := &TaskPruneResult{Archives: []string{}}
+
+	for status, retention := range s.settings.Retention.byStatus() {
+		if retention == 0 {
+			continue
+		}
+
+		result, err := s.PruneTasks(ctx, TaskPruneFilter{
+			Statuses:       []string{status},
+			FinishedBefore: now.Add(-retention),
+		})
+		if err != nil {
+			return total, fmt.Errorf("could not prune %s tasks: %w", status, err)
+		}
+
+		total.Deleted += result.Deleted
+		total.Archived += result.Archived
+		total.Archives = append(total.Archives, result.Archives...)
+	}
+
+	return total, nil
+}
+
+// PruneTasks deletes the tasks matching the filter in batches. If archival is enabled, every batch is
+// archived before it is deleted, so a failing archive never loses tasks.
+func (s *ServiceTaskHistory) PruneTasks(ctx context.Context, filter TaskPruneFilter) (*TaskPruneResult, error) {
+	result := &TaskPruneResult{Archives: []string{}}
+
+	for {
+		tasks, err := s.selectPruneBatch(ctx, filter)
+		if err != nil {
+			return result, err
+		}
+
+		if len(tasks) == 0 {
+			return result, nil
+		}
+
+		if err = s.pruneBatch(ctx, tasks, result); err != nil {
+			return result, err
+		}
+
+		if len(tasks) < s.settings.BatchSize {
+			return result, nil
+		}
+	}
+}
+
+func (s *ServiceTaskHistory) pruneBatch(ctx context.Context, tasks []Task, result *TaskPruneResult) error {
+	var err error
+	var location string
+	var res sqlc.Result
+	var affected int64
+
+	if s.archiver != nil {
+		if location, err = s.archiver.Archive(ctx, tasks); err != nil {
+			return fmt.Errorf("could not archive tasks %d to %d: %w", tasks[0].Id, tasks[len(tasks)-1].Id, err)
+		}
+
+		result.Archived += int64(len(tasks))
+		result.Archives = append(result.Archives, location)
+	}
+
+	ids := make([]int64, len(tasks))
+	for i := range tasks {
+		ids[i] = tasks[i].Id
+	}
+
+	del := s.sqlClient.Q().Delete("tasks").Where(sqlc.Col("id").In(int64sToAny(ids)...))
+	if res, err = del.Exec(ctx); err != nil {
+		return fmt.Errorf("could not delete pruned tasks: %w", err)
+	}
+
+	if affected, err = res.RowsAffected(); err != nil {
+		return fmt.Errorf("could not get rows affected: %w", err)
+	}
+
+	result.Deleted += affected
+
+	return nil
+}
+
+func (s *ServiceTaskHistory) selectPruneBatch(ctx context.Context, filter TaskPruneFilter) ([]Task, error) {
+	var tasks []Task
+
+	sel := s.sqlClient.Q().From("tasks").OrderBy(sqlc.Col("id").Asc()).Limit(s.settings.BatchSize)
+
+	if filter.Database != "" {
+		sel = sel.Where(sqlc.Eq{"database": filter.Database})
+	}
+
+	if len(filter.Statuses) > 0 {
+		sel = sel.Where(sqlc.Col("status").In(stringsToAny(filter.Statuses)...))
+	}
+
+	if len(filter.Kinds) > 0 {
+		sel = sel.Where(sqlc.Col("kind").In(stringsToAny(filter.Kinds)...))
+	}
+
+	if !filter.FinishedBefore.IsZero() {
+		sel = sel.Where(sqlc.Col("finished_at").Lte(filter.FinishedBefore))
+	}
+
+	if err := sel.Select(ctx, &tasks); err != nil {
+		return nil, fmt.Errorf("could not select tasks to prune: %w", err)
+	}
+
+	return tasks, nil
+}
+
+func stringsToAny(values []string) []any {
+	result := make([]any, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+
+	return result
+}
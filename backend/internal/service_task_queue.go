@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,12 +13,18 @@ import (
 	"github.com/justtrackio/gosoline/pkg/cfg"
 	"github.com/justtrackio/gosoline/pkg/db"
 	"github.com/justtrackio/gosoline/pkg/log"
+	"github.com/spf13/cast"
 )
 
 type ServiceTaskQueue struct {
 	logger                 log.Logger
 	sqlClient              sqlc.Client
 	serviceSettings        *ServiceSettings
+	leaseSettings          *TaskLeaseSettings
+	retryPolicies          map[TaskKind]*TaskRetryPolicy
+	events                 *TaskEventBus
+	refresher              TableRefresher
+	refreshes              *TaskRefreshQueue
 	defaultTaskConcurrency int
 }
 
@@ -27,6 +34,11 @@ func NewServiceTaskQueue(ctx context.Context, config cfg.Config, logger log.Logg
 	var err error
 	var sqlClient sqlc.Client
 	var serviceSettings *ServiceSettings
+	var leaseSettings *TaskLeaseSettings
+	var retryPolicies map[TaskKind]*TaskRetryPolicy
+	var events *TaskEventBus
+	var refresher *ServiceRefresh
+	var refreshes *TaskRefreshQueue
 	var defaultTaskConcurrency int
 
 	if sqlClient, err = sqlc.ProvideClient(ctx, config, logger, "default"); err != nil {
@@ -37,6 +49,26 @@ func NewServiceTaskQueue(ctx context.Context, config cfg.Config, logger log.Logg
 		return nil, fmt.Errorf("could not create settings service: %w", err)
 	}
 
+	if leaseSettings, err = ReadTaskLeaseSettings(config); err != nil {
+		return nil, fmt.Errorf("could not read task lease settings: %w", err)
+	}
+
+	if retryPolicies, err = ReadTaskRetryPolicies(config); err != nil {
+		return nil, fmt.Errorf("could not read task retry policies: %w", err)
+	}
+
+	if events, err = ProvideTaskEventBus(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create task event bus: %w", err)
+	}
+
+	if refresher, err = NewServiceRefresh(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create refresh service: %w", err)
+	}
+
+	if refreshes, err = ProvideTaskRefreshQueue(ctx, logger); err != nil {
+		return nil, fmt.Errorf("could not create task refresh queue: %w", err)
+	}
+
 	if defaultTaskConcurrency, err = config.GetInt("tasks.worker_count"); err != nil || defaultTaskConcurrency < 1 {
 		defaultTaskConcurrency = 1
 	}
@@ -45,27 +77,162 @@ func NewServiceTaskQueue(ctx context.Context, config cfg.Config, logger log.Logg
 		logger:                 logger.WithChannel("task_queue"),
 		sqlClient:              sqlClient,
 		serviceSettings:        serviceSettings,
+		leaseSettings:          leaseSettings,
+		retryPolicies:          retryPolicies,
+		events:                 events,
+		refresher:              refresher,
+		refreshes:              refreshes,
 		defaultTaskConcurrency: defaultTaskConcurrency,
 	}, nil
 }
 
-func (s *ServiceTaskQueue) EnqueueTask(ctx context.Context, database string, table string, kind string, engine string, input map[string]any) (int64, error) {
-	var err error
-	var res sqlc.Result
-	var id int64
+// EnqueueTask inserts a new queued task unless an equivalent task (same database, table and kind with
+// an overlapping input range) is already queued or running. In that case the existing task is returned
+// and marked as deduplicated. A task with dependencies is only deduplicated against a queued task, which
+// then additionally waits for these dependencies. A task whose dependencies already failed is inserted as
+// skipped.
+func (s *ServiceTaskQueue) EnqueueTask(ctx context.Context, database string, table string, kind string, engine string, input map[string]any, options TaskEnqueueOptions) (EnqueuedTask, error) {
+	var enqueued EnqueuedTask
+
+	entry := newQueuedTask(database, table, kind, engine, input, options.Priority)
+
+	err := s.sqlClient.WithTx(ctx, func(cttx sqlc.Tx) error {
+		var err error
+		var res sqlc.Result
+		var existing []Task
+
+		sel := cttx.Q().From("tasks").
+			Where(sqlc.Eq{"database": database, "table": table, "kind": kind}).
+			Where(sqlc.Col("status").In(taskStatusQueued, taskStatusRunning)).
+			OrderBy(sqlc.Col("started_at").Asc())
+		if err = sel.Select(cttx, &existing); err != nil {
+			return fmt.Errorf("could not list active tasks: %w", err)
+		}
+
+		for i := range existing {
+			if !taskInputRangesOverlap(existing[i].Input.Get(), entry.Input.Get()) {
+				continue
+			}
+
+			if len(options.DependsOn) > 0 {
+				var merged bool
+				if merged, err = s.mergeTaskDependenciesInTx(cttx, &existing[i], options.DependsOn); err != nil {
+					return err
+				}
+
+				if !merged {
+					continue
+				}
+			}
+
+			enqueued = EnqueuedTask{TaskId: existing[i].Id, Deduplicated: true}
+
+			return nil
+		}
+
+		if len(options.DependsOn) > 0 {
+			if err = s.markTaskSkippedIfDependencyFailedInTx(cttx, entry, options.DependsOn); err != nil {
+				return err
+			}
+		}
+
+		ins := cttx.Q().Into("tasks").Records(entry)
+		if res, err = ins.Exec(cttx); err != nil {
+			return fmt.Errorf("could not enqueue task: %w", err)
+		}
+
+		if enqueued.TaskId, err = res.LastInsertId(); err != nil {
+			return fmt.Errorf("could not get last insert id: %w", err)
+		}
+
+		return s.insertTaskDependenciesInTx(cttx, enqueued.TaskId, options.DependsOn)
+	}, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return EnqueuedTask{}, err
+	}
+
+	if !enqueued.Deduplicated {
+		entry.Id = enqueued.TaskId
+		s.events.Publish(ctx, newTaskEvent(TaskEventEnqueued, entry))
+	}
+
+	return enqueued, nil
+}
+
+// RecordTask stores an operation which was executed outside of the task queue, e.g. a rollback triggered
+// through the api, as a finished task so it shows up in the task history. A failed operation is recorded
+// with its error, but can not be retried through the queue.
+func (s *ServiceTaskQueue) RecordTask(ctx context.Context, database string, table string, kind string, engine string, input map[string]any, result map[string]any, startedAt time.Time, taskErr error) (int64, error) {
+	if result == nil {
+		result = map[string]any{}
+	}
 
-	entry := newQueuedTask(database, table, kind, engine, input)
+	now := time.Now()
+	entry := newQueuedTask(database, table, kind, engine, input, 0)
+	entry.StartedAt = startedAt
+	entry.NotBefore = startedAt
+	entry.PickedUpAt = &startedAt
+	entry.FinishedAt = &now
+	entry.Status = taskStatusSuccess
+	entry.Result = db.NewJSON(result, db.NonNullable{})
+
+	if taskErr != nil {
+		message := taskErr.Error()
+		entry.Status = taskStatusError
+		entry.ErrorMessage = &message
+	}
 
-	ins := s.sqlClient.Q().Into("tasks").Records(entry)
-	if res, err = ins.Exec(ctx); err != nil {
-		return 0, fmt.Errorf("could not enqueue task: %w", err)
+	res, err := s.sqlClient.Q().Into("tasks").Records(entry).Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not record %s task: %w", kind, err)
 	}
 
-	if id, err = res.LastInsertId(); err != nil {
+	if entry.Id, err = res.LastInsertId(); err != nil {
 		return 0, fmt.Errorf("could not get last insert id: %w", err)
 	}
 
-	return id, nil
+	s.events.Publish(ctx, newTaskEvent(TaskEventCompleted, entry))
+
+	return entry.Id, nil
+}
+
+// taskInputRangesOverlap reports whether two task inputs cover overlapping from/to ranges. Inputs scoped
+// to a partition only overlap with inputs of the same partition. An input without a range or partition
+// applies to the whole table and thus overlaps with everything.
+func taskInputRangesOverlap(a map[string]any, b map[string]any) bool {
+	aScope, aScoped := optimizeScopeFromTaskInput(a)
+	bScope, bScoped := optimizeScopeFromTaskInput(b)
+
+	if aScoped && bScoped && len(aScope) > 0 && len(bScope) > 0 {
+		return aScope.String() == bScope.String()
+	}
+
+	aFrom, aTo, aOk := taskInputRange(a)
+	bFrom, bTo, bOk := taskInputRange(b)
+
+	if !aOk || !bOk {
+		return true
+	}
+
+	return !aFrom.After(bTo) && !bFrom.After(aTo)
+}
+
+func taskInputRange(input map[string]any) (from time.Time, to time.Time, ok bool) {
+	var err error
+
+	if input["from"] == nil || input["to"] == nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	if from, err = cast.ToTimeE(input["from"]); err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	if to, err = cast.ToTimeE(input["to"]); err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	return from, to, true
 }
 
 func (s *ServiceTaskQueue) GetTask(ctx context.Context, id int64) (*Task, error) {
@@ -87,12 +254,12 @@ func (s *ServiceTaskQueue) RetryTask(ctx context.Context, id int64) (int64, erro
 	var retryTaskID int64
 
 	err := s.sqlClient.WithTx(ctx, func(cttx sqlc.Tx) error {
-		task, err := s.getTaskForRetry(cttx, id)
+		task, err := s.getTaskInTx(cttx, id)
 		if err != nil {
 			return err
 		}
 
-		retryTaskID, err = s.retryTaskInTx(cttx, task)
+		retryTaskID, err = s.retryTaskInTx(cttx, task, time.Now())
 		if err != nil {
 			return err
 		}
@@ -103,11 +270,13 @@ func (s *ServiceTaskQueue) RetryTask(ctx context.Context, id int64) (int64, erro
 		return 0, err
 	}
 
+	s.publishTaskEvents(ctx, TaskEventEnqueued, retryTaskID)
+
 	return retryTaskID, nil
 }
 
 func (s *ServiceTaskQueue) RetryAllTasks(ctx context.Context, database string) (int64, error) {
-	var retriedCount int64
+	var retryTaskIDs []int64
 
 	err := s.sqlClient.WithTx(ctx, func(cttx sqlc.Tx) error {
 		var tasks []Task
@@ -125,16 +294,18 @@ func (s *ServiceTaskQueue) RetryAllTasks(ctx context.Context, database string) (
 			return fmt.Errorf("could not list retryable tasks: %w", err)
 		}
 
+		retryTaskIDs = make([]int64, 0, len(tasks))
 		for i := range tasks {
-			if _, err := s.retryTaskInTx(cttx, &tasks[i]); err != nil {
-				if errors.Is(err, errTaskAlreadyRetried) {
+			retryTaskID, err := s.retryTaskInTx(cttx, &tasks[i], time.Now())
+			if err != nil {
+				if errors.Is(err, errTaskAlreadyRetried) || errors.Is(err, errTaskNotRetryable) {
 					continue
 				}
 
 				return err
 			}
 
-			retriedCount++
+			retryTaskIDs = append(retryTaskIDs, retryTaskID)
 		}
 
 		return nil
@@ -143,12 +314,23 @@ func (s *ServiceTaskQueue) RetryAllTasks(ctx context.Context, database string) (
 		return 0, err
 	}
 
-	return retriedCount, nil
+	s.publishTaskEvents(ctx, TaskEventEnqueued, retryTaskIDs...)
+
+	return int64(len(retryTaskIDs)), nil
 }
 
-var errTaskAlreadyRetried = errors.New("task already retried")
+var (
+	errTaskAlreadyRetried = errors.New("task already retried")
+	errTaskNotRetryable   = errors.New("task kind can not be retried")
+)
+
+// isTaskRetryable reports whether a task failed and can still be enqueued again. Recorded tasks like
+// rollbacks never ran through the queue and are not retryable.
+func isTaskRetryable(task *Task) bool {
+	return task.Status == taskStatusError && !task.Retried && slices.Contains(taskKinds, TaskKind(task.Kind))
+}
 
-func (s *ServiceTaskQueue) getTaskForRetry(ctx sqlc.Tx, id int64) (*Task, error) {
+func (s *ServiceTaskQueue) getTaskInTx(ctx sqlc.Tx, id int64) (*Task, error) {
 	var task Task
 
 	stmt := ctx.Q().From("tasks").Where(sqlc.Eq{"id": id}).Limit(1)
@@ -163,11 +345,17 @@ func (s *ServiceTaskQueue) getTaskForRetry(ctx sqlc.Tx, id int64) (*Task, error)
 	return &task, nil
 }
 
-func (s *ServiceTaskQueue) retryTaskInTx(ctx sqlc.Tx, task *Task) (int64, error) {
+// retryTaskInTx marks a failed task as retried and enqueues its next attempt, which will not be
+// claimed before notBefore.
+func (s *ServiceTaskQueue) retryTaskInTx(ctx sqlc.Tx, task *Task, notBefore time.Time) (int64, error) {
 	if task.Status != taskStatusError {
 		return 0, fmt.Errorf("task %d cannot be retried because it is in status %s", task.Id, task.Status)
 	}
 
+	if !slices.Contains(taskKinds, TaskKind(task.Kind)) {
+		return 0, fmt.Errorf("task %d of kind %s cannot be retried: %w", task.Id, task.Kind, errTaskNotRetryable)
+	}
+
 	if task.Retried {
 		return 0, fmt.Errorf("task %d has already been retried: %w", task.Id, errTaskAlreadyRetried)
 	}
@@ -187,7 +375,12 @@ func (s *ServiceTaskQueue) retryTaskInTx(ctx sqlc.Tx, task *Task) (int64, error)
 		return 0, fmt.Errorf("task %d has already been retried: %w", task.Id, errTaskAlreadyRetried)
 	}
 
-	insert := ctx.Q().Into("tasks").Records(newQueuedTask(task.Database, task.Table, task.Kind, task.Engine, task.Input.Get()))
+	retryTask := newQueuedTask(task.Database, task.Table, task.Kind, task.Engine, task.Input.Get(), task.Priority)
+	retryTask.ParentTaskId = &task.Id
+	retryTask.Attempt = task.Attempt + 1
+	retryTask.NotBefore = notBefore
+
+	insert := ctx.Q().Into("tasks").Records(retryTask)
 	res, err = insert.Exec(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("could not enqueue retry for task %d: %w", task.Id, err)
@@ -198,29 +391,196 @@ func (s *ServiceTaskQueue) retryTaskInTx(ctx sqlc.Tx, task *Task) (int64, error)
 		return 0, fmt.Errorf("could not get retry task id for task %d: %w", task.Id, err)
 	}
 
+	if err = s.redirectTaskDependenciesInTx(ctx, task.Id, retryTaskID); err != nil {
+		return 0, err
+	}
+
 	return retryTaskID, nil
 }
 
-func newQueuedTask(database string, table string, kind string, engine string, input map[string]any) *Task {
+// SetTaskPriority changes the priority of a task which is still waiting in the queue.
+func (s *ServiceTaskQueue) SetTaskPriority(ctx context.Context, id int64, priority int) error {
+	var err error
+	var res sqlc.Result
+	var affected int64
+	var task *Task
+
+	update := s.sqlClient.Q().Update("tasks").Set("priority", priority).Where(sqlc.Eq{"id": id, "status": taskStatusQueued})
+	if res, err = update.Exec(ctx); err != nil {
+		return fmt.Errorf("could not update priority of task %d: %w", id, err)
+	}
+
+	if affected, err = res.RowsAffected(); err != nil {
+		return fmt.Errorf("could not get rows affected when updating priority of task %d: %w", id, err)
+	}
+
+	if affected > 0 {
+		return nil
+	}
+
+	if task, err = s.GetTask(ctx, id); err != nil {
+		return err
+	}
+
+	if task.Status != taskStatusQueued {
+		return fmt.Errorf("task %d cannot change its priority because it is in status %s", id, task.Status)
+	}
+
+	// MySQL reports zero affected rows if the priority did not change
+	return nil
+}
+
+var errTaskNotCancellable = errors.New("task cannot be cancelled")
+
+// CancelTask marks a queued or running task as cancelled. The returned task reflects the row
+// as it was before the cancellation, so callers can tell whether engine work has to be stopped.
+func (s *ServiceTaskQueue) CancelTask(ctx context.Context, id int64) (*Task, error) {
+	var cancelledTask *Task
+	var skippedTaskIDs []int64
+
+	err := s.sqlClient.WithTx(ctx, func(cttx sqlc.Tx) error {
+		task, err := s.getTaskInTx(cttx, id)
+		if err != nil {
+			return err
+		}
+
+		if skippedTaskIDs, err = s.cancelTaskInTx(cttx, task); err != nil {
+			return err
+		}
+
+		cancelledTask = task
+
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishTaskEvents(ctx, TaskEventCompleted, append([]int64{id}, skippedTaskIDs...)...)
+
+	return cancelledTask, nil
+}
+
+// CancelAllTasks cancels every queued and running task, optionally restricted to a database.
+// Like CancelTask, the returned tasks reflect their state before the cancellation.
+func (s *ServiceTaskQueue) CancelAllTasks(ctx context.Context, database string) ([]Task, error) {
+	var cancelledTasks []Task
+	var completedTaskIDs []int64
+
+	err := s.sqlClient.WithTx(ctx, func(cttx sqlc.Tx) error {
+		var tasks []Task
+
+		query := cttx.Q().
+			From("tasks").
+			Where(sqlc.Col("status").In(taskStatusQueued, taskStatusRunning)).
+			OrderBy(sqlc.Col("started_at").Asc())
+
+		if database != "" {
+			query = query.Where(sqlc.Eq{"database": database})
+		}
+
+		if err := query.Select(cttx, &tasks); err != nil {
+			return fmt.Errorf("could not list cancellable tasks: %w", err)
+		}
+
+		cancelledTasks = make([]Task, 0, len(tasks))
+		completedTaskIDs = make([]int64, 0, len(tasks))
+		for i := range tasks {
+			skippedTaskIDs, err := s.cancelTaskInTx(cttx, &tasks[i])
+			if err != nil {
+				if errors.Is(err, errTaskNotCancellable) {
+					continue
+				}
+
+				return err
+			}
+
+			cancelledTasks = append(cancelledTasks, tasks[i])
+			completedTaskIDs = append(completedTaskIDs, tasks[i].Id)
+			completedTaskIDs = append(completedTaskIDs, skippedTaskIDs...)
+		}
+
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishTaskEvents(ctx, TaskEventCompleted, completedTaskIDs...)
+
+	return cancelledTasks, nil
+}
+
+// cancelTaskInTx cancels the task and skips all tasks depending on it. It returns the ids of the skipped tasks.
+func (s *ServiceTaskQueue) cancelTaskInTx(ctx sqlc.Tx, task *Task) ([]int64, error) {
+	if task.Status != taskStatusQueued && task.Status != taskStatusRunning {
+		return nil, fmt.Errorf("task %d is in status %s: %w", task.Id, task.Status, errTaskNotCancellable)
+	}
+
+	now := time.Now()
+	message := fmt.Sprintf("task was cancelled while %s", task.Status)
+	result := mergeTaskResult(task.Result.Get(), map[string]any{
+		"cancelled_at":          now,
+		"cancelled_from_status": task.Status,
+	})
+
+	update := ctx.Q().Update("tasks").
+		Set("status", taskStatusCancelled).
+		Set("finished_at", &now).
+		Set("error_message", &message).
+		Set("result", db.NewJSON(result, db.NonNullable{})).
+		Where(sqlc.Eq{"id": task.Id, "status": task.Status})
+
+	res, err := update.Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not cancel task %d: %w", task.Id, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("could not get rows affected when cancelling task %d: %w", task.Id, err)
+	}
+
+	if affected == 0 {
+		return nil, fmt.Errorf("task %d changed its status concurrently: %w", task.Id, errTaskNotCancellable)
+	}
+
+	cancelledTask := *task
+	cancelledTask.Status = taskStatusCancelled
+	cancelledTask.ErrorMessage = &message
+
+	return s.skipDependentTasksInTx(ctx, &cancelledTask)
+}
+
+func newQueuedTask(database string, table string, kind string, engine string, input map[string]any, priority int) *Task {
 	if input == nil {
 		input = map[string]any{}
 	}
 
+	now := time.Now()
+
 	return &Task{
 		Database:  database,
 		Table:     table,
 		Kind:      kind,
 		Engine:    engine,
-		StartedAt: time.Now(),
+		StartedAt: now,
+		NotBefore: now,
 		Status:    taskStatusQueued,
+		Priority:  priority,
 		Retried:   false,
+		Attempt:   1,
 		Input:     db.NewJSON(input, db.NonNullable{}),
 		Result:    db.NewJSON(map[string]any{}, db.NonNullable{}),
 	}
 }
 
+// taskClaimCandidateBatchSize is the number of queued tasks inspected at once when looking for a task
+// which is not blocked by any concurrency limit.
+const taskClaimCandidateBatchSize = 100
+
 func (s *ServiceTaskQueue) ClaimTask(ctx context.Context) (*Task, error) {
-	taskConcurrency, err := s.serviceSettings.GetIntSetting(ctx, "task_concurrency", s.defaultTaskConcurrency)
+	taskConcurrency, err := s.serviceSettings.GetIntSetting(ctx, settingKeyTaskConcurrency, s.defaultTaskConcurrency)
 	if err != nil {
 		return nil, fmt.Errorf("could not load task concurrency setting: %w", err)
 	}
@@ -229,13 +589,26 @@ func (s *ServiceTaskQueue) ClaimTask(ctx context.Context) (*Task, error) {
 		taskConcurrency = 1
 	}
 
+	limits, err := s.serviceSettings.GetTaskConcurrencyLimits(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not load task concurrency limits: %w", err)
+	}
+
 	var claimedTask *Task
 
+	// claims read the queue with consistent non-locking reads and only lock the claimed row with the
+	// conditional update, so concurrent claims do not lock the whole queue and deadlock each other. The
+	// workers of a process claim one task after the other, only claims of different processes can race
+	// for the same concurrency slot.
 	for i := 0; i < 3; i++ {
 		err = s.sqlClient.WithTx(ctx, func(cttx sqlc.Tx) error {
-			return s.claimTaskWithConcurrency(cttx, taskConcurrency, &claimedTask)
-		}, &sql.TxOptions{Isolation: sql.LevelSerializable})
+			return s.claimTaskWithConcurrency(cttx, taskConcurrency, limits, &claimedTask)
+		}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
 		if err == nil {
+			if claimedTask != nil {
+				s.events.Publish(ctx, newTaskEvent(TaskEventClaimed, claimedTask))
+			}
+
 			return claimedTask, nil
 		}
 
@@ -247,40 +620,45 @@ func (s *ServiceTaskQueue) ClaimTask(ctx context.Context) (*Task, error) {
 	return nil, fmt.Errorf("could not claim task after retries: %w", err)
 }
 
-func (s *ServiceTaskQueue) claimTaskWithConcurrency(ctx sqlc.Tx, taskConcurrency int, claimedTask **Task) error {
+func (s *ServiceTaskQueue) claimTaskWithConcurrency(ctx sqlc.Tx, taskConcurrency int, limits *TaskConcurrencyLimits, claimedTask **Task) error {
 	var err error
 	var res sqlc.Result
 	var affected int64
+	var runningTasks []Task
 
-	var runningCount struct {
-		Count int `db:"count"`
+	stmt := ctx.Q().From("tasks").Where(sqlc.Eq{"status": taskStatusRunning})
+	if err := stmt.Select(ctx, &runningTasks); err != nil {
+		return fmt.Errorf("could not list running tasks: %w", err)
 	}
 
-	stmt := ctx.Q().From("tasks").Column(sqlc.Col("*").Count().As("count")).Where(sqlc.Eq{"status": taskStatusRunning})
-	if err := stmt.Get(ctx, &runningCount); err != nil {
-		return fmt.Errorf("could not count running tasks: %w", err)
-	}
-
-	if runningCount.Count >= taskConcurrency {
+	if len(runningTasks) >= taskConcurrency {
 		*claimedTask = nil
 
 		return nil
 	}
 
-	var task Task
-	stmt = ctx.Q().From("tasks").Where(sqlc.Eq{"status": taskStatusQueued}).OrderBy(sqlc.Col("started_at").Asc()).Limit(1)
-	if err := stmt.Get(ctx, &task); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			*claimedTask = nil
+	gate := newTaskClaimGate(limits, runningTasks)
 
-			return nil
-		}
+	task, err := s.selectClaimableTask(ctx, gate)
+	if err != nil {
+		return err
+	}
+
+	if task == nil {
+		*claimedTask = nil
 
-		return fmt.Errorf("could not select queued task: %w", err)
+		return nil
 	}
 
 	now := time.Now()
-	upd := ctx.Q().Update("tasks").Set("status", taskStatusRunning).Set("picked_up_at", &now).Where(sqlc.Eq{"id": task.Id, "status": taskStatusQueued})
+	leaseExpiresAt := now.Add(s.leaseSettings.Duration)
+	upd := ctx.Q().Update("tasks").
+		Set("status", taskStatusRunning).
+		Set("picked_up_at", &now).
+		Set("heartbeat_at", &now).
+		Set("lease_expires_at", &leaseExpiresAt).
+		Set("claim", task.Claim+1).
+		Where(sqlc.Eq{"id": task.Id, "status": taskStatusQueued, "claim": task.Claim})
 	if res, err = upd.Exec(ctx); err != nil {
 		return fmt.Errorf("could not update task status to running: %w", err)
 	}
@@ -295,11 +673,154 @@ func (s *ServiceTaskQueue) claimTaskWithConcurrency(ctx sqlc.Tx, taskConcurrency
 
 	task.Status = taskStatusRunning
 	task.PickedUpAt = &now
-	*claimedTask = &task
+	task.HeartbeatAt = &now
+	task.LeaseExpiresAt = &leaseExpiresAt
+	task.Claim++
+	*claimedTask = task
 
 	return nil
 }
 
+// selectClaimableTask walks the due queued tasks in claim order (highest priority first, oldest first
+// within a priority) and returns the first one whose dependencies succeeded and which the gate allows,
+// so a saturated engine, kind or table does not block the remaining queue. Saturated engines and kinds
+// are already excluded by the query, only the table limit and the dependencies are checked per batch.
+func (s *ServiceTaskQueue) selectClaimableTask(ctx sqlc.Tx, gate *taskClaimGate) (*Task, error) {
+	now := time.Now()
+	engines, kinds := gate.claimableEnginesAndKinds()
+
+	if (engines != nil && len(engines) == 0) || (kinds != nil && len(kinds) == 0) {
+		return nil, nil
+	}
+
+	for offset := 0; ; offset += taskClaimCandidateBatchSize {
+		var candidates []Task
+
+		stmt := ctx.Q().From("tasks").
+			Where(sqlc.Eq{"status": taskStatusQueued}).
+			Where(sqlc.Col("not_before").Lte(now)).
+			OrderBy(sqlc.Col("priority").Desc()).
+			OrderBy(sqlc.Col("started_at").Asc()).
+			Limit(taskClaimCandidateBatchSize).
+			Offset(offset)
+
+		if engines != nil {
+			stmt = stmt.Where(sqlc.Col("engine").In(engines...))
+		}
+
+		if kinds != nil {
+			stmt = stmt.Where(sqlc.Col("kind").In(kinds...))
+		}
+
+		if err := stmt.Select(ctx, &candidates); err != nil {
+			return nil, fmt.Errorf("could not select queued tasks: %w", err)
+		}
+
+		blocked, err := s.blockedTaskIDsInTx(ctx, candidates)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range candidates {
+			if _, ok := blocked[candidates[i].Id]; ok {
+				continue
+			}
+
+			if gate.allows(&candidates[i]) {
+				return &candidates[i], nil
+			}
+		}
+
+		if len(candidates) < taskClaimCandidateBatchSize {
+			return nil, nil
+		}
+	}
+}
+
+// taskClaimGate decides whether a queued task may start given the currently running tasks and the
+// per-engine, per-kind and per-table concurrency limits.
+type taskClaimGate struct {
+	limits  *TaskConcurrencyLimits
+	engines map[TaskEngine]int
+	kinds   map[TaskKind]int
+	tables  map[string]struct{}
+}
+
+func newTaskClaimGate(limits *TaskConcurrencyLimits, runningTasks []Task) *taskClaimGate {
+	gate := &taskClaimGate{
+		limits:  limits,
+		engines: make(map[TaskEngine]int),
+		kinds:   make(map[TaskKind]int),
+		tables:  make(map[string]struct{}),
+	}
+
+	for _, task := range runningTasks {
+		gate.engines[TaskEngine(task.Engine)]++
+		gate.kinds[TaskKind(task.Kind)]++
+		gate.tables[task.Database+"."+task.Table] = struct{}{}
+	}
+
+	return gate
+}
+
+func (g *taskClaimGate) allows(task *Task) bool {
+	if !g.allowsEngine(TaskEngine(task.Engine)) || !g.allowsKind(TaskKind(task.Kind)) {
+		return false
+	}
+
+	if _, ok := g.tables[task.Database+"."+task.Table]; ok && g.limits.OnePerTable {
+		return false
+	}
+
+	return true
+}
+
+// claimableEnginesAndKinds returns the registered engines and the task kinds which did not reach their
+// limit yet. A nil list means that nothing is saturated and no filter is needed, which also keeps tasks
+// of engines which are not registered anymore claimable, so they can be failed by the worker.
+func (g *taskClaimGate) claimableEnginesAndKinds() (engines []any, kinds []any) {
+	for _, engine := range registeredTaskEngines() {
+		if limit := g.limits.Engines[engine]; limit > 0 && g.engines[engine] >= limit {
+			engines = claimableValues(registeredTaskEngines(), g.allowsEngine)
+
+			break
+		}
+	}
+
+	for _, kind := range taskKinds {
+		if limit := g.limits.Kinds[kind]; limit > 0 && g.kinds[kind] >= limit {
+			kinds = claimableValues(taskKinds, g.allowsKind)
+
+			break
+		}
+	}
+
+	return engines, kinds
+}
+
+func claimableValues[T ~string](values []T, allows func(T) bool) []any {
+	claimable := make([]any, 0, len(values))
+	for _, value := range values {
+		if allows(value) {
+			claimable = append(claimable, string(value))
+		}
+	}
+
+	return claimable
+}
+
+func (g *taskClaimGate) allowsEngine(engine TaskEngine) bool {
+	limit := g.limits.Engines[engine]
+
+	return limit == 0 || g.engines[engine] < limit
+}
+
+func (g *taskClaimGate) allowsKind(kind TaskKind) bool {
+	limit := g.limits.Kinds[kind]
+
+	return limit == 0 || g.kinds[kind] < limit
+}
+
 func isTaskClaimRetryable(err error) bool {
 	if err == nil {
 		return false
@@ -312,7 +833,11 @@ func isTaskClaimRetryable(err error) bool {
 	return strings.Contains(errMessage, "deadlock") || strings.Contains(errMessage, "concurrent update")
 }
 
-func (s *ServiceTaskQueue) CompleteTask(ctx context.Context, id int64, result map[string]any, err error) error {
+// CompleteTask finishes the given claim of a running task. Completing a claim which is not current anymore,
+// e.g. because the task was re-queued after its lease expired, is a no-op. The table of a successful task
+// is refreshed in the background afterwards, see TaskRefreshQueue.
+func (s *ServiceTaskQueue) CompleteTask(ctx context.Context, claim TaskClaim, result map[string]any, err error) error {
+	id := claim.TaskId
 	status := taskStatusSuccess
 	var errMsg *string
 
@@ -339,7 +864,7 @@ func (s *ServiceTaskQueue) CompleteTask(ctx context.Context, id int64, result ma
 		Set("status", status).
 		Set("error_message", errMsg).
 		Set("result", db.NewJSON(mergedResult, db.NonNullable{})).
-		Where(sqlc.Eq{"id": id, "status": taskStatusRunning})
+		Where(sqlc.Eq{"id": id, "status": taskStatusRunning, "claim": claim.Claim})
 
 	res, err := upd.Exec(ctx)
 	if err != nil {
@@ -355,11 +880,190 @@ func (s *ServiceTaskQueue) CompleteTask(ctx context.Context, id int64, result ma
 		return nil
 	}
 
+	task.Status = status
+	task.FinishedAt = &now
+	task.ErrorMessage = errMsg
+	task.Result = db.NewJSON(mergedResult, db.NonNullable{})
+
+	event := newTaskEvent(TaskEventCompleted, &task)
+	event.Result = mergedResult
+	s.events.Publish(ctx, event)
+
+	if errMsg == nil {
+		s.refreshes.Enqueue(ctx, &task)
+
+		return nil
+	}
+
+	retried, err := s.scheduleAutomaticRetry(ctx, &task)
+	if err != nil {
+		s.logger.Warn(ctx, "could not schedule automatic retry for task %d: %s", id, err)
+	}
+
+	if retried {
+		return nil
+	}
+
+	if err = s.SkipDependentTasks(ctx, &task); err != nil {
+		s.logger.Warn(ctx, "could not skip tasks depending on failed task %d: %s", id, err)
+	}
+
 	return nil
 }
 
-func (s *ServiceTaskQueue) UpdateTaskResult(ctx context.Context, id int64, result map[string]any) error {
+// scheduleAutomaticRetry enqueues the next attempt of a failed task if the retry policy of its kind allows it
+// and reports whether the task is retried.
+func (s *ServiceTaskQueue) scheduleAutomaticRetry(ctx context.Context, task *Task) (bool, error) {
+	var retryTaskID int64
+
+	policy, ok := s.retryPolicies[TaskKind(task.Kind)]
+	if !ok || task.ErrorMessage == nil || !policy.ShouldRetry(task.Attempt, *task.ErrorMessage) {
+		return false, nil
+	}
+
+	notBefore := time.Now().Add(policy.Backoff(task.Attempt))
+
+	err := s.sqlClient.WithTx(ctx, func(cttx sqlc.Tx) error {
+		var err error
+		retryTaskID, err = s.retryTaskInTx(cttx, task, notBefore)
+
+		return err
+	}, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		if errors.Is(err, errTaskAlreadyRetried) {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	s.logger.Info(ctx, "scheduled attempt %d of task %d as task %d not before %s", task.Attempt+1, task.Id, retryTaskID, notBefore.Format(time.RFC3339))
+	s.publishTaskEvents(ctx, TaskEventEnqueued, retryTaskID)
+
+	err = s.UpdateTaskResult(ctx, task.CurrentClaim(), map[string]any{
+		"retry_task_id":    retryTaskID,
+		"retry_not_before": notBefore,
+	})
+
+	return true, err
+}
+
+// HeartbeatTasks extends the lease of the given claims of running tasks. Claims which are not current
+// anymore are ignored.
+func (s *ServiceTaskQueue) HeartbeatTasks(ctx context.Context, claims ...TaskClaim) error {
+	now := time.Now()
+	leaseExpiresAt := now.Add(s.leaseSettings.Duration)
+
+	for _, claim := range claims {
+		upd := s.sqlClient.Q().Update("tasks").
+			Set("heartbeat_at", &now).
+			Set("lease_expires_at", &leaseExpiresAt).
+			Where(sqlc.Eq{"id": claim.TaskId, "status": taskStatusRunning, "claim": claim.Claim})
+
+		if _, err := upd.Exec(ctx); err != nil {
+			return fmt.Errorf("could not heartbeat task %d: %w", claim.TaskId, err)
+		}
+	}
+
+	return nil
+}
+
+// ListRunningTasks returns all running tasks which are executed by the given engine.
+func (s *ServiceTaskQueue) ListRunningTasks(ctx context.Context, engine TaskEngine) ([]Task, error) {
+	var tasks []Task
+
+	sel := s.sqlClient.Q().From("tasks").Where(sqlc.Eq{"status": taskStatusRunning, "engine": string(engine)}).OrderBy(sqlc.Col("picked_up_at").Asc())
+	if err := sel.Select(ctx, &tasks); err != nil {
+		return nil, fmt.Errorf("could not list running %s tasks: %w", engine, err)
+	}
+
+	return tasks, nil
+}
+
+// ReapExpiredTasks looks for running tasks whose lease expired and, depending on the configured
+// action, either puts them back into the queue or fails them. The engine work of a task is stopped with
+// stop before, so a re-queued task never runs twice at the same time. A task which can not be reaped is
+// logged and left for the next run.
+func (s *ServiceTaskQueue) ReapExpiredTasks(ctx context.Context, stop func(ctx context.Context, task *Task) error) (int, error) {
+	var tasks []Task
+
+	now := time.Now()
+	sel := s.sqlClient.Q().From("tasks").
+		Where(sqlc.Eq{"status": taskStatusRunning}).
+		Where(sqlc.Col("lease_expires_at").Lte(now)).
+		OrderBy(sqlc.Col("lease_expires_at").Asc())
+
+	if err := sel.Select(ctx, &tasks); err != nil {
+		return 0, fmt.Errorf("could not list tasks with expired leases: %w", err)
+	}
+
+	reaped := 0
+	for i := range tasks {
+		if err := s.reapExpiredTask(ctx, &tasks[i], now, stop); err != nil {
+			s.logger.Error(ctx, "could not reap task %d with expired lease: %s", tasks[i].Id, err)
+
+			continue
+		}
+
+		reaped++
+	}
+
+	return reaped, nil
+}
+
+// reapExpiredTask stops the engine work of a task with an expired lease and then fails or re-queues it.
+// Re-queueing advances the claim, so the previous claim can neither update the result nor complete the task.
+func (s *ServiceTaskQueue) reapExpiredTask(ctx context.Context, task *Task, now time.Time, stop func(ctx context.Context, task *Task) error) error {
+	leaseExpiredAt := now
+	if task.LeaseExpiresAt != nil {
+		leaseExpiredAt = *task.LeaseExpiresAt
+	}
+
+	if err := stop(ctx, task); err != nil {
+		return fmt.Errorf("could not stop the engine work of task %d: %w", task.Id, err)
+	}
+
+	if s.leaseSettings.ExpiredAction == taskLeaseExpiredActionFail {
+		s.logger.Warn(ctx, "failing task %d (%s for %s.%s) because its lease expired at %s", task.Id, task.Kind, task.Database, task.Table, leaseExpiredAt.Format(time.RFC3339))
+
+		result := map[string]any{
+			"lease_expired_at": leaseExpiredAt,
+		}
+
+		return s.CompleteTask(ctx, task.CurrentClaim(), result, fmt.Errorf("task lease expired at %s without a heartbeat from the worker", leaseExpiredAt.Format(time.RFC3339)))
+	}
+
+	s.logger.Warn(ctx, "re-queueing task %d (%s for %s.%s) because its lease expired at %s", task.Id, task.Kind, task.Database, task.Table, leaseExpiredAt.Format(time.RFC3339))
+
+	result := mergeTaskResult(task.Result.Get(), map[string]any{
+		"lease_expired_at": leaseExpiredAt,
+	})
+
+	upd := s.sqlClient.Q().Update("tasks").
+		Set("status", taskStatusQueued).
+		Set("picked_up_at", (*time.Time)(nil)).
+		Set("heartbeat_at", (*time.Time)(nil)).
+		Set("lease_expires_at", (*time.Time)(nil)).
+		Set("result", db.NewJSON(result, db.NonNullable{})).
+		Set("claim", task.Claim+1).
+		Where(sqlc.Eq{"id": task.Id, "status": taskStatusRunning, "claim": task.Claim}).
+		Where(sqlc.Col("lease_expires_at").Lte(now))
+
+	if _, err := upd.Exec(ctx); err != nil {
+		return fmt.Errorf("could not re-queue task %d with expired lease: %w", task.Id, err)
+	}
+
+	s.publishTaskEvents(ctx, TaskEventEnqueued, task.Id)
+
+	return nil
+}
+
+// UpdateTaskResult merges result into the result of the given claim of a task. The update is dropped if the
+// task has been re-queued or claimed again in the meantime.
+func (s *ServiceTaskQueue) UpdateTaskResult(ctx context.Context, claim TaskClaim, result map[string]any) error {
 	var task Task
+
+	id := claim.TaskId
 	if err := s.sqlClient.Q().From("tasks").Where(sqlc.Eq{"id": id}).Limit(1).Get(ctx, &task); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return fmt.Errorf("task %d not found for result update", id)
@@ -372,16 +1076,56 @@ func (s *ServiceTaskQueue) UpdateTaskResult(ctx context.Context, id int64, resul
 
 	upd := s.sqlClient.Q().Update("tasks").
 		Set("result", db.NewJSON(mergedResult, db.NonNullable{})).
-		Where(sqlc.Eq{"id": id})
+		Where(sqlc.Eq{"id": id, "claim": claim.Claim})
 
-	if _, err := upd.Exec(ctx); err != nil {
+	res, err := upd.Exec(ctx)
+	if err != nil {
 		return fmt.Errorf("could not update task result: %w", err)
 	}
 
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not get rows affected when updating task result: %w", err)
+	}
+
+	if affected == 0 && task.Claim != claim.Claim {
+		s.logger.Warn(ctx, "dropping result update of claim %d of task %d which has been re-queued or claimed again", claim.Claim, id)
+
+		return nil
+	}
+
+	event := newTaskEvent(TaskEventResultUpdated, &task)
+	event.Result = mergedResult
+	s.events.Publish(ctx, event)
+
 	return nil
 }
 
-func (s *ServiceTaskQueue) UpdateTaskResultNested(ctx context.Context, id int64, key string, result map[string]any) error {
+// publishTaskEvents loads the current state of the given tasks and publishes an event for each of them.
+// Events are best effort, so failing to load the tasks is only logged.
+func (s *ServiceTaskQueue) publishTaskEvents(ctx context.Context, eventType TaskEventType, ids ...int64) {
+	var tasks []Task
+
+	if len(ids) == 0 {
+		return
+	}
+
+	sel := s.sqlClient.Q().From("tasks").Where(sqlc.Col("id").In(int64sToAny(ids)...))
+	if err := sel.Select(ctx, &tasks); err != nil {
+		s.logger.Warn(ctx, "could not load tasks to publish %s events: %s", eventType, err)
+
+		return
+	}
+
+	events := make([]TaskEvent, len(tasks))
+	for i := range tasks {
+		events[i] = newTaskEvent(eventType, &tasks[i])
+	}
+
+	s.events.Publish(ctx, events...)
+}
+
+func (s *ServiceTaskQueue) UpdateTaskResultNested(ctx context.Context, claim TaskClaim, key string, result map[string]any) error {
 	key = strings.TrimSpace(key)
 	if key == "" {
 		return fmt.Errorf("result key is required")
@@ -391,7 +1135,7 @@ func (s *ServiceTaskQueue) UpdateTaskResultNested(ctx context.Context, id int64,
 		result = map[string]any{}
 	}
 
-	return s.UpdateTaskResult(ctx, id, map[string]any{key: result})
+	return s.UpdateTaskResult(ctx, claim, map[string]any{key: result})
 }
 
 func mergeTaskResult(existing map[string]any, update map[string]any) map[string]any {
@@ -507,25 +1251,9 @@ func (s *ServiceTaskQueue) ListTasks(ctx context.Context, database string, table
 		return nil, fmt.Errorf("could not list tasks: %w", err)
 	}
 
-	// Convert to DTO
-	dtos := make([]sTask, len(result))
-	for i, r := range result {
-		dtos[i] = sTask{
-			Id:           r.Id,
-			Database:     r.Database,
-			Table:        r.Table,
-			Kind:         r.Kind,
-			Engine:       r.Engine,
-			StartedAt:    r.StartedAt,
-			PickedUpAt:   r.PickedUpAt,
-			FinishedAt:   r.FinishedAt,
-			Status:       r.Status,
-			Retried:      r.Retried,
-			CanRetry:     r.Status == taskStatusError && !r.Retried,
-			ErrorMessage: r.ErrorMessage,
-			Input:        r.Input.Get(),
-			Result:       r.Result.Get(),
-		}
+	dtos, err := s.newTaskDTOs(ctx, result)
+	if err != nil {
+		return nil, err
 	}
 
 	return &PaginatedTasks{
@@ -534,22 +1262,90 @@ func (s *ServiceTaskQueue) ListTasks(ctx context.Context, database string, table
 	}, nil
 }
 
-func (s *ServiceTaskQueue) FlushTasks(ctx context.Context, database string) (int64, error) {
+// GetTaskChain returns all attempts of the retry chain the given task belongs to, ordered by attempt.
+func (s *ServiceTaskQueue) GetTaskChain(ctx context.Context, id int64) ([]sTask, error) {
 	var err error
-	var res sqlc.Result
-	var affected int64
+	var task *Task
 
-	del := s.sqlClient.Q().Delete("tasks")
-	if database != "" {
-		del = del.Where(sqlc.Eq{"database": database})
+	if task, err = s.GetTask(ctx, id); err != nil {
+		return nil, err
 	}
-	if res, err = del.Exec(ctx); err != nil {
-		return 0, fmt.Errorf("could not flush tasks: %w", err)
+
+	chain := []Task{*task}
+	for task.ParentTaskId != nil {
+		if task, err = s.GetTask(ctx, *task.ParentTaskId); err != nil {
+			return nil, fmt.Errorf("could not load parent of task %d: %w", chain[0].Id, err)
+		}
+
+		chain = append([]Task{*task}, chain...)
 	}
 
-	if affected, err = res.RowsAffected(); err != nil {
-		return 0, fmt.Errorf("could not get rows affected: %w", err)
+	for current := chain[len(chain)-1]; ; {
+		var children []Task
+
+		sel := s.sqlClient.Q().From("tasks").Where(sqlc.Eq{"parent_task_id": current.Id}).OrderBy(sqlc.Col("id").Asc()).Limit(1)
+		if err = sel.Select(ctx, &children); err != nil {
+			return nil, fmt.Errorf("could not load retry of task %d: %w", current.Id, err)
+		}
+
+		if len(children) == 0 {
+			break
+		}
+
+		current = children[0]
+		chain = append(chain, current)
 	}
 
-	return affected, nil
+	return s.newTaskDTOs(ctx, chain)
+}
+
+// newTaskDTOs converts tasks to their DTOs including the ids of the tasks they depend on.
+func (s *ServiceTaskQueue) newTaskDTOs(ctx context.Context, tasks []Task) ([]sTask, error) {
+	taskIDs := make([]int64, len(tasks))
+	for i := range tasks {
+		taskIDs[i] = tasks[i].Id
+	}
+
+	dependencies, err := s.GetTaskDependencies(ctx, taskIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := make([]sTask, len(tasks))
+	for i, task := range tasks {
+		dtos[i] = newTaskDTO(task)
+
+		if dependsOn, ok := dependencies[task.Id]; ok {
+			dtos[i].DependsOn = dependsOn
+		}
+	}
+
+	return dtos, nil
+}
+
+func newTaskDTO(task Task) sTask {
+	return sTask{
+		Id:             task.Id,
+		ParentTaskId:   task.ParentTaskId,
+		Database:       task.Database,
+		Table:          task.Table,
+		Kind:           task.Kind,
+		Engine:         task.Engine,
+		StartedAt:      task.StartedAt,
+		NotBefore:      task.NotBefore,
+		PickedUpAt:     task.PickedUpAt,
+		HeartbeatAt:    task.HeartbeatAt,
+		LeaseExpiresAt: task.LeaseExpiresAt,
+		FinishedAt:     task.FinishedAt,
+		Status:         task.Status,
+		Priority:       task.Priority,
+		Retried:        task.Retried,
+		Attempt:        task.Attempt,
+		Claim:          task.Claim,
+		DependsOn:      []int64{},
+		CanRetry:       isTaskRetryable(&task),
+		ErrorMessage:   task.ErrorMessage,
+		Input:          task.Input.Get(),
+		Result:         task.Result.Get(),
+	}
 }
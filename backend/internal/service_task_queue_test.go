@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskClaimGateSkipsSaturatedEngineKindAndTable(t *testing.T) {
+	limits := &TaskConcurrencyLimits{
+		Engines:     map[TaskEngine]int{TaskEngineSpark: 1},
+		Kinds:       map[TaskKind]int{TaskKindRemoveOrphanFiles: 1},
+		OnePerTable: true,
+	}
+
+	gate := newTaskClaimGate(limits, []Task{
+		{Database: "main", Table: "events", Kind: string(TaskKindOptimize), Engine: string(TaskEngineSpark)},
+		{Database: "main", Table: "installs", Kind: string(TaskKindRemoveOrphanFiles), Engine: string(TaskEngineTrino)},
+	})
+
+	require.False(t, gate.allows(&Task{Database: "main", Table: "clicks", Kind: string(TaskKindOptimize), Engine: string(TaskEngineSpark)}))
+	require.False(t, gate.allows(&Task{Database: "main", Table: "clicks", Kind: string(TaskKindRemoveOrphanFiles), Engine: string(TaskEngineTrino)}))
+	require.False(t, gate.allows(&Task{Database: "main", Table: "events", Kind: string(TaskKindExpireSnapshots), Engine: string(TaskEngineTrino)}))
+	require.True(t, gate.allows(&Task{Database: "main", Table: "clicks", Kind: string(TaskKindExpireSnapshots), Engine: string(TaskEngineTrino)}))
+	require.True(t, gate.allows(&Task{Database: "other", Table: "events", Kind: string(TaskKindExpireSnapshots), Engine: string(TaskEngineTrino)}))
+}
+
+func TestTaskClaimGateWithoutLimitsAllowsEverything(t *testing.T) {
+	gate := newTaskClaimGate(&TaskConcurrencyLimits{}, []Task{
+		{Database: "main", Table: "events", Kind: string(TaskKindOptimize), Engine: string(TaskEngineSpark)},
+	})
+
+	require.True(t, gate.allows(&Task{Database: "main", Table: "events", Kind: string(TaskKindOptimize), Engine: string(TaskEngineSpark)}))
+}
+
+func TestTaskClaimGateClaimableEnginesAndKinds(t *testing.T) {
+	running := []Task{
+		{Database: "main", Table: "events", Kind: string(TaskKindOptimize), Engine: string(TaskEngineSpark)},
+	}
+
+	engines, kinds := newTaskClaimGate(&TaskConcurrencyLimits{}, running).claimableEnginesAndKinds()
+	require.Nil(t, engines)
+	require.Nil(t, kinds)
+
+	limits := &TaskConcurrencyLimits{
+		Engines: map[TaskEngine]int{TaskEngineSpark: 1, TaskEngineTrino: 2},
+		Kinds:   map[TaskKind]int{TaskKindOptimize: 1},
+	}
+
+	engines, kinds = newTaskClaimGate(limits, running).claimableEnginesAndKinds()
+	require.Equal(t, []any{string(TaskEngineTrino)}, engines)
+	require.NotContains(t, kinds, string(TaskKindOptimize))
+	require.Contains(t, kinds, string(TaskKindExpireSnapshots))
+}
+
+func TestTaskInputRangesOverlap(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2026, time.March, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	// stored inputs come back from the database as json strings
+	stored := map[string]any{"from": "2026-03-02T00:00:00Z", "to": "2026-03-08T00:00:00Z"}
+
+	require.True(t, taskInputRangesOverlap(stored, map[string]any{"from": day(8), "to": day(14)}))
+	require.True(t, taskInputRangesOverlap(stored, map[string]any{"from": day(4), "to": day(4)}))
+	require.False(t, taskInputRangesOverlap(stored, map[string]any{"from": day(9), "to": day(15)}))
+	require.False(t, taskInputRangesOverlap(stored, map[string]any{"from": day(1), "to": day(1)}))
+	require.True(t, taskInputRangesOverlap(map[string]any{"retention_days": 7}, map[string]any{"retention_days": 14}))
+
+	storedPartition := map[string]any{"partition": map[string]any{"country": "de"}}
+
+	require.True(t, taskInputRangesOverlap(storedPartition, map[string]any{"partition": PartitionValues{"country": "de"}}))
+	require.False(t, taskInputRangesOverlap(storedPartition, map[string]any{"partition": PartitionValues{"country": "fr"}}))
+	require.True(t, taskInputRangesOverlap(storedPartition, map[string]any{"partition": PartitionValues{}}))
+}
+
+func TestIsTaskRetryable(t *testing.T) {
+	require.True(t, isTaskRetryable(&Task{Kind: string(TaskKindOptimize), Status: taskStatusError}))
+	require.False(t, isTaskRetryable(&Task{Kind: string(TaskKindOptimize), Status: taskStatusError, Retried: true}))
+	require.False(t, isTaskRetryable(&Task{Kind: string(TaskKindOptimize), Status: taskStatusSuccess}))
+	require.False(t, isTaskRetryable(&Task{Kind: string(TaskKindRollback), Status: taskStatusError}))
+}
@@ -2,12 +2,15 @@ package internal
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/gosoline-project/sqlc"
 	"github.com/justtrackio/gosoline/pkg/cfg"
+	"github.com/justtrackio/gosoline/pkg/db"
 	"github.com/justtrackio/gosoline/pkg/log"
 )
 
@@ -27,6 +30,10 @@ type ServiceTasks struct {
 	logger           log.Logger
 	serviceTaskQueue *ServiceTaskQueue
 	engineResolver   *TaskEngineResolver
+	profiles         *ServiceMaintenanceProfiles
+	executors        *ServiceMaintenanceExecutor
+	taskHistory      *ServiceTaskHistory
+	metadata         *ServiceMetadata
 	sqlClient        sqlc.Client
 	settings         *IcebergSettings
 }
@@ -43,21 +50,84 @@ type BatchEnqueueFailure struct {
 	Error string `json:"error"`
 }
 
+// BatchEnqueueResult lists the ids of all tasks covering the request in TaskIds. Ids of tasks which
+// were already queued or running are additionally reported in Deduplicated and not part of EnqueuedCount.
 type BatchEnqueueResult struct {
 	TaskIds       []int64               `json:"task_ids"`
 	EnqueuedCount int64                 `json:"enqueued_count"`
+	Deduplicated  []int64               `json:"deduplicated"`
 	FailedTables  []BatchEnqueueFailure `json:"failed_tables"`
 }
 
+// BatchPlanResult lists the tasks a batch enqueue would create, see PlannedTask.
+type BatchPlanResult struct {
+	Tasks        []PlannedTask         `json:"tasks"`
+	FailedTables []BatchEnqueueFailure `json:"failed_tables"`
+}
+
+// PlannedTask describes a task as it would be enqueued. For optimize tasks, Partitions lists the
+// partitions needing optimization within the range of the task and the counts are summed over them.
+type PlannedTask struct {
+	Database                 string              `json:"database"`
+	Table                    string              `json:"table"`
+	Kind                     string              `json:"kind"`
+	Engine                   string              `json:"engine"`
+	Input                    map[string]any      `json:"input"`
+	Partitions               []PlannedPartition  `json:"partitions"`
+	RecordCount              int64               `json:"record_count"`
+	FileCount                int64               `json:"file_count"`
+	TotalDataFileSizeInBytes int64               `json:"total_data_file_size_in_bytes"`
+	ProtectedSnapshots       []ProtectedSnapshot `json:"protected_snapshots,omitempty"`
+}
+
+type PlannedPartition struct {
+	Partition                PartitionValues `json:"partition"`
+	RecordCount              int64           `json:"record_count"`
+	FileCount                int64           `json:"file_count"`
+	TotalDataFileSizeInBytes int64           `json:"total_data_file_size_in_bytes"`
+}
+
+type optimizePartitionRow struct {
+	Year                     string                                   `db:"year"`
+	Month                    string                                   `db:"month"`
+	Day                      string                                   `db:"day"`
+	Partition                db.JSON[PartitionValues, db.NonNullable] `db:"partition"`
+	RecordCount              int64                                    `db:"record_count"`
+	FileCount                int64                                    `db:"file_count"`
+	TotalDataFileSizeInBytes int64                                    `db:"total_data_file_size_in_bytes"`
+}
+
+type optimizeChunkPlan struct {
+	chunk      optimizeRangeChunk
+	partitions []PlannedPartition
+}
+
 type BatchOptimizeTable struct {
-	Table   string
-	ChunkBy string
+	Table    string
+	ChunkBy  string
+	Strategy string
+	Columns  []string
+}
+
+// TaskEnqueueOptions holds the queue related options of newly enqueued tasks. Tasks with a higher
+// priority are claimed first, tasks with the same priority are claimed in the order they were enqueued.
+// A task with dependencies is only claimed once all tasks in DependsOn succeeded. With PreferProfile the
+// requested task parameters only apply where neither the maintenance profile nor the table properties set
+// a value, e.g. for the defaults of the maintenance schedule.
+type TaskEnqueueOptions struct {
+	Priority      int
+	DependsOn     []int64
+	PreferProfile bool
 }
 
 func NewServiceTasks(ctx context.Context, config cfg.Config, logger log.Logger) (*ServiceTasks, error) {
 	var err error
 	var serviceTaskQueue *ServiceTaskQueue
 	var engineResolver *TaskEngineResolver
+	var profiles *ServiceMaintenanceProfiles
+	var executors *ServiceMaintenanceExecutor
+	var taskHistory *ServiceTaskHistory
+	var metadata *ServiceMetadata
 	var settings *IcebergSettings
 
 	var sqlClient sqlc.Client
@@ -74,6 +144,22 @@ func NewServiceTasks(ctx context.Context, config cfg.Config, logger log.Logger)
 		return nil, fmt.Errorf("could not create task engine resolver: %w", err)
 	}
 
+	if profiles, err = NewServiceMaintenanceProfiles(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create maintenance profiles service: %w", err)
+	}
+
+	if executors, err = ProvideServiceMaintenanceExecutor(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create maintenance executor service: %w", err)
+	}
+
+	if taskHistory, err = NewServiceTaskHistory(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create task history service: %w", err)
+	}
+
+	if metadata, err = NewServiceMetadata(ctx, config, logger); err != nil {
+		return nil, fmt.Errorf("could not create metadata service: %w", err)
+	}
+
 	if settings, err = ReadIcebergSettings(config); err != nil {
 		return nil, fmt.Errorf("could not read iceberg settings: %w", err)
 	}
@@ -82,92 +168,260 @@ func NewServiceTasks(ctx context.Context, config cfg.Config, logger log.Logger)
 		logger:           logger.WithChannel("tasks"),
 		serviceTaskQueue: serviceTaskQueue,
 		engineResolver:   engineResolver,
+		profiles:         profiles,
+		executors:        executors,
+		taskHistory:      taskHistory,
+		metadata:         metadata,
 		sqlClient:        sqlClient,
 		settings:         settings,
 	}, nil
 }
 
 // EnqueueExpireSnapshots enqueues a task to expire old snapshots for a table
-func (s *ServiceTasks) EnqueueExpireSnapshots(ctx context.Context, database string, table string, retentionDays int) (int64, error) {
-	// Apply minimum constraints
-	if retentionDays < minRetentionDays {
-		retentionDays = minRetentionDays
+func (s *ServiceTasks) EnqueueExpireSnapshots(ctx context.Context, database string, table string, expireOptions ExpireSnapshotsOptions, options TaskEnqueueOptions) (EnqueuedTask, error) {
+	plan, err := s.planExpireSnapshots(ctx, database, table, expireOptions, options.PreferProfile)
+	if err != nil {
+		return EnqueuedTask{}, err
 	}
 
-	taskInput := map[string]any{
-		"retention_days": retentionDays,
+	return s.enqueuePlan(ctx, plan, options)
+}
+
+// PlanExpireSnapshots returns the task EnqueueExpireSnapshots would enqueue without enqueueing it.
+// Without a requested retention, the maintenance profile and then history.expire.max-snapshot-age-ms
+// of the table are used. An explicit older_than replaces the retention and has to be at least
+// minRetentionDays in the past. Without a requested retain_last, history.expire.min-snapshots-to-keep
+// of the table applies. A table which was not refreshed yet has no stored properties, only the profile
+// and the requested values apply then.
+func (s *ServiceTasks) PlanExpireSnapshots(ctx context.Context, database string, table string, expireOptions ExpireSnapshotsOptions) (PlannedTask, error) {
+	return s.planExpireSnapshots(ctx, database, table, expireOptions, false)
+}
+
+func (s *ServiceTasks) planExpireSnapshots(ctx context.Context, database string, table string, expireOptions ExpireSnapshotsOptions, preferProfile bool) (PlannedTask, error) {
+	var err error
+	var desc *TableDescription
+	var profile MaintenanceProfile
+	var properties map[string]string
+
+	if expireOptions.RetainLast < 0 {
+		return PlannedTask{}, fmt.Errorf("retain_last must not be negative")
 	}
 
-	engine, err := s.engineResolver.Resolve(TaskKindExpireSnapshots)
-	if err != nil {
-		return 0, fmt.Errorf("could not resolve engine for expire snapshots task: %w", err)
+	if profile, err = s.profiles.ResolveProfile(ctx, database, table); err != nil {
+		return PlannedTask{}, err
+	}
+
+	desc, err = s.metadata.GetTable(ctx, database, table)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		s.logger.Warn(ctx, "table %s.%s is not stored yet, planning expire snapshots without its properties", database, table)
+	case err != nil:
+		return PlannedTask{}, fmt.Errorf("could not load table %s.%s: %w", database, table, err)
+	default:
+		properties = desc.Properties.Get()
 	}
 
-	taskId, err := s.serviceTaskQueue.EnqueueTask(ctx, database, table, string(TaskKindExpireSnapshots), string(engine), taskInput)
+	input := map[string]any{}
+	now := time.Now().UTC()
+
+	var cutoff time.Time
+	if expireOptions.OlderThan != nil {
+		olderThan := expireOptions.OlderThan.UTC()
+		if olderThan.After(now.AddDate(0, 0, -minRetentionDays)) {
+			return PlannedTask{}, fmt.Errorf("older_than must be at least %d days in the past", minRetentionDays)
+		}
+
+		cutoff = olderThan
+		input["older_than"] = olderThan.Format(time.RFC3339)
+	} else {
+		requested := requestedTaskParameter(expireOptions.RetentionDays, preferProfile, profile.ExpireSnapshotsRetentionDays, tablePropertyRetentionDays(properties))
+		retentionDays, retentionSource := resolveTaskParameter(requested, profile.ExpireSnapshotsRetentionDays, tablePropertyRetentionDays(properties), minRetentionDays)
+
+		// Apply minimum constraints
+		if retentionDays < minRetentionDays {
+			retentionDays = minRetentionDays
+		}
+
+		cutoff = now.AddDate(0, 0, -retentionDays)
+		input["retention_days"] = retentionDays
+		input["retention_days_source"] = retentionSource
+	}
+
+	requestedRetainLast := requestedTaskParameter(expireOptions.RetainLast, preferProfile, nil, tablePropertyRetainLast(properties))
+	retainLast, retainLastSource := resolveTaskParameter(requestedRetainLast, nil, tablePropertyRetainLast(properties), 1)
+	input["retain_last"] = retainLast
+	input["retain_last_source"] = retainLastSource
+
+	input["clean_expired_metadata"] = true
+	if expireOptions.CleanExpiredMetadata != nil {
+		input["clean_expired_metadata"] = *expireOptions.CleanExpiredMetadata
+	}
+
+	engine, err := profile.resolveEngine(TaskKindExpireSnapshots, s.engineResolver)
 	if err != nil {
-		return 0, fmt.Errorf("could not enqueue expire snapshots task: %w", err)
+		return PlannedTask{}, fmt.Errorf("could not resolve engine for expire snapshots task: %w", err)
 	}
 
-	return taskId, nil
+	plan := newPlannedTask(database, table, TaskKindExpireSnapshots, engine, input)
+	if plan.ProtectedSnapshots, err = s.listProtectedSnapshots(ctx, database, table, cutoff, now); err != nil {
+		return PlannedTask{}, err
+	}
+
+	return plan, nil
+}
+
+// listProtectedSnapshots returns the stored snapshots older than cutoff which expire_snapshots keeps
+// because a tag or branch points to them, see protectedSnapshots.
+func (s *ServiceTasks) listProtectedSnapshots(ctx context.Context, database string, table string, cutoff time.Time, now time.Time) ([]ProtectedSnapshot, error) {
+	var err error
+	var refs []SnapshotRef
+	var snapshots []Snapshot
+
+	if refs, err = s.metadata.ListSnapshotRefs(ctx, database, table); err != nil {
+		return nil, err
+	}
+
+	if len(refs) == 0 {
+		return []ProtectedSnapshot{}, nil
+	}
+
+	if snapshots, err = s.metadata.ListSnapshots(ctx, database, table); err != nil {
+		return nil, err
+	}
+
+	return protectedSnapshots(snapshots, refs, cutoff, now), nil
 }
 
 // EnqueueRemoveOrphanFiles enqueues a task to remove orphan files for a table
-func (s *ServiceTasks) EnqueueRemoveOrphanFiles(ctx context.Context, database string, table string, retentionDays int) (int64, error) {
+func (s *ServiceTasks) EnqueueRemoveOrphanFiles(ctx context.Context, database string, table string, retentionDays int, options TaskEnqueueOptions) (EnqueuedTask, error) {
+	plan, err := s.planRemoveOrphanFiles(ctx, database, table, retentionDays, options.PreferProfile)
+	if err != nil {
+		return EnqueuedTask{}, err
+	}
+
+	return s.enqueuePlan(ctx, plan, options)
+}
+
+// PlanRemoveOrphanFiles returns the task EnqueueRemoveOrphanFiles would enqueue without enqueueing it
+func (s *ServiceTasks) PlanRemoveOrphanFiles(ctx context.Context, database string, table string, retentionDays int) (PlannedTask, error) {
+	return s.planRemoveOrphanFiles(ctx, database, table, retentionDays, false)
+}
+
+func (s *ServiceTasks) planRemoveOrphanFiles(ctx context.Context, database string, table string, retentionDays int, preferProfile bool) (PlannedTask, error) {
+	profile, err := s.profiles.ResolveProfile(ctx, database, table)
+	if err != nil {
+		return PlannedTask{}, err
+	}
+
+	retentionDays = requestedTaskParameter(retentionDays, preferProfile, profile.RemoveOrphanFilesRetentionDays, nil)
+
+	if retentionDays < 1 {
+		retentionDays = valueOrDefault(profile.RemoveOrphanFilesRetentionDays, minRetentionDays)
+	}
+
 	// Apply minimum constraint
 	if retentionDays < minRetentionDays {
 		retentionDays = minRetentionDays
 	}
 
-	taskInput := map[string]any{
+	engine, err := profile.resolveEngine(TaskKindRemoveOrphanFiles, s.engineResolver)
+	if err != nil {
+		return PlannedTask{}, fmt.Errorf("could not resolve engine for remove orphan files task: %w", err)
+	}
+
+	return newPlannedTask(database, table, TaskKindRemoveOrphanFiles, engine, map[string]any{
 		"retention_days": retentionDays,
+	}), nil
+}
+
+// EnqueueTableTask enqueues a task of a kind which only needs the table, e.g. rewrite_manifests.
+func (s *ServiceTasks) EnqueueTableTask(ctx context.Context, kind TaskKind, database string, table string, options TaskEnqueueOptions) (EnqueuedTask, error) {
+	plan, err := s.PlanTableTask(ctx, kind, database, table)
+	if err != nil {
+		return EnqueuedTask{}, err
+	}
+
+	return s.enqueuePlan(ctx, plan, options)
+}
+
+// PlanTableTask returns the task EnqueueTableTask would enqueue without enqueueing it
+func (s *ServiceTasks) PlanTableTask(ctx context.Context, kind TaskKind, database string, table string) (PlannedTask, error) {
+	descriptor, err := lookupTableTaskKind(kind)
+	if err != nil {
+		return PlannedTask{}, err
 	}
 
-	engine, err := s.engineResolver.Resolve(TaskKindRemoveOrphanFiles)
+	profile, err := s.profiles.ResolveProfile(ctx, database, table)
 	if err != nil {
-		return 0, fmt.Errorf("could not resolve engine for remove orphan files task: %w", err)
+		return PlannedTask{}, err
 	}
 
-	taskId, err := s.serviceTaskQueue.EnqueueTask(ctx, database, table, string(TaskKindRemoveOrphanFiles), string(engine), taskInput)
+	engine, err := profile.resolveEngine(kind, s.engineResolver)
 	if err != nil {
-		return 0, fmt.Errorf("could not enqueue remove orphan files task: %w", err)
+		return PlannedTask{}, fmt.Errorf("could not resolve engine for %s task: %w", kind, err)
 	}
 
-	return taskId, nil
+	return newPlannedTask(database, table, kind, engine, descriptor.input(profile)), nil
 }
 
-func (s *ServiceTasks) EnqueueExpireSnapshotsBatch(ctx context.Context, database string, tables []string, retentionDays int) (*BatchEnqueueResult, error) {
-	return s.enqueueBatch(ctx, tables, func(cttx context.Context, table string) (int64, error) {
-		return s.EnqueueExpireSnapshots(cttx, database, table, retentionDays)
+func (s *ServiceTasks) EnqueueTableTaskBatch(ctx context.Context, kind TaskKind, database string, tables []string, options TaskEnqueueOptions) (*BatchEnqueueResult, error) {
+	if _, err := lookupTableTaskKind(kind); err != nil {
+		return nil, err
+	}
+
+	return s.enqueueBatch(ctx, tables, func(cttx context.Context, table string) (EnqueuedTask, error) {
+		return s.EnqueueTableTask(cttx, kind, database, table, options)
 	})
 }
 
-func (s *ServiceTasks) EnqueueRemoveOrphanFilesBatch(ctx context.Context, database string, tables []string, retentionDays int) (*BatchEnqueueResult, error) {
-	return s.enqueueBatch(ctx, tables, func(cttx context.Context, table string) (int64, error) {
-		return s.EnqueueRemoveOrphanFiles(cttx, database, table, retentionDays)
+func (s *ServiceTasks) PlanTableTaskBatch(ctx context.Context, kind TaskKind, database string, tables []string) (*BatchPlanResult, error) {
+	if _, err := lookupTableTaskKind(kind); err != nil {
+		return nil, err
+	}
+
+	return s.planBatch(ctx, tables, singlePlan(func(cttx context.Context, table string) (PlannedTask, error) {
+		return s.PlanTableTask(cttx, kind, database, table)
+	}))
+}
+
+func (s *ServiceTasks) EnqueueExpireSnapshotsBatch(ctx context.Context, database string, tables []string, expireOptions ExpireSnapshotsOptions, options TaskEnqueueOptions) (*BatchEnqueueResult, error) {
+	return s.enqueueBatch(ctx, tables, func(cttx context.Context, table string) (EnqueuedTask, error) {
+		return s.EnqueueExpireSnapshots(cttx, database, table, expireOptions, options)
 	})
 }
 
-func (s *ServiceTasks) EnqueueOptimizeBatch(ctx context.Context, database string, tables []BatchOptimizeTable, targetFileSizeMb int, from time.Time, to time.Time) (*BatchEnqueueResult, error) {
-	if from.IsZero() || to.IsZero() {
-		return nil, fmt.Errorf("from and to dates are required for optimize")
-	}
+func (s *ServiceTasks) EnqueueRemoveOrphanFilesBatch(ctx context.Context, database string, tables []string, retentionDays int, options TaskEnqueueOptions) (*BatchEnqueueResult, error) {
+	return s.enqueueBatch(ctx, tables, func(cttx context.Context, table string) (EnqueuedTask, error) {
+		return s.EnqueueRemoveOrphanFiles(cttx, database, table, retentionDays, options)
+	})
+}
 
-	if from.After(to) {
-		return nil, fmt.Errorf("from date must be before or equal to the to date")
-	}
+func (s *ServiceTasks) PlanExpireSnapshotsBatch(ctx context.Context, database string, tables []string, expireOptions ExpireSnapshotsOptions) (*BatchPlanResult, error) {
+	return s.planBatch(ctx, tables, singlePlan(func(cttx context.Context, table string) (PlannedTask, error) {
+		return s.PlanExpireSnapshots(cttx, database, table, expireOptions)
+	}))
+}
 
-	normalizedTables := normalizeBatchOptimizeTables(tables)
-	if len(normalizedTables) == 0 {
-		return nil, fmt.Errorf("at least one table must be provided")
+func (s *ServiceTasks) PlanRemoveOrphanFilesBatch(ctx context.Context, database string, tables []string, retentionDays int) (*BatchPlanResult, error) {
+	return s.planBatch(ctx, tables, singlePlan(func(cttx context.Context, table string) (PlannedTask, error) {
+		return s.PlanRemoveOrphanFiles(cttx, database, table, retentionDays)
+	}))
+}
+
+func (s *ServiceTasks) EnqueueOptimizeBatch(ctx context.Context, database string, tables []BatchOptimizeTable, targetFileSizeMb int, from time.Time, to time.Time, options TaskEnqueueOptions) (*BatchEnqueueResult, error) {
+	normalizedTables, err := normalizeOptimizeBatch(tables, from, to)
+	if err != nil {
+		return nil, err
 	}
 
 	result := &BatchEnqueueResult{
 		TaskIds:      make([]int64, 0, len(normalizedTables)),
+		Deduplicated: make([]int64, 0),
 		FailedTables: make([]BatchEnqueueFailure, 0),
 	}
 
 	for _, tableConfig := range normalizedTables {
-		taskIDs, err := s.EnqueueOptimize(ctx, database, tableConfig.Table, targetFileSizeMb, from, to, tableConfig.ChunkBy)
+		enqueuedTasks, err := s.EnqueueOptimize(ctx, database, tableConfig.Table, targetFileSizeMb, from, to, tableConfig.ChunkBy, tableConfig.strategy(), options)
 		if err != nil {
 			s.logger.Warn(ctx, "failed to enqueue optimize maintenance task for table %s: %s", tableConfig.Table, err)
 			result.FailedTables = append(result.FailedTables, BatchEnqueueFailure{
@@ -178,36 +432,138 @@ func (s *ServiceTasks) EnqueueOptimizeBatch(ctx context.Context, database string
 			continue
 		}
 
-		result.TaskIds = append(result.TaskIds, taskIDs...)
-		result.EnqueuedCount += int64(len(taskIDs))
+		for _, enqueued := range enqueuedTasks {
+			result.add(enqueued)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *ServiceTasks) PlanOptimizeBatch(ctx context.Context, database string, tables []BatchOptimizeTable, targetFileSizeMb int, from time.Time, to time.Time) (*BatchPlanResult, error) {
+	normalizedTables, err := normalizeOptimizeBatch(tables, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BatchPlanResult{
+		Tasks:        make([]PlannedTask, 0),
+		FailedTables: make([]BatchEnqueueFailure, 0),
+	}
+
+	for _, tableConfig := range normalizedTables {
+		plans, err := s.PlanOptimize(ctx, database, tableConfig.Table, targetFileSizeMb, from, to, tableConfig.ChunkBy, tableConfig.strategy())
+		if err != nil {
+			result.FailedTables = append(result.FailedTables, BatchEnqueueFailure{
+				Table: tableConfig.Table,
+				Error: err.Error(),
+			})
+
+			continue
+		}
+
+		result.Tasks = append(result.Tasks, plans...)
 	}
 
 	return result, nil
 }
 
-// EnqueueOptimize queries the partitions table for partitions that need optimization
-// within the given date range and enqueues one optimize task per qualifying chunk.
-func (s *ServiceTasks) EnqueueOptimize(ctx context.Context, database string, table string, targetFileSizeMb int, from time.Time, to time.Time, chunkBy string) ([]int64, error) {
+// EnqueueOptimize queries the partitions table for partitions that need optimization and enqueues
+// one optimize task per qualifying chunk of the date range or partition scope, see PlanOptimize.
+func (s *ServiceTasks) EnqueueOptimize(ctx context.Context, database string, table string, targetFileSizeMb int, from time.Time, to time.Time, chunkBy string, strategy OptimizeStrategy, options TaskEnqueueOptions) ([]EnqueuedTask, error) {
 	var err error
-	var taskId int64
-	var taskIds []int64
+	var plans []PlannedTask
+	var enqueued EnqueuedTask
+
+	if plans, err = s.planOptimize(ctx, database, table, targetFileSizeMb, from, to, chunkBy, strategy, options.PreferProfile); err != nil {
+		return nil, err
+	}
+
+	enqueuedTasks := make([]EnqueuedTask, 0, len(plans))
+	for _, plan := range plans {
+		if enqueued, err = s.serviceTaskQueue.EnqueueTask(ctx, plan.Database, plan.Table, plan.Kind, plan.Engine, plan.Input, options); err != nil {
+			return nil, fmt.Errorf("could not enqueue optimize task for %s: %w", describeOptimizeTaskInput(plan.Input), err)
+		}
+		enqueuedTasks = append(enqueuedTasks, enqueued)
+	}
+
+	return enqueuedTasks, nil
+}
+
+// PlanOptimize returns the optimize tasks EnqueueOptimize would enqueue without enqueueing them.
+// For tables partitioned by hour or day, each task covers one chunk of the date range containing at
+// least one partition needing optimization. All other tables get one task per partition scope, see
+// optimizePartitionLayout.scope; the date range only applies to month and year partitioned tables.
+// Unpartitioned tables are optimized as a whole once their single partition needs optimization.
+// Without an explicit strategy, the tasks sort by the iceberg sort order of the table if it has one.
+// Without a requested target file size, the maintenance profile and then write.target-file-size-bytes
+// of the table are used.
+func (s *ServiceTasks) PlanOptimize(ctx context.Context, database string, table string, targetFileSizeMb int, from time.Time, to time.Time, chunkBy string, strategy OptimizeStrategy) ([]PlannedTask, error) {
+	return s.planOptimize(ctx, database, table, targetFileSizeMb, from, to, chunkBy, strategy, false)
+}
+
+func (s *ServiceTasks) planOptimize(ctx context.Context, database string, table string, targetFileSizeMb int, from time.Time, to time.Time, chunkBy string, strategy OptimizeStrategy, preferProfile bool) ([]PlannedTask, error) {
+	var err error
+	var desc *TableDescription
+	var profile MaintenanceProfile
+
+	if profile, err = s.profiles.ResolveProfile(ctx, database, table); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(chunkBy) == "" || preferProfile && profile.ChunkBy != nil {
+		chunkBy = valueOrDefault(profile.ChunkBy, "")
+	}
+
 	chunkBy, err = normalizeOptimizeChunkBy(chunkBy)
 	if err != nil {
 		return nil, err
 	}
 
-	engine, err := s.engineResolver.Resolve(TaskKindOptimize)
+	engine, err := profile.resolveEngine(TaskKindOptimize, s.engineResolver)
 	if err != nil {
 		return nil, fmt.Errorf("could not resolve engine for optimize task: %w", err)
 	}
 
+	if desc, err = s.metadata.GetTable(ctx, database, table); err != nil {
+		return nil, fmt.Errorf("could not load table %s.%s: %w", database, table, err)
+	}
+
+	if strategy, err = normalizeOptimizeStrategy(strategy, desc.SortOrder.Get()); err != nil {
+		return nil, err
+	}
+
+	if engine == TaskEngineTrino {
+		if err = validateTrinoOptimizeStrategy(strategy, desc.SortOrder.Get()); err != nil {
+			return nil, err
+		}
+	}
+
 	// Apply default target size.
-	if targetFileSizeMb < 1 {
-		targetFileSizeMb = 512
+	targetFileSizeMb = requestedTaskParameter(targetFileSizeMb, preferProfile, profile.TargetFileSizeMb, tablePropertyTargetFileSizeMb(desc.Properties.Get()))
+	targetFileSizeMb, targetFileSizeSource := resolveTaskParameter(targetFileSizeMb, profile.TargetFileSizeMb, tablePropertyTargetFileSizeMb(desc.Properties.Get()), 512)
+
+	layout := newOptimizePartitionLayout(desc.Partitions.Get())
+
+	newPlan := func(input map[string]any, partitions []PlannedPartition) PlannedTask {
+		input["target_file_size_mb"] = targetFileSizeMb
+		input["target_file_size_mb_source"] = targetFileSizeSource
+		input["strategy"] = strategy.Strategy
+		input["columns"] = strategy.Columns
+
+		plan := newPlannedTask(database, table, TaskKindOptimize, engine, input)
+		for _, partition := range partitions {
+			plan.Partitions = append(plan.Partitions, partition)
+			plan.RecordCount += partition.RecordCount
+			plan.FileCount += partition.FileCount
+			plan.TotalDataFileSizeInBytes += partition.TotalDataFileSizeInBytes
+		}
+
+		return plan
 	}
 
 	// Validate date range
-	if from.IsZero() || to.IsZero() {
+	if layout.timeTransform != "" && (from.IsZero() || to.IsZero()) {
 		return nil, fmt.Errorf("from and to dates are required for optimize")
 	}
 
@@ -215,20 +571,68 @@ func (s *ServiceTasks) EnqueueOptimize(ctx context.Context, database string, tab
 		return nil, fmt.Errorf("from date must be before or equal to the to date")
 	}
 
-	effectiveRange, ok := optimizeRangeWithinDelay(from, to, time.Now().UTC(), s.settings.NeedsOptimizeDelay)
-	if !ok {
-		return []int64{}, nil
+	var effectiveRange *optimizeRangeChunk
+	if layout.timeTransform != "" {
+		withinDelay, ok := optimizeRangeWithinDelay(from, to, time.Now().UTC(), s.settings.NeedsOptimizeDelay)
+		if !ok {
+			return []PlannedTask{}, nil
+		}
+
+		effectiveRange = &withinDelay
 	}
 
-	// Query partitions that need optimization within the date range
-	// The partition column stores JSON like {"year": "2025", "month": "06", "day": "15"}
-	// We need to construct a date from these fields and filter by the date range
-	type partitionRow struct {
-		Year  string `db:"year"`
-		Month string `db:"month"`
-		Day   string `db:"day"`
+	if !layout.chunksByDate() {
+		var partitions []optimizePartitionRow
+		var scopePlans []optimizeScopePlan
+
+		if partitions, err = s.selectOptimizeScopePartitions(ctx, database, table, layout, effectiveRange); err != nil {
+			return nil, err
+		}
+
+		if scopePlans, err = planOptimizeScopes(layout, partitions); err != nil {
+			return nil, err
+		}
+
+		plans := make([]PlannedTask, 0, len(scopePlans))
+		for _, scopePlan := range scopePlans {
+			input := map[string]any{"partition": scopePlan.scope}
+			if scopePlan.period != nil {
+				input["from"] = scopePlan.period.from
+				input["to"] = scopePlan.period.to
+			}
+
+			plans = append(plans, newPlan(input, scopePlan.partitions))
+		}
+
+		return plans, nil
+	}
+
+	var partitions []optimizePartitionRow
+	var chunkPlans []optimizeChunkPlan
+
+	if partitions, err = s.selectOptimizePartitions(ctx, database, table, *effectiveRange); err != nil {
+		return nil, err
+	}
+
+	if chunkPlans, err = planOptimizeChunks(partitions, chunkBy, *effectiveRange); err != nil {
+		return nil, err
 	}
 
+	plans := make([]PlannedTask, 0, len(chunkPlans))
+	for _, chunkPlan := range chunkPlans {
+		plans = append(plans, newPlan(map[string]any{
+			"from": chunkPlan.chunk.from,
+			"to":   chunkPlan.chunk.to,
+		}, chunkPlan.partitions))
+	}
+
+	return plans, nil
+}
+
+// selectOptimizePartitions queries the partitions that need optimization within the date range.
+// The partition column stores JSON like {"year": "2025", "month": "06", "day": "15"}
+// We need to construct a date from these fields and filter by the date range
+func (s *ServiceTasks) selectOptimizePartitions(ctx context.Context, database string, table string, effectiveRange optimizeRangeChunk) ([]optimizePartitionRow, error) {
 	// Build a date path expression: CONCAT(year, '-', LPAD(month, 2, '0'), '-', LPAD(day, 2, '0'))
 	// Use sqlc.Concat() with LPAD to ensure zero-padded dates for proper string comparison
 	datePath := sqlc.Concat(
@@ -243,20 +647,70 @@ func (s *ServiceTasks) EnqueueOptimize(ctx context.Context, database string, tab
 		Column(sqlc.Col("p.partition->>'$.year'").As("year")).
 		Column(sqlc.Col("p.partition->>'$.month'").As("month")).
 		Column(sqlc.Col("p.partition->>'$.day'").As("day")).
+		Column(sqlc.Col("p.partition").As("partition")).
+		Column(sqlc.Col("p.record_count").As("record_count")).
+		Column(sqlc.Col("p.file_count").As("file_count")).
+		Column(sqlc.Col("p.total_data_file_size_in_bytes").As("total_data_file_size_in_bytes")).
 		Where(sqlc.Eq{"p.database": database, "p.table": table, "p.needs_optimize": true}).
 		Where(datePath.Gte(effectiveRange.from.Format(time.DateOnly))).
 		Where(datePath.Lte(effectiveRange.to.Format(time.DateOnly))).
 		OrderBy(datePath.Asc())
 
-	var partitions []partitionRow
-	if err = sel.Select(ctx, &partitions); err != nil {
+	var partitions []optimizePartitionRow
+	if err := sel.Select(ctx, &partitions); err != nil {
+		return nil, fmt.Errorf("could not query partitions that need optimization: %w", err)
+	}
+
+	return partitions, nil
+}
+
+// selectOptimizeScopePartitions queries the partitions that need optimization for tables which are not
+// optimized in date range chunks. For month and year partitioned tables, only partitions starting
+// within the date range are selected.
+func (s *ServiceTasks) selectOptimizeScopePartitions(ctx context.Context, database string, table string, layout optimizePartitionLayout, effectiveRange *optimizeRangeChunk) ([]optimizePartitionRow, error) {
+	sel := s.sqlClient.Q().From("partitions").As("p").
+		Column(sqlc.Col("p.partition").As("partition")).
+		Column(sqlc.Col("p.record_count").As("record_count")).
+		Column(sqlc.Col("p.file_count").As("file_count")).
+		Column(sqlc.Col("p.total_data_file_size_in_bytes").As("total_data_file_size_in_bytes")).
+		Where(sqlc.Eq{"p.database": database, "p.table": table, "p.needs_optimize": true})
+
+	if effectiveRange != nil {
+		from := layout.periodStart(effectiveRange.from).Format(time.DateOnly)
+		to := effectiveRange.to.Format(time.DateOnly)
+
+		switch layout.timeTransform {
+		case transformMonth:
+			periodStart := sqlc.Concat(
+				sqlc.Col("p.partition->>'$.year'"),
+				sqlc.Literal("'-'"),
+				sqlc.Col("p.partition->>'$.month'").Lpad(2, "0"),
+				sqlc.Literal("'-01'"),
+			)
+			sel = sel.Where(periodStart.Gte(from)).Where(periodStart.Lte(to))
+		case transformYear:
+			periodStart := sqlc.Concat(
+				sqlc.Col("p.partition->>'$.year'"),
+				sqlc.Literal("'-01-01'"),
+			)
+			sel = sel.Where(periodStart.Gte(from)).Where(periodStart.Lte(to))
+		}
+	}
+
+	var partitions []optimizePartitionRow
+	if err := sel.Select(ctx, &partitions); err != nil {
 		return nil, fmt.Errorf("could not query partitions that need optimization: %w", err)
 	}
 
-	chunkSet := make([]optimizeRangeChunk, 0, len(partitions))
-	seenChunks := make(map[string]struct{}, len(partitions))
+	return partitions, nil
+}
+
+// planOptimizeChunks groups the partitions into chunks, one per chunk that contains at least one
+// qualifying partition. Chunks are clamped to the effective range and keep the order of the partitions.
+func planOptimizeChunks(partitions []optimizePartitionRow, chunkBy string, effectiveRange optimizeRangeChunk) ([]optimizeChunkPlan, error) {
+	chunkPlans := make([]optimizeChunkPlan, 0, len(partitions))
+	chunkIndexes := make(map[string]int, len(partitions))
 
-	// Enqueue one task per chunk that contains at least one qualifying partition.
 	for _, p := range partitions {
 		dateStr := fmt.Sprintf("%s-%s-%s", p.Year, p.Month, p.Day)
 		partitionDate, err := time.Parse("2006-1-2", dateStr)
@@ -264,38 +718,40 @@ func (s *ServiceTasks) EnqueueOptimize(ctx context.Context, database string, tab
 			return nil, fmt.Errorf("could not parse partition date %s: %w", dateStr, err)
 		}
 
-		chunk := optimizeChunkForDate(partitionDate, chunkBy)
-		chunk, ok = clampOptimizeRange(chunk, effectiveRange)
+		chunk, ok := clampOptimizeRange(optimizeChunkForDate(partitionDate, chunkBy), effectiveRange)
 		if !ok {
 			continue
 		}
 
 		chunkKey := chunk.from.Format(time.DateOnly) + ":" + chunk.to.Format(time.DateOnly)
-		if _, ok := seenChunks[chunkKey]; ok {
-			continue
+		index, ok := chunkIndexes[chunkKey]
+		if !ok {
+			index = len(chunkPlans)
+			chunkIndexes[chunkKey] = index
+			chunkPlans = append(chunkPlans, optimizeChunkPlan{chunk: chunk})
 		}
 
-		seenChunks[chunkKey] = struct{}{}
-		chunkSet = append(chunkSet, chunk)
+		chunkPlans[index].partitions = append(chunkPlans[index].partitions, PlannedPartition{
+			Partition:                p.Partition.Get(),
+			RecordCount:              p.RecordCount,
+			FileCount:                p.FileCount,
+			TotalDataFileSizeInBytes: p.TotalDataFileSizeInBytes,
+		})
 	}
 
-	for _, chunk := range chunkSet {
-		taskInput := map[string]any{
-			"target_file_size_mb": targetFileSizeMb,
-			"from":                chunk.from,
-			"to":                  chunk.to,
-		}
+	return chunkPlans, nil
+}
 
-		if taskId, err = s.serviceTaskQueue.EnqueueTask(ctx, database, table, string(TaskKindOptimize), string(engine), taskInput); err != nil {
-			return nil, fmt.Errorf("could not enqueue optimize task for range %s to %s: %w", chunk.from.Format(time.DateOnly), chunk.to.Format(time.DateOnly), err)
-		}
-		taskIds = append(taskIds, taskId)
+func (s *ServiceTasks) enqueuePlan(ctx context.Context, plan PlannedTask, options TaskEnqueueOptions) (EnqueuedTask, error) {
+	enqueued, err := s.serviceTaskQueue.EnqueueTask(ctx, plan.Database, plan.Table, plan.Kind, plan.Engine, plan.Input, options)
+	if err != nil {
+		return EnqueuedTask{}, fmt.Errorf("could not enqueue %s task: %w", plan.Kind, err)
 	}
 
-	return taskIds, nil
+	return enqueued, nil
 }
 
-func (s *ServiceTasks) enqueueBatch(ctx context.Context, tables []string, enqueue func(context.Context, string) (int64, error)) (*BatchEnqueueResult, error) {
+func (s *ServiceTasks) enqueueBatch(ctx context.Context, tables []string, enqueue func(context.Context, string) (EnqueuedTask, error)) (*BatchEnqueueResult, error) {
 	normalizedTables := normalizeBatchTables(tables)
 	if len(normalizedTables) == 0 {
 		return nil, fmt.Errorf("at least one table must be provided")
@@ -303,11 +759,12 @@ func (s *ServiceTasks) enqueueBatch(ctx context.Context, tables []string, enqueu
 
 	result := &BatchEnqueueResult{
 		TaskIds:      make([]int64, 0, len(normalizedTables)),
+		Deduplicated: make([]int64, 0),
 		FailedTables: make([]BatchEnqueueFailure, 0),
 	}
 
 	for _, table := range normalizedTables {
-		taskID, err := enqueue(ctx, table)
+		enqueued, err := enqueue(ctx, table)
 		if err != nil {
 			s.logger.Warn(ctx, "failed to enqueue maintenance task for table %s: %s", table, err)
 			result.FailedTables = append(result.FailedTables, BatchEnqueueFailure{
@@ -318,13 +775,64 @@ func (s *ServiceTasks) enqueueBatch(ctx context.Context, tables []string, enqueu
 			continue
 		}
 
-		result.TaskIds = append(result.TaskIds, taskID)
-		result.EnqueuedCount++
+		result.add(enqueued)
 	}
 
 	return result, nil
 }
 
+func (s *ServiceTasks) planBatch(ctx context.Context, tables []string, plan func(context.Context, string) ([]PlannedTask, error)) (*BatchPlanResult, error) {
+	normalizedTables := normalizeBatchTables(tables)
+	if len(normalizedTables) == 0 {
+		return nil, fmt.Errorf("at least one table must be provided")
+	}
+
+	result := &BatchPlanResult{
+		Tasks:        make([]PlannedTask, 0, len(normalizedTables)),
+		FailedTables: make([]BatchEnqueueFailure, 0),
+	}
+
+	for _, table := range normalizedTables {
+		plans, err := plan(ctx, table)
+		if err != nil {
+			result.FailedTables = append(result.FailedTables, BatchEnqueueFailure{
+				Table: table,
+				Error: err.Error(),
+			})
+
+			continue
+		}
+
+		result.Tasks = append(result.Tasks, plans...)
+	}
+
+	return result, nil
+}
+
+// singlePlan adapts the plan function of a kind planning exactly one task per table to planBatch.
+func singlePlan(plan func(context.Context, string) (PlannedTask, error)) func(context.Context, string) ([]PlannedTask, error) {
+	return func(ctx context.Context, table string) ([]PlannedTask, error) {
+		planned, err := plan(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+
+		return []PlannedTask{planned}, nil
+	}
+}
+
+func (r *BatchEnqueueResult) add(enqueued EnqueuedTask) {
+	r.TaskIds = append(r.TaskIds, enqueued.TaskId)
+
+	if enqueued.Deduplicated {
+		r.Deduplicated = append(r.Deduplicated, enqueued.TaskId)
+
+		return
+	}
+
+	r.EnqueuedCount++
+}
+
 func (s *ServiceTasks) RetryTask(ctx context.Context, taskID int64) (int64, error) {
 	retryTaskID, err := s.serviceTaskQueue.RetryTask(ctx, taskID)
 	if err != nil {
@@ -343,7 +851,70 @@ func (s *ServiceTasks) RetryAllTasks(ctx context.Context, database string) (int6
 	return retriedCount, nil
 }
 
-func (s *ServiceTasks) UpdateProcedureResult(ctx context.Context, taskID int64, callback *TaskProcedureCallback) error {
+func (s *ServiceTasks) SetTaskPriority(ctx context.Context, taskID int64, priority int) error {
+	if err := s.serviceTaskQueue.SetTaskPriority(ctx, taskID, priority); err != nil {
+		return fmt.Errorf("could not set priority of task %d: %w", taskID, err)
+	}
+
+	return nil
+}
+
+// GetTaskChain is a pass-through to ServiceTaskQueue.GetTaskChain
+func (s *ServiceTasks) GetTaskChain(ctx context.Context, taskID int64) ([]sTask, error) {
+	chain, err := s.serviceTaskQueue.GetTaskChain(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get retry chain of task %d: %w", taskID, err)
+	}
+
+	return chain, nil
+}
+
+// CancelTask cancels a queued or running task. Running tasks additionally have their engine work
+// stopped: spark tasks get their SparkApplication deleted, trino tasks get their query killed.
+func (s *ServiceTasks) CancelTask(ctx context.Context, taskID int64) error {
+	task, err := s.serviceTaskQueue.CancelTask(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("could not cancel task %d: %w", taskID, err)
+	}
+
+	if err = s.stopCancelledTask(ctx, task); err != nil {
+		return fmt.Errorf("task %d was cancelled but its engine work could not be stopped: %w", taskID, err)
+	}
+
+	return nil
+}
+
+func (s *ServiceTasks) CancelAllTasks(ctx context.Context, database string) (int64, error) {
+	tasks, err := s.serviceTaskQueue.CancelAllTasks(ctx, database)
+	if err != nil {
+		return 0, fmt.Errorf("could not cancel tasks: %w", err)
+	}
+
+	for i := range tasks {
+		if err = s.stopCancelledTask(ctx, &tasks[i]); err != nil {
+			s.logger.Warn(ctx, "task %d was cancelled but its engine work could not be stopped: %s", tasks[i].Id, err)
+		}
+	}
+
+	return int64(len(tasks)), nil
+}
+
+func (s *ServiceTasks) stopCancelledTask(ctx context.Context, task *Task) error {
+	if task.Status != taskStatusRunning {
+		return nil
+	}
+
+	executor, err := s.executors.ForEngine(TaskEngine(task.Engine))
+	if err != nil {
+		return err
+	}
+
+	return executor.CancelTask(ctx, task)
+}
+
+func (s *ServiceTasks) UpdateProcedureResult(ctx context.Context, claim TaskClaim, callback *TaskProcedureCallback) error {
+	taskID := claim.TaskId
+
 	task, err := s.serviceTaskQueue.GetTask(ctx, taskID)
 	if err != nil {
 		return fmt.Errorf("could not load task %d for procedure callback: %w", taskID, err)
@@ -357,6 +928,10 @@ func (s *ServiceTasks) UpdateProcedureResult(ctx context.Context, taskID int64,
 		return fmt.Errorf("task %d cannot accept procedure callback in status %s", taskID, task.Status)
 	}
 
+	if task.Claim != claim.Claim {
+		return fmt.Errorf("task %d cannot accept procedure callback of claim %d, it is in claim %d", taskID, claim.Claim, task.Claim)
+	}
+
 	result := map[string]any{
 		"query":       callback.Query,
 		"rows":        callback.Rows,
@@ -367,7 +942,21 @@ func (s *ServiceTasks) UpdateProcedureResult(ctx context.Context, taskID int64,
 		result["meta"] = callback.Meta
 	}
 
-	if err = s.serviceTaskQueue.UpdateTaskResultNested(ctx, taskID, "procedure", result); err != nil {
+	update := map[string]any{
+		"procedure": result,
+	}
+
+	// rewrite_manifests reports the manifest counts around the procedure call in its meta, they are
+	// stored at the top level of the result the same way the trino engine does.
+	if TaskKind(task.Kind) == TaskKindRewriteManifests {
+		for _, key := range []string{"manifest_count_before", "manifest_count_after"} {
+			if value, ok := callback.Meta[key]; ok {
+				update[key] = value
+			}
+		}
+	}
+
+	if err = s.serviceTaskQueue.UpdateTaskResult(ctx, claim, update); err != nil {
 		return fmt.Errorf("could not update procedure result for task %d: %w", taskID, err)
 	}
 
@@ -394,14 +983,42 @@ func (s *ServiceTasks) TaskCounts(ctx context.Context, database string) (running
 	return running, queued, nil
 }
 
-// FlushTasks is a pass-through to ServiceTaskQueue.FlushTasks
-func (s *ServiceTasks) FlushTasks(ctx context.Context, database string) (int64, error) {
-	deleted, err := s.serviceTaskQueue.FlushTasks(ctx, database)
+// FlushTasks is a pass-through to ServiceTaskHistory.PruneTasks
+func (s *ServiceTasks) FlushTasks(ctx context.Context, filter TaskPruneFilter) (*TaskPruneResult, error) {
+	result, err := s.taskHistory.PruneTasks(ctx, filter)
 	if err != nil {
-		return 0, fmt.Errorf("could not flush tasks: %w", err)
+		return nil, fmt.Errorf("could not flush tasks: %w", err)
 	}
 
-	return deleted, nil
+	return result, nil
+}
+
+func newPlannedTask(database string, table string, kind TaskKind, engine TaskEngine, input map[string]any) PlannedTask {
+	return PlannedTask{
+		Database:   database,
+		Table:      table,
+		Kind:       string(kind),
+		Engine:     string(engine),
+		Input:      input,
+		Partitions: []PlannedPartition{},
+	}
+}
+
+func normalizeOptimizeBatch(tables []BatchOptimizeTable, from time.Time, to time.Time) ([]BatchOptimizeTable, error) {
+	if from.IsZero() || to.IsZero() {
+		return nil, fmt.Errorf("from and to dates are required for optimize")
+	}
+
+	if from.After(to) {
+		return nil, fmt.Errorf("from date must be before or equal to the to date")
+	}
+
+	normalizedTables := normalizeBatchOptimizeTables(tables)
+	if len(normalizedTables) == 0 {
+		return nil, fmt.Errorf("at least one table must be provided")
+	}
+
+	return normalizedTables, nil
 }
 
 func optimizeRangeWithinDelay(from time.Time, to time.Time, now time.Time, delay time.Duration) (optimizeRangeChunk, bool) {
@@ -513,10 +1130,16 @@ func normalizeBatchOptimizeTables(tables []BatchOptimizeTable) []BatchOptimizeTa
 
 		seen[trimmedTable] = struct{}{}
 		normalized = append(normalized, BatchOptimizeTable{
-			Table:   trimmedTable,
-			ChunkBy: strings.TrimSpace(table.ChunkBy),
+			Table:    trimmedTable,
+			ChunkBy:  strings.TrimSpace(table.ChunkBy),
+			Strategy: strings.TrimSpace(table.Strategy),
+			Columns:  table.Columns,
 		})
 	}
 
 	return normalized
 }
+
+func (t BatchOptimizeTable) strategy() OptimizeStrategy {
+	return OptimizeStrategy{Strategy: t.Strategy, Columns: t.Columns}
+}
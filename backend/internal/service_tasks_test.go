@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/justtrackio/gosoline/pkg/db"
 	"github.com/stretchr/testify/require"
 )
 
@@ -66,3 +67,29 @@ func TestClampOptimizeRangeClampsMonthlyChunkToDelayCutoff(t *testing.T) {
 	require.Equal(t, time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC), chunk.from)
 	require.Equal(t, time.Date(2026, time.March, 29, 0, 0, 0, 0, time.UTC), chunk.to)
 }
+
+func TestPlanOptimizeChunksGroupsPartitionsByWeek(t *testing.T) {
+	allowed := optimizeRangeChunk{
+		from: time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC),
+		to:   time.Date(2026, time.March, 29, 0, 0, 0, 0, time.UTC),
+	}
+
+	partitions := []optimizePartitionRow{
+		{Year: "2026", Month: "03", Day: "16", Partition: db.NewJSON(PartitionValues{"day": "16"}, db.NonNullable{}), FileCount: 10, TotalDataFileSizeInBytes: 100},
+		{Year: "2026", Month: "03", Day: "18", Partition: db.NewJSON(PartitionValues{"day": "18"}, db.NonNullable{}), FileCount: 5, TotalDataFileSizeInBytes: 50},
+		{Year: "2026", Month: "03", Day: "29", Partition: db.NewJSON(PartitionValues{"day": "29"}, db.NonNullable{}), FileCount: 3, TotalDataFileSizeInBytes: 30},
+	}
+
+	chunkPlans, err := planOptimizeChunks(partitions, optimizeChunkWeek, allowed)
+	require.NoError(t, err)
+	require.Len(t, chunkPlans, 2)
+
+	require.Equal(t, time.Date(2026, time.March, 16, 0, 0, 0, 0, time.UTC), chunkPlans[0].chunk.from)
+	require.Equal(t, time.Date(2026, time.March, 22, 0, 0, 0, 0, time.UTC), chunkPlans[0].chunk.to)
+	require.Len(t, chunkPlans[0].partitions, 2)
+	require.Equal(t, PartitionValues{"day": "18"}, chunkPlans[0].partitions[1].Partition)
+
+	require.Equal(t, time.Date(2026, time.March, 23, 0, 0, 0, 0, time.UTC), chunkPlans[1].chunk.from)
+	require.Equal(t, time.Date(2026, time.March, 29, 0, 0, 0, 0, time.UTC), chunkPlans[1].chunk.to)
+	require.Len(t, chunkPlans[1].partitions, 1)
+}
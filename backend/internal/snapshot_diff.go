@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+)
+
+const (
+	defaultSnapshotDiffLimit = 100
+	maxSnapshotDiffLimit     = 1000
+)
+
+// diffSnapshotStates compares the files, schema and partition specs of two snapshots. The file counts and
+// partition deltas always cover the whole diff, only the listed files are paginated by limit and offset.
+func diffSnapshotStates(from IcebergSnapshotState, to IcebergSnapshotState, limit int, offset int) *SnapshotDiff {
+	if limit <= 0 {
+		limit = defaultSnapshotDiffLimit
+	}
+
+	if limit > maxSnapshotDiffLimit {
+		limit = maxSnapshotDiffLimit
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	diff := &SnapshotDiff{
+		FromSnapshotID: from.SnapshotID,
+		ToSnapshotID:   to.SnapshotID,
+		Partitions:     []SnapshotPartitionDelta{},
+		Files:          []SnapshotDiffFile{},
+		Limit:          limit,
+		Offset:         offset,
+	}
+
+	files := append(changedSnapshotFiles(from.Files, to.Files, snapshotFileChangeRemoved), changedSnapshotFiles(to.Files, from.Files, snapshotFileChangeAdded)...)
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].Change != files[j].Change {
+			return files[i].Change < files[j].Change
+		}
+
+		return files[i].Path < files[j].Path
+	})
+
+	deltas := make(map[string]*SnapshotPartitionDelta)
+	for _, file := range files {
+		key := file.Partition.String()
+		if _, ok := deltas[key]; !ok {
+			deltas[key] = &SnapshotPartitionDelta{Partition: file.Partition}
+		}
+
+		sign := int64(1)
+		if file.Change == snapshotFileChangeRemoved {
+			sign = -1
+		}
+
+		delta := deltas[key]
+		isData := file.Content == snapshotFileContentData
+
+		switch {
+		case isData && file.Change == snapshotFileChangeAdded:
+			diff.AddedDataFileCount++
+		case isData:
+			diff.RemovedDataFileCount++
+		case file.Change == snapshotFileChangeAdded:
+			diff.AddedDeleteFileCount++
+		default:
+			diff.RemovedDeleteFileCount++
+		}
+
+		if isData {
+			delta.RecordCountDelta += sign * file.RecordCount
+			delta.DataFileCountDelta += sign
+			delta.DataFileSizeBytesDelta += sign * file.SizeBytes
+		} else {
+			delta.DeleteFileCountDelta += sign
+			delta.DeleteFileSizeBytesDelta += sign * file.SizeBytes
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(deltas)) {
+		diff.Partitions = append(diff.Partitions, *deltas[key])
+	}
+
+	diff.TotalFiles = len(files)
+	if offset < len(files) {
+		diff.Files = files[offset:min(offset+limit, len(files))]
+	}
+
+	diff.SchemaChange = diffSnapshotSchemas(from, to)
+
+	if !maps.Equal(from.PartitionSpecs, to.PartitionSpecs) {
+		diff.PartitionSpecChange = &SnapshotPartitionSpecChange{
+			FromSpecs: from.PartitionSpecs,
+			ToSpecs:   to.PartitionSpecs,
+		}
+	}
+
+	return diff
+}
+
+// changedSnapshotFiles returns the files which are not part of other, marked with change.
+func changedSnapshotFiles(files []IcebergSnapshotFile, other []IcebergSnapshotFile, change string) []SnapshotDiffFile {
+	otherPaths := make(map[string]struct{}, len(other))
+	for _, file := range other {
+		otherPaths[file.Path] = struct{}{}
+	}
+
+	changed := make([]SnapshotDiffFile, 0)
+	for _, file := range files {
+		if _, ok := otherPaths[file.Path]; !ok {
+			changed = append(changed, SnapshotDiffFile{IcebergSnapshotFile: file, Change: change})
+		}
+	}
+
+	return changed
+}
+
+// diffSnapshotSchemas compares the top level columns of the schemas two snapshots were written with,
+// nil if both use the same schema.
+func diffSnapshotSchemas(from IcebergSnapshotState, to IcebergSnapshotState) *SnapshotSchemaChange {
+	if from.SchemaID == to.SchemaID {
+		return nil
+	}
+
+	change := &SnapshotSchemaChange{
+		FromSchemaID:   from.SchemaID,
+		ToSchemaID:     to.SchemaID,
+		AddedColumns:   []string{},
+		RemovedColumns: []string{},
+		ChangedColumns: []string{},
+	}
+
+	for _, column := range slices.Sorted(maps.Keys(to.Columns)) {
+		fromType, ok := from.Columns[column]
+		switch {
+		case !ok:
+			change.AddedColumns = append(change.AddedColumns, column)
+		case fromType != to.Columns[column]:
+			change.ChangedColumns = append(change.ChangedColumns, fmt.Sprintf("%s: %s -> %s", column, fromType, to.Columns[column]))
+		}
+	}
+
+	for _, column := range slices.Sorted(maps.Keys(from.Columns)) {
+		if _, ok := to.Columns[column]; !ok {
+			change.RemovedColumns = append(change.RemovedColumns, column)
+		}
+	}
+
+	return change
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSnapshotStates(t *testing.T) {
+	dayOne := PartitionValues{"day": "01"}
+	dayTwo := PartitionValues{"day": "02"}
+
+	from := IcebergSnapshotState{
+		SnapshotID:     1,
+		SchemaID:       0,
+		Columns:        map[string]string{"id": "long", "name": "string", "legacy": "string"},
+		PartitionSpecs: map[int32]string{0: "day(event_time)"},
+		Files: []IcebergSnapshotFile{
+			{Path: "a.parquet", Content: snapshotFileContentData, Partition: dayOne, RecordCount: 10, SizeBytes: 100},
+			{Path: "b.parquet", Content: snapshotFileContentData, Partition: dayOne, RecordCount: 20, SizeBytes: 200},
+			{Path: "c.parquet", Content: snapshotFileContentData, Partition: dayTwo, RecordCount: 5, SizeBytes: 50},
+		},
+	}
+	to := IcebergSnapshotState{
+		SnapshotID:     2,
+		SchemaID:       1,
+		Columns:        map[string]string{"id": "long", "name": "binary", "country": "string"},
+		PartitionSpecs: map[int32]string{0: "day(event_time)"},
+		Files: []IcebergSnapshotFile{
+			{Path: "c.parquet", Content: snapshotFileContentData, Partition: dayTwo, RecordCount: 5, SizeBytes: 50},
+			{Path: "d.parquet", Content: snapshotFileContentData, Partition: dayOne, RecordCount: 30, SizeBytes: 250},
+			{Path: "d-deletes.parquet", Content: snapshotFileContentPositionDeletes, Partition: dayOne, RecordCount: 2, SizeBytes: 10},
+		},
+	}
+
+	diff := diffSnapshotStates(from, to, 0, 0)
+	require.Equal(t, 1, diff.AddedDataFileCount)
+	require.Equal(t, 2, diff.RemovedDataFileCount)
+	require.Equal(t, 1, diff.AddedDeleteFileCount)
+	require.Equal(t, 0, diff.RemovedDeleteFileCount)
+	require.Equal(t, []SnapshotPartitionDelta{{
+		Partition:                dayOne,
+		RecordCountDelta:         0,
+		DataFileCountDelta:       -1,
+		DataFileSizeBytesDelta:   -50,
+		DeleteFileCountDelta:     1,
+		DeleteFileSizeBytesDelta: 10,
+	}}, diff.Partitions)
+	require.Equal(t, &SnapshotSchemaChange{
+		FromSchemaID:   0,
+		ToSchemaID:     1,
+		AddedColumns:   []string{"country"},
+		RemovedColumns: []string{"legacy"},
+		ChangedColumns: []string{"name: string -> binary"},
+	}, diff.SchemaChange)
+	require.Nil(t, diff.PartitionSpecChange)
+
+	require.Equal(t, 4, diff.TotalFiles)
+	require.Equal(t, defaultSnapshotDiffLimit, diff.Limit)
+
+	paths := make([]string, len(diff.Files))
+	for i, file := range diff.Files {
+		paths[i] = file.Change + " " + file.Path
+	}
+	require.Equal(t, []string{"added d-deletes.parquet", "added d.parquet", "removed a.parquet", "removed b.parquet"}, paths)
+}
+
+func TestDiffSnapshotStatesPaginatesFiles(t *testing.T) {
+	from := IcebergSnapshotState{SnapshotID: 1, PartitionSpecs: map[int32]string{0: "unpartitioned"}}
+	to := IcebergSnapshotState{
+		SnapshotID:     2,
+		PartitionSpecs: map[int32]string{1: "day(event_time)"},
+		Files: []IcebergSnapshotFile{
+			{Path: "a.parquet", Content: snapshotFileContentData},
+			{Path: "b.parquet", Content: snapshotFileContentData},
+			{Path: "c.parquet", Content: snapshotFileContentData},
+		},
+	}
+
+	diff := diffSnapshotStates(from, to, 2, 1)
+	require.Equal(t, 3, diff.TotalFiles)
+	require.Len(t, diff.Files, 2)
+	require.Equal(t, "b.parquet", diff.Files[0].Path)
+	require.NotNil(t, diff.PartitionSpecChange)
+	require.Nil(t, diff.SchemaChange)
+
+	diff = diffSnapshotStates(from, to, 2, 5)
+	require.Empty(t, diff.Files)
+}
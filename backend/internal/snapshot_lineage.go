@@ -0,0 +1,49 @@
+package internal
+
+import "time"
+
+// snapshotAncestors returns the snapshot snapshotID followed by its ancestors, newest first. The lineage
+// ends at the first parent which is not part of snapshots, e.g. because it was expired.
+func snapshotAncestors(snapshots []Snapshot, snapshotID int64) []Snapshot {
+	byID := make(map[int64]Snapshot, len(snapshots))
+	for _, snapshot := range snapshots {
+		byID[snapshot.SnapshotId] = snapshot
+	}
+
+	ancestors := make([]Snapshot, 0)
+	for id := &snapshotID; id != nil; {
+		snapshot, ok := byID[*id]
+		if !ok {
+			break
+		}
+
+		ancestors = append(ancestors, snapshot)
+		id = snapshot.ParentId
+	}
+
+	return ancestors
+}
+
+// isProperAncestor reports whether snapshotID is an ancestor of currentSnapshotID other than the current
+// snapshot itself, i.e. whether pointing the table to it discards snapshots.
+func isProperAncestor(snapshots []Snapshot, currentSnapshotID int64, snapshotID int64) bool {
+	for _, ancestor := range snapshotAncestors(snapshots, currentSnapshotID) {
+		if ancestor.SnapshotId == snapshotID && ancestor.SnapshotId != currentSnapshotID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// latestAncestorCommittedAt returns the newest ancestor of the current snapshot committed at or before at,
+// which is the snapshot a rollback to that point in time restores.
+func latestAncestorCommittedAt(snapshots []Snapshot, currentSnapshotID int64, at time.Time) (Snapshot, bool) {
+	for _, snapshot := range snapshotAncestors(snapshots, currentSnapshotID) {
+		if !snapshot.CommittedAt.After(at) {
+			return snapshot, true
+		}
+	}
+
+	return Snapshot{}, false
+}
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestAncestorCommittedAt(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC)
+	}
+	parent := func(id int64) *int64 {
+		return &id
+	}
+
+	// 4 is a staged snapshot on top of 2 which is not part of the current lineage 1 <- 2 <- 3
+	snapshots := []Snapshot{
+		{SnapshotId: 1, CommittedAt: day(1)},
+		{SnapshotId: 2, ParentId: parent(1), CommittedAt: day(3)},
+		{SnapshotId: 4, ParentId: parent(2), CommittedAt: day(4)},
+		{SnapshotId: 3, ParentId: parent(2), CommittedAt: day(5)},
+	}
+
+	ancestors := snapshotAncestors(snapshots, 3)
+	require.Len(t, ancestors, 3)
+	require.Equal(t, int64(1), ancestors[2].SnapshotId)
+
+	snapshot, ok := latestAncestorCommittedAt(snapshots, 3, day(4))
+	require.True(t, ok)
+	require.Equal(t, int64(2), snapshot.SnapshotId)
+
+	snapshot, ok = latestAncestorCommittedAt(snapshots, 3, day(3))
+	require.True(t, ok)
+	require.Equal(t, int64(2), snapshot.SnapshotId)
+
+	_, ok = latestAncestorCommittedAt(snapshots, 3, day(1).Add(-time.Second))
+	require.False(t, ok)
+
+	require.True(t, isProperAncestor(snapshots, 3, 1))
+	require.False(t, isProperAncestor(snapshots, 3, 3))
+	require.False(t, isProperAncestor(snapshots, 3, 4))
+	require.False(t, isProperAncestor(snapshots, 2, 3))
+}
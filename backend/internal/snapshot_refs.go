@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/apache/iceberg-go/table"
+)
+
+var errSnapshotRefRejected = errors.New("snapshot ref change rejected")
+
+// SnapshotRefRetention holds the optional retention settings of a branch or tag. Tags only support
+// MaxRefAgeMs, the snapshot settings only apply to the ancestors of a branch.
+type SnapshotRefRetention struct {
+	MinSnapshotsToKeep *int   `json:"min_snapshots_to_keep"`
+	MaxSnapshotAgeMs   *int64 `json:"max_snapshot_age_ms"`
+	MaxRefAgeMs        *int64 `json:"max_ref_age_ms"`
+}
+
+// ProtectedSnapshot is a snapshot which is old enough to be expired but is kept because a ref points to it.
+type ProtectedSnapshot struct {
+	SnapshotID  int64     `json:"snapshot_id,string"`
+	CommittedAt time.Time `json:"committed_at"`
+	Refs        []string  `json:"refs"`
+}
+
+// snapshotRefsFromMetadata returns the branches and tags of the table metadata, branches first and
+// sorted by name.
+func snapshotRefsFromMetadata(metadata table.Metadata) []SnapshotRef {
+	refs := make([]SnapshotRef, 0)
+	for name, ref := range metadata.Refs() {
+		refs = append(refs, SnapshotRef{
+			Name:               name,
+			Type:               string(ref.SnapshotRefType),
+			SnapshotId:         ref.SnapshotID,
+			MinSnapshotsToKeep: ref.MinSnapshotsToKeep,
+			MaxSnapshotAgeMs:   ref.MaxSnapshotAgeMs,
+			MaxRefAgeMs:        ref.MaxRefAgeMs,
+		})
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Type != refs[j].Type {
+			return refs[i].Type == string(table.BranchRef)
+		}
+
+		return refs[i].Name < refs[j].Name
+	})
+
+	return refs
+}
+
+func snapshotRefByName(metadata table.Metadata, name string) (table.SnapshotRef, bool) {
+	for refName, ref := range metadata.Refs() {
+		if refName == name {
+			return ref, true
+		}
+	}
+
+	return table.SnapshotRef{}, false
+}
+
+func snapshotRefRetention(ref table.SnapshotRef) SnapshotRefRetention {
+	return SnapshotRefRetention{
+		MinSnapshotsToKeep: ref.MinSnapshotsToKeep,
+		MaxSnapshotAgeMs:   ref.MaxSnapshotAgeMs,
+		MaxRefAgeMs:        ref.MaxRefAgeMs,
+	}
+}
+
+// checkSetSnapshotRef validates creating the ref name or, with replace, moving the existing ref name.
+func checkSetSnapshotRef(existing *table.SnapshotRef, name string, refType table.RefType, retention SnapshotRefRetention, replace bool) error {
+	if name == "" {
+		return fmt.Errorf("a ref name is required: %w", errSnapshotRefRejected)
+	}
+
+	if name == table.MainBranch {
+		return fmt.Errorf("the %s branch can not be changed through refs: %w", table.MainBranch, errSnapshotRefRejected)
+	}
+
+	if refType == table.TagRef && (retention.MinSnapshotsToKeep != nil || retention.MaxSnapshotAgeMs != nil) {
+		return fmt.Errorf("tags only support max_ref_age_ms: %w", errSnapshotRefRejected)
+	}
+
+	if (retention.MinSnapshotsToKeep != nil && *retention.MinSnapshotsToKeep < 1) ||
+		(retention.MaxSnapshotAgeMs != nil && *retention.MaxSnapshotAgeMs < 1) ||
+		(retention.MaxRefAgeMs != nil && *retention.MaxRefAgeMs < 1) {
+		return fmt.Errorf("retention settings have to be positive: %w", errSnapshotRefRejected)
+	}
+
+	switch {
+	case existing == nil && replace:
+		return fmt.Errorf("%s %s does not exist: %w", refType, name, errSnapshotRefRejected)
+	case existing != nil && !replace:
+		return fmt.Errorf("%s %s already exists: %w", existing.SnapshotRefType, name, errSnapshotRefRejected)
+	case existing != nil && existing.SnapshotRefType != refType:
+		return fmt.Errorf("%s is a %s and not a %s: %w", name, existing.SnapshotRefType, refType, errSnapshotRefRejected)
+	}
+
+	return nil
+}
+
+// checkFastForward validates that the branch at snapshot from can be fast-forwarded to snapshot to, which
+// requires from to be an ancestor of to.
+func checkFastForward(snapshots []Snapshot, from int64, to int64) error {
+	for _, ancestor := range snapshotAncestors(snapshots, to) {
+		if ancestor.SnapshotId == from {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("snapshot %d is not an ancestor of snapshot %d, the branch can not be fast-forwarded: %w", from, to, errSnapshotRefRejected)
+}
+
+// protectedSnapshots returns the snapshots committed before cutoff which are kept by expire_snapshots
+// because a tag or the head of a branch other than main points to them. Refs older than their max ref age
+// are removed by expire_snapshots and do not protect their snapshot.
+func protectedSnapshots(snapshots []Snapshot, refs []SnapshotRef, cutoff time.Time, now time.Time) []ProtectedSnapshot {
+	refsBySnapshot := make(map[int64][]SnapshotRef)
+	for _, ref := range refs {
+		if ref.Name == table.MainBranch {
+			continue
+		}
+
+		refsBySnapshot[ref.SnapshotId] = append(refsBySnapshot[ref.SnapshotId], ref)
+	}
+
+	protected := make([]ProtectedSnapshot, 0)
+	for _, snapshot := range snapshots {
+		if !snapshot.CommittedAt.Before(cutoff) {
+			continue
+		}
+
+		names := make([]string, 0)
+		for _, ref := range refsBySnapshot[snapshot.SnapshotId] {
+			if ref.MaxRefAgeMs != nil && snapshot.CommittedAt.Before(now.Add(-time.Duration(*ref.MaxRefAgeMs)*time.Millisecond)) {
+				continue
+			}
+
+			names = append(names, ref.Name)
+		}
+
+		if len(names) == 0 {
+			continue
+		}
+
+		sort.Strings(names)
+		protected = append(protected, ProtectedSnapshot{
+			SnapshotID:  snapshot.SnapshotId,
+			CommittedAt: snapshot.CommittedAt,
+			Refs:        names,
+		})
+	}
+
+	sort.Slice(protected, func(i, j int) bool {
+		return protected[i].CommittedAt.Before(protected[j].CommittedAt)
+	})
+
+	return protected
+}
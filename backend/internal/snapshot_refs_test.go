@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/iceberg-go/table"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRefsFromMetadata(t *testing.T) {
+	maxRefAgeMs := int64(86400000)
+	metadata := &testTableMetadata{refs: map[string]table.SnapshotRef{
+		"before-backfill": {SnapshotID: 1, SnapshotRefType: table.TagRef, MaxRefAgeMs: &maxRefAgeMs},
+		"main":            {SnapshotID: 3, SnapshotRefType: table.BranchRef},
+		"audit":           {SnapshotID: 2, SnapshotRefType: table.BranchRef},
+	}}
+
+	refs := snapshotRefsFromMetadata(metadata)
+	require.Equal(t, []SnapshotRef{
+		{Name: "audit", Type: "branch", SnapshotId: 2},
+		{Name: "main", Type: "branch", SnapshotId: 3},
+		{Name: "before-backfill", Type: "tag", SnapshotId: 1, MaxRefAgeMs: &maxRefAgeMs},
+	}, refs)
+
+	ref, ok := snapshotRefByName(metadata, "audit")
+	require.True(t, ok)
+	require.Equal(t, int64(2), ref.SnapshotID)
+}
+
+func TestCheckSetSnapshotRef(t *testing.T) {
+	minSnapshotsToKeep := 2
+	tag := &table.SnapshotRef{SnapshotID: 1, SnapshotRefType: table.TagRef}
+
+	require.NoError(t, checkSetSnapshotRef(nil, "before-backfill", table.TagRef, SnapshotRefRetention{}, false))
+	require.NoError(t, checkSetSnapshotRef(tag, "before-backfill", table.TagRef, SnapshotRefRetention{}, true))
+	require.NoError(t, checkSetSnapshotRef(nil, "audit", table.BranchRef, SnapshotRefRetention{MinSnapshotsToKeep: &minSnapshotsToKeep}, false))
+
+	for _, err := range []error{
+		checkSetSnapshotRef(nil, "", table.TagRef, SnapshotRefRetention{}, false),
+		checkSetSnapshotRef(nil, table.MainBranch, table.BranchRef, SnapshotRefRetention{}, false),
+		checkSetSnapshotRef(nil, "before-backfill", table.TagRef, SnapshotRefRetention{MinSnapshotsToKeep: &minSnapshotsToKeep}, false),
+		checkSetSnapshotRef(nil, "before-backfill", table.TagRef, SnapshotRefRetention{}, true),
+		checkSetSnapshotRef(tag, "before-backfill", table.TagRef, SnapshotRefRetention{}, false),
+		checkSetSnapshotRef(tag, "before-backfill", table.BranchRef, SnapshotRefRetention{}, true),
+	} {
+		require.ErrorIs(t, err, errSnapshotRefRejected)
+	}
+}
+
+func TestCheckFastForward(t *testing.T) {
+	parent := func(id int64) *int64 {
+		return &id
+	}
+
+	// 4 was committed to a branch created at 2 while main moved on to 3
+	snapshots := []Snapshot{
+		{SnapshotId: 1},
+		{SnapshotId: 2, ParentId: parent(1)},
+		{SnapshotId: 3, ParentId: parent(2)},
+		{SnapshotId: 4, ParentId: parent(2)},
+	}
+
+	require.NoError(t, checkFastForward(snapshots, 2, 4))
+	require.NoError(t, checkFastForward(snapshots, 1, 3))
+	require.NoError(t, checkFastForward(snapshots, 3, 3))
+	require.ErrorIs(t, checkFastForward(snapshots, 3, 4), errSnapshotRefRejected)
+}
+
+func TestProtectedSnapshots(t *testing.T) {
+	now := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time {
+		return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC)
+	}
+	maxRefAgeMs := int64(3 * 24 * time.Hour / time.Millisecond)
+
+	snapshots := []Snapshot{
+		{SnapshotId: 1, CommittedAt: day(1)},
+		{SnapshotId: 2, CommittedAt: day(5)},
+		{SnapshotId: 3, CommittedAt: day(14)},
+		{SnapshotId: 4, CommittedAt: day(15)},
+	}
+	refs := []SnapshotRef{
+		{Name: "main", Type: "branch", SnapshotId: 4},
+		{Name: "before-backfill", Type: "tag", SnapshotId: 2},
+		{Name: "audit", Type: "branch", SnapshotId: 2},
+		{Name: "short-lived", Type: "tag", SnapshotId: 1, MaxRefAgeMs: &maxRefAgeMs},
+		{Name: "recent", Type: "tag", SnapshotId: 3},
+	}
+
+	require.Equal(t, []ProtectedSnapshot{
+		{SnapshotID: 2, CommittedAt: day(5), Refs: []string{"audit", "before-backfill"}},
+	}, protectedSnapshots(snapshots, refs, day(9), now))
+}
@@ -66,8 +66,8 @@ func ReadSparkSettings(config cfg.Config) (*SparkSettings, error) {
 	return settings, nil
 }
 
-func BuildTaskProcedureCallbackURL(host string, taskID int64) string {
+func BuildTaskProcedureCallbackURL(host string, claim TaskClaim) string {
 	host = strings.TrimRight(strings.TrimSpace(host), "/")
 
-	return fmt.Sprintf("%s/api/tasks/callback/%d/result", host, taskID)
+	return fmt.Sprintf("%s/api/tasks/callback/%d/result?claim=%d", host, claim.TaskId, claim.Claim)
 }
@@ -2,6 +2,17 @@ package internal
 
 import "strings"
 
+// sqlDialect holds the quoting rules of the engines maintenance queries are rendered for.
+type sqlDialect struct {
+	quoteIdent   func(string) string
+	quoteLiteral func(string) string
+}
+
+var (
+	trinoDialect = sqlDialect{quoteIdent: quoteIdent, quoteLiteral: quoteLiteral}
+	sparkDialect = sqlDialect{quoteIdent: quoteSparkIdent, quoteLiteral: quoteSparkLiteral}
+)
+
 func quoteIdent(ident string) string {
 	return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
 }
@@ -13,3 +24,21 @@ func quoteLiteral(literal string) string {
 func qualifiedTableName(catalog, schema, table string) string {
 	return quoteIdent(catalog) + "." + quoteIdent(schema) + "." + quoteIdent(table)
 }
+
+func quoteSparkIdent(ident string) string {
+	return "`" + strings.ReplaceAll(ident, "`", "``") + "`"
+}
+
+func quoteSparkLiteral(literal string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(literal) + `"`
+}
+
+// quoteIdentPath quotes every part of a dot-separated column path, e.g. a nested struct field.
+func quoteIdentPath(path string, quote func(string) string) string {
+	parts := strings.Split(path, ".")
+	for i := range parts {
+		parts[i] = quote(parts[i])
+	}
+
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,352 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gosoline-project/sqlc"
+)
+
+type TaskDependency struct {
+	TaskId          int64 `db:"task_id"`
+	DependsOnTaskId int64 `db:"depends_on_task_id"`
+}
+
+// markTaskSkippedIfDependencyFailedInTx verifies that all dependencies of a task about to be enqueued
+// exist. If one of them already ended without success, the task can never run and is marked as skipped.
+func (s *ServiceTaskQueue) markTaskSkippedIfDependencyFailedInTx(ctx sqlc.Tx, task *Task, dependsOn []int64) error {
+	var tasks []Task
+
+	sel := ctx.Q().From("tasks").Where(sqlc.Col("id").In(int64sToAny(dependsOn)...))
+	if err := sel.Select(ctx, &tasks); err != nil {
+		return fmt.Errorf("could not load dependency tasks: %w", err)
+	}
+
+	found := make(map[int64]*Task, len(tasks))
+	for i := range tasks {
+		found[tasks[i].Id] = &tasks[i]
+	}
+
+	for _, id := range dependsOn {
+		upstream, ok := found[id]
+		if !ok {
+			return fmt.Errorf("dependency task %d not found", id)
+		}
+
+		if !isTaskStatusUnsuccessful(upstream.Status) {
+			continue
+		}
+
+		now := time.Now()
+		reason := taskDependencySkipReason(upstream)
+
+		task.Status = taskStatusSkipped
+		task.FinishedAt = &now
+		task.ErrorMessage = &reason
+
+		return nil
+	}
+
+	return nil
+}
+
+func (s *ServiceTaskQueue) insertTaskDependenciesInTx(ctx sqlc.Tx, taskID int64, dependsOn []int64) error {
+	for _, dependsOnTaskID := range dependsOn {
+		ins := ctx.Q().Into("task_dependencies").Records(&TaskDependency{
+			TaskId:          taskID,
+			DependsOnTaskId: dependsOnTaskID,
+		})
+
+		if _, err := ins.Exec(ctx); err != nil {
+			return fmt.Errorf("could not add dependency of task %d on task %d: %w", taskID, dependsOnTaskID, err)
+		}
+	}
+
+	return nil
+}
+
+// mergeTaskDependenciesInTx lets an existing queued task additionally wait for the given tasks, so a
+// deduplicated enqueue keeps the ordering its caller asked for. It returns false without changes if the
+// existing task already runs, a dependency already ended without success or a dependency itself waits for
+// the existing task. The caller has to enqueue a new task in that case.
+func (s *ServiceTaskQueue) mergeTaskDependenciesInTx(ctx sqlc.Tx, existing *Task, dependsOn []int64) (bool, error) {
+	var tasks []Task
+	var current []TaskDependency
+
+	if existing.Status != taskStatusQueued {
+		return false, nil
+	}
+
+	sel := ctx.Q().From("tasks").Where(sqlc.Col("id").In(int64sToAny(dependsOn)...))
+	if err := sel.Select(ctx, &tasks); err != nil {
+		return false, fmt.Errorf("could not load dependency tasks: %w", err)
+	}
+
+	found := make(map[int64]*Task, len(tasks))
+	for i := range tasks {
+		found[tasks[i].Id] = &tasks[i]
+	}
+
+	for _, id := range dependsOn {
+		upstream, ok := found[id]
+		if !ok {
+			return false, fmt.Errorf("dependency task %d not found", id)
+		}
+
+		if upstream.Id == existing.Id || isTaskStatusUnsuccessful(upstream.Status) {
+			return false, nil
+		}
+	}
+
+	upstreamIDs, err := s.upstreamTaskIDsInTx(ctx, dependsOn)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := upstreamIDs[existing.Id]; ok {
+		return false, nil
+	}
+
+	sel = ctx.Q().From("task_dependencies").Where(sqlc.Eq{"task_id": existing.Id})
+	if err = sel.Select(ctx, &current); err != nil {
+		return false, fmt.Errorf("could not load dependencies of task %d: %w", existing.Id, err)
+	}
+
+	known := make(map[int64]struct{}, len(current)+len(dependsOn))
+	for _, dependency := range current {
+		known[dependency.DependsOnTaskId] = struct{}{}
+	}
+
+	missing := make([]int64, 0, len(dependsOn))
+	for _, id := range dependsOn {
+		if _, ok := known[id]; ok {
+			continue
+		}
+
+		known[id] = struct{}{}
+		missing = append(missing, id)
+	}
+
+	if err = s.insertTaskDependenciesInTx(ctx, existing.Id, missing); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// upstreamTaskIDsInTx returns the ids of all tasks the given tasks directly or transitively depend on.
+func (s *ServiceTaskQueue) upstreamTaskIDsInTx(ctx sqlc.Tx, taskIDs []int64) (map[int64]struct{}, error) {
+	upstream := make(map[int64]struct{})
+	frontier := taskIDs
+
+	for len(frontier) > 0 {
+		var dependencies []TaskDependency
+
+		sel := ctx.Q().From("task_dependencies").Where(sqlc.Col("task_id").In(int64sToAny(frontier)...))
+		if err := sel.Select(ctx, &dependencies); err != nil {
+			return nil, fmt.Errorf("could not load task dependencies: %w", err)
+		}
+
+		frontier = nil
+		for _, dependency := range dependencies {
+			if _, ok := upstream[dependency.DependsOnTaskId]; ok {
+				continue
+			}
+
+			upstream[dependency.DependsOnTaskId] = struct{}{}
+			frontier = append(frontier, dependency.DependsOnTaskId)
+		}
+	}
+
+	return upstream, nil
+}
+
+// redirectTaskDependenciesInTx lets all tasks waiting on a failed task wait on its retry instead.
+func (s *ServiceTaskQueue) redirectTaskDependenciesInTx(ctx sqlc.Tx, fromTaskID int64, toTaskID int64) error {
+	upd := ctx.Q().Update("task_dependencies").Set("depends_on_task_id", toTaskID).Where(sqlc.Eq{"depends_on_task_id": fromTaskID})
+	if _, err := upd.Exec(ctx); err != nil {
+		return fmt.Errorf("could not redirect dependencies from task %d to task %d: %w", fromTaskID, toTaskID, err)
+	}
+
+	return nil
+}
+
+// blockedTaskIDsInTx returns the ids of the given tasks which still wait for at least one of their
+// dependencies to succeed.
+func (s *ServiceTaskQueue) blockedTaskIDsInTx(ctx sqlc.Tx, tasks []Task) (map[int64]struct{}, error) {
+	var dependencies []TaskDependency
+	var upstreamTasks []Task
+
+	if len(tasks) == 0 {
+		return map[int64]struct{}{}, nil
+	}
+
+	taskIDs := make([]int64, len(tasks))
+	for i := range tasks {
+		taskIDs[i] = tasks[i].Id
+	}
+
+	sel := ctx.Q().From("task_dependencies").Where(sqlc.Col("task_id").In(int64sToAny(taskIDs)...))
+	if err := sel.Select(ctx, &dependencies); err != nil {
+		return nil, fmt.Errorf("could not load task dependencies: %w", err)
+	}
+
+	if len(dependencies) == 0 {
+		return map[int64]struct{}{}, nil
+	}
+
+	upstreamIDs := make([]int64, len(dependencies))
+	for i, dependency := range dependencies {
+		upstreamIDs[i] = dependency.DependsOnTaskId
+	}
+
+	sel = ctx.Q().From("tasks").Where(sqlc.Col("id").In(int64sToAny(upstreamIDs)...))
+	if err := sel.Select(ctx, &upstreamTasks); err != nil {
+		return nil, fmt.Errorf("could not load dependency tasks: %w", err)
+	}
+
+	statuses := make(map[int64]string, len(upstreamTasks))
+	for _, task := range upstreamTasks {
+		statuses[task.Id] = task.Status
+	}
+
+	return blockedTaskIDs(dependencies, statuses), nil
+}
+
+// blockedTaskIDs returns the ids of all tasks with at least one dependency which did not succeed yet.
+func blockedTaskIDs(dependencies []TaskDependency, statuses map[int64]string) map[int64]struct{} {
+	blocked := make(map[int64]struct{})
+
+	for _, dependency := range dependencies {
+		if statuses[dependency.DependsOnTaskId] != taskStatusSuccess {
+			blocked[dependency.TaskId] = struct{}{}
+		}
+	}
+
+	return blocked
+}
+
+// SkipDependentTasks marks all queued tasks depending on the given unsuccessful task as skipped.
+func (s *ServiceTaskQueue) SkipDependentTasks(ctx context.Context, upstream *Task) error {
+	var skippedTaskIDs []int64
+
+	err := s.sqlClient.WithTx(ctx, func(cttx sqlc.Tx) error {
+		var err error
+		skippedTaskIDs, err = s.skipDependentTasksInTx(cttx, upstream)
+
+		return err
+	}, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return fmt.Errorf("could not skip tasks depending on task %d: %w", upstream.Id, err)
+	}
+
+	if len(skippedTaskIDs) > 0 {
+		s.logger.Info(ctx, "skipped %d tasks depending on task %d which ended with status %s", len(skippedTaskIDs), upstream.Id, upstream.Status)
+		s.publishTaskEvents(ctx, TaskEventCompleted, skippedTaskIDs...)
+	}
+
+	return nil
+}
+
+// skipDependentTasksInTx marks the queued tasks depending on the upstream task as skipped, and
+// transitively all queued tasks depending on those. It returns the ids of the skipped tasks.
+func (s *ServiceTaskQueue) skipDependentTasksInTx(ctx sqlc.Tx, upstream *Task) ([]int64, error) {
+	var skipped []int64
+
+	reason := taskDependencySkipReason(upstream)
+	pending := []int64{upstream.Id}
+
+	for len(pending) > 0 {
+		var dependencies []TaskDependency
+
+		id := pending[0]
+		pending = pending[1:]
+
+		sel := ctx.Q().From("task_dependencies").Where(sqlc.Eq{"depends_on_task_id": id})
+		if err := sel.Select(ctx, &dependencies); err != nil {
+			return nil, fmt.Errorf("could not load tasks depending on task %d: %w", id, err)
+		}
+
+		for _, dependency := range dependencies {
+			ok, err := s.skipTaskInTx(ctx, dependency.TaskId, reason)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				skipped = append(skipped, dependency.TaskId)
+				pending = append(pending, dependency.TaskId)
+			}
+		}
+	}
+
+	return skipped, nil
+}
+
+func (s *ServiceTaskQueue) skipTaskInTx(ctx sqlc.Tx, id int64, reason string) (bool, error) {
+	now := time.Now()
+	upd := ctx.Q().Update("tasks").
+		Set("status", taskStatusSkipped).
+		Set("finished_at", &now).
+		Set("error_message", &reason).
+		Where(sqlc.Eq{"id": id, "status": taskStatusQueued})
+
+	res, err := upd.Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("could not skip task %d: %w", id, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not get rows affected when skipping task %d: %w", id, err)
+	}
+
+	return affected > 0, nil
+}
+
+// GetTaskDependencies returns the ids of the tasks each of the given tasks depends on.
+func (s *ServiceTaskQueue) GetTaskDependencies(ctx context.Context, taskIDs []int64) (map[int64][]int64, error) {
+	var dependencies []TaskDependency
+
+	result := make(map[int64][]int64)
+	if len(taskIDs) == 0 {
+		return result, nil
+	}
+
+	sel := s.sqlClient.Q().From("task_dependencies").
+		Where(sqlc.Col("task_id").In(int64sToAny(taskIDs)...)).
+		OrderBy(sqlc.Col("depends_on_task_id").Asc())
+	if err := sel.Select(ctx, &dependencies); err != nil {
+		return nil, fmt.Errorf("could not load task dependencies: %w", err)
+	}
+
+	for _, dependency := range dependencies {
+		result[dependency.TaskId] = append(result[dependency.TaskId], dependency.DependsOnTaskId)
+	}
+
+	return result, nil
+}
+
+func isTaskStatusUnsuccessful(status string) bool {
+	return status == taskStatusError || status == taskStatusCancelled || status == taskStatusSkipped
+}
+
+func taskDependencySkipReason(upstream *Task) string {
+	reason := fmt.Sprintf("dependency task %d (%s) ended with status %s", upstream.Id, upstream.Kind, upstream.Status)
+	if upstream.ErrorMessage != nil && *upstream.ErrorMessage != "" {
+		reason = fmt.Sprintf("%s: %s", reason, *upstream.ErrorMessage)
+	}
+
+	return reason
+}
+
+func int64sToAny(values []int64) []any {
+	result := make([]any, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+
+	return result
+}
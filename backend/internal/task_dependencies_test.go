@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockedTaskIDs(t *testing.T) {
+	dependencies := []TaskDependency{
+		{TaskId: 10, DependsOnTaskId: 1},
+		{TaskId: 10, DependsOnTaskId: 2},
+		{TaskId: 11, DependsOnTaskId: 1},
+		{TaskId: 12, DependsOnTaskId: 3},
+	}
+
+	statuses := map[int64]string{
+		1: taskStatusSuccess,
+		2: taskStatusRunning,
+	}
+
+	blocked := blockedTaskIDs(dependencies, statuses)
+
+	require.Equal(t, map[int64]struct{}{10: {}, 12: {}}, blocked)
+}
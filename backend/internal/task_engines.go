@@ -2,35 +2,27 @@ package internal
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/justtrackio/gosoline/pkg/cfg"
 )
 
-type TaskEngineSettings struct {
-	ExpireSnapshots   string `cfg:"expire_snapshots"`
-	RemoveOrphanFiles string `cfg:"remove_orphan_files"`
-	Optimize          string `cfg:"optimize"`
-}
-
 type TaskEngineResolver struct {
 	engines map[TaskKind]TaskEngine
 }
 
+// NewTaskEngineResolver reads the engine of every task kind from tasks.engines.<kind>, falling back to the
+// default engine of the kind.
 func NewTaskEngineResolver(config cfg.Config) (*TaskEngineResolver, error) {
-	settings := TaskEngineSettings{
-		ExpireSnapshots:   string(TaskEngineTrino),
-		RemoveOrphanFiles: string(TaskEngineTrino),
-		Optimize:          string(TaskEngineSpark),
-	}
+	engines := make(map[TaskKind]TaskEngine, len(taskKindDescriptors))
 
-	if err := config.UnmarshalKey("tasks.engines", &settings); err != nil {
-		return nil, fmt.Errorf("could not unmarshal task engine settings: %w", err)
-	}
+	for _, descriptor := range taskKindDescriptors {
+		engine, err := config.GetString(fmt.Sprintf("tasks.engines.%s", descriptor.kind), string(descriptor.defaultEngine))
+		if err != nil {
+			return nil, fmt.Errorf("could not read engine of task kind %s: %w", descriptor.kind, err)
+		}
 
-	engines := map[TaskKind]TaskEngine{
-		TaskKindExpireSnapshots:   TaskEngine(settings.ExpireSnapshots),
-		TaskKindRemoveOrphanFiles: TaskEngine(settings.RemoveOrphanFiles),
-		TaskKindOptimize:          TaskEngine(settings.Optimize),
+		engines[descriptor.kind] = TaskEngine(engine)
 	}
 
 	for kind, engine := range engines {
@@ -51,11 +43,29 @@ func (r *TaskEngineResolver) Resolve(kind TaskKind) (TaskEngine, error) {
 	return engine, nil
 }
 
+// KindsForEngine returns the task kinds routed to the engine, in the order of taskKinds.
+func (r *TaskEngineResolver) KindsForEngine(engine TaskEngine) []TaskKind {
+	kinds := make([]TaskKind, 0, len(r.engines))
+
+	for _, kind := range taskKinds {
+		if r.engines[kind] == engine {
+			kinds = append(kinds, kind)
+		}
+	}
+
+	return kinds
+}
+
+// validateTaskEngine checks that the engine is registered and declares support for the task kind.
 func validateTaskEngine(kind TaskKind, engine TaskEngine) error {
-	switch engine {
-	case TaskEngineTrino, TaskEngineSpark:
-		return nil
-	default:
+	registration, ok := lookupMaintenanceExecutor(engine)
+	if !ok {
 		return fmt.Errorf("invalid engine %q configured for task kind %s", engine, kind)
 	}
+
+	if !slices.Contains(registration.Kinds, kind) {
+		return fmt.Errorf("task kind %s is not supported by engine %s", kind, engine)
+	}
+
+	return nil
 }
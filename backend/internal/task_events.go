@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/justtrackio/gosoline/pkg/appctx"
+	"github.com/justtrackio/gosoline/pkg/cfg"
+	"github.com/justtrackio/gosoline/pkg/log"
+)
+
+type TaskEventType string
+
+const (
+	TaskEventEnqueued      TaskEventType = "enqueued"
+	TaskEventClaimed       TaskEventType = "claimed"
+	TaskEventResultUpdated TaskEventType = "result_updated"
+	TaskEventCompleted     TaskEventType = "completed"
+	TaskEventSparkState    TaskEventType = "spark_state"
+)
+
+type TaskEventSettings struct {
+	BufferSize        int           `cfg:"buffer_size" default:"256"`
+	KeepaliveInterval time.Duration `cfg:"keepalive_interval" default:"15s"`
+}
+
+func ReadTaskEventSettings(config cfg.Config) (*TaskEventSettings, error) {
+	settings := &TaskEventSettings{}
+	if err := config.UnmarshalKey("tasks.events", settings); err != nil {
+		return nil, fmt.Errorf("could not unmarshal task event settings: %w", err)
+	}
+
+	if settings.BufferSize < 1 {
+		return nil, fmt.Errorf("tasks.events.buffer_size must be at least 1")
+	}
+
+	if settings.KeepaliveInterval <= 0 {
+		return nil, fmt.Errorf("tasks.events.keepalive_interval must be positive")
+	}
+
+	return settings, nil
+}
+
+type TaskEvent struct {
+	Type     TaskEventType  `json:"type"`
+	TaskId   int64          `json:"task_id"`
+	Database string         `json:"database"`
+	Table    string         `json:"table"`
+	Kind     string         `json:"kind,omitempty"`
+	Status   string         `json:"status,omitempty"`
+	Result   map[string]any `json:"result,omitempty"`
+	Time     time.Time      `json:"time"`
+}
+
+func newTaskEvent(eventType TaskEventType, task *Task) TaskEvent {
+	return TaskEvent{
+		Type:     eventType,
+		TaskId:   task.Id,
+		Database: task.Database,
+		Table:    task.Table,
+		Kind:     task.Kind,
+		Status:   task.Status,
+		Time:     time.Now(),
+	}
+}
+
+// TaskEventFilter restricts a subscription to the events of a database and optionally a single table.
+// Empty fields match everything.
+type TaskEventFilter struct {
+	Database string
+	Table    string
+}
+
+func (f TaskEventFilter) matches(event TaskEvent) bool {
+	if f.Database != "" && f.Database != event.Database {
+		return false
+	}
+
+	if f.Table != "" && f.Table != event.Table {
+		return false
+	}
+
+	return true
+}
+
+type TaskEventSubscription struct {
+	filter TaskEventFilter
+	events chan TaskEvent
+}
+
+func (s *TaskEventSubscription) Events() <-chan TaskEvent {
+	return s.events
+}
+
+// TaskEventBus fans task lifecycle events out to all subscribers of this process. Publishing never
+// blocks: if a subscriber does not keep up, events for it are dropped.
+type TaskEventBus struct {
+	logger      log.Logger
+	settings    *TaskEventSettings
+	mutex       sync.RWMutex
+	subscribers map[*TaskEventSubscription]struct{}
+}
+
+type taskEventBusCtxKey struct{}
+
+func ProvideTaskEventBus(ctx context.Context, config cfg.Config, logger log.Logger) (*TaskEventBus, error) {
+	return appctx.Provide(ctx, taskEventBusCtxKey{}, func() (*TaskEventBus, error) {
+		settings, err := ReadTaskEventSettings(config)
+		if err != nil {
+			return nil, fmt.Errorf("could not read task event settings: %w", err)
+		}
+
+		return newTaskEventBus(logger, settings), nil
+	})
+}
+
+func newTaskEventBus(logger log.Logger, settings *TaskEventSettings) *TaskEventBus {
+	return &TaskEventBus{
+		logger:      logger.WithChannel("task_events"),
+		settings:    settings,
+		subscribers: make(map[*TaskEventSubscription]struct{}),
+	}
+}
+
+func (b *TaskEventBus) Settings() *TaskEventSettings {
+	return b.settings
+}
+
+func (b *TaskEventBus) Subscribe(filter TaskEventFilter) *TaskEventSubscription {
+	subscription := &TaskEventSubscription{
+		filter: filter,
+		events: make(chan TaskEvent, b.settings.BufferSize),
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.subscribers[subscription] = struct{}{}
+
+	return subscription
+}
+
+func (b *TaskEventBus) Unsubscribe(subscription *TaskEventSubscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.subscribers[subscription]; !ok {
+		return
+	}
+
+	delete(b.subscribers, subscription)
+	close(subscription.events)
+}
+
+func (b *TaskEventBus) Publish(ctx context.Context, events ...TaskEvent) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for _, event := range events {
+		for subscription := range b.subscribers {
+			if !subscription.filter.matches(event) {
+				continue
+			}
+
+			select {
+			case subscription.events <- event:
+			default:
+				b.logger.Warn(ctx, "dropped %s event of task %d because a subscriber is not keeping up", event.Type, event.TaskId)
+			}
+		}
+	}
+}
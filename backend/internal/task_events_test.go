@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskEventBusDeliversMatchingEvents(t *testing.T) {
+	bus := &TaskEventBus{
+		settings:    &TaskEventSettings{BufferSize: 10},
+		subscribers: make(map[*TaskEventSubscription]struct{}),
+	}
+
+	all := bus.Subscribe(TaskEventFilter{})
+	events := bus.Subscribe(TaskEventFilter{Database: "main", Table: "events"})
+
+	bus.Publish(context.Background(),
+		TaskEvent{Type: TaskEventEnqueued, TaskId: 1, Database: "main", Table: "events"},
+		TaskEvent{Type: TaskEventEnqueued, TaskId: 2, Database: "main", Table: "installs"},
+		TaskEvent{Type: TaskEventEnqueued, TaskId: 3, Database: "other", Table: "events"},
+	)
+
+	require.Len(t, all.Events(), 3)
+	require.Len(t, events.Events(), 1)
+	require.Equal(t, int64(1), (<-events.Events()).TaskId)
+
+	bus.Unsubscribe(events)
+	bus.Publish(context.Background(), TaskEvent{Type: TaskEventClaimed, TaskId: 1, Database: "main", Table: "events"})
+
+	_, ok := <-events.Events()
+	require.False(t, ok)
+	require.Len(t, all.Events(), 4)
+}
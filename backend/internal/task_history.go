@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/justtrackio/gosoline/pkg/cfg"
+	gosoS3 "github.com/justtrackio/gosoline/pkg/cloud/aws/s3"
+	"github.com/justtrackio/gosoline/pkg/log"
+)
+
+const (
+	taskArchiveModeNone  = "none"
+	taskArchiveModeLocal = "local"
+	taskArchiveModeS3    = "s3"
+)
+
+// TaskHistorySettings controls how long finished tasks are kept and where pruned tasks are archived.
+// A retention of zero keeps tasks of that status forever.
+type TaskHistorySettings struct {
+	Enabled   bool                         `cfg:"enabled" default:"false"`
+	Cron      string                       `cfg:"cron" default:"15 * * * *"`
+	BatchSize int                          `cfg:"batch_size" default:"500"`
+	Retention TaskHistoryRetentionSettings `cfg:"retention"`
+	Archive   TaskHistoryArchiveSettings   `cfg:"archive"`
+}
+
+type TaskHistoryRetentionSettings struct {
+	Success   time.Duration `cfg:"success" default:"720h"`
+	Error     time.Duration `cfg:"error" default:"2160h"`
+	Cancelled time.Duration `cfg:"cancelled" default:"720h"`
+	Skipped   time.Duration `cfg:"skipped" default:"720h"`
+}
+
+type TaskHistoryArchiveSettings struct {
+	Mode       string `cfg:"mode" default:"none"`
+	Directory  string `cfg:"directory"`
+	ClientName string `cfg:"client_name" default:"default"`
+	Bucket     string `cfg:"bucket"`
+	Prefix     string `cfg:"prefix" default:"task-history"`
+}
+
+func ReadTaskHistorySettings(config cfg.Config) (*TaskHistorySettings, error) {
+	settings := &TaskHistorySettings{}
+	if err := config.UnmarshalKey("tasks.history", settings); err != nil {
+		return nil, fmt.Errorf("could not unmarshal task history settings: %w", err)
+	}
+
+	if _, err := parseStandardCronSchedule(settings.Cron); err != nil {
+		return nil, fmt.Errorf("invalid task history cron expression: %w", err)
+	}
+
+	if settings.BatchSize < 1 {
+		return nil, fmt.Errorf("tasks.history.batch_size must be at least 1")
+	}
+
+	for status, retention := range settings.Retention.byStatus() {
+		if retention < 0 {
+			return nil, fmt.Errorf("tasks.history.retention.%s must not be negative", status)
+		}
+	}
+
+	switch settings.Archive.Mode {
+	case taskArchiveModeNone:
+	case taskArchiveModeLocal:
+		if settings.Archive.Directory == "" {
+			return nil, fmt.Errorf("tasks.history.archive.directory is required for archive mode %s", taskArchiveModeLocal)
+		}
+	case taskArchiveModeS3:
+		if settings.Archive.Bucket == "" {
+			return nil, fmt.Errorf("tasks.history.archive.bucket is required for archive mode %s", taskArchiveModeS3)
+		}
+	default:
+		return nil, fmt.Errorf("invalid tasks.history.archive.mode %q, expected %s, %s or %s", settings.Archive.Mode, taskArchiveModeNone, taskArchiveModeLocal, taskArchiveModeS3)
+	}
+
+	return settings, nil
+}
+
+func (s TaskHistoryRetentionSettings) byStatus() map[string]time.Duration {
+	return map[string]time.Duration{
+		taskStatusSuccess:   s.Success,
+		taskStatusError:     s.Error,
+		taskStatusCancelled: s.Cancelled,
+		taskStatusSkipped:   s.Skipped,
+	}
+}
+
+func NewTaskArchiver(ctx context.Context, config cfg.Config, logger log.Logger, settings TaskHistoryArchiveSettings) (TaskArchiver, error) {
+	switch settings.Mode {
+	case taskArchiveModeLocal:
+		return &localTaskArchiver{directory: settings.Directory}, nil
+	case taskArchiveModeS3:
+		s3Client, err := gosoS3.ProvideClient(ctx, config, logger, settings.ClientName)
+		if err != nil {
+			return nil, fmt.Errorf("could not create s3 client: %w", err)
+		}
+
+		return &s3TaskArchiver{
+			client: s3Client,
+			bucket: settings.Bucket,
+			prefix: strings.Trim(settings.Prefix, "/"),
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+type localTaskArchiver struct {
+	directory string
+}
+
+func (a *localTaskArchiver) Archive(_ context.Context, tasks []Task) (string, error) {
+	body, err := encodeTaskArchive(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	name := taskArchiveObjectName(tasks, time.Now().UTC())
+	target := filepath.Join(a.directory, filepath.FromSlash(name))
+
+	if err = os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", fmt.Errorf("could not create task archive directory: %w", err)
+	}
+
+	if err = os.WriteFile(target, body, 0o644); err != nil {
+		return "", fmt.Errorf("could not write task archive %s: %w", target, err)
+	}
+
+	return target, nil
+}
+
+type s3TaskArchiver struct {
+	client *awsS3.Client
+	bucket string
+	prefix string
+}
+
+func (a *s3TaskArchiver) Archive(ctx context.Context, tasks []Task) (string, error) {
+	body, err := encodeTaskArchive(tasks)
+	if err != nil {
+		return "", err
+	}
+
+	key := path.Join(a.prefix, taskArchiveObjectName(tasks, time.Now().UTC()))
+
+	_, err = a.client.PutObject(ctx, &awsS3.PutObjectInput{
+		Bucket:      aws.String(a.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/x-ndjson"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not upload task archive to s3://%s/%s: %w", a.bucket, key, err)
+	}
+
+	return fmt.Sprintf("s3://%s/%s", a.bucket, key), nil
+}
+
+// encodeTaskArchive encodes the tasks as json lines, one task dto per line.
+func encodeTaskArchive(tasks []Task) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+
+	for _, task := range tasks {
+		if err := encoder.Encode(newTaskDTO(task)); err != nil {
+			return nil, fmt.Errorf("could not encode task %d for archival: %w", task.Id, err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// taskArchiveObjectName partitions archives by the day they were written and names them after the
+// range of task ids they contain, e.g. 2026/10/16/tasks-20261016T101500Z-12-511.jsonl.
+func taskArchiveObjectName(tasks []Task, now time.Time) string {
+	return fmt.Sprintf("%s/tasks-%s-%d-%d.jsonl", now.Format("2006/01/02"), now.Format("20060102T150405Z"), tasks[0].Id, tasks[len(tasks)-1].Id)
+}
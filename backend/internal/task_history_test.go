@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskArchiveObjectName(t *testing.T) {
+	tasks := []Task{{Id: 12}, {Id: 40}, {Id: 511}}
+	now := time.Date(2026, 10, 16, 10, 15, 0, 0, time.UTC)
+
+	require.Equal(t, "2026/10/16/tasks-20261016T101500Z-12-511.jsonl", taskArchiveObjectName(tasks, now))
+}
+
+func TestTaskHistoryRetentionByStatus(t *testing.T) {
+	retention := TaskHistoryRetentionSettings{
+		Success: 720 * time.Hour,
+		Error:   2160 * time.Hour,
+	}
+
+	require.Equal(t, map[string]time.Duration{
+		taskStatusSuccess:   720 * time.Hour,
+		taskStatusError:     2160 * time.Hour,
+		taskStatusCancelled: 0,
+		taskStatusSkipped:   0,
+	}, retention.byStatus())
+}
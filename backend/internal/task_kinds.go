@@ -0,0 +1,59 @@
+package internal
+
+import "fmt"
+
+// taskKindDescriptor describes a task kind the queue runs. The default engine applies unless
+// tasks.engines.<kind> configures another one. Kinds with an input builder take no parameters besides the
+// table and are enqueued and planned through the generic EnqueueTableTask and PlanTableTask paths, all
+// others have their own Enqueue and Plan functions on ServiceTasks.
+type taskKindDescriptor struct {
+	kind          TaskKind
+	defaultEngine TaskEngine
+	input         func(profile MaintenanceProfile) map[string]any
+}
+
+var taskKindDescriptors = []taskKindDescriptor{
+	{kind: TaskKindExpireSnapshots, defaultEngine: TaskEngineTrino},
+	{kind: TaskKindRemoveOrphanFiles, defaultEngine: TaskEngineTrino},
+	{kind: TaskKindOptimize, defaultEngine: TaskEngineSpark},
+	{kind: TaskKindRewriteManifests, defaultEngine: TaskEngineTrino, input: emptyTaskInput},
+	{kind: TaskKindRewritePositionDeleteFiles, defaultEngine: TaskEngineSpark, input: emptyTaskInput},
+	{kind: TaskKindCheckFileIntegrity, defaultEngine: TaskEngineTrino, input: emptyTaskInput},
+}
+
+var taskKinds = func() []TaskKind {
+	kinds := make([]TaskKind, 0, len(taskKindDescriptors))
+	for _, descriptor := range taskKindDescriptors {
+		kinds = append(kinds, descriptor.kind)
+	}
+
+	return kinds
+}()
+
+func lookupTaskKindDescriptor(kind TaskKind) (taskKindDescriptor, error) {
+	for _, descriptor := range taskKindDescriptors {
+		if descriptor.kind == kind {
+			return descriptor, nil
+		}
+	}
+
+	return taskKindDescriptor{}, fmt.Errorf("unknown task kind %s", kind)
+}
+
+// lookupTableTaskKind returns the descriptor of a kind which only needs the table, see taskKindDescriptor.
+func lookupTableTaskKind(kind TaskKind) (taskKindDescriptor, error) {
+	descriptor, err := lookupTaskKindDescriptor(kind)
+	if err != nil {
+		return taskKindDescriptor{}, err
+	}
+
+	if descriptor.input == nil {
+		return taskKindDescriptor{}, fmt.Errorf("task kind %s needs parameters besides the table", kind)
+	}
+
+	return descriptor, nil
+}
+
+func emptyTaskInput(MaintenanceProfile) map[string]any {
+	return map[string]any{}
+}
@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupTableTaskKind(t *testing.T) {
+	descriptor, err := lookupTableTaskKind(TaskKindRewriteManifests)
+	require.NoError(t, err)
+	require.Equal(t, TaskEngineTrino, descriptor.defaultEngine)
+	require.Equal(t, map[string]any{}, descriptor.input(MaintenanceProfile{}))
+
+	_, err = lookupTableTaskKind(TaskKindExpireSnapshots)
+	require.ErrorContains(t, err, "needs parameters besides the table")
+
+	_, err = lookupTableTaskKind(TaskKindRollback)
+	require.ErrorContains(t, err, "unknown task kind rollback")
+}
+
+func TestTaskKindsFollowDescriptors(t *testing.T) {
+	require.Len(t, taskKinds, len(taskKindDescriptors))
+
+	for i, descriptor := range taskKindDescriptors {
+		require.Equal(t, descriptor.kind, taskKinds[i])
+		require.NoError(t, validateTaskEngine(descriptor.kind, descriptor.defaultEngine))
+	}
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/justtrackio/gosoline/pkg/cfg"
+)
+
+const (
+	taskLeaseExpiredActionRequeue = "requeue"
+	taskLeaseExpiredActionFail    = "fail"
+)
+
+// TaskClaim identifies one claim of a task. The claim number is increased every time a worker claims
+// the task, so the worker of a claim whose lease expired and which was re-queued can no longer
+// complete, heartbeat or update the task once it has been claimed again.
+type TaskClaim struct {
+	TaskId int64
+	Claim  int
+}
+
+// TaskLeaseSettings controls how long a running task may go without a heartbeat before the
+// reaper considers it stale.
+type TaskLeaseSettings struct {
+	Duration          time.Duration `cfg:"duration" default:"5m"`
+	HeartbeatInterval time.Duration `cfg:"heartbeat_interval" default:"30s"`
+	ReaperInterval    time.Duration `cfg:"reaper_interval" default:"1m"`
+	ExpiredAction     string        `cfg:"expired_action" default:"fail"`
+}
+
+func ReadTaskLeaseSettings(config cfg.Config) (*TaskLeaseSettings, error) {
+	settings := &TaskLeaseSettings{}
+	if err := config.UnmarshalKey("tasks.lease", settings); err != nil {
+		return nil, fmt.Errorf("could not unmarshal task lease settings: %w", err)
+	}
+
+	if settings.HeartbeatInterval <= 0 {
+		return nil, fmt.Errorf("tasks.lease.heartbeat_interval must be positive")
+	}
+
+	if settings.Duration <= settings.HeartbeatInterval {
+		return nil, fmt.Errorf("tasks.lease.duration must be longer than tasks.lease.heartbeat_interval")
+	}
+
+	if settings.ReaperInterval <= 0 {
+		return nil, fmt.Errorf("tasks.lease.reaper_interval must be positive")
+	}
+
+	switch settings.ExpiredAction {
+	case taskLeaseExpiredActionRequeue, taskLeaseExpiredActionFail:
+	default:
+		return nil, fmt.Errorf("invalid tasks.lease.expired_action %q, expected %s or %s", settings.ExpiredAction, taskLeaseExpiredActionRequeue, taskLeaseExpiredActionFail)
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	tablePropertyMaxSnapshotAgeMs    = "history.expire.max-snapshot-age-ms"
+	tablePropertyMinSnapshotsToKeep  = "history.expire.min-snapshots-to-keep"
+	tablePropertyTargetFileSizeBytes = "write.target-file-size-bytes"
+)
+
+const (
+	taskParameterSourceRequest       = "request"
+	taskParameterSourceProfile       = "profile"
+	taskParameterSourceTableProperty = "table_property"
+	taskParameterSourceDefault       = "default"
+)
+
+const (
+	millisecondsPerDay = int64(24 * 60 * 60 * 1000)
+	bytesPerMb         = int64(1024 * 1024)
+)
+
+// resolveTaskParameter picks the value of a task parameter in the order request, maintenance profile,
+// table property and default. Requested values below 1 count as not set. The second return value names
+// the source of the value and is recorded in the task input.
+func resolveTaskParameter(requested int, profile *int, property *int, fallback int) (int, string) {
+	switch {
+	case requested > 0:
+		return requested, taskParameterSourceRequest
+	case profile != nil:
+		return *profile, taskParameterSourceProfile
+	case property != nil:
+		return *property, taskParameterSourceTableProperty
+	default:
+		return fallback, taskParameterSourceDefault
+	}
+}
+
+// requestedTaskParameter drops a requested value if the maintenance profile or the table property sets the
+// parameter and preferProfile is set, see TaskEnqueueOptions.PreferProfile.
+func requestedTaskParameter(requested int, preferProfile bool, profile *int, property *int) int {
+	if preferProfile && (profile != nil || property != nil) {
+		return 0
+	}
+
+	return requested
+}
+
+// tablePropertyRetentionDays converts history.expire.max-snapshot-age-ms to whole days, rounding up so
+// expire_snapshots never removes snapshots the table property still wants to keep.
+func tablePropertyRetentionDays(properties map[string]string) *int {
+	ageMs, ok := parsePositiveTableProperty(properties, tablePropertyMaxSnapshotAgeMs)
+	if !ok {
+		return nil
+	}
+
+	days := int((ageMs + millisecondsPerDay - 1) / millisecondsPerDay)
+
+	return &days
+}
+
+// tablePropertyTargetFileSizeMb converts write.target-file-size-bytes to megabytes, rounding up.
+func tablePropertyTargetFileSizeMb(properties map[string]string) *int {
+	sizeBytes, ok := parsePositiveTableProperty(properties, tablePropertyTargetFileSizeBytes)
+	if !ok {
+		return nil
+	}
+
+	sizeMb := int((sizeBytes + bytesPerMb - 1) / bytesPerMb)
+
+	return &sizeMb
+}
+
+// tablePropertyRetainLast returns history.expire.min-snapshots-to-keep if the table sets it.
+func tablePropertyRetainLast(properties map[string]string) *int {
+	count, ok := parsePositiveTableProperty(properties, tablePropertyMinSnapshotsToKeep)
+	if !ok {
+		return nil
+	}
+
+	result := int(count)
+
+	return &result
+}
+
+// parsePositiveTableProperty reads a numeric table property. Missing, malformed and non positive values
+// are ignored, as iceberg falls back to its own defaults for them as well.
+func parsePositiveTableProperty(properties map[string]string, key string) (int64, bool) {
+	value, ok := properties[key]
+	if !ok {
+		return 0, false
+	}
+
+	number, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || number < 1 {
+		return 0, false
+	}
+
+	return number, true
+}
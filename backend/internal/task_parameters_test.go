@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveTaskParameterPrecedence(t *testing.T) {
+	profile, property := 14, 30
+
+	value, source := resolveTaskParameter(10, &profile, &property, 7)
+	require.Equal(t, 10, value)
+	require.Equal(t, taskParameterSourceRequest, source)
+
+	value, source = resolveTaskParameter(0, &profile, &property, 7)
+	require.Equal(t, 14, value)
+	require.Equal(t, taskParameterSourceProfile, source)
+
+	value, source = resolveTaskParameter(0, nil, &property, 7)
+	require.Equal(t, 30, value)
+	require.Equal(t, taskParameterSourceTableProperty, source)
+
+	value, source = resolveTaskParameter(-1, nil, nil, 7)
+	require.Equal(t, 7, value)
+	require.Equal(t, taskParameterSourceDefault, source)
+}
+
+func TestRequestedTaskParameter(t *testing.T) {
+	profile, property := 14, 30
+
+	require.Equal(t, 10, requestedTaskParameter(10, false, &profile, &property))
+	require.Equal(t, 10, requestedTaskParameter(10, true, nil, nil))
+	require.Equal(t, 0, requestedTaskParameter(10, true, &profile, nil))
+	require.Equal(t, 0, requestedTaskParameter(10, true, nil, &property))
+}
+
+func TestTablePropertyConversions(t *testing.T) {
+	properties := map[string]string{
+		tablePropertyMaxSnapshotAgeMs:    "432000001",
+		tablePropertyTargetFileSizeBytes: "536870912",
+	}
+
+	require.Equal(t, 6, *tablePropertyRetentionDays(properties))
+	require.Equal(t, 512, *tablePropertyTargetFileSizeMb(properties))
+
+	require.Nil(t, tablePropertyRetentionDays(map[string]string{tablePropertyMaxSnapshotAgeMs: "forever"}))
+	require.Nil(t, tablePropertyTargetFileSizeMb(map[string]string{tablePropertyTargetFileSizeBytes: "0"}))
+	require.Nil(t, tablePropertyTargetFileSizeMb(nil))
+}
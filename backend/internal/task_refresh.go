@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gosoline-project/sqlc"
+	"github.com/justtrackio/gosoline/pkg/appctx"
+	"github.com/justtrackio/gosoline/pkg/log"
+)
+
+// taskRefreshQueueSize bounds how many completed tasks can wait for the refresh of their table.
+const taskRefreshQueueSize = 256
+
+// TaskRefreshQueue hands successfully completed tasks from CompleteTask to the background worker
+// refreshing their tables, see RunTableRefreshes. CompleteTask runs inside the spark informer among others,
+// where a refresh listing the partitions of a whole table would hold back the events of every other
+// application.
+type TaskRefreshQueue struct {
+	logger log.Logger
+	tasks  chan Task
+}
+
+type taskRefreshQueueCtxKey struct{}
+
+func ProvideTaskRefreshQueue(ctx context.Context, logger log.Logger) (*TaskRefreshQueue, error) {
+	return appctx.Provide(ctx, taskRefreshQueueCtxKey{}, func() (*TaskRefreshQueue, error) {
+		return newTaskRefreshQueue(logger, taskRefreshQueueSize), nil
+	})
+}
+
+func newTaskRefreshQueue(logger log.Logger, size int) *TaskRefreshQueue {
+	return &TaskRefreshQueue{
+		logger: logger.WithChannel("task_refresh"),
+		tasks:  make(chan Task, size),
+	}
+}
+
+// Enqueue schedules the refresh of the table of a completed task without blocking. The refresh is best
+// effort, so it is dropped if the queue is full.
+func (q *TaskRefreshQueue) Enqueue(ctx context.Context, task *Task) bool {
+	select {
+	case q.tasks <- *task:
+		return true
+	default:
+		q.logger.Warn(ctx, "dropping the refresh of table %s.%s after task %d as the refresh queue is full", task.Database, task.Table, task.Id)
+
+		return false
+	}
+}
+
+// RunTableRefreshes refreshes the tables of the tasks enqueued on the refresh queue until ctx is done and
+// stores the refresh results on the tasks, see refreshTaskTable.
+func (s *ServiceTaskQueue) RunTableRefreshes(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case task := <-s.refreshes.tasks:
+			refreshResult := s.refreshTaskTable(ctx, &task)
+			if len(refreshResult) == 0 {
+				continue
+			}
+
+			if err := s.UpdateTaskResult(ctx, task.CurrentClaim(), refreshResult); err != nil {
+				s.logger.Warn(ctx, "could not store the refresh result of task %d: %s", task.Id, err)
+			}
+		}
+	}
+}
+
+// refreshTaskTable brings the stored description, snapshots and partitions of the table a successful task
+// maintained up to date, so the browse views do not show stale state until the next scheduled refresh.
+// The refresh is best effort: the task already succeeded, so failures are only logged. For
+// expire_snapshots, the returned result reports how many of the stored snapshots the task expired.
+func (s *ServiceTaskQueue) refreshTaskTable(ctx context.Context, task *Task) map[string]any {
+	var err error
+	var storedSnapshotIDs []int64
+	var snapshots []Snapshot
+
+	if s.refresher == nil || task.Database == "" || task.Table == "" || !taskKindChangesTable(TaskKind(task.Kind)) {
+		return nil
+	}
+
+	if TaskKind(task.Kind) == TaskKindExpireSnapshots {
+		if storedSnapshotIDs, err = s.storedSnapshotIDs(ctx, task.Database, task.Table); err != nil {
+			s.logger.Warn(ctx, "could not load stored snapshots of table %s.%s before refreshing them: %s", task.Database, task.Table, err)
+		}
+	}
+
+	err = s.sqlClient.WithTx(ctx, func(cttx sqlc.Tx) error {
+		snapshots, err = refreshTaskTableInTx(cttx, s.refresher, task)
+
+		return err
+	})
+	if err != nil {
+		s.logger.Warn(ctx, "could not refresh table %s.%s after task %d: %s", task.Database, task.Table, task.Id, err)
+
+		return nil
+	}
+
+	if storedSnapshotIDs == nil {
+		return nil
+	}
+
+	return map[string]any{
+		"expired_snapshot_count": countExpiredSnapshots(storedSnapshotIDs, snapshots),
+		"snapshot_count":         len(snapshots),
+	}
+}
+
+func (s *ServiceTaskQueue) storedSnapshotIDs(ctx context.Context, database string, table string) ([]int64, error) {
+	var snapshots []Snapshot
+
+	sel := s.sqlClient.Q().From("snapshots").Where(sqlc.Eq{"database": database, "table": table})
+	if err := sel.Select(ctx, &snapshots); err != nil {
+		return nil, fmt.Errorf("could not select snapshots: %w", err)
+	}
+
+	ids := make([]int64, len(snapshots))
+	for i, snapshot := range snapshots {
+		ids[i] = snapshot.SnapshotId
+	}
+
+	return ids, nil
+}
+
+// refreshTaskTableInTx refreshes the stored state of the table of a task and returns the refreshed snapshots.
+// Partitions are only refreshed within the scope of the task if it has one, see partitionRefreshScopeFromTask.
+func refreshTaskTableInTx(cttx sqlc.Tx, refresher TableRefresher, task *Task) ([]Snapshot, error) {
+	if !taskKindChangesTable(TaskKind(task.Kind)) {
+		return nil, nil
+	}
+
+	desc, err := refresher.RefreshTable(cttx, task.Database, task.Table)
+	if err != nil {
+		return nil, fmt.Errorf("could not refresh table description: %w", err)
+	}
+
+	snapshots, err := refresher.RefreshSnapshots(cttx, task.Database, task.Table)
+	if err != nil {
+		return nil, fmt.Errorf("could not refresh snapshots: %w", err)
+	}
+
+	if !taskKindTouchesPartitions(TaskKind(task.Kind)) {
+		return snapshots, nil
+	}
+
+	if scope, ok := partitionRefreshScopeFromTask(task, desc.Partitions.Get()); ok {
+		if _, err = refresher.RefreshPartitionsInScope(cttx, task.Database, task.Table, scope); err != nil {
+			return nil, fmt.Errorf("could not refresh partitions in scope: %w", err)
+		}
+
+		return snapshots, nil
+	}
+
+	if _, err = refresher.RefreshPartitions(cttx, task.Database, task.Table); err != nil {
+		return nil, fmt.Errorf("could not refresh partitions: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// taskKindChangesTable reports whether a task kind commits to the table at all. A file integrity check
+// only reads the table, so there is nothing to refresh after it.
+func taskKindChangesTable(kind TaskKind) bool {
+	return kind != TaskKindCheckFileIntegrity
+}
+
+// taskKindTouchesPartitions reports whether a task kind changes the data or delete files of a table and
+// with it the stored partition statistics. Without a scope of the task, the partitions are listed from the
+// current snapshot as a whole and all partitions of the table are replaced, which covers the ones the task
+// touched. Unknown kinds are assumed to touch partitions.
+func taskKindTouchesPartitions(kind TaskKind) bool {
+	switch kind {
+	case TaskKindExpireSnapshots, TaskKindRemoveOrphanFiles, TaskKindRewriteManifests, TaskKindCheckFileIntegrity:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	iceberg "github.com/apache/iceberg-go"
+	"github.com/gosoline-project/sqlc"
+	"github.com/justtrackio/gosoline/pkg/db"
+	logMocks "github.com/justtrackio/gosoline/pkg/log/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingTableRefresher struct {
+	calls      []string
+	partitions []TablePartition
+	scope      partitionRefreshScope
+	err        error
+}
+
+func (r *recordingTableRefresher) RefreshTable(_ sqlc.Tx, database string, table string) (*TableDescription, error) {
+	r.calls = append(r.calls, "table "+database+"."+table)
+
+	return &TableDescription{Partitions: db.NewJSON(r.partitions, db.NonNullable{})}, r.err
+}
+
+func (r *recordingTableRefresher) RefreshSnapshots(_ sqlc.Tx, database string, table string) ([]Snapshot, error) {
+	r.calls = append(r.calls, "snapshots "+database+"."+table)
+
+	return nil, nil
+}
+
+func (r *recordingTableRefresher) RefreshPartitions(_ sqlc.Tx, database string, table string) ([]Partition, error) {
+	r.calls = append(r.calls, "partitions "+database+"."+table)
+
+	return nil, nil
+}
+
+func (r *recordingTableRefresher) RefreshPartitionsInScope(_ sqlc.Tx, database string, table string, scope partitionRefreshScope) ([]Partition, error) {
+	r.calls = append(r.calls, "partitions in scope "+database+"."+table)
+	r.scope = scope
+
+	return nil, nil
+}
+
+func TestRefreshTaskTableInTx(t *testing.T) {
+	tests := []struct {
+		kind     TaskKind
+		expected []string
+	}{
+		{kind: TaskKindOptimize, expected: []string{"table db.events", "snapshots db.events", "partitions db.events"}},
+		{kind: TaskKindRewritePositionDeleteFiles, expected: []string{"table db.events", "snapshots db.events", "partitions db.events"}},
+		{kind: TaskKindExpireSnapshots, expected: []string{"table db.events", "snapshots db.events"}},
+		{kind: TaskKindRemoveOrphanFiles, expected: []string{"table db.events", "snapshots db.events"}},
+		{kind: TaskKindRewriteManifests, expected: []string{"table db.events", "snapshots db.events"}},
+		{kind: TaskKindCheckFileIntegrity, expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			refresher := &recordingTableRefresher{}
+
+			_, err := refreshTaskTableInTx(nil, refresher, &Task{Database: "db", Table: "events", Kind: string(tt.kind)})
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, refresher.calls)
+		})
+	}
+}
+
+func TestRefreshTaskTableInTxWithinTaskScope(t *testing.T) {
+	refresher := &recordingTableRefresher{partitions: []TablePartition{
+		{Name: "year", RawFieldName: "event_time_day", IsHidden: true, Hidden: TablePartitionHidden{Column: "event_time", Type: transformDay}},
+		{Name: "month", RawFieldName: "event_time_day", IsHidden: true, Hidden: TablePartitionHidden{Column: "event_time", Type: transformDay}},
+		{Name: "day", RawFieldName: "event_time_day", IsHidden: true, Hidden: TablePartitionHidden{Column: "event_time", Type: transformDay}},
+	}}
+
+	input := map[string]any{"from": "2026-03-02T00:00:00Z", "to": "2026-03-08T00:00:00Z"}
+	task := &Task{Database: "db", Table: "events", Kind: string(TaskKindOptimize), Input: db.NewJSON(input, db.NonNullable{})}
+
+	_, err := refreshTaskTableInTx(nil, refresher, task)
+	require.NoError(t, err)
+	require.Equal(t, []string{"table db.events", "snapshots db.events", "partitions in scope db.events"}, refresher.calls)
+
+	require.True(t, refresher.scope.contains(PartitionValues{"year": "2026", "month": "03", "day": "02"}))
+	require.True(t, refresher.scope.contains(PartitionValues{"year": "2026", "month": "03", "day": "08"}))
+	require.False(t, refresher.scope.contains(PartitionValues{"year": "2026", "month": "03", "day": "09"}))
+	require.False(t, refresher.scope.contains(PartitionValues{"year": "2026", "month": "03"}))
+}
+
+func TestPartitionRefreshScopeFromTask(t *testing.T) {
+	fields := []TablePartition{
+		{Name: "country", RawFieldName: "country", SourceType: "string"},
+		{Name: "platform", RawFieldName: "platform", SourceType: "string"},
+	}
+
+	task := func(kind TaskKind, input map[string]any) *Task {
+		return &Task{Kind: string(kind), Input: db.NewJSON(input, db.NonNullable{})}
+	}
+
+	scope, ok := partitionRefreshScopeFromTask(task(TaskKindOptimize, map[string]any{"partition": map[string]any{"country": "de", "platform": nil}}), fields)
+	require.True(t, ok)
+	require.True(t, scope.contains(PartitionValues{"country": "de", "platform": nil}))
+	require.False(t, scope.contains(PartitionValues{"country": "de", "platform": "ios"}))
+	require.False(t, scope.contains(PartitionValues{"country": "fr", "platform": nil}))
+	require.True(t, iceberg.NewAnd(iceberg.EqualTo(iceberg.Reference("country"), "de"), iceberg.IsNull(iceberg.Reference("platform"))).Equals(scope.rowFilter()))
+
+	_, ok = partitionRefreshScopeFromTask(task(TaskKindOptimize, map[string]any{"partition": map[string]any{}}), fields)
+	require.False(t, ok)
+
+	_, ok = partitionRefreshScopeFromTask(task(TaskKindOptimize, map[string]any{"from": "2026-03-02T00:00:00Z", "to": "2026-03-08T00:00:00Z"}), fields)
+	require.False(t, ok)
+
+	_, ok = partitionRefreshScopeFromTask(task(TaskKindRewritePositionDeleteFiles, map[string]any{}), fields)
+	require.False(t, ok)
+}
+
+func TestRefreshTaskTableInTxStopsOnError(t *testing.T) {
+	refresher := &recordingTableRefresher{err: errors.New("table not found")}
+
+	_, err := refreshTaskTableInTx(nil, refresher, &Task{Database: "db", Table: "events", Kind: string(TaskKindOptimize)})
+	require.ErrorContains(t, err, "could not refresh table description")
+	require.Equal(t, []string{"table db.events"}, refresher.calls)
+}
+
+func TestTaskRefreshQueueDropsRefreshesWhenFull(t *testing.T) {
+	queue := newTaskRefreshQueue(logMocks.NewLoggerMock(logMocks.WithMockAll), 1)
+
+	require.True(t, queue.Enqueue(context.Background(), &Task{Id: 1, Database: "db", Table: "events"}))
+	require.False(t, queue.Enqueue(context.Background(), &Task{Id: 2, Database: "db", Table: "events"}))
+
+	task := <-queue.tasks
+	require.Equal(t, int64(1), task.Id)
+}
@@ -5,6 +5,9 @@ func expireSnapshotsResultMap(res *ExpireSnapshotsResult) map[string]any {
 		"database":               res.Database,
 		"table":                  res.Table,
 		"retention_days":         res.RetentionDays,
+		"older_than":             res.OlderThan,
+		"retain_last":            res.RetainLast,
+		"retention_threshold":    res.RetentionThreshold,
 		"clean_expired_metadata": res.CleanExpiredMetadata,
 		"status":                 res.Status,
 	}
@@ -20,12 +23,54 @@ func removeOrphanFilesResultMap(res *RemoveOrphanFilesResult) map[string]any {
 	}
 }
 
+func rewriteManifestsResultMap(res *RewriteManifestsResult) map[string]any {
+	return map[string]any{
+		"database":              res.Database,
+		"table":                 res.Table,
+		"manifest_count_before": res.ManifestCountBefore,
+		"manifest_count_after":  res.ManifestCountAfter,
+		"status":                res.Status,
+	}
+}
+
+func fileIntegrityReportResultMap(res *FileIntegrityReport) map[string]any {
+	return map[string]any{
+		"database":             res.Database,
+		"table":                res.Table,
+		"snapshot_count":       res.SnapshotCount,
+		"object_counts":        res.ObjectCounts,
+		"checked_object_count": res.CheckedObjectCount,
+		"missing_object_count": res.MissingObjectCount,
+		"size_mismatch_count":  res.SizeMismatchCount,
+		"missing_objects":      res.MissingObjects,
+		"size_mismatches":      res.SizeMismatches,
+		"status":               res.Status,
+	}
+}
+
+func trinoOptimizeResultMap(res *TrinoOptimizeResult) map[string]any {
+	return map[string]any{
+		"database":                  res.Database,
+		"table":                     res.Table,
+		"target_file_size_mb":       res.TargetFileSizeMb,
+		"where":                     res.Where,
+		"strategy":                  res.Strategy,
+		"data_file_count_before":    res.DataFileCountBefore,
+		"data_file_count_after":     res.DataFileCountAfter,
+		"rewritten_data_file_count": res.RewrittenDataFileCount,
+		"added_data_file_count":     res.AddedDataFileCount,
+		"status":                    res.Status,
+	}
+}
+
 func optimizeResultMap(res *OptimizeResult) map[string]any {
 	return map[string]any{
 		"database":            res.Database,
 		"table":               res.Table,
 		"target_file_size_mb": res.TargetFileSizeMb,
 		"where":               res.Where,
+		"strategy":            res.Strategy,
+		"sort_order":          res.SortOrder,
 		"tracking_id":         res.ApplicationName,
 		"application_name":    res.ApplicationName,
 		"status":              res.Status,
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/justtrackio/gosoline/pkg/cfg"
+)
+
+type TaskRetryPolicySettings struct {
+	MaxAttempts     int           `cfg:"max_attempts" default:"1"`
+	Backoff         time.Duration `cfg:"backoff" default:"1m"`
+	MaxBackoff      time.Duration `cfg:"max_backoff" default:"30m"`
+	RetryableErrors []string      `cfg:"retryable_errors"`
+}
+
+// TaskRetryPolicy decides whether a failed task is retried automatically and when the retry may run.
+type TaskRetryPolicy struct {
+	maxAttempts     int
+	backoff         time.Duration
+	maxBackoff      time.Duration
+	retryableErrors []*regexp.Regexp
+}
+
+// ReadTaskRetryPolicies reads the retry policy of every task kind from tasks.retry.<kind>.
+func ReadTaskRetryPolicies(config cfg.Config) (map[TaskKind]*TaskRetryPolicy, error) {
+	policies := make(map[TaskKind]*TaskRetryPolicy, len(taskKinds))
+
+	for _, kind := range taskKinds {
+		settings := TaskRetryPolicySettings{}
+		if err := config.UnmarshalKey(fmt.Sprintf("tasks.retry.%s", kind), &settings); err != nil {
+			return nil, fmt.Errorf("could not unmarshal retry settings of task kind %s: %w", kind, err)
+		}
+
+		policy, err := NewTaskRetryPolicy(settings)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry policy for task kind %s: %w", kind, err)
+		}
+
+		policies[kind] = policy
+	}
+
+	return policies, nil
+}
+
+func NewTaskRetryPolicy(settings TaskRetryPolicySettings) (*TaskRetryPolicy, error) {
+	if settings.MaxAttempts < 1 {
+		return nil, fmt.Errorf("max_attempts must be at least 1")
+	}
+
+	if settings.Backoff < 0 || settings.MaxBackoff < 0 {
+		return nil, fmt.Errorf("backoff and max_backoff must not be negative")
+	}
+
+	policy := &TaskRetryPolicy{
+		maxAttempts:     settings.MaxAttempts,
+		backoff:         settings.Backoff,
+		maxBackoff:      settings.MaxBackoff,
+		retryableErrors: make([]*regexp.Regexp, 0, len(settings.RetryableErrors)),
+	}
+
+	for _, pattern := range settings.RetryableErrors {
+		expr, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("could not compile retryable error pattern %q: %w", pattern, err)
+		}
+
+		policy.retryableErrors = append(policy.retryableErrors, expr)
+	}
+
+	return policy, nil
+}
+
+// ShouldRetry reports whether a task which failed in the given attempt with the given error message
+// gets another attempt. Without any configured patterns every error is considered retryable.
+func (p *TaskRetryPolicy) ShouldRetry(attempt int, errMessage string) bool {
+	if attempt >= p.maxAttempts {
+		return false
+	}
+
+	if len(p.retryableErrors) == 0 {
+		return true
+	}
+
+	for _, expr := range p.retryableErrors {
+		if expr.MatchString(errMessage) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Backoff returns how long to wait before running the attempt following the given one. The delay
+// doubles with every attempt and is capped at the configured maximum.
+func (p *TaskRetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.backoff
+
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+
+		if p.maxBackoff > 0 && delay >= p.maxBackoff {
+			return p.maxBackoff
+		}
+	}
+
+	if p.maxBackoff > 0 && delay > p.maxBackoff {
+		return p.maxBackoff
+	}
+
+	return delay
+}
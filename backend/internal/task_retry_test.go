@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskRetryPolicyBackoffDoublesUpToMaximum(t *testing.T) {
+	policy, err := NewTaskRetryPolicy(TaskRetryPolicySettings{
+		MaxAttempts: 5,
+		Backoff:     time.Minute,
+		MaxBackoff:  5 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, time.Minute, policy.Backoff(1))
+	require.Equal(t, 2*time.Minute, policy.Backoff(2))
+	require.Equal(t, 4*time.Minute, policy.Backoff(3))
+	require.Equal(t, 5*time.Minute, policy.Backoff(4))
+	require.Equal(t, 5*time.Minute, policy.Backoff(10))
+}
+
+func TestTaskRetryPolicyShouldRetryMatchesPatternsAndAttempts(t *testing.T) {
+	policy, err := NewTaskRetryPolicy(TaskRetryPolicySettings{
+		MaxAttempts:     3,
+		Backoff:         time.Minute,
+		RetryableErrors: []string{"(?i)query failed", "evicted"},
+	})
+	require.NoError(t, err)
+
+	require.True(t, policy.ShouldRetry(1, "trino: Query Failed (200 OK)"))
+	require.True(t, policy.ShouldRetry(2, "driver pod was evicted"))
+	require.False(t, policy.ShouldRetry(3, "driver pod was evicted"))
+	require.False(t, policy.ShouldRetry(1, "table does not exist"))
+}
+
+func TestTaskRetryPolicyWithoutPatternsRetriesEveryError(t *testing.T) {
+	policy, err := NewTaskRetryPolicy(TaskRetryPolicySettings{MaxAttempts: 2})
+	require.NoError(t, err)
+
+	require.True(t, policy.ShouldRetry(1, "anything"))
+	require.False(t, policy.ShouldRetry(2, "anything"))
+}
+
+func TestNewTaskRetryPolicyRejectsInvalidPattern(t *testing.T) {
+	_, err := NewTaskRetryPolicy(TaskRetryPolicySettings{MaxAttempts: 2, RetryableErrors: []string{"("}})
+	require.Error(t, err)
+}
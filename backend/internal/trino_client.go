@@ -41,6 +41,10 @@ func ProvideTrinoClient(ctx context.Context, config cfg.Config, logger log.Logge
 		checks := []exec.ErrorChecker{
 			exec.CheckConnectionError,
 			func(_ any, err error) exec.ErrorType {
+				if isTrinoQueryKilled(err) {
+					return exec.ErrorTypePermanent
+				}
+
 				if strings.Contains(err.Error(), "query failed") {
 					return exec.ErrorTypeRetryable
 				}
@@ -132,3 +136,50 @@ func (s *TrinoClient) Query(ctx context.Context, query string, args ...any) (*sq
 
 	return res.(*sqlx.Rows), nil
 }
+
+// TagQuery prefixes a query with a comment carrying the given tag and value, so the query can
+// later be found in system.runtime.queries by KillTaggedQueries.
+func TagQuery(tag string, value string, query string) string {
+	return fmt.Sprintf("/* %s:%s */ %s", tag, value, query)
+}
+
+// KillTaggedQueries kills all unfinished queries which have been tagged with TagQuery and returns
+// the number of killed queries.
+func (c *TrinoClient) KillTaggedQueries(ctx context.Context, tag string, value string, message string) (int, error) {
+	var err error
+	var rows []map[string]any
+
+	// the marker is assembled by trino, otherwise this lookup would match its own query text
+	lookup := fmt.Sprintf(
+		"SELECT query_id FROM system.runtime.queries WHERE state NOT IN ('FINISHED', 'FAILED') AND strpos(query, concat(%s, %s)) > 0",
+		quoteLiteral(tag+":"),
+		quoteLiteral(value+" */"),
+	)
+
+	if rows, err = c.QueryRows(ctx, lookup); err != nil {
+		return 0, fmt.Errorf("could not look up queries tagged with %s:%s: %w", tag, value, err)
+	}
+
+	killed := 0
+	for _, row := range rows {
+		queryID, ok := row["query_id"].(string)
+		if !ok || queryID == "" {
+			continue
+		}
+
+		kill := fmt.Sprintf("CALL system.runtime.kill_query(query_id => %s, message => %s)", quoteLiteral(queryID), quoteLiteral(message))
+		if err = c.Exec(ctx, kill); err != nil {
+			return killed, fmt.Errorf("could not kill query %s: %w", queryID, err)
+		}
+
+		killed++
+	}
+
+	return killed, nil
+}
+
+func isTrinoQueryKilled(err error) bool {
+	errMessage := strings.ToLower(err.Error())
+
+	return strings.Contains(errMessage, "administratively_killed") || strings.Contains(errMessage, "query killed") || strings.Contains(errMessage, "user_canceled")
+}
@@ -7,16 +7,28 @@ import (
 )
 
 const (
-	statusOK        = "ok"
-	statusError     = "error"
-	statusSubmitted = "submitted"
-
-	taskStatusQueued  = "queued"
-	taskStatusRunning = "running"
-	taskStatusSuccess = "success"
-	taskStatusError   = statusError
+	statusOK           = "ok"
+	statusError        = "error"
+	statusSubmitted    = "submitted"
+	statusDeduplicated = "deduplicated"
+
+	taskStatusQueued    = "queued"
+	taskStatusRunning   = "running"
+	taskStatusSuccess   = "success"
+	taskStatusError     = statusError
+	taskStatusCancelled = "cancelled"
+	taskStatusSkipped   = "skipped"
 )
 
+var taskStatuses = []string{
+	taskStatusQueued,
+	taskStatusRunning,
+	taskStatusSuccess,
+	taskStatusError,
+	taskStatusCancelled,
+	taskStatusSkipped,
+}
+
 type Snapshot struct {
 	Database     string                                  `json:"database" db:"database"`
 	Table        string                                  `json:"table" db:"table"`
@@ -28,37 +40,58 @@ type Snapshot struct {
 	Summary      db.JSON[map[string]any, db.NonNullable] `json:"summary" db:"summary"`
 }
 
+// SnapshotRef is a branch or tag of a table together with its retention settings. Unset settings fall back
+// to the table properties.
+type SnapshotRef struct {
+	Database           string `json:"database" db:"database"`
+	Table              string `json:"table" db:"table"`
+	Name               string `json:"name" db:"name"`
+	Type               string `json:"type" db:"type"`
+	SnapshotId         int64  `json:"snapshot_id,string" db:"snapshot_id"`
+	MinSnapshotsToKeep *int   `json:"min_snapshots_to_keep" db:"min_snapshots_to_keep"`
+	MaxSnapshotAgeMs   *int64 `json:"max_snapshot_age_ms" db:"max_snapshot_age_ms"`
+	MaxRefAgeMs        *int64 `json:"max_ref_age_ms" db:"max_ref_age_ms"`
+}
+
 type Partition struct {
-	Database                 string                                   `json:"database" db:"database"`
-	Table                    string                                   `json:"table" db:"table"`
-	Partition                db.JSON[PartitionValues, db.NonNullable] `json:"partition" db:"partition"`
-	SpecId                   int                                      `json:"spec_id" db:"spec_id"`
-	RecordCount              int64                                    `json:"record_count" db:"record_count"`
-	FileCount                int64                                    `json:"file_count" db:"file_count"`
-	TotalDataFileSizeInBytes int64                                    `json:"total_data_file_size_in_bytes" db:"total_data_file_size_in_bytes"`
-	LastUpdatedAt            time.Time                                `json:"last_updated_at" db:"last_updated_at"`
-	LastUpdatedSnapshotId    int64                                    `json:"last_updated_snapshot_id,string" db:"last_updated_snapshot_id"`
-	NeedsOptimize            bool                                     `json:"needs_optimize" db:"needs_optimize"`
+	Database                   string                                   `json:"database" db:"database"`
+	Table                      string                                   `json:"table" db:"table"`
+	Partition                  db.JSON[PartitionValues, db.NonNullable] `json:"partition" db:"partition"`
+	SpecId                     int                                      `json:"spec_id" db:"spec_id"`
+	RecordCount                int64                                    `json:"record_count" db:"record_count"`
+	FileCount                  int64                                    `json:"file_count" db:"file_count"`
+	TotalDataFileSizeInBytes   int64                                    `json:"total_data_file_size_in_bytes" db:"total_data_file_size_in_bytes"`
+	DeleteFileCount            int64                                    `json:"delete_file_count" db:"delete_file_count"`
+	TotalDeleteFileSizeInBytes int64                                    `json:"total_delete_file_size_in_bytes" db:"total_delete_file_size_in_bytes"`
+	LastUpdatedAt              time.Time                                `json:"last_updated_at" db:"last_updated_at"`
+	LastUpdatedSnapshotId      int64                                    `json:"last_updated_snapshot_id,string" db:"last_updated_snapshot_id"`
+	NeedsOptimize              bool                                     `json:"needs_optimize" db:"needs_optimize"`
+	NeedsCompaction            bool                                     `json:"needs_compaction" db:"needs_compaction"`
 }
 
 type sPartition struct {
-	Partition                map[string]any `json:"partition" db:"partition"`
-	SpecId                   int            `json:"spec_id" db:"spec_id"`
-	RecordCount              int64          `json:"record_count" db:"record_count"`
-	FileCount                int64          `json:"file_count" db:"file_count"`
-	TotalDataFileSizeInBytes int64          `json:"total_data_file_size_in_bytes" db:"total_data_file_size_in_bytes"`
-	LastUpdatedAt            time.Time      `json:"last_updated_at" db:"last_updated_at"`
-	LastUpdatedSnapshotId    int64          `json:"last_updated_snapshot_id" db:"last_updated_snapshot_id"`
-	NeedsOptimize            bool           `json:"needs_optimize" db:"needs_optimize"`
+	Partition                  map[string]any `json:"partition" db:"partition"`
+	SpecId                     int            `json:"spec_id" db:"spec_id"`
+	RecordCount                int64          `json:"record_count" db:"record_count"`
+	FileCount                  int64          `json:"file_count" db:"file_count"`
+	TotalDataFileSizeInBytes   int64          `json:"total_data_file_size_in_bytes" db:"total_data_file_size_in_bytes"`
+	DeleteFileCount            int64          `json:"delete_file_count" db:"delete_file_count"`
+	TotalDeleteFileSizeInBytes int64          `json:"total_delete_file_size_in_bytes" db:"total_delete_file_size_in_bytes"`
+	LastUpdatedAt              time.Time      `json:"last_updated_at" db:"last_updated_at"`
+	LastUpdatedSnapshotId      int64          `json:"last_updated_snapshot_id" db:"last_updated_snapshot_id"`
+	NeedsOptimize              bool           `json:"needs_optimize" db:"needs_optimize"`
+	NeedsCompaction            bool           `json:"needs_compaction" db:"needs_compaction"`
 }
 
 type TableDescription struct {
-	Database          string                                    `json:"database" db:"database"`
-	Name              string                                    `json:"name" db:"name"`
-	Columns           db.JSON[TableColumns, db.NonNullable]     `json:"columns" db:"columns"`
-	Partitions        db.JSON[[]TablePartition, db.NonNullable] `json:"partitions" db:"partitions"`
-	CurrentSnapshotID *int64                                    `json:"current_snapshot_id,string,omitempty" db:"current_snapshot_id"`
-	UpdatedAt         time.Time                                 `json:"updated_at" db:"updated_at"`
+	Database          string                                     `json:"database" db:"database"`
+	Name              string                                     `json:"name" db:"name"`
+	Columns           db.JSON[TableColumns, db.NonNullable]      `json:"columns" db:"columns"`
+	Partitions        db.JSON[[]TablePartition, db.NonNullable]  `json:"partitions" db:"partitions"`
+	SortOrder         db.JSON[[]TableSortField, db.NonNullable]  `json:"sort_order" db:"sort_order"`
+	Properties        db.JSON[map[string]string, db.NonNullable] `json:"properties" db:"properties"`
+	CurrentSnapshotID *int64                                     `json:"current_snapshot_id,string,omitempty" db:"current_snapshot_id"`
+	UpdatedAt         time.Time                                  `json:"updated_at" db:"updated_at"`
 }
 
 type TableColumns []TableColumn
@@ -73,6 +106,8 @@ type TablePartition struct {
 	RawFieldName string               `json:"raw_field_name" db:"raw_field_name"`
 	IsHidden     bool                 `json:"is_hidden" db:"is_hidden"`
 	Hidden       TablePartitionHidden `json:"hidden" db:"hidden"`
+	// SourceType is the iceberg type of the source column of an identity partition, e.g. date.
+	SourceType string `json:"source_type,omitempty" db:"source_type"`
 }
 
 type TablePartitionHidden struct {
@@ -80,6 +115,13 @@ type TablePartitionHidden struct {
 	Type   string `json:"type" db:"type"`
 }
 
+type TableSortField struct {
+	Column    string `json:"column" db:"column"`
+	Transform string `json:"transform" db:"transform"`
+	Direction string `json:"direction" db:"direction"`
+	NullOrder string `json:"null_order" db:"null_order"`
+}
+
 type TableSummary struct {
 	Database                 string           `json:"database" db:"database"`
 	Name                     string           `json:"name" db:"name"`
@@ -90,41 +132,68 @@ type TableSummary struct {
 	FileCount                int64            `json:"file_count" db:"file_count"`
 	RecordCount              int64            `json:"record_count" db:"record_count"`
 	TotalDataFileSizeInBytes int64            `json:"total_data_file_size_in_bytes" db:"total_data_file_size_in_bytes"`
+	DeleteFileCount          int64            `json:"delete_file_count" db:"delete_file_count"`
 	NeedsOptimize            bool             `json:"needs_optimize" db:"needs_optimize"`
+	NeedsCompaction          bool             `json:"needs_compaction" db:"needs_compaction"`
 	UpdatedAt                time.Time        `json:"updated_at" db:"updated_at"`
 }
 
 type Task struct {
-	Id           int64                                   `json:"id" db:"id"`
-	Database     string                                  `json:"database" db:"database"`
-	Table        string                                  `json:"table" db:"table"`
-	Kind         string                                  `json:"kind" db:"kind"`
-	Engine       string                                  `json:"engine" db:"engine"`
-	StartedAt    time.Time                               `json:"started_at" db:"started_at"`
-	PickedUpAt   *time.Time                              `json:"picked_up_at" db:"picked_up_at"`
-	FinishedAt   *time.Time                              `json:"finished_at" db:"finished_at"`
-	Status       string                                  `json:"status" db:"status"`
-	Retried      bool                                    `json:"retried" db:"retried"`
-	ErrorMessage *string                                 `json:"error_message" db:"error_message"`
-	Input        db.JSON[map[string]any, db.NonNullable] `json:"input" db:"input"`
-	Result       db.JSON[map[string]any, db.NonNullable] `json:"result" db:"result"`
+	Id             int64                                   `json:"id" db:"id"`
+	ParentTaskId   *int64                                  `json:"parent_task_id" db:"parent_task_id"`
+	Database       string                                  `json:"database" db:"database"`
+	Table          string                                  `json:"table" db:"table"`
+	Kind           string                                  `json:"kind" db:"kind"`
+	Engine         string                                  `json:"engine" db:"engine"`
+	StartedAt      time.Time                               `json:"started_at" db:"started_at"`
+	NotBefore      time.Time                               `json:"not_before" db:"not_before"`
+	PickedUpAt     *time.Time                              `json:"picked_up_at" db:"picked_up_at"`
+	HeartbeatAt    *time.Time                              `json:"heartbeat_at" db:"heartbeat_at"`
+	LeaseExpiresAt *time.Time                              `json:"lease_expires_at" db:"lease_expires_at"`
+	FinishedAt     *time.Time                              `json:"finished_at" db:"finished_at"`
+	Status         string                                  `json:"status" db:"status"`
+	Priority       int                                     `json:"priority" db:"priority"`
+	Retried        bool                                    `json:"retried" db:"retried"`
+	Attempt        int                                     `json:"attempt" db:"attempt"`
+	Claim          int                                     `json:"claim" db:"claim"`
+	ErrorMessage   *string                                 `json:"error_message" db:"error_message"`
+	Input          db.JSON[map[string]any, db.NonNullable] `json:"input" db:"input"`
+	Result         db.JSON[map[string]any, db.NonNullable] `json:"result" db:"result"`
+}
+
+// CurrentClaim returns the claim the task was loaded with.
+func (t *Task) CurrentClaim() TaskClaim {
+	return TaskClaim{TaskId: t.Id, Claim: t.Claim}
 }
 
 type sTask struct {
-	Id           int64          `json:"id" db:"id"`
-	Database     string         `json:"database" db:"database"`
-	Table        string         `json:"table" db:"table"`
-	Kind         string         `json:"kind" db:"kind"`
-	Engine       string         `json:"engine" db:"engine"`
-	StartedAt    time.Time      `json:"started_at" db:"started_at"`
-	PickedUpAt   *time.Time     `json:"picked_up_at" db:"picked_up_at"`
-	FinishedAt   *time.Time     `json:"finished_at" db:"finished_at"`
-	Status       string         `json:"status" db:"status"`
-	Retried      bool           `json:"retried" db:"retried"`
-	CanRetry     bool           `json:"can_retry"`
-	ErrorMessage *string        `json:"error_message" db:"error_message"`
-	Input        map[string]any `json:"input" db:"input"`
-	Result       map[string]any `json:"result" db:"result"`
+	Id             int64          `json:"id" db:"id"`
+	ParentTaskId   *int64         `json:"parent_task_id" db:"parent_task_id"`
+	Database       string         `json:"database" db:"database"`
+	Table          string         `json:"table" db:"table"`
+	Kind           string         `json:"kind" db:"kind"`
+	Engine         string         `json:"engine" db:"engine"`
+	StartedAt      time.Time      `json:"started_at" db:"started_at"`
+	NotBefore      time.Time      `json:"not_before" db:"not_before"`
+	PickedUpAt     *time.Time     `json:"picked_up_at" db:"picked_up_at"`
+	HeartbeatAt    *time.Time     `json:"heartbeat_at" db:"heartbeat_at"`
+	LeaseExpiresAt *time.Time     `json:"lease_expires_at" db:"lease_expires_at"`
+	FinishedAt     *time.Time     `json:"finished_at" db:"finished_at"`
+	Status         string         `json:"status" db:"status"`
+	Priority       int            `json:"priority" db:"priority"`
+	Retried        bool           `json:"retried" db:"retried"`
+	Attempt        int            `json:"attempt" db:"attempt"`
+	Claim          int            `json:"claim" db:"claim"`
+	DependsOn      []int64        `json:"depends_on"`
+	CanRetry       bool           `json:"can_retry"`
+	ErrorMessage   *string        `json:"error_message" db:"error_message"`
+	Input          map[string]any `json:"input" db:"input"`
+	Result         map[string]any `json:"result" db:"result"`
+}
+
+type EnqueuedTask struct {
+	TaskId       int64 `json:"task_id"`
+	Deduplicated bool  `json:"deduplicated"`
 }
 
 type PaginatedTasks struct {
@@ -19,15 +19,104 @@ type IcebergSnapshot struct {
 	Summary      map[string]any `json:"summary"`
 }
 
+const (
+	snapshotFileContentData            = "data"
+	snapshotFileContentPositionDeletes = "position_deletes"
+	snapshotFileContentEqualityDeletes = "equality_deletes"
+	snapshotFileChangeAdded            = "added"
+	snapshotFileChangeRemoved          = "removed"
+	tableObjectContentMetadata         = "metadata"
+	tableObjectContentManifestList     = "manifest_list"
+	tableObjectContentManifest         = "manifest"
+)
+
+// IcebergTableObject is an object referenced by the metadata of a table. Content is one of the
+// tableObjectContent or snapshotFileContent consts. SizeBytes is only known for objects whose size is
+// recorded by the metadata referencing them, i.e. manifests and data and delete files.
+type IcebergTableObject struct {
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	SizeBytes *int64 `json:"size_bytes,omitempty"`
+}
+
+type IcebergTableObjects struct {
+	SnapshotCount int
+	Objects       []IcebergTableObject
+}
+
+// IcebergSnapshotState is the content of a single snapshot, compared by the snapshot diff.
+type IcebergSnapshotState struct {
+	SnapshotID     int64
+	SchemaID       int
+	Columns        map[string]string
+	PartitionSpecs map[int32]string
+	Files          []IcebergSnapshotFile
+}
+
+type IcebergSnapshotFile struct {
+	Path        string          `json:"path"`
+	Content     string          `json:"content"`
+	Partition   PartitionValues `json:"partition"`
+	SpecID      int32           `json:"spec_id"`
+	RecordCount int64           `json:"record_count"`
+	SizeBytes   int64           `json:"size_bytes"`
+}
+
+type SnapshotDiffFile struct {
+	IcebergSnapshotFile
+	Change string `json:"change"`
+}
+
+type SnapshotPartitionDelta struct {
+	Partition                PartitionValues `json:"partition"`
+	RecordCountDelta         int64           `json:"record_count_delta"`
+	DataFileCountDelta       int64           `json:"data_file_count_delta"`
+	DataFileSizeBytesDelta   int64           `json:"data_file_size_bytes_delta"`
+	DeleteFileCountDelta     int64           `json:"delete_file_count_delta"`
+	DeleteFileSizeBytesDelta int64           `json:"delete_file_size_bytes_delta"`
+}
+
+type SnapshotSchemaChange struct {
+	FromSchemaID   int      `json:"from_schema_id"`
+	ToSchemaID     int      `json:"to_schema_id"`
+	AddedColumns   []string `json:"added_columns"`
+	RemovedColumns []string `json:"removed_columns"`
+	ChangedColumns []string `json:"changed_columns"`
+}
+
+type SnapshotPartitionSpecChange struct {
+	FromSpecs map[int32]string `json:"from_specs"`
+	ToSpecs   map[int32]string `json:"to_specs"`
+}
+
+type SnapshotDiff struct {
+	FromSnapshotID         int64                        `json:"from_snapshot_id,string"`
+	ToSnapshotID           int64                        `json:"to_snapshot_id,string"`
+	AddedDataFileCount     int                          `json:"added_data_file_count"`
+	RemovedDataFileCount   int                          `json:"removed_data_file_count"`
+	AddedDeleteFileCount   int                          `json:"added_delete_file_count"`
+	RemovedDeleteFileCount int                          `json:"removed_delete_file_count"`
+	Partitions             []SnapshotPartitionDelta     `json:"partitions"`
+	SchemaChange           *SnapshotSchemaChange        `json:"schema_change"`
+	PartitionSpecChange    *SnapshotPartitionSpecChange `json:"partition_spec_change"`
+	Files                  []SnapshotDiffFile           `json:"files"`
+	TotalFiles             int                          `json:"total_files"`
+	Limit                  int                          `json:"limit"`
+	Offset                 int                          `json:"offset"`
+}
+
 type IcebergPartition struct {
-	Partition         PartitionValues `json:"partition"`
-	SpecID            int32           `json:"spec_id"`
-	RecordCount       int64           `json:"record_count"`
-	FileCount         int64           `json:"file_count"`
-	DataFileSizeBytes int64           `json:"data_file_size_bytes"`
-	NeedsOptimize     bool            `json:"needs_optimize"`
-	LastUpdatedAt     time.Time       `json:"last_updated_at"`
-	LastSnapshotID    int64           `json:"last_snapshot_id,string"`
+	Partition           PartitionValues `json:"partition"`
+	SpecID              int32           `json:"spec_id"`
+	RecordCount         int64           `json:"record_count"`
+	FileCount           int64           `json:"file_count"`
+	DataFileSizeBytes   int64           `json:"data_file_size_bytes"`
+	DeleteFileCount     int64           `json:"delete_file_count"`
+	DeleteFileSizeBytes int64           `json:"delete_file_size_bytes"`
+	NeedsOptimize       bool            `json:"needs_optimize"`
+	NeedsCompaction     bool            `json:"needs_compaction"`
+	LastUpdatedAt       time.Time       `json:"last_updated_at"`
+	LastSnapshotID      int64           `json:"last_snapshot_id,string"`
 }
 
 type IcebergPartitionStats struct {
@@ -36,6 +125,7 @@ type IcebergPartitionStats struct {
 	SpecID         int32
 	RecordCount    int64
 	Files          IcebergPartitionStatsFiles
+	DeleteFiles    IcebergPartitionStatsFiles
 	LastUpdatedAt  int64
 	LastSnapshotID int64
 }
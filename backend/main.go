@@ -34,6 +34,7 @@ func main() {
 		}),
 		application.WithModuleFactory("maintenance_schedule", internal.NewModuleMaintenanceSchedule),
 		application.WithModuleFactory("refresh", internal.NewModuleRefresh),
+		application.WithModuleFactory("task_history", internal.NewModuleTaskHistory),
 		application.WithModuleFactory("http", httpserver.NewServer("default", func(ctx context.Context, config cfg.Config, logger log.Logger, router *httpserver.Router) error {
 			router.Use(cors.Default())
 			router.UseFactory(httpserver.CreateEmbeddedStaticServe(publicFs, "public", "/api"))
@@ -41,19 +42,30 @@ func main() {
 			router.Group("/api/maintenance").HandleWith(httpserver.With(internal.NewHandlerMaintenance, func(r *httpserver.Router, handler *internal.HandlerMaintenance) {
 				r.POST("/:database/expire-snapshots", httpserver.Bind(handler.ExpireSnapshots))
 				r.POST("/:database/remove-orphan-files", httpserver.Bind(handler.RemoveOrphanFiles))
+				r.POST("/:database/rewrite-manifests", httpserver.Bind(handler.TableTask(internal.TaskKindRewriteManifests)))
+				r.POST("/:database/rewrite-position-delete-files", httpserver.Bind(handler.TableTask(internal.TaskKindRewritePositionDeleteFiles)))
+				r.POST("/:database/check-file-integrity", httpserver.Bind(handler.TableTask(internal.TaskKindCheckFileIntegrity)))
 				r.POST("/:database/optimize", httpserver.Bind(handler.Optimize))
 			}))
 
 			router.Group("/api/tasks").HandleWith(httpserver.With(internal.NewHandlerTasks, func(r *httpserver.Router, handler *internal.HandlerTasks) {
 				r.GET("", httpserver.Bind(handler.ListAllTasks))
 				r.GET("/counts", httpserver.BindN(handler.AllTaskCounts))
-				r.DELETE("", httpserver.BindN(handler.FlushAllTasks))
+				r.GET("/events", handler.Events)
+				r.GET("/chain/:id", httpserver.Bind(handler.TaskChain))
+				r.DELETE("", httpserver.Bind(handler.FlushAllTasks))
 				r.POST("/retry-all", httpserver.BindN(handler.RetryAllTasksGlobal))
 				r.POST("/callback/:id/result", httpserver.Bind(handler.ProcedureResultCallback))
 				r.POST("/:database/retry-all", httpserver.Bind(handler.RetryAllTasks))
 				r.POST("/retry/:id", httpserver.Bind(handler.RetryTask))
+				r.POST("/:database/cancel-all", httpserver.Bind(handler.CancelAllTasks))
+				r.POST("/cancel/:id", httpserver.Bind(handler.CancelTask))
+				r.PUT("/priority/:id", httpserver.Bind(handler.SetTaskPriority))
 				r.POST("/:database/:table/expire-snapshots", httpserver.Bind(handler.ExpireSnapshots))
 				r.POST("/:database/:table/remove-orphan-files", httpserver.Bind(handler.RemoveOrphanFiles))
+				r.POST("/:database/:table/rewrite-manifests", httpserver.Bind(handler.TableTask(internal.TaskKindRewriteManifests)))
+				r.POST("/:database/:table/rewrite-position-delete-files", httpserver.Bind(handler.TableTask(internal.TaskKindRewritePositionDeleteFiles)))
+				r.POST("/:database/:table/check-file-integrity", httpserver.Bind(handler.TableTask(internal.TaskKindCheckFileIntegrity)))
 				r.POST("/:database/:table/optimize", httpserver.Bind(handler.Optimize))
 				r.GET("/:database", httpserver.Bind(handler.ListTasks))
 				r.GET("/:database/counts", httpserver.Bind(handler.TaskCounts))
@@ -63,6 +75,19 @@ func main() {
 			router.Group("/api/settings").HandleWith(httpserver.With(internal.NewHandlerSettings, func(r *httpserver.Router, handler *internal.HandlerSettings) {
 				r.GET("/task-concurrency", httpserver.BindN(handler.GetTaskConcurrency))
 				r.PUT("/task-concurrency", httpserver.Bind(handler.SetTaskConcurrency))
+				r.GET("/task-concurrency-limits", httpserver.BindN(handler.GetTaskConcurrencyLimits))
+				r.PUT("/task-concurrency-limits", httpserver.Bind(handler.SetTaskConcurrencyLimits))
+				r.GET("/maintenance-profiles/:database", httpserver.Bind(handler.ListMaintenanceProfiles))
+				r.GET("/maintenance-profiles/:database/profile", httpserver.Bind(handler.GetMaintenanceProfile))
+				r.PUT("/maintenance-profiles/:database/profile", httpserver.Bind(handler.SetMaintenanceProfile))
+				r.DELETE("/maintenance-profiles/:database/profile", httpserver.Bind(handler.DeleteMaintenanceProfile))
+				r.GET("/maintenance-profiles/:database/tables/:table", httpserver.Bind(handler.GetMaintenanceProfile))
+				r.PUT("/maintenance-profiles/:database/tables/:table", httpserver.Bind(handler.SetMaintenanceProfile))
+				r.DELETE("/maintenance-profiles/:database/tables/:table", httpserver.Bind(handler.DeleteMaintenanceProfile))
+			}))
+
+			router.Group("/api/engines").HandleWith(httpserver.With(internal.NewHandlerEngines, func(r *httpserver.Router, handler *internal.HandlerEngines) {
+				r.GET("", httpserver.BindN(handler.ListEngines))
 			}))
 
 			router.Group("/api/metadata").HandleWith(httpserver.With(internal.NewHandlerMetadata, func(r *httpserver.Router, handler *internal.HandlerMetadata) {
@@ -90,9 +115,19 @@ func main() {
 				r.GET("/databases", httpserver.BindN(handler.ListDatabases))
 				r.GET("/:database/tables", httpserver.Bind(handler.ListTables))
 				r.GET("/:database/:table", httpserver.Bind(handler.DescribeTable))
+				r.POST("/:database/:table/snapshots/rollback-to-timestamp", httpserver.Bind(handler.RollbackToTimestamp))
 				r.POST("/:database/:table/snapshots/:snapshotId/rollback", httpserver.Bind(handler.RollbackToSnapshot))
+				r.POST("/:database/:table/snapshots/:snapshotId/set-current", httpserver.Bind(handler.SetCurrentSnapshot))
+				r.POST("/:database/:table/snapshots/:snapshotId/cherry-pick", httpserver.Bind(handler.CherryPickSnapshot))
 				r.GET("/:database/:table/snapshots/:snapshotId/missing-files", httpserver.Bind(handler.ListSnapshotMissingFiles))
+				r.GET("/:database/:table/snapshots/:snapshotId/diff/:to", httpserver.Bind(handler.DiffSnapshots))
 				r.GET("/:database/:table/snapshots", httpserver.Bind(handler.ListSnapshots))
+				r.GET("/:database/:table/refs", httpserver.Bind(handler.ListSnapshotRefs))
+				r.POST("/:database/:table/tags", httpserver.Bind(handler.CreateTag))
+				r.PUT("/:database/:table/tags/:name", httpserver.Bind(handler.ReplaceTag))
+				r.DELETE("/:database/:table/tags/:name", httpserver.Bind(handler.DropTag))
+				r.POST("/:database/:table/branches", httpserver.Bind(handler.CreateBranch))
+				r.POST("/:database/:table/branches/:name/fast-forward", httpserver.Bind(handler.FastForwardBranch))
 				r.GET("/:database/:table/partitions", httpserver.Bind(handler.ListPartitions))
 			}))
 